@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+// AddressBookConfig is the configuration for an address book.
+type AddressBookConfig struct {
+	// Hosts are the hostnames to track.
+	Hosts []string
+	// Network is the type of addresses to track, one of "ip", "ip4" or "ip6".
+	// Defaults to "ip".
+	Network string
+	// MinTTL is the minimum interval between lookups of a host, answers with
+	// shorter TTLs (or without one, eg. from the hosts file) are re-resolved
+	// after MinTTL. Defaults to 5 seconds.
+	MinTTL *time.Duration
+	// MaxTTL is the maximum interval between lookups of a host. Defaults to
+	// 5 minutes.
+	MaxTTL *time.Duration
+	// RetryInterval is how long to wait before retrying a failed lookup.
+	// Addresses are kept until a lookup succeeds (or the name is reported to
+	// no longer exist). Defaults to 5 seconds.
+	RetryInterval *time.Duration
+	// OnChange is an optional hook, called for each address added to or
+	// removed from a host. Events are delivered in order, from a single
+	// goroutine per host.
+	OnChange func(AddressEvent)
+	// Logger is an optional logger, failed lookups are logged at warn level.
+	Logger *slog.Logger
+}
+
+// AddressEventType is the type of an address book change.
+type AddressEventType string
+
+const (
+	// AddressAdded is reported when a host gains an address.
+	AddressAdded AddressEventType = "added"
+	// AddressRemoved is reported when a host loses an address.
+	AddressRemoved AddressEventType = "removed"
+)
+
+// AddressEvent is a change to the addresses of a host in an address book.
+type AddressEvent struct {
+	// Type is the type of change.
+	Type AddressEventType
+	// Host is the hostname, as configured.
+	Host string
+	// Addr is the address that was added or removed.
+	Addr netip.Addr
+}
+
+// AddressBook tracks the addresses of a set of hostnames, re-resolving each
+// host when the TTL of its answer expires and reporting the addresses that
+// were added or removed. It is intended as the foundation for client-side
+// load balancers that must react to DNS-based failover.
+type AddressBook struct {
+	resolver      Resolver
+	hosts         []string
+	network       string
+	minTTL        time.Duration
+	maxTTL        time.Duration
+	retryInterval time.Duration
+	onChange      func(AddressEvent)
+	logger        *slog.Logger
+	mu            sync.RWMutex
+	addrs         map[string][]netip.Addr
+}
+
+// NewAddressBook creates a new address book, that resolves hosts using
+// resolver. Hosts are not resolved until Run is called.
+func NewAddressBook(resolver Resolver, conf *AddressBookConfig) (*AddressBook, error) {
+	var hosts []string
+	if conf != nil {
+		hosts = slices.Clone(conf.Hosts)
+	}
+
+	conf, err := defaults.WithDefaults(conf, &AddressBookConfig{
+		Network:       "ip",
+		MinTTL:        ptr.To(5 * time.Second),
+		MaxTTL:        ptr.To(5 * time.Minute),
+		RetryInterval: ptr.To(5 * time.Second),
+		OnChange:      func(AddressEvent) {},
+		Logger:        discardLogger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to address book config: %w", err)
+	}
+
+	if conf.Network != "ip" && conf.Network != "ip4" && conf.Network != "ip6" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedNetwork, conf.Network)
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts configured")
+	}
+
+	slices.Sort(hosts)
+	hosts = slices.Compact(hosts)
+
+	for _, host := range hosts {
+		if _, ok := dns.IsDomainName(host); !ok {
+			return nil, fmt.Errorf("invalid hostname: %q", host)
+		}
+	}
+
+	return &AddressBook{
+		resolver:      resolver,
+		hosts:         hosts,
+		network:       conf.Network,
+		minTTL:        *conf.MinTTL,
+		maxTTL:        *conf.MaxTTL,
+		retryInterval: *conf.RetryInterval,
+		onChange:      conf.OnChange,
+		logger:        conf.Logger,
+		addrs:         make(map[string][]netip.Addr),
+	}, nil
+}
+
+// Addrs returns the current addresses of host, in the order returned by the
+// resolver.
+func (b *AddressBook) Addrs(host string) []netip.Addr {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return slices.Clone(b.addrs[host])
+}
+
+// Run resolves and tracks the configured hosts until ctx is canceled.
+func (b *AddressBook) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, host := range b.hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			b.track(ctx, host)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// track periodically resolves host, until ctx is canceled.
+func (b *AddressBook) track(ctx context.Context, host string) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		timer.Reset(b.resolve(ctx, host))
+	}
+}
+
+// resolve looks up host and updates its addresses, returning how long to
+// wait before the next lookup.
+func (b *AddressBook) resolve(ctx context.Context, host string) time.Duration {
+	recorder := &ttlRecorder{}
+	addrs, err := b.resolver.LookupNetIP(withTTLRecorder(ctx, recorder), b.network, host)
+	if err != nil && !IsNXDomain(err) && !IsNoData(err) {
+		if ctx.Err() == nil {
+			b.logger.Warn("Failed to resolve host", slog.String("host", host), slog.Any("error", err))
+		}
+		return b.retryInterval
+	}
+
+	b.update(host, addrs)
+
+	return min(max(recorder.ttl(), b.minTTL), b.maxTTL)
+}
+
+// update replaces the addresses of host, reporting any that were added
+// (before any that were removed, so that consumers always have an address
+// to use during a failover).
+func (b *AddressBook) update(host string, addrs []netip.Addr) {
+	addrs = dedupAddrs(addrs)
+
+	b.mu.Lock()
+	previous := b.addrs[host]
+	if len(addrs) == 0 {
+		delete(b.addrs, host)
+	} else {
+		b.addrs[host] = addrs
+	}
+	b.mu.Unlock()
+
+	for _, addr := range addrs {
+		if !slices.Contains(previous, addr) {
+			b.onChange(AddressEvent{Type: AddressAdded, Host: host, Addr: addr})
+		}
+	}
+
+	for _, addr := range previous {
+		if !slices.Contains(addrs, addr) {
+			b.onChange(AddressEvent{Type: AddressRemoved, Host: host, Addr: addr})
+		}
+	}
+}
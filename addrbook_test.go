@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressBook(t *testing.T) {
+	a := netip.MustParseAddr("10.0.0.1")
+	b := netip.MustParseAddr("10.0.0.2")
+	c := netip.MustParseAddr("10.0.0.3")
+
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "host.example").
+		Return([]netip.Addr{a, b}, nil).Once()
+	inner.On("LookupNetIP", mock.Anything, "ip", "host.example").
+		Return([]netip.Addr{b, c}, nil)
+
+	events := make(chan resolver.AddressEvent, 16)
+
+	book, err := resolver.NewAddressBook(inner, &resolver.AddressBookConfig{
+		Hosts:  []string{"host.example"},
+		MinTTL: ptr.To(10 * time.Millisecond),
+		OnChange: func(event resolver.AddressEvent) {
+			events <- event
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- book.Run(ctx)
+	}()
+
+	var received []resolver.AddressEvent
+	for len(received) < 4 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for address events")
+		}
+	}
+
+	require.Equal(t, []resolver.AddressEvent{
+		{Type: resolver.AddressAdded, Host: "host.example", Addr: a},
+		{Type: resolver.AddressAdded, Host: "host.example", Addr: b},
+		{Type: resolver.AddressAdded, Host: "host.example", Addr: c},
+		{Type: resolver.AddressRemoved, Host: "host.example", Addr: a},
+	}, received)
+
+	require.Equal(t, []netip.Addr{b, c}, book.Addrs("host.example"))
+
+	cancel()
+	require.NoError(t, <-done)
+
+	t.Run("No Hosts", func(t *testing.T) {
+		_, err := resolver.NewAddressBook(inner, nil)
+		require.Error(t, err)
+	})
+}
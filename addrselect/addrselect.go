@@ -41,24 +41,57 @@
 // preferred source and destination address selection algorithm for
 // Internet Protocol version 6 (IPv6) and Internet Protocol version 4
 // (IPv4).
+//
+// The policy table used to rank addresses can be customized (like
+// /etc/gai.conf), eg. to deliberately prefer IPv4 or specific prefixes.
 package addrselect
 
 import (
+	"cmp"
 	stdnet "net"
 	"net/netip"
+	"slices"
 	"sort"
 )
 
+// DialFunc is used to determine the source address that would be used to
+// reach a destination (no packets are sent).
 type DialFunc func(network, address string) (stdnet.Conn, error)
 
+// SortByRFC6724 sorts addrs in order of preference, using the default policy
+// table of RFC 6724.
 func SortByRFC6724(dial DialFunc, addrs []netip.Addr) {
+	rfc6724policyTable.Sort(dial, addrs)
+}
+
+// SortByRFC6724withSrcs sorts addrs in order of preference, given the source
+// address that would be used to reach each of them (or an invalid address if
+// unreachable). It uses the default policy table of RFC 6724.
+func SortByRFC6724withSrcs(dial DialFunc, addrs []netip.Addr, srcs []netip.Addr) {
+	rfc6724policyTable.sortWithSrcs(addrs, srcs)
+}
+
+// Sort sorts addrs in order of preference, using the policy table t.
+func (t PolicyTable) Sort(dial DialFunc, addrs []netip.Addr) {
 	if len(addrs) < 2 {
 		return
 	}
-	SortByRFC6724withSrcs(dial, addrs, srcAddrs(dial, addrs))
+	t.sortWithSrcs(addrs, srcAddrs(dial, addrs))
 }
 
-func SortByRFC6724withSrcs(dial DialFunc, addrs []netip.Addr, srcs []netip.Addr) {
+func (t PolicyTable) sortWithSrcs(addrs []netip.Addr, srcs []netip.Addr) {
+	Policy{Table: t}.sortWithSrcs(addrs, srcs)
+}
+
+// Sort sorts addrs in order of preference, using the policy p.
+func (p Policy) Sort(dial DialFunc, addrs []netip.Addr) {
+	if len(addrs) < 2 {
+		return
+	}
+	p.sortWithSrcs(addrs, srcAddrs(dial, addrs))
+}
+
+func (p Policy) sortWithSrcs(addrs []netip.Addr, srcs []netip.Addr) {
 	if len(addrs) != len(srcs) {
 		panic("internal error")
 	}
@@ -66,8 +99,8 @@ func SortByRFC6724withSrcs(dial DialFunc, addrs []netip.Addr, srcs []netip.Addr)
 	srcAttr := make([]ipAttr, len(srcs))
 	for i, v := range addrs {
 		addrAttrIP, _ := netip.AddrFromSlice(v.AsSlice())
-		addrAttr[i] = ipAttrOf(addrAttrIP)
-		srcAttr[i] = ipAttrOf(srcs[i])
+		addrAttr[i] = p.ipAttrOf(addrAttrIP)
+		srcAttr[i] = p.ipAttrOf(srcs[i])
 	}
 	sort.Stable(&byRFC6724{
 		addrs:    addrs,
@@ -94,17 +127,34 @@ func srcAddrs(dial DialFunc, addrs []netip.Addr) []netip.Addr {
 	return srcs
 }
 
+// ipAttrOf returns the attributes of ip, with the scope of IPv4 addresses
+// taken from the IPv4 scope table (if any).
+func (p Policy) ipAttrOf(ip netip.Addr) ipAttr {
+	attr := p.Table.ipAttrOf(ip)
+	if ip.IsValid() && ip.Unmap().Is4() && len(p.IPv4Scopes) > 0 {
+		attr.Scope = scopeGlobal
+		bits := -1
+		for _, ent := range p.IPv4Scopes {
+			if ent.Prefix.Bits() > bits && ent.Prefix.Contains(ip.Unmap()) {
+				attr.Scope = scope(ent.Scope)
+				bits = ent.Prefix.Bits()
+			}
+		}
+	}
+	return attr
+}
+
 type ipAttr struct {
 	Scope      scope
 	Precedence uint8
 	Label      uint8
 }
 
-func ipAttrOf(ip netip.Addr) ipAttr {
+func (t PolicyTable) ipAttrOf(ip netip.Addr) ipAttr {
 	if !ip.IsValid() {
 		return ipAttr{}
 	}
-	match := rfc6724policyTable.Classify(ip)
+	match := t.Classify(ip)
 	return ipAttr{
 		Scope:      classifyScope(ip),
 		Precedence: match.Precedence,
@@ -252,17 +302,73 @@ func (s *byRFC6724) Less(i, j int) bool {
 	return false // "equal"
 }
 
-type policyTableEntry struct {
-	Prefix     netip.Prefix
+// Policy is an address selection policy, a policy table along with optional
+// overrides of the scopes of IPv4 addresses (like the scopev4 setting of
+// gai.conf).
+type Policy struct {
+	// Table is the policy table used to classify addresses.
+	Table PolicyTable
+	// IPv4Scopes overrides the scopes of IPv4 addresses, the longest matching
+	// prefix is used. If set, IPv4 addresses that don't match any prefix have
+	// global scope (otherwise loopback and link-local addresses have
+	// link-local scope).
+	IPv4Scopes []ScopeTableEntry
+}
+
+// ScopeTableEntry assigns a scope (RFC 6724 section 3.1, eg. 0x2 for
+// link-local or 0xe for global) to the IPv4 addresses of a prefix.
+type ScopeTableEntry struct {
+	// Prefix is an IPv4 prefix.
+	Prefix netip.Prefix
+	// Scope is the scope of the addresses.
+	Scope uint8
+}
+
+// PolicyTableEntry is an entry of a policy table (RFC 6724 section 2.1).
+type PolicyTableEntry struct {
+	// Prefix is the prefix the entry applies to, IPv4 prefixes apply to
+	// IPv4 (and IPv4-mapped IPv6) addresses.
+	Prefix netip.Prefix
+	// Precedence is used to sort destination addresses, higher is preferred.
 	Precedence uint8
-	Label      uint8
+	// Label is used to prefer source and destination addresses that share
+	// the same label.
+	Label uint8
 }
 
-type policyTable []policyTableEntry
+// PolicyTable is a longest matching prefix lookup table, used to classify
+// addresses.
+type PolicyTable []PolicyTableEntry
+
+// NewPolicyTable returns a policy table containing entries, sorted so that
+// the longest matching prefix of an address is found first. IPv4 prefixes
+// are converted to their IPv4-mapped IPv6 equivalents.
+func NewPolicyTable(entries ...PolicyTableEntry) PolicyTable {
+	t := make(PolicyTable, 0, len(entries))
+	for _, ent := range entries {
+		if ent.Prefix.Addr().Is4() {
+			ent.Prefix = netip.PrefixFrom(netip.AddrFrom16(ent.Prefix.Addr().As16()), ent.Prefix.Bits()+96)
+		}
+		ent.Prefix = ent.Prefix.Masked()
+		t = append(t, ent)
+	}
+
+	slices.SortStableFunc(t, func(a, b PolicyTableEntry) int {
+		return cmp.Compare(b.Prefix.Bits(), a.Prefix.Bits())
+	})
+
+	return t
+}
+
+// DefaultPolicyTable returns a copy of the default policy table of RFC 6724
+// section 2.1, eg. as a starting point for a custom table.
+func DefaultPolicyTable() PolicyTable {
+	return slices.Clone(rfc6724policyTable)
+}
 
 // RFC 6724 section 2.1.
 // Items are sorted by the size of their Prefix.Mask.Size,
-var rfc6724policyTable = policyTable{
+var rfc6724policyTable = PolicyTable{
 	{
 		// "::1/128"
 		Prefix:     netip.PrefixFrom(netip.AddrFrom16([16]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01}), 128),
@@ -322,10 +428,10 @@ var rfc6724policyTable = policyTable{
 	},
 }
 
-// Classify returns the policyTableEntry of the entry with the longest
-// matching prefix that contains ip.
-// The table t must be sorted from largest mask size to smallest.
-func (t policyTable) Classify(ip netip.Addr) policyTableEntry {
+// Classify returns the entry with the longest matching prefix that contains
+// ip. The table t must be sorted from largest mask size to smallest (see
+// NewPolicyTable).
+func (t PolicyTable) Classify(ip netip.Addr) PolicyTableEntry {
 	// Prefix.Contains() will not match an IPv6 prefix for an IPv4 address.
 	if ip.Is4() {
 		ip = netip.AddrFrom16(ip.As16())
@@ -335,7 +441,7 @@ func (t policyTable) Classify(ip netip.Addr) policyTableEntry {
 			return ent
 		}
 	}
-	return policyTableEntry{}
+	return PolicyTableEntry{}
 }
 
 // RFC 6724 section 3.1.
@@ -43,6 +43,7 @@ import (
 	"net"
 	"net/netip"
 	"reflect"
+	"slices"
 	"testing"
 )
 
@@ -189,7 +190,7 @@ func TestRFC6724PolicyTableOrder(t *testing.T) {
 }
 
 func TestRFC6724PolicyTableContent(t *testing.T) {
-	expectedRfc6724policyTable := policyTable{
+	expectedRfc6724policyTable := PolicyTable{
 		{
 			Prefix:     netip.MustParsePrefix("::1/128"),
 			Precedence: 50,
@@ -244,11 +245,11 @@ func TestRFC6724PolicyTableContent(t *testing.T) {
 func TestRFC6724PolicyTableClassify(t *testing.T) {
 	tests := []struct {
 		ip   netip.Addr
-		want policyTableEntry
+		want PolicyTableEntry
 	}{
 		{
 			ip: netip.MustParseAddr("127.0.0.1"),
-			want: policyTableEntry{
+			want: PolicyTableEntry{
 				Prefix:     netip.MustParsePrefix("::ffff:0:0/96"),
 				Precedence: 35,
 				Label:      4,
@@ -256,7 +257,7 @@ func TestRFC6724PolicyTableClassify(t *testing.T) {
 		},
 		{
 			ip: netip.MustParseAddr("2601:645:8002:a500:986f:1db8:c836:bd65"),
-			want: policyTableEntry{
+			want: PolicyTableEntry{
 				Prefix:     netip.MustParsePrefix("::/0"),
 				Precedence: 40,
 				Label:      1,
@@ -264,7 +265,7 @@ func TestRFC6724PolicyTableClassify(t *testing.T) {
 		},
 		{
 			ip: netip.MustParseAddr("::1"),
-			want: policyTableEntry{
+			want: PolicyTableEntry{
 				Prefix:     netip.MustParsePrefix("::1/128"),
 				Precedence: 50,
 				Label:      0,
@@ -272,7 +273,7 @@ func TestRFC6724PolicyTableClassify(t *testing.T) {
 		},
 		{
 			ip: netip.MustParseAddr("2002::ab12"),
-			want: policyTableEntry{
+			want: PolicyTableEntry{
 				Prefix:     netip.MustParsePrefix("2002::/16"),
 				Precedence: 30,
 				Label:      2,
@@ -343,3 +344,51 @@ func TestRFC6724CommonPrefixLength(t *testing.T) {
 		}
 	}
 }
+
+func TestCustomPolicyTable(t *testing.T) {
+	addrs := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("192.0.2.1")}
+	srcs := []netip.Addr{netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("192.0.2.2")}
+
+	// Prefer IPv4, like "precedence ::ffff:0:0/96 100" in /etc/gai.conf.
+	entries := DefaultPolicyTable()
+	for i := range entries {
+		if entries[i].Prefix == netip.MustParsePrefix("::ffff:0:0/96") {
+			entries[i].Precedence = 100
+		}
+	}
+	table := NewPolicyTable(entries...)
+
+	got := slices.Clone(addrs)
+	table.sortWithSrcs(got, slices.Clone(srcs))
+
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sort = %v; want %v", got, want)
+	}
+
+	got = slices.Clone(addrs)
+	SortByRFC6724withSrcs(nil, got, slices.Clone(srcs))
+
+	if !reflect.DeepEqual(got, addrs) {
+		t.Errorf("default sort = %v; want %v", got, addrs)
+	}
+}
+
+func TestNewPolicyTable(t *testing.T) {
+	table := NewPolicyTable(
+		PolicyTableEntry{Prefix: netip.MustParsePrefix("::/0"), Precedence: 40, Label: 1},
+		PolicyTableEntry{Prefix: netip.MustParsePrefix("10.1.2.3/8"), Precedence: 50, Label: 20},
+	)
+
+	want := PolicyTable{
+		{Prefix: netip.MustParsePrefix("::ffff:10.0.0.0/104"), Precedence: 50, Label: 20},
+		{Prefix: netip.MustParsePrefix("::/0"), Precedence: 40, Label: 1},
+	}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("NewPolicyTable = %v; want %v", table, want)
+	}
+
+	if got := table.Classify(netip.MustParseAddr("10.9.9.9")); got.Label != 20 {
+		t.Errorf("Classify(10.9.9.9) = %v; want label 20", got)
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package addrselect
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParseGAIConf parses a gai.conf(5) document, returning the address
+// selection policy it describes. As with glibc, if there are any label (or
+// precedence) lines they replace the labels (or precedences) of the default
+// policy table, and scopev4 lines replace the default scopes of IPv4
+// addresses. Other settings (eg. reload) are ignored.
+func ParseGAIConf(r io.Reader) (Policy, error) {
+	var labels, precedences []PolicyTableEntry
+	var scopes []ScopeTableEntry
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "label", "precedence", "scopev4":
+		default:
+			continue
+		}
+
+		if len(fields) != 3 {
+			return Policy{}, fmt.Errorf("line %d: expected a prefix and a value", lineNum)
+		}
+
+		prefix, err := parseGAIPrefix(fields[1])
+		if err != nil {
+			return Policy{}, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		value, err := strconv.ParseUint(fields[2], 10, 8)
+		if err != nil {
+			return Policy{}, fmt.Errorf("line %d: invalid value %q: %w", lineNum, fields[2], err)
+		}
+
+		switch fields[0] {
+		case "label":
+			labels = append(labels, PolicyTableEntry{Prefix: prefix, Label: uint8(value)})
+		case "precedence":
+			precedences = append(precedences, PolicyTableEntry{Prefix: prefix, Precedence: uint8(value)})
+		case "scopev4":
+			// Prefixes are written as IPv4-mapped IPv6 prefixes.
+			if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+			}
+			if !prefix.Addr().Is4() {
+				return Policy{}, fmt.Errorf("line %d: scopev4 prefix %s is not an IPv4 prefix", lineNum, fields[1])
+			}
+			scopes = append(scopes, ScopeTableEntry{Prefix: prefix.Masked(), Scope: uint8(value)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Policy{}, fmt.Errorf("failed to read gai.conf: %w", err)
+	}
+
+	policy := Policy{Table: DefaultPolicyTable(), IPv4Scopes: scopes}
+	if len(labels) == 0 && len(precedences) == 0 {
+		return policy, nil
+	}
+
+	labelTable, precedenceTable := policy.Table, policy.Table
+	if len(labels) > 0 {
+		labelTable = NewPolicyTable(labels...)
+	}
+	if len(precedences) > 0 {
+		precedenceTable = NewPolicyTable(precedences...)
+	}
+
+	// Merge the tables, every prefix of either table is classified using the
+	// longest prefix of each table that contains it.
+	var entries []PolicyTableEntry
+	seen := make(map[netip.Prefix]bool)
+	for _, ent := range append(append(PolicyTable{}, labelTable...), precedenceTable...) {
+		if seen[ent.Prefix] {
+			continue
+		}
+		seen[ent.Prefix] = true
+
+		entries = append(entries, PolicyTableEntry{
+			Prefix:     ent.Prefix,
+			Precedence: precedenceTable.containing(ent.Prefix).Precedence,
+			Label:      labelTable.containing(ent.Prefix).Label,
+		})
+	}
+	policy.Table = NewPolicyTable(entries...)
+
+	return policy, nil
+}
+
+// containing returns the entry with the longest prefix that contains all of
+// prefix. The table t must be sorted from largest mask size to smallest.
+func (t PolicyTable) containing(prefix netip.Prefix) PolicyTableEntry {
+	for _, ent := range t {
+		if ent.Prefix.Bits() <= prefix.Bits() && ent.Prefix.Contains(prefix.Addr()) {
+			return ent
+		}
+	}
+	return PolicyTableEntry{}
+}
+
+// parseGAIPrefix parses a prefix, an address without a length is a single
+// address prefix.
+func parseGAIPrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix %q: %w", s, err)
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix %q: %w", s, err)
+	}
+	return prefix, nil
+}
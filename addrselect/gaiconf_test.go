@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package addrselect
+
+import (
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseGAIConf(t *testing.T) {
+	t.Run("Precedence", func(t *testing.T) {
+		policy, err := ParseGAIConf(strings.NewReader(`
+# Prefer IPv4.
+reload no
+precedence ::ffff:0:0/96  100
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addrs := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("192.0.2.1")}
+		srcs := []netip.Addr{netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("192.0.2.2")}
+		policy.sortWithSrcs(addrs, srcs)
+
+		want := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}
+		if !reflect.DeepEqual(addrs, want) {
+			t.Errorf("sort = %v; want %v", addrs, want)
+		}
+
+		// Only the precedences are replaced, the default labels are kept.
+		got := policy.Table.Classify(netip.MustParseAddr("2002::1"))
+		if want := (PolicyTableEntry{Prefix: netip.MustParsePrefix("2002::/16"), Label: 2}); got != want {
+			t.Errorf("Classify(2002::1) = %v; want %v", got, want)
+		}
+	})
+
+	t.Run("Labels And Precedences", func(t *testing.T) {
+		policy, err := ParseGAIConf(strings.NewReader(`
+label      ::/0           1
+label      fd00::/8       7
+precedence ::/0           40
+precedence ::1            50
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			ip   netip.Addr
+			want PolicyTableEntry
+		}{
+			{netip.MustParseAddr("::1"), PolicyTableEntry{Prefix: netip.MustParsePrefix("::1/128"), Precedence: 50, Label: 1}},
+			{netip.MustParseAddr("fd00::1"), PolicyTableEntry{Prefix: netip.MustParsePrefix("fd00::/8"), Precedence: 40, Label: 7}},
+			{netip.MustParseAddr("192.0.2.1"), PolicyTableEntry{Prefix: netip.MustParsePrefix("::/0"), Precedence: 40, Label: 1}},
+		}
+		for _, tt := range tests {
+			if got := policy.Table.Classify(tt.ip); got != tt.want {
+				t.Errorf("Classify(%s) = %v; want %v", tt.ip, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("Scopes", func(t *testing.T) {
+		policy, err := ParseGAIConf(strings.NewReader("scopev4 ::ffff:10.0.0.0/104 5\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(policy.IPv4Scopes, []ScopeTableEntry{{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Scope: 5}}) {
+			t.Errorf("IPv4Scopes = %v", policy.IPv4Scopes)
+		}
+
+		tests := []struct {
+			ip   netip.Addr
+			want scope
+		}{
+			{netip.MustParseAddr("10.1.2.3"), scopeSiteLocal},
+			{netip.MustParseAddr("::ffff:10.1.2.3"), scopeSiteLocal},
+			// The default scopes are replaced.
+			{netip.MustParseAddr("127.0.0.1"), scopeGlobal},
+			{netip.MustParseAddr("fe80::1"), scopeLinkLocal},
+		}
+		for _, tt := range tests {
+			if got := policy.ipAttrOf(tt.ip).Scope; got != tt.want {
+				t.Errorf("scope of %s = %x; want %x", tt.ip, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, doc := range []string{
+			"label ::/0\n",
+			"precedence nonsense 10\n",
+			"precedence ::/0 1000\n",
+			"scopev4 2001:db8::/32 14\n",
+		} {
+			if _, err := ParseGAIConf(strings.NewReader(doc)); err == nil {
+				t.Errorf("ParseGAIConf(%q) succeeded; want error", doc)
+			}
+		}
+	})
+}
@@ -0,0 +1,335 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var _ Resolver = (*auditResolver)(nil)
+
+// AuditEntry is a lookup recorded by an audit resolver.
+type AuditEntry struct {
+	// Time is when the lookup started.
+	Time time.Time
+	// Client is the (possibly truncated) address of the client the lookup
+	// was performed on behalf of (see WithClientAddr), it is invalid if the
+	// client address is unknown.
+	Client netip.Prefix
+	// Identity is the identity of the client (see WithClientIdentity), if
+	// known.
+	Identity string
+	// Network is the network of the lookup (eg. "ip4").
+	Network string
+	// Name is the looked up name, or its hash if names are hashed.
+	Name string
+	// Addrs are the answered addresses, they are omitted if answers are
+	// redacted.
+	Addrs []netip.Addr
+	// Duration is how long the lookup took.
+	Duration time.Duration
+	// Err is the error that occurred, if any. If names are hashed, only the
+	// class of the error is recorded (eg. ErrNoSuchHost), as the original
+	// error would reveal the name.
+	Err error
+}
+
+// AuditSink records audit entries. Sinks are called synchronously for every
+// lookup, so they must be safe for concurrent use.
+type AuditSink interface {
+	Record(entry AuditEntry)
+}
+
+// AuditSinkFunc is a function that implements AuditSink.
+type AuditSinkFunc func(entry AuditEntry)
+
+func (f AuditSinkFunc) Record(entry AuditEntry) {
+	f(entry)
+}
+
+// AuditLogSink returns a sink that logs audit entries at info level.
+func AuditLogSink(logger *slog.Logger) AuditSink {
+	return AuditSinkFunc(func(entry AuditEntry) {
+		attrs := []slog.Attr{
+			slog.Time("time", entry.Time),
+			slog.String("network", entry.Network),
+			slog.String("name", entry.Name),
+			slog.Duration("duration", entry.Duration),
+		}
+		if entry.Client.IsValid() {
+			attrs = append(attrs, slog.String("client", entry.Client.String()))
+		}
+		if entry.Identity != "" {
+			attrs = append(attrs, slog.String("identity", entry.Identity))
+		}
+		if entry.Addrs != nil {
+			attrs = append(attrs, slog.Any("addrs", entry.Addrs))
+		}
+		if entry.Err != nil {
+			attrs = append(attrs, slog.Any("error", entry.Err))
+		}
+
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "Lookup", attrs...)
+	})
+}
+
+// auditRecord is the JSON representation of an audit entry.
+type auditRecord struct {
+	Time     time.Time    `json:"time"`
+	Client   string       `json:"client,omitempty"`
+	Identity string       `json:"identity,omitempty"`
+	Network  string       `json:"network"`
+	Name     string       `json:"name"`
+	Addrs    []netip.Addr `json:"addrs,omitempty"`
+	Duration Duration     `json:"duration"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// AuditJSONSink returns a sink that writes audit entries to w as JSON, one
+// entry per line. Write errors are ignored.
+func AuditJSONSink(w io.Writer) AuditSink {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+
+	return AuditSinkFunc(func(entry AuditEntry) {
+		record := auditRecord{
+			Time:     entry.Time,
+			Identity: entry.Identity,
+			Network:  entry.Network,
+			Name:     entry.Name,
+			Addrs:    entry.Addrs,
+			Duration: Duration(entry.Duration),
+		}
+		if entry.Client.IsValid() {
+			record.Client = entry.Client.String()
+		}
+		if entry.Err != nil {
+			record.Error = entry.Err.Error()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		_ = enc.Encode(record)
+	})
+}
+
+// AuditResolverConfig is the configuration for an audit resolver.
+type AuditResolverConfig struct {
+	// Sink records the audit entries, it is required.
+	Sink AuditSink
+	// HashNames replaces looked up names with their (hex encoded) SHA-256
+	// hash, so that the log can be searched for a known name without
+	// revealing the others. Defaults to false.
+	HashNames *bool
+	// HashKey is an optional key used to hash names (using HMAC-SHA256).
+	// Without a key, hashes of common names can be reversed using a
+	// dictionary. Rotating the key makes entries unlinkable across rotations.
+	HashKey Secret
+	// ClientIPv4PrefixLen is the number of leading bits of IPv4 client
+	// addresses that are recorded. Defaults to 32 (the full address).
+	ClientIPv4PrefixLen *int
+	// ClientIPv6PrefixLen is the number of leading bits of IPv6 client
+	// addresses that are recorded. Defaults to 128 (the full address).
+	ClientIPv6PrefixLen *int
+	// RedactAnswers omits the answered addresses from entries. Defaults to
+	// false.
+	RedactAnswers *bool
+	// Clock is an optional source of the current time. Defaults to the
+	// system's time.
+	Clock Clock
+}
+
+// auditResolver is a resolver that records who looked up what.
+type auditResolver struct {
+	resolver            Resolver
+	sink                AuditSink
+	hashNames           bool
+	hashKey             Secret
+	clientIPv4PrefixLen int
+	clientIPv6PrefixLen int
+	redactAnswers       bool
+	clock               Clock
+}
+
+// Audit returns a resolver that records every lookup (including the client it
+// was performed on behalf of, see WithClientAddr and WithClientIdentity) to
+// an audit sink, redacting entries as configured.
+func Audit(resolver Resolver, conf *AuditResolverConfig) (*auditResolver, error) {
+	if conf == nil || conf.Sink == nil {
+		return nil, errors.New("no audit sink")
+	}
+
+	conf, err := defaults.WithDefaults(conf, &AuditResolverConfig{
+		HashNames:           ptr.To(false),
+		ClientIPv4PrefixLen: ptr.To(32),
+		ClientIPv6PrefixLen: ptr.To(128),
+		RedactAnswers:       ptr.To(false),
+		Clock:               systemClock{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to populate config: %w", err)
+	}
+
+	if *conf.ClientIPv4PrefixLen < 0 || *conf.ClientIPv4PrefixLen > 32 {
+		return nil, fmt.Errorf("invalid IPv4 client prefix length %d", *conf.ClientIPv4PrefixLen)
+	}
+	if *conf.ClientIPv6PrefixLen < 0 || *conf.ClientIPv6PrefixLen > 128 {
+		return nil, fmt.Errorf("invalid IPv6 client prefix length %d", *conf.ClientIPv6PrefixLen)
+	}
+
+	return &auditResolver{
+		resolver:            resolver,
+		sink:                conf.Sink,
+		hashNames:           *conf.HashNames,
+		hashKey:             conf.HashKey,
+		clientIPv4PrefixLen: *conf.ClientIPv4PrefixLen,
+		clientIPv6PrefixLen: *conf.ClientIPv6PrefixLen,
+		redactAnswers:       *conf.RedactAnswers,
+		clock:               conf.Clock,
+	}, nil
+}
+
+func (r *auditResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	start := r.clock.Now()
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+
+	entry := AuditEntry{
+		Time:     start,
+		Network:  network,
+		Name:     r.name(host),
+		Duration: r.clock.Now().Sub(start),
+		Err:      err,
+	}
+	if r.hashNames && err != nil {
+		entry.Err = redactError(entry.Name, err)
+	}
+	if addr, ok := ClientAddr(ctx); ok {
+		entry.Client = r.client(addr)
+	}
+	if identity, ok := ClientIdentity(ctx); ok {
+		entry.Identity = identity
+	}
+	if !r.redactAnswers && err == nil {
+		entry.Addrs = addrs
+	}
+
+	r.sink.Record(entry)
+
+	return addrs, err
+}
+
+// name returns the recorded form of a looked up name.
+func (r *auditResolver) name(host string) string {
+	name := dns.CanonicalName(host)
+	if !r.hashNames {
+		return name
+	}
+
+	if r.hashKey == nil {
+		sum := sha256.Sum256([]byte(name))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var hashed string
+	err := r.hashKey.Use(func(key []byte) error {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(name))
+		hashed = hex.EncodeToString(mac.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		// Never fall back to recording the name in the clear.
+		return redacted
+	}
+
+	return hashed
+}
+
+// errLookupFailed is the class of redacted errors that have no more specific
+// class.
+var errLookupFailed = errors.New("lookup failed")
+
+// redactError returns a lookup error for the (hashed) name that only retains
+// the class of err, as err itself may include the name in the clear.
+func redactError(name string, err error) error {
+	var class error
+	switch {
+	case IsNXDomain(err):
+		class = ErrNoSuchHost
+	case IsNoData(err):
+		class = ErrNoData
+	case errors.Is(err, ErrTimeout) || isTimeout(err):
+		class = ErrTimeout
+	case errors.Is(err, ErrServFail):
+		class = ErrServFail
+	case errors.Is(err, ErrRefused):
+		class = ErrRefused
+	case errors.Is(err, ErrSecureFailure):
+		class = ErrSecureFailure
+	default:
+		class = errLookupFailed
+	}
+
+	return newError(name, "", class)
+}
+
+// client returns the recorded form of a client address.
+func (r *auditResolver) client(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+
+	bits := r.clientIPv6PrefixLen
+	if addr.Is4() {
+		bits = r.clientIPv4PrefixLen
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}
+	}
+
+	return prefix
+}
+
+// Close closes the underlying resolver.
+func (r *auditResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *auditResolver) describe() Description {
+	attributes := map[string]string{}
+	if r.hashNames {
+		attributes["names"] = "hashed"
+	}
+	if r.redactAnswers {
+		attributes["answers"] = "redacted"
+	}
+
+	return Description{
+		Type:       "audit",
+		Attributes: attributes,
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditResolver(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip4", "www.example.com").Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip4", "missing.example.com").Return(nil, &resolver.Error{})
+
+	var mu sync.Mutex
+	var entries []resolver.AuditEntry
+	sink := resolver.AuditSinkFunc(func(entry resolver.AuditEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		entries = append(entries, entry)
+	})
+
+	ctx := resolver.WithClientIdentity(context.Background(), "tenant-1")
+	ctx = resolver.WithClientAddr(ctx, netip.MustParseAddr("::ffff:198.51.100.23"))
+
+	t.Run("Clear", func(t *testing.T) {
+		entries = nil
+
+		res, err := resolver.Audit(inner, &resolver.AuditResolverConfig{Sink: sink})
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "www.example.com")
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "missing.example.com")
+		require.Error(t, err)
+
+		require.Len(t, entries, 2)
+		require.Equal(t, netip.MustParsePrefix("198.51.100.23/32"), entries[0].Client)
+		require.Equal(t, "tenant-1", entries[0].Identity)
+		require.Equal(t, "www.example.com.", entries[0].Name)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, entries[0].Addrs)
+		require.NoError(t, entries[0].Err)
+
+		require.Equal(t, "missing.example.com.", entries[1].Name)
+		require.Error(t, entries[1].Err)
+	})
+
+	t.Run("Redacted", func(t *testing.T) {
+		entries = nil
+
+		key := []byte("audit key")
+		res, err := resolver.Audit(inner, &resolver.AuditResolverConfig{
+			Sink:                sink,
+			HashNames:           ptr.To(true),
+			HashKey:             resolver.NewRotatingSecret(key),
+			ClientIPv4PrefixLen: ptr.To(24),
+			RedactAnswers:       ptr.To(true),
+		})
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "www.example.com")
+		require.NoError(t, err)
+
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte("www.example.com."))
+
+		require.Len(t, entries, 1)
+		require.Equal(t, netip.MustParsePrefix("198.51.100.0/24"), entries[0].Client)
+		require.Equal(t, hex.EncodeToString(mac.Sum(nil)), entries[0].Name)
+		require.Nil(t, entries[0].Addrs)
+	})
+
+	t.Run("JSON Sink", func(t *testing.T) {
+		var buf bytes.Buffer
+		res, err := resolver.Audit(inner, &resolver.AuditResolverConfig{
+			Sink:                resolver.AuditJSONSink(&buf),
+			ClientIPv4PrefixLen: ptr.To(16),
+		})
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "www.example.com")
+		require.NoError(t, err)
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		require.Equal(t, "198.51.0.0/16", record["client"])
+		require.Equal(t, "www.example.com.", record["name"])
+		require.Equal(t, []any{"192.0.2.1"}, record["addrs"])
+	})
+
+	t.Run("JSON Sink Redacted Error", func(t *testing.T) {
+		inner := new(dnstest.MockResolver)
+		inner.On("LookupNetIP", mock.Anything, "ip4", "secret.example.com").
+			Return(nil, &resolver.Error{DNSError: net.DNSError{
+				Err:        resolver.ErrNoSuchHost.Error(),
+				Name:       "secret.example.com.",
+				Server:     "192.0.2.53:53",
+				IsNotFound: true,
+			}})
+
+		var buf bytes.Buffer
+		res, err := resolver.Audit(inner, &resolver.AuditResolverConfig{
+			Sink:      resolver.AuditJSONSink(&buf),
+			HashNames: ptr.To(true),
+		})
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "secret.example.com")
+		require.True(t, resolver.IsNXDomain(err))
+
+		require.NotContains(t, buf.String(), "secret")
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+		sum := sha256.Sum256([]byte("secret.example.com."))
+		require.Equal(t, hex.EncodeToString(sum[:]), record["name"])
+		require.Contains(t, record["error"], resolver.ErrNoSuchHost.Error())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := resolver.Audit(inner, nil)
+		require.Error(t, err)
+
+		_, err = resolver.Audit(inner, &resolver.AuditResolverConfig{
+			Sink:                sink,
+			ClientIPv6PrefixLen: ptr.To(129),
+		})
+		require.Error(t, err)
+	})
+}
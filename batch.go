@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+// LookupHostsConfig is the configuration for a batched lookup.
+type LookupHostsConfig struct {
+	// Network is the type of addresses to look up, one of "ip", "ip4" or
+	// "ip6". Defaults to "ip".
+	Network string
+	// Concurrency is the maximum number of lookups in flight at once.
+	// Defaults to 16.
+	Concurrency *int
+}
+
+// HostResult is the result of looking up a single host in a batch.
+type HostResult struct {
+	// Addrs are the addresses of the host, if the lookup succeeded.
+	Addrs []netip.Addr
+	// Err is the error, if the lookup failed.
+	Err error
+}
+
+// LookupHosts looks up many hosts using resolver, with a bounded number of
+// lookups in flight. Every host is present in the returned map, the failure
+// of some lookups does not affect the others. Hosts that were not looked up
+// before ctx was done report the context's error.
+//
+// All lookups share the resolver, and so share any connections it keeps open
+// (eg. to DNS over TLS or HTTPS servers).
+func LookupHosts(ctx context.Context, resolver Resolver, hosts []string, conf *LookupHostsConfig) map[string]HostResult {
+	conf, err := defaults.WithDefaults(conf, &LookupHostsConfig{
+		Network:     "ip",
+		Concurrency: ptr.To(16),
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	results := make(map[string]HostResult, len(hosts))
+	var mu sync.Mutex
+
+	sem := make(chan struct{}, max(*conf.Concurrency, 1))
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		mu.Lock()
+		_, seen := results[host]
+		if !seen {
+			// Reserve the host, so that duplicates are only looked up once.
+			results[host] = HostResult{}
+		}
+		mu.Unlock()
+		if seen {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			results[host] = HostResult{Err: newError(host, "", ctx.Err())}
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			addrs, err := resolver.LookupNetIP(ctx, conf.Network, host)
+
+			mu.Lock()
+			results[host] = HostResult{Addrs: addrs, Err: err}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
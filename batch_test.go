@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupHosts(t *testing.T) {
+	a := netip.MustParseAddr("192.0.2.1")
+	b := netip.MustParseAddr("192.0.2.2")
+
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "a.example").
+		Return([]netip.Addr{a}, nil).Once()
+	inner.On("LookupNetIP", mock.Anything, "ip", "b.example").
+		Return([]netip.Addr{b}, nil).Once()
+	inner.On("LookupNetIP", mock.Anything, "ip", "missing.example").
+		Return([]netip.Addr(nil), resolver.ErrNoSuchHost).Once()
+
+	results := resolver.LookupHosts(context.Background(), inner,
+		[]string{"a.example", "b.example", "missing.example", "a.example"}, nil)
+
+	require.Len(t, results, 3)
+
+	require.NoError(t, results["a.example"].Err)
+	require.Equal(t, []netip.Addr{a}, results["a.example"].Addrs)
+
+	require.NoError(t, results["b.example"].Err)
+	require.Equal(t, []netip.Addr{b}, results["b.example"].Addrs)
+
+	require.True(t, resolver.IsNXDomain(results["missing.example"].Err))
+	require.Empty(t, results["missing.example"].Addrs)
+
+	inner.AssertExpectations(t)
+}
+
+func TestLookupHostsConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	release := make(chan struct{})
+
+	res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		<-release
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil
+	})
+
+	hosts := []string{"a.example", "b.example", "c.example", "d.example", "e.example"}
+
+	done := make(chan map[string]resolver.HostResult)
+	go func() {
+		done <- resolver.LookupHosts(context.Background(), res, hosts, &resolver.LookupHostsConfig{
+			Concurrency: ptr.To(2),
+		})
+	}()
+
+	close(release)
+	results := <-done
+
+	require.Len(t, results, len(hosts))
+	for _, host := range hosts {
+		require.NoError(t, results[host].Err)
+	}
+	require.LessOrEqual(t, maxInFlight.Load(), int32(2))
+}
+
+func TestLookupHostsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		return nil, ctx.Err()
+	})
+
+	results := resolver.LookupHosts(ctx, res, []string{"a.example", "b.example"}, nil)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.ErrorIs(t, result.Err, context.Canceled)
+	}
+}
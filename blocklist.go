@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+var _ Resolver = (*blocklistResolver)(nil)
+
+// NameMatcher matches domain names, eg. a *blocklist.List or a
+// *blocklist.Loader.
+type NameMatcher interface {
+	// Match reports whether name is matched.
+	Match(name string) bool
+}
+
+// BlocklistResolverConfig is the configuration for a blocklist resolver.
+type BlocklistResolverConfig struct {
+	// Matcher reports whether a name is blocked. If it implements io.Closer
+	// (eg. a *blocklist.Loader), it is closed along with the resolver.
+	Matcher NameMatcher
+}
+
+// blocklistResolver is a resolver that refuses to resolve blocked names.
+type blocklistResolver struct {
+	resolver Resolver
+	matcher  NameMatcher
+}
+
+// Blocklist returns a resolver that answers lookups for blocked names with
+// ErrNoSuchHost (carrying a "Blocked" extended DNS error), without passing
+// them to the next resolver.
+func Blocklist(resolver Resolver, conf *BlocklistResolverConfig) *blocklistResolver {
+	return &blocklistResolver{
+		resolver: resolver,
+		matcher:  conf.Matcher,
+	}
+}
+
+func (r *blocklistResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if r.matcher.Match(host) {
+		return nil, newError(host, "", ErrNoSuchHost).withExtendedErrorCodes([]ExtendedError{
+			{InfoCode: dns.ExtendedErrorCodeBlocked},
+		})
+	}
+
+	return r.resolver.LookupNetIP(ctx, network, host)
+}
+
+// Close closes the underlying resolver, and the matcher if it is closable.
+func (r *blocklistResolver) Close() error {
+	var err error
+	if closer, ok := r.matcher.(io.Closer); ok {
+		err = closer.Close()
+	}
+
+	return errors.Join(err, Close(r.resolver))
+}
+
+func (r *blocklistResolver) describe() Description {
+	return Description{
+		Type:     "blocklist",
+		Children: []Description{Describe(r.resolver)},
+	}
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package blocklist implements loading and matching of domain blocklists, in
+// the formats commonly used by DNS filtering tools.
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Format is the format of a blocklist.
+type Format string
+
+const (
+	// FormatHosts is a hosts file, where blocked domains are mapped to an
+	// unroutable address (eg. "0.0.0.0 ads.example.com").
+	FormatHosts Format = "hosts"
+	// FormatDomains is a list of domains, one per line.
+	FormatDomains Format = "domains"
+	// FormatAdBlock is a list of AdBlock-style domain rules (eg.
+	// "||ads.example.com^"), exception rules (eg. "@@||cdn.example.com^") are
+	// supported.
+	FormatAdBlock Format = "adblock"
+)
+
+// hostsIgnoredNames are names commonly found in hosts file style blocklists,
+// that are not meant to be blocked.
+var hostsIgnoredNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+}
+
+type rule uint8
+
+const (
+	ruleNone rule = iota
+	ruleBlock
+	ruleAllow
+)
+
+type node struct {
+	children map[string]*node
+	rule     rule
+}
+
+// List is a set of blocked domains, stored as a trie of labels so that a
+// domain matches all of its subdomains. The most specific rule wins, and
+// allow rules take precedence over block rules for the same domain.
+//
+// A List is not safe for concurrent modification, but it is safe to match
+// names concurrently once it has been built.
+type List struct {
+	root  node
+	rules int
+}
+
+// Block adds a rule blocking domain and all of its subdomains.
+func (l *List) Block(domain string) {
+	l.add(domain, ruleBlock)
+}
+
+// Allow adds a rule allowing domain and all of its subdomains, overriding
+// less specific block rules.
+func (l *List) Allow(domain string) {
+	l.add(domain, ruleAllow)
+}
+
+// Len returns the number of rules in the list.
+func (l *List) Len() int {
+	return l.rules
+}
+
+// Match reports whether name is blocked by the list.
+func (l *List) Match(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	n := &l.root
+	matched := n.rule
+	for name != "" {
+		var label string
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name, label = name[:i], name[i+1:]
+		} else {
+			name, label = "", name
+		}
+
+		n = n.children[label]
+		if n == nil {
+			break
+		}
+
+		if n.rule != ruleNone {
+			matched = n.rule
+		}
+	}
+
+	return matched == ruleBlock
+}
+
+func (l *List) add(domain string, r rule) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	// The root domain matches every name.
+	n := &l.root
+	if domain != "" {
+		labels := strings.Split(domain, ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			child := n.children[labels[i]]
+			if child == nil {
+				if n.children == nil {
+					n.children = make(map[string]*node)
+				}
+
+				child = &node{}
+				n.children[labels[i]] = child
+			}
+			n = child
+		}
+	}
+
+	if n.rule == ruleNone {
+		l.rules++
+	}
+	if n.rule != ruleAllow {
+		n.rule = r
+	}
+}
+
+// Decode adds the rules of a blocklist in the given format to the list. Lines
+// that can't be parsed (eg. AdBlock cosmetic rules) are skipped, as public
+// blocklists commonly contain them.
+func (l *List) Decode(rdr io.Reader, format Format) error {
+	var decodeLine func(line string)
+	switch format {
+	case FormatHosts:
+		decodeLine = l.decodeHostsLine
+	case FormatDomains:
+		decodeLine = l.decodeDomainsLine
+	case FormatAdBlock:
+		decodeLine = l.decodeAdBlockLine
+	default:
+		return fmt.Errorf("unknown blocklist format %q", format)
+	}
+
+	scanner := bufio.NewScanner(rdr)
+	for scanner.Scan() {
+		decodeLine(strings.TrimSpace(scanner.Text()))
+	}
+
+	return scanner.Err()
+}
+
+func (l *List) decodeHostsLine(line string) {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return
+	}
+
+	if _, err := netip.ParseAddr(fields[0]); err != nil {
+		return
+	}
+
+	for _, name := range fields[1:] {
+		if hostsIgnoredNames[strings.ToLower(name)] {
+			continue
+		}
+
+		if isDomain(name) {
+			l.Block(name)
+		}
+	}
+}
+
+func (l *List) decodeDomainsLine(line string) {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+
+	// Subdomains are always matched, so wildcards are redundant.
+	domain := strings.TrimPrefix(line, "*.")
+	if isDomain(domain) {
+		l.Block(domain)
+	}
+}
+
+func (l *List) decodeAdBlockLine(line string) {
+	if line == "" || line[0] == '!' || line[0] == '[' {
+		return
+	}
+
+	r := ruleBlock
+	if rest, ok := strings.CutPrefix(line, "@@"); ok {
+		line, r = rest, ruleAllow
+	}
+
+	line, ok := strings.CutPrefix(line, "||")
+	if !ok {
+		return
+	}
+
+	domain, options, ok := strings.Cut(line, "^")
+	if !ok {
+		return
+	}
+
+	// Rules with modifiers (other than "important") apply only to some
+	// requests, so they can't be enforced on DNS queries.
+	if options != "" && options != "$important" {
+		return
+	}
+
+	if isDomain(domain) {
+		l.add(domain, r)
+	}
+}
+
+// isDomain reports whether s is a domain name that can be blocked, IP
+// literals and wildcards are rejected.
+func isDomain(s string) bool {
+	if s == "" || strings.ContainsAny(s, "*/ \t") {
+		return false
+	}
+
+	if _, err := netip.ParseAddr(s); err == nil {
+		return false
+	}
+
+	_, ok := dns.IsDomainName(s)
+	return ok
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package blocklist_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver/blocklist"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	t.Run("Hosts", func(t *testing.T) {
+		var list blocklist.List
+		require.NoError(t, list.Decode(strings.NewReader(`# Ad servers
+127.0.0.1 localhost
+0.0.0.0 0.0.0.0
+0.0.0.0 ads.example.com tracker.example.net # inline comment
+:: ADS.example.org
+not-an-address example.edu
+`), blocklist.FormatHosts))
+
+		require.Equal(t, 3, list.Len())
+		require.True(t, list.Match("ads.example.com"))
+		require.True(t, list.Match("cdn.ads.example.com."))
+		require.True(t, list.Match("ads.example.org"))
+		require.True(t, list.Match("tracker.example.net"))
+		require.False(t, list.Match("example.com"))
+		require.False(t, list.Match("localhost"))
+		require.False(t, list.Match("example.edu"))
+	})
+
+	t.Run("Domains", func(t *testing.T) {
+		var list blocklist.List
+		require.NoError(t, list.Decode(strings.NewReader(`# Trackers
+tracker.example.com
+*.ads.example.net
+
+not a domain
+`), blocklist.FormatDomains))
+
+		require.Equal(t, 2, list.Len())
+		require.True(t, list.Match("tracker.example.com"))
+		require.True(t, list.Match("pixel.tracker.example.com"))
+		require.True(t, list.Match("ads.example.net"))
+		require.True(t, list.Match("www.ads.example.net"))
+		require.False(t, list.Match("example.com"))
+	})
+
+	t.Run("AdBlock", func(t *testing.T) {
+		var list blocklist.List
+		require.NoError(t, list.Decode(strings.NewReader(`[Adblock Plus 2.0]
+! Title: Example
+||example.com^
+||tracker.example.net^$important
+@@||cdn.example.com^
+||ads.example.org^$third-party
+example.com##.banner
+/banner/*/img^
+`), blocklist.FormatAdBlock))
+
+		require.Equal(t, 3, list.Len())
+		require.True(t, list.Match("example.com"))
+		require.True(t, list.Match("www.example.com"))
+		require.True(t, list.Match("tracker.example.net"))
+		require.False(t, list.Match("cdn.example.com"))
+		require.False(t, list.Match("img.cdn.example.com"))
+		require.False(t, list.Match("ads.example.org"))
+	})
+
+	t.Run("Allow Precedence", func(t *testing.T) {
+		var list blocklist.List
+		list.Allow("example.com")
+		list.Block("example.com")
+		list.Block("ads.example.com")
+
+		require.Equal(t, 2, list.Len())
+		require.False(t, list.Match("example.com"))
+		require.True(t, list.Match("ads.example.com"))
+	})
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		var list blocklist.List
+		require.Error(t, list.Decode(strings.NewReader(""), "xml"))
+	})
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+// Source is a blocklist to load.
+type Source struct {
+	// URL is the location of the blocklist, "http", "https", and "file" URLs
+	// are supported.
+	URL string
+	// Format is the format of the blocklist.
+	Format Format
+}
+
+// LoaderConfig is the configuration for a Loader.
+type LoaderConfig struct {
+	// Sources are the blocklists to load, their rules are merged.
+	Sources []Source
+	// RefreshInterval is the interval at which the blocklists are reloaded.
+	// Defaults to 24 hours, zero disables periodic refreshes.
+	RefreshInterval *time.Duration
+	// HTTPClient is the client used to fetch blocklists over HTTP. Defaults to
+	// a client with a one minute timeout.
+	HTTPClient *http.Client
+	// MaxSize is the maximum size, in bytes, of each blocklist. Defaults to
+	// 64 MiB.
+	MaxSize *int64
+	// Logger is an optional logger, failures to refresh the blocklists are
+	// logged at warning level.
+	Logger *slog.Logger
+}
+
+// Loader loads blocklists from a set of sources, and periodically refreshes
+// them in the background. If a refresh fails, the previously loaded rules are
+// kept.
+type Loader struct {
+	sources         []Source
+	refreshInterval time.Duration
+	client          *http.Client
+	maxSize         int64
+	logger          *slog.Logger
+	list            atomic.Pointer[List]
+	cancel          context.CancelFunc
+	done            chan struct{}
+}
+
+// NewLoader creates a new Loader, the blocklists are loaded before it
+// returns.
+func NewLoader(ctx context.Context, conf *LoaderConfig) (*Loader, error) {
+	if conf == nil || len(conf.Sources) == 0 {
+		return nil, errors.New("no blocklist sources")
+	}
+
+	conf, err := defaults.WithDefaults(conf, &LoaderConfig{
+		RefreshInterval: ptr.To(24 * time.Hour),
+		HTTPClient:      &http.Client{Timeout: time.Minute},
+		MaxSize:         ptr.To(int64(64 << 20)),
+		Logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to populate config: %w", err)
+	}
+
+	l := &Loader{
+		sources:         conf.Sources,
+		refreshInterval: *conf.RefreshInterval,
+		client:          conf.HTTPClient,
+		maxSize:         *conf.MaxSize,
+		logger:          conf.Logger,
+		done:            make(chan struct{}),
+	}
+
+	if err := l.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, l.cancel = context.WithCancel(context.Background())
+	go l.run(ctx)
+
+	return l, nil
+}
+
+// Match reports whether name is blocked by the most recently loaded rules.
+func (l *Loader) Match(name string) bool {
+	return l.list.Load().Match(name)
+}
+
+// List returns the most recently loaded rules.
+func (l *Loader) List() *List {
+	return l.list.Load()
+}
+
+// Refresh reloads the blocklists, the rules are only replaced if every
+// source is loaded successfully.
+func (l *Loader) Refresh(ctx context.Context) error {
+	list := &List{}
+	for _, source := range l.sources {
+		if err := l.load(ctx, list, source); err != nil {
+			return fmt.Errorf("failed to load blocklist %q: %w", source.URL, err)
+		}
+	}
+
+	l.list.Store(list)
+
+	return nil
+}
+
+// Close stops refreshing the blocklists.
+func (l *Loader) Close() error {
+	l.cancel()
+	<-l.done
+	return nil
+}
+
+func (l *Loader) run(ctx context.Context) {
+	defer close(l.done)
+
+	if l.refreshInterval <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(l.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Refresh(ctx); err != nil && ctx.Err() == nil {
+				l.logger.Warn("Failed to refresh blocklists", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (l *Loader) load(ctx context.Context, list *List, source Source) error {
+	u, err := url.Parse(source.URL)
+	if err != nil {
+		return err
+	}
+
+	var rc io.ReadCloser
+	switch u.Scheme {
+	case "file":
+		rc, err = os.Open(u.Path)
+		if err != nil {
+			return err
+		}
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := l.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+
+		rc = resp.Body
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	defer rc.Close()
+
+	lr := &io.LimitedReader{R: rc, N: l.maxSize + 1}
+	err = list.Decode(lr, source.Format)
+	if lr.N <= 0 {
+		return fmt.Errorf("blocklist exceeds %d bytes", l.maxSize)
+	}
+
+	return err
+}
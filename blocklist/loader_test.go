@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package blocklist_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver/blocklist"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader(t *testing.T) {
+	var blocked atomic.Value
+	blocked.Store("ads.example.com\n")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(blocked.Load().(string)))
+	}))
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "adblock.txt")
+	require.NoError(t, os.WriteFile(path, []byte("||tracker.example.net^\n"), 0o644))
+
+	ctx := context.Background()
+
+	loader, err := blocklist.NewLoader(ctx, &blocklist.LoaderConfig{
+		Sources: []blocklist.Source{
+			{URL: srv.URL, Format: blocklist.FormatDomains},
+			{URL: (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(), Format: blocklist.FormatAdBlock},
+		},
+		RefreshInterval: ptr.To(10 * time.Millisecond),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, loader.Close())
+	})
+
+	require.Equal(t, 2, loader.List().Len())
+	require.True(t, loader.Match("ads.example.com"))
+	require.True(t, loader.Match("tracker.example.net"))
+
+	blocked.Store("ads.example.org\n")
+
+	require.Eventually(t, func() bool {
+		return loader.Match("ads.example.org") && !loader.Match("ads.example.com")
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("Failed Load", func(t *testing.T) {
+		_, err := blocklist.NewLoader(ctx, &blocklist.LoaderConfig{
+			Sources: []blocklist.Source{{URL: srv.URL + "/missing", Format: "xml"}},
+		})
+		require.Error(t, err)
+
+		_, err = blocklist.NewLoader(ctx, &blocklist.LoaderConfig{
+			Sources: []blocklist.Source{{URL: "ftp://example.com/list.txt", Format: blocklist.FormatDomains}},
+		})
+		require.Error(t, err)
+
+		_, err = blocklist.NewLoader(ctx, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("Too Large", func(t *testing.T) {
+		_, err := blocklist.NewLoader(ctx, &blocklist.LoaderConfig{
+			Sources: []blocklist.Source{{URL: srv.URL, Format: blocklist.FormatDomains}},
+			MaxSize: ptr.To(int64(8)),
+		})
+		require.ErrorContains(t, err, "exceeds 8 bytes")
+	})
+}
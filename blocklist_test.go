@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/blocklist"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocklistResolver(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "www.example.com").Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	var list blocklist.List
+	list.Block("ads.example.com")
+
+	res := resolver.Blocklist(inner, &resolver.BlocklistResolverConfig{
+		Matcher: &list,
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Blocked", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip", "cdn.ads.example.com")
+		require.True(t, resolver.IsNXDomain(err))
+		require.ErrorContains(t, err, "EDE 15 Blocked")
+
+		var resolverErr *resolver.Error
+		require.True(t, errors.As(err, &resolverErr))
+		require.Equal(t, []resolver.ExtendedError{{InfoCode: dns.ExtendedErrorCodeBlocked}}, resolverErr.ExtendedErrors)
+	})
+
+	inner.AssertNumberOfCalls(t, "LookupNetIP", 1)
+}
@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+// BulkResolverConfig is the configuration for a bulk resolver.
+type BulkResolverConfig struct {
+	// Network is the type of addresses to look up, one of "ip", "ip4" or
+	// "ip6". Defaults to "ip".
+	Network string
+	// Workers is the number of lookups in flight at once, it bounds the
+	// number of sockets used by the underlying resolver. Defaults to 64.
+	Workers *int
+	// Attempts is the number of attempts made for each name, names are only
+	// retried after temporary failures (eg. timeouts). Defaults to 2.
+	Attempts *int
+	// QueriesPerSecond is an optional limit on the rate at which lookups
+	// (including retries) are started. Defaults to unlimited.
+	QueriesPerSecond int
+	// OnResult is an optional hook, called with the result of each name once
+	// it has been resolved (or has failed). It may be called concurrently
+	// from multiple workers.
+	OnResult func(BulkResult)
+	// Logger is an optional logger, failed attempts are logged at debug level.
+	Logger *slog.Logger
+}
+
+// BulkResult is the result of resolving a single name in bulk.
+type BulkResult struct {
+	// Host is the hostname that was looked up.
+	Host string
+	// Addrs are the addresses of the host, if the lookup succeeded.
+	Addrs []netip.Addr
+	// Err is the error from the last attempt, if the lookup failed.
+	Err error
+	// Attempts is the number of attempts that were made.
+	Attempts int
+}
+
+// BulkProgress is a snapshot of the progress of a bulk resolver.
+type BulkProgress struct {
+	// Completed is the number of names that have been resolved or have failed.
+	Completed uint64
+	// Failed is the number of names that have failed.
+	Failed uint64
+	// Queries is the number of lookups that have been started, including
+	// retries.
+	Queries uint64
+}
+
+// BulkResolver resolves very large numbers of names (eg. millions, when
+// scanning) using a fixed pool of workers, so that memory and socket usage
+// stay bounded regardless of the size of the workload.
+type BulkResolver struct {
+	resolver Resolver
+	network  string
+	workers  int
+	attempts int
+	interval time.Duration
+	onResult func(BulkResult)
+	logger   *slog.Logger
+
+	completed atomic.Uint64
+	failed    atomic.Uint64
+	queries   atomic.Uint64
+}
+
+// NewBulkResolver returns a new bulk resolver, that looks up names using
+// resolver. Wrapping resolver in a cache is usually counterproductive, as
+// the cache would grow with the workload.
+func NewBulkResolver(resolver Resolver, conf *BulkResolverConfig) (*BulkResolver, error) {
+	conf, err := defaults.WithDefaults(conf, &BulkResolverConfig{
+		Network:  "ip",
+		Workers:  ptr.To(64),
+		Attempts: ptr.To(2),
+		Logger:   discardLogger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults: %w", err)
+	}
+
+	if conf.Network != "ip" && conf.Network != "ip4" && conf.Network != "ip6" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedNetwork, conf.Network)
+	}
+
+	if *conf.Workers < 1 {
+		return nil, fmt.Errorf("workers must be at least 1")
+	}
+
+	if conf.QueriesPerSecond < 0 {
+		return nil, fmt.Errorf("queries per second must not be negative")
+	}
+
+	var interval time.Duration
+	if conf.QueriesPerSecond > 0 {
+		interval = time.Second / time.Duration(conf.QueriesPerSecond)
+	}
+
+	return &BulkResolver{
+		resolver: resolver,
+		network:  conf.Network,
+		workers:  *conf.Workers,
+		attempts: max(*conf.Attempts, 1),
+		interval: interval,
+		onResult: conf.OnResult,
+		logger:   conf.Logger,
+	}, nil
+}
+
+// Run resolves each name received from hosts, until hosts is closed (and
+// every name has been resolved) or ctx is done. Individual lookup failures
+// are reported through the OnResult hook, the returned error is only
+// non-nil if ctx was done before all names were resolved.
+func (b *BulkResolver) Run(ctx context.Context, hosts <-chan string) (BulkProgress, error) {
+	var tick <-chan time.Time
+	if b.interval > 0 {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case host, ok := <-hosts:
+					if !ok {
+						return
+					}
+
+					result, ok := b.resolve(ctx, tick, host)
+					if !ok {
+						return
+					}
+
+					b.completed.Add(1)
+					if result.Err != nil {
+						b.failed.Add(1)
+					}
+
+					if b.onResult != nil {
+						b.onResult(result)
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return b.Progress(), ctx.Err()
+}
+
+// Progress returns a snapshot of the progress of the bulk resolver, it is
+// safe to call concurrently with Run (eg. to report progress periodically).
+func (b *BulkResolver) Progress() BulkProgress {
+	return BulkProgress{
+		Completed: b.completed.Load(),
+		Failed:    b.failed.Load(),
+		Queries:   b.queries.Load(),
+	}
+}
+
+// resolve looks up a single host, retrying temporary failures. It returns
+// false if ctx was done before the host could be resolved.
+func (b *BulkResolver) resolve(ctx context.Context, tick <-chan time.Time, host string) (BulkResult, bool) {
+	result := BulkResult{Host: host}
+
+	for result.Attempts < b.attempts {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return result, false
+			case <-tick:
+			}
+		}
+
+		b.queries.Add(1)
+		result.Attempts++
+
+		result.Addrs, result.Err = b.resolver.LookupNetIP(ctx, b.network, host)
+		if result.Err == nil || !isTemporary(result.Err) {
+			break
+		}
+
+		if ctx.Err() != nil {
+			return result, false
+		}
+
+		b.logger.LogAttrs(ctx, slog.LevelDebug, "Lookup attempt failed",
+			slog.String("host", host),
+			slog.Int("attempt", result.Attempts),
+			slog.String("error", result.Err.Error()))
+	}
+
+	return result, true
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkResolver(t *testing.T) {
+	addr := netip.MustParseAddr("192.0.2.1")
+
+	var attempts sync.Map
+	res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		n, _ := attempts.LoadOrStore(host, new(atomic.Int32))
+		attempt := n.(*atomic.Int32).Add(1)
+
+		switch host {
+		case "missing.example":
+			return nil, resolver.ErrNoSuchHost
+		case "flaky.example":
+			if attempt == 1 {
+				return nil, &net.DNSError{Err: "timeout", Name: host, IsTimeout: true, IsTemporary: true}
+			}
+		case "down.example":
+			return nil, &net.DNSError{Err: "timeout", Name: host, IsTimeout: true, IsTemporary: true}
+		}
+
+		return []netip.Addr{addr}, nil
+	})
+
+	var mu sync.Mutex
+	results := map[string]resolver.BulkResult{}
+
+	bulk, err := resolver.NewBulkResolver(res, &resolver.BulkResolverConfig{
+		Workers:  ptr.To(4),
+		Attempts: ptr.To(3),
+		OnResult: func(result resolver.BulkResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[result.Host] = result
+		},
+	})
+	require.NoError(t, err)
+
+	hosts := make(chan string)
+	go func() {
+		defer close(hosts)
+		for i := 0; i < 100; i++ {
+			hosts <- fmt.Sprintf("host%d.example", i)
+		}
+		hosts <- "missing.example"
+		hosts <- "flaky.example"
+		hosts <- "down.example"
+	}()
+
+	progress, err := bulk.Run(context.Background(), hosts)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(103), progress.Completed)
+	require.Equal(t, uint64(2), progress.Failed)
+	require.Equal(t, uint64(100+1+2+3), progress.Queries)
+	require.Equal(t, progress, bulk.Progress())
+
+	require.Len(t, results, 103)
+	require.Equal(t, []netip.Addr{addr}, results["host42.example"].Addrs)
+
+	require.ErrorIs(t, results["missing.example"].Err, resolver.ErrNoSuchHost)
+	require.Equal(t, 1, results["missing.example"].Attempts)
+
+	require.NoError(t, results["flaky.example"].Err)
+	require.Equal(t, 2, results["flaky.example"].Attempts)
+
+	require.Error(t, results["down.example"].Err)
+	require.Equal(t, 3, results["down.example"].Attempts)
+}
+
+func TestBulkResolverRateLimit(t *testing.T) {
+	res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil
+	})
+
+	bulk, err := resolver.NewBulkResolver(res, &resolver.BulkResolverConfig{
+		QueriesPerSecond: 50,
+	})
+	require.NoError(t, err)
+
+	hosts := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		hosts <- fmt.Sprintf("host%d.example", i)
+	}
+	close(hosts)
+
+	start := time.Now()
+	progress, err := bulk.Run(context.Background(), hosts)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(10), progress.Completed)
+	require.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+}
+
+func TestBulkResolverCanceled(t *testing.T) {
+	res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	bulk, err := resolver.NewBulkResolver(res, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Never closed, Run must return once ctx is done.
+	hosts := make(chan string, 1)
+	hosts <- "host.example"
+
+	_, err = bulk.Run(ctx, hosts)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBulkResolverInvalidConfig(t *testing.T) {
+	_, err := resolver.NewBulkResolver(nil, &resolver.BulkResolverConfig{Network: "tcp"})
+	require.ErrorIs(t, err, resolver.ErrUnsupportedNetwork)
+
+	_, err = resolver.NewBulkResolver(nil, &resolver.BulkResolverConfig{Workers: ptr.To(0)})
+	require.Error(t, err)
+}
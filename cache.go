@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var _ Resolver = (*cacheResolver)(nil)
+
+// CacheResolverConfig is the configuration for a caching resolver.
+type CacheResolverConfig struct {
+	// MinTTL is the minimum duration an answer will be cached for, answers with
+	// shorter TTLs (eg. zero) are cached for MinTTL. Defaults to 0.
+	MinTTL *time.Duration
+	// MaxTTL is the maximum duration an answer will be cached for, answers with
+	// longer TTLs are cached for MaxTTL. Defaults to 24 hours.
+	MaxTTL *time.Duration
+	// MaxEntries is the maximum number of answers that will be cached.
+	// Defaults to 10000.
+	MaxEntries *int
+	// Metrics is an optional recorder of cache hits and misses.
+	Metrics MetricsRecorder
+	// Clock is an optional source of the current time, used to expire
+	// answers. Defaults to the system's time.
+	Clock Clock
+}
+
+// cacheKey identifies a cached answer. scope discriminates between lookups
+// that were routed differently by context values (see cacheScope).
+type cacheKey struct {
+	network string
+	name    string
+	scope   string
+}
+
+type cacheEntry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// cacheResolver is a resolver that caches the answers of another resolver.
+type cacheResolver struct {
+	resolver   Resolver
+	minTTL     time.Duration
+	maxTTL     time.Duration
+	maxEntries int
+	metrics    MetricsRecorder
+	clock      Clock
+	mu         sync.Mutex
+	entries    map[cacheKey]cacheEntry
+}
+
+// Cache returns a resolver that caches the answers of resolver, for the TTL
+// reported by the DNS server (clamped to MinTTL and MaxTTL). Answers from
+// sources without a TTL (eg. the hosts file) are cached for MinTTL. Errors are
+// not cached. Answers are only shared between lookups made with the same
+// client identity, interface and EDNS options in their context, as these can
+// change the answer.
+func Cache(resolver Resolver, conf *CacheResolverConfig) *cacheResolver {
+	conf, err := defaults.WithDefaults(conf, &CacheResolverConfig{
+		MinTTL:     ptr.To(time.Duration(0)),
+		MaxTTL:     ptr.To(24 * time.Hour),
+		MaxEntries: ptr.To(10000),
+		Metrics:    nopMetricsRecorder{},
+		Clock:      systemClock{},
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	return &cacheResolver{
+		resolver:   resolver,
+		minTTL:     *conf.MinTTL,
+		maxTTL:     *conf.MaxTTL,
+		maxEntries: *conf.MaxEntries,
+		metrics:    conf.Metrics,
+		clock:      conf.Clock,
+		entries:    make(map[cacheKey]cacheEntry),
+	}
+}
+
+func (r *cacheResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	key := cacheKey{network: network, name: dns.CanonicalName(host), scope: cacheScope(ctx)}
+
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+
+	now := r.clock.Now()
+	if ok && now.Before(entry.expires) {
+		r.metrics.RecordCacheLookup(true)
+		recordTTL(ctx, entry.expires.Sub(now))
+		recordProvenance(ctx, AddrInfo{
+			Source: AddrSourceCache,
+			TTL:    entry.expires.Sub(now),
+		}, entry.addrs...)
+		return slices.Clone(entry.addrs), nil
+	}
+	r.metrics.RecordCacheLookup(false)
+
+	recorder := &ttlRecorder{}
+	addrs, err := r.resolver.LookupNetIP(withTTLRecorder(ctx, recorder), network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := min(max(recorder.ttl(), r.minTTL), r.maxTTL)
+	if ttl > 0 {
+		r.mu.Lock()
+		r.evict()
+		r.entries[key] = cacheEntry{
+			addrs:   slices.Clone(addrs),
+			expires: r.clock.Now().Add(ttl),
+		}
+		r.mu.Unlock()
+	}
+	recordTTL(ctx, ttl)
+
+	return addrs, nil
+}
+
+// evict makes room for a new entry, expired entries are removed first.
+// The caller must hold the lock.
+func (r *cacheResolver) evict() {
+	if len(r.entries) < r.maxEntries {
+		return
+	}
+
+	now := r.clock.Now()
+	for key, entry := range r.entries {
+		if now.After(entry.expires) {
+			delete(r.entries, key)
+		}
+	}
+
+	// Otherwise remove arbitrary entries.
+	for key := range r.entries {
+		if len(r.entries) < r.maxEntries {
+			break
+		}
+		delete(r.entries, key)
+	}
+}
+
+type ttlRecorderKey struct{}
+
+// ttlRecorder records the lowest TTL of the records used to answer a lookup.
+type ttlRecorder struct {
+	mu       sync.Mutex
+	recorded bool
+	lowest   time.Duration
+}
+
+func withTTLRecorder(ctx context.Context, recorder *ttlRecorder) context.Context {
+	return context.WithValue(ctx, ttlRecorderKey{}, recorder)
+}
+
+// recordTTL records the TTL of an answer, if the lookup is being cached.
+func recordTTL(ctx context.Context, ttl time.Duration) {
+	recorder, ok := ctx.Value(ttlRecorderKey{}).(*ttlRecorder)
+	if !ok {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if !recorder.recorded || ttl < recorder.lowest {
+		recorder.lowest = ttl
+		recorder.recorded = true
+	}
+}
+
+func (r *ttlRecorder) ttl() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lowest
+}
+
+// recordedTTL returns the lowest recorded TTL, and whether any TTL was
+// recorded at all (eg. answers from the hosts file have no TTL).
+func (r *ttlRecorder) recordedTTL() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lowest, r.recorded
+}
+
+// answerTTL returns the lowest TTL of the records in rrs.
+func answerTTL(rrs []dns.RR) time.Duration {
+	var ttl uint32
+	for i, rr := range rrs {
+		if i == 0 || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// Close discards all cached answers and closes the underlying resolver.
+func (r *cacheResolver) Close() error {
+	r.mu.Lock()
+	clear(r.entries)
+	r.mu.Unlock()
+
+	return Close(r.resolver)
+}
+
+func (r *cacheResolver) describe() Description {
+	r.mu.Lock()
+	entries := len(r.entries)
+	r.mu.Unlock()
+
+	return Description{
+		Type: "cache",
+		Attributes: map[string]string{
+			"entries":     strconv.Itoa(entries),
+			"max_entries": strconv.Itoa(r.maxEntries),
+			"min_ttl":     r.minTTL.String(),
+			"max_ttl":     r.maxTTL.String(),
+		},
+		Children: []Description{Describe(r.resolver)},
+	}
+}
+
+// cacheScope returns a discriminator for the context values that can route a
+// lookup to a different view, interface or answer, so that answers for one
+// are never returned for another. Client addresses are deliberately left out,
+// as servers set them on every lookup (routing by source address is the job
+// of Views, which sits above the per-view caches).
+func cacheScope(ctx context.Context) string {
+	var sb strings.Builder
+	if identity, ok := ClientIdentity(ctx); ok {
+		fmt.Fprintf(&sb, "identity=%q;", identity)
+	}
+	if name, ok := Interface(ctx); ok {
+		fmt.Fprintf(&sb, "interface=%q;", name)
+	}
+	for _, opt := range LookupEDNSOptions(ctx) {
+		fmt.Fprintf(&sb, "edns=%d:%x;", opt.Code, opt.Data)
+	}
+	return sb.String()
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheResolver(t *testing.T) {
+	var queries atomic.Int32
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		queries.Add(1)
+
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		q := req.Question[0]
+		if q.Qtype == dns.TypeA {
+			ttl := "300"
+			if q.Name == "zero.example." {
+				ttl = "0"
+			}
+			reply.Answer = []dns.RR{mustRR(t, q.Name+" "+ttl+" IN A 192.0.2.1")}
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	upstream := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.Cache(upstream, nil)
+
+		for i := 0; i < 3; i++ {
+			addrs, err := res.LookupNetIP(context.Background(), "ip", "www.example")
+			require.NoError(t, err)
+			require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+		}
+
+		// A and AAAA queries.
+		require.Equal(t, int32(2), queries.Load())
+	})
+
+	t.Run("Zero TTL", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.Cache(upstream, nil)
+
+		for i := 0; i < 2; i++ {
+			_, err := res.LookupNetIP(context.Background(), "ip4", "zero.example")
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, int32(2), queries.Load())
+	})
+
+	t.Run("Min TTL", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.Cache(upstream, &resolver.CacheResolverConfig{
+			MinTTL: ptr.To(5 * time.Second),
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := res.LookupNetIP(context.Background(), "ip4", "zero.example")
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, int32(1), queries.Load())
+	})
+
+	t.Run("Max TTL", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.Cache(upstream, &resolver.CacheResolverConfig{
+			MaxTTL: ptr.To(10 * time.Millisecond),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, int32(2), queries.Load())
+	})
+
+	t.Run("No TTL", func(t *testing.T) {
+		inner := new(dnstest.MockResolver)
+		inner.On("LookupNetIP", mock.Anything, "ip", "host.example").
+			Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+
+		res := resolver.Cache(inner, &resolver.CacheResolverConfig{
+			MinTTL: ptr.To(time.Minute),
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := res.LookupNetIP(context.Background(), "ip", "host.example")
+			require.NoError(t, err)
+		}
+
+		inner.AssertNumberOfCalls(t, "LookupNetIP", 1)
+	})
+
+	t.Run("Context Scope", func(t *testing.T) {
+		inner := new(dnstest.MockResolver)
+		inner.On("LookupNetIP", mock.Anything, "ip", "host.example").
+			Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+
+		res := resolver.Cache(inner, &resolver.CacheResolverConfig{
+			MinTTL: ptr.To(time.Minute),
+		})
+
+		ctxs := []context.Context{
+			context.Background(),
+			resolver.WithClientIdentity(context.Background(), "alice"),
+			resolver.WithInterface(context.Background(), "eth0"),
+			resolver.WithEDNSOptions(context.Background(), resolver.EDNSOption{Code: 65001, Data: []byte{1}}),
+			resolver.WithEDNSOptions(context.Background(), resolver.EDNSOption{Code: 65001, Data: []byte{2}}),
+		}
+
+		for i := 0; i < 2; i++ {
+			for _, ctx := range ctxs {
+				_, err := res.LookupNetIP(ctx, "ip", "host.example")
+				require.NoError(t, err)
+			}
+		}
+
+		inner.AssertNumberOfCalls(t, "LookupNetIP", len(ctxs))
+
+		// Lookups on behalf of different clients share cached answers.
+		for _, addr := range []string{"192.0.2.10", "192.0.2.11"} {
+			_, err := res.LookupNetIP(resolver.WithClientAddr(context.Background(), netip.MustParseAddr(addr)), "ip", "host.example")
+			require.NoError(t, err)
+		}
+
+		inner.AssertNumberOfCalls(t, "LookupNetIP", len(ctxs))
+	})
+}
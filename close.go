@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"errors"
+	"io"
+)
+
+// Close releases any resources held by resolver (eg. cached answers) and, for
+// composite resolvers, the resolvers it is composed of. Resolvers that do not
+// hold any resources (ie. do not implement io.Closer) are left untouched.
+// Closing a resolver more than once is safe.
+func Close(resolver Resolver) error {
+	if resolver == nil {
+		return nil
+	}
+
+	if c, ok := resolver.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func closeAll(resolvers []Resolver) error {
+	var errs []error
+	for _, resolver := range resolvers {
+		if err := Close(resolver); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+type closingResolver struct {
+	dnstest.MockResolver
+	closed int
+	err    error
+}
+
+func (r *closingResolver) Close() error {
+	r.closed++
+	return r.err
+}
+
+func TestClose(t *testing.T) {
+	t.Run("Propagates", func(t *testing.T) {
+		first := &closingResolver{}
+		second := &closingResolver{}
+		third := &closingResolver{}
+
+		res := resolver.Relative(resolver.Sequential(
+			resolver.Literal(),
+			resolver.Cache(resolver.RoundRobin(first, second), nil),
+			resolver.Routing(&resolver.RoutingResolverConfig{
+				Default: third,
+			}),
+		), nil)
+
+		require.NoError(t, resolver.Close(res))
+
+		require.Equal(t, 1, first.closed)
+		require.Equal(t, 1, second.closed)
+		require.Equal(t, 1, third.closed)
+	})
+
+	t.Run("Joins Errors", func(t *testing.T) {
+		errFirst := errors.New("first")
+		errSecond := errors.New("second")
+
+		res := resolver.Parallel(
+			&closingResolver{err: errFirst},
+			&closingResolver{err: errSecond},
+		)
+
+		err := resolver.Close(res)
+		require.ErrorIs(t, err, errFirst)
+		require.ErrorIs(t, err, errSecond)
+	})
+
+	t.Run("DNS", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: netip.MustParseAddrPort("192.0.2.53:53"),
+		})
+
+		require.NoError(t, res.Close())
+		require.NoError(t, res.Close())
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.ErrorIs(t, err, net.ErrClosed)
+	})
+
+	t.Run("Not Closer", func(t *testing.T) {
+		require.NoError(t, resolver.Close(new(dnstest.MockResolver)))
+		require.NoError(t, resolver.Close(nil))
+	})
+}
@@ -0,0 +1,420 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package main implements getresolvd, a dig-like command line tool for
+// querying DNS servers and exercising the resolver library.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/internal/dnsconfig"
+)
+
+type options struct {
+	server        string
+	transport     string
+	tlsServerName string
+	timeout       time.Duration
+	dnssec        bool
+	lookup        bool
+	trace         bool
+	zeroconf      bool
+	json          bool
+	name          string
+	qType         uint16
+	explicitType  bool
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "getresolvd: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	opts, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	if opts.lookup || opts.trace || opts.zeroconf {
+		return lookup(ctx, out, opts)
+	}
+
+	return query(ctx, out, opts)
+}
+
+func parseArgs(args []string) (*options, error) {
+	var opts options
+
+	fs := flag.NewFlagSet("getresolvd", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: getresolvd [flags] [@server] name [type]\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&opts.server, "server", "", "DNS server address (defaults to the system's first nameserver)")
+	fs.StringVar(&opts.transport, "transport", "udp", "transport protocol (udp, tcp, tls, or https)")
+	fs.StringVar(&opts.tlsServerName, "tls-server-name", "", "server name used to verify the DNS over TLS server's certificate")
+	fs.DurationVar(&opts.timeout, "timeout", 5*time.Second, "overall timeout")
+	fs.BoolVar(&opts.dnssec, "dnssec", false, "request DNSSEC records (set the DO bit)")
+	fs.BoolVar(&opts.lookup, "lookup", false, "resolve addresses like an application would (hosts file, search list, etc)")
+	fs.BoolVar(&opts.trace, "trace", false, "show the full resolution path of an address lookup (implies -lookup)")
+	fs.BoolVar(&opts.zeroconf, "zeroconf", false, "resolve .local names using mDNS, like desktop operating systems (implies -lookup)")
+	fs.BoolVar(&opts.json, "json", false, "output JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var positional []string
+	for _, arg := range fs.Args() {
+		if strings.HasPrefix(arg, "@") {
+			opts.server = strings.TrimPrefix(arg, "@")
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	if len(positional) < 1 || len(positional) > 2 {
+		fs.Usage()
+		return nil, errors.New("expected a name and an optional record type")
+	}
+
+	opts.name = positional[0]
+	opts.qType = dns.TypeA
+	if len(positional) == 2 {
+		qType, ok := dns.StringToType[strings.ToUpper(positional[1])]
+		if !ok {
+			return nil, fmt.Errorf("unknown record type %q", positional[1])
+		}
+		opts.qType = qType
+		opts.explicitType = true
+	}
+
+	return &opts, nil
+}
+
+// dnsResolver is a resolver that can also send arbitrary queries.
+type dnsResolver interface {
+	resolver.Resolver
+	Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error)
+}
+
+// newDNSResolver returns a resolver that queries the selected server (or the
+// system's first nameserver).
+func newDNSResolver(opts *options) (dnsResolver, netip.AddrPort, error) {
+	server := opts.server
+	if server == "" {
+		conf, err := dnsconfig.Read(dnsconfig.Location)
+		if err != nil {
+			return nil, netip.AddrPort{}, fmt.Errorf("failed to read system DNS configuration: %w", err)
+		}
+		if len(conf.Servers) == 0 {
+			return nil, netip.AddrPort{}, errors.New("no nameservers configured")
+		}
+		server = conf.Servers[0]
+	}
+
+	var transport resolver.DNSTransport
+	switch opts.transport {
+	case "udp":
+		transport = resolver.DNSTransportUDP
+	case "tcp":
+		transport = resolver.DNSTransportTCP
+	case "tls":
+		transport = resolver.DNSTransportTLS
+	case "https":
+		transport = resolver.DNSTransportHTTPS
+	default:
+		return nil, netip.AddrPort{}, fmt.Errorf("unknown transport %q", opts.transport)
+	}
+
+	addrPort, err := netip.ParseAddrPort(server)
+	if err != nil {
+		addr, err := netip.ParseAddr(server)
+		if err != nil {
+			return nil, netip.AddrPort{}, fmt.Errorf("invalid server address %q", server)
+		}
+
+		port := uint16(53)
+		switch transport {
+		case resolver.DNSTransportTLS:
+			port = 853
+		case resolver.DNSTransportHTTPS:
+			port = 443
+		}
+		addrPort = netip.AddrPortFrom(addr, port)
+	}
+
+	conf := resolver.DNSResolverConfig{
+		Server:    addrPort,
+		Transport: &transport,
+		Timeout:   &opts.timeout,
+		DNSSECOK:  &opts.dnssec,
+	}
+	if opts.tlsServerName != "" {
+		conf.TLSConfig = &tls.Config{ServerName: opts.tlsServerName}
+	}
+
+	return resolver.DNS(conf), addrPort, nil
+}
+
+type queryOutput struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Server     string   `json:"server"`
+	Transport  string   `json:"transport"`
+	Rcode      string   `json:"rcode"`
+	Answer     []string `json:"answer,omitempty"`
+	Authority  []string `json:"authority,omitempty"`
+	Additional []string `json:"additional,omitempty"`
+	Duration   string   `json:"duration"`
+}
+
+// query sends a single query for any record type, and prints the reply.
+func query(ctx context.Context, out io.Writer, opts *options) error {
+	res, server, err := newDNSResolver(opts)
+	if err != nil {
+		return err
+	}
+
+	req := &dns.Msg{}
+	req.SetQuestion(dns.Fqdn(opts.name), opts.qType)
+	req.SetEdns0(dns.DefaultMsgSize, opts.dnssec)
+
+	start := time.Now()
+	reply, err := res.Exchange(ctx, req)
+	if err != nil {
+		return err
+	}
+	duration := time.Since(start)
+
+	if opts.json {
+		return writeJSON(out, queryOutput{
+			Name:       req.Question[0].Name,
+			Type:       dns.TypeToString[opts.qType],
+			Server:     server.String(),
+			Transport:  opts.transport,
+			Rcode:      dns.RcodeToString[reply.Rcode],
+			Answer:     rrStrings(reply.Answer),
+			Authority:  rrStrings(reply.Ns),
+			Additional: rrStrings(reply.Extra),
+			Duration:   duration.String(),
+		})
+	}
+
+	fmt.Fprintln(out, reply.String())
+	fmt.Fprintf(out, ";; Query time: %s\n", duration.Round(time.Microsecond))
+	fmt.Fprintf(out, ";; SERVER: %s (%s)\n", server, opts.transport)
+	return nil
+}
+
+type addrOutput struct {
+	Addr      string `json:"addr"`
+	Source    string `json:"source"`
+	Server    string `json:"server,omitempty"`
+	Transport string `json:"transport,omitempty"`
+	TTL       string `json:"ttl,omitempty"`
+	RTT       string `json:"rtt,omitempty"`
+}
+
+type traceEventOutput struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name,omitempty"`
+	Target    string   `json:"target,omitempty"`
+	QType     string   `json:"qtype,omitempty"`
+	Server    string   `json:"server,omitempty"`
+	Transport string   `json:"transport,omitempty"`
+	Rcode     string   `json:"rcode,omitempty"`
+	RTT       string   `json:"rtt,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Addrs     []string `json:"addrs,omitempty"`
+}
+
+type traceOutput struct {
+	Network  string             `json:"network"`
+	Host     string             `json:"host"`
+	Events   []traceEventOutput `json:"events"`
+	Addrs    []string           `json:"addrs,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Duration string             `json:"duration"`
+}
+
+// lookup resolves the addresses of a name through the full resolver chain.
+func lookup(ctx context.Context, out io.Writer, opts *options) error {
+	var res resolver.Resolver
+	if opts.server != "" {
+		dnsRes, _, err := newDNSResolver(opts)
+		if err != nil {
+			return err
+		}
+		res = resolver.Sequential(resolver.Literal(), dnsRes)
+	} else {
+		var err error
+		res, err = resolver.System(nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.zeroconf {
+		var err error
+		res, err = resolver.Zeroconf(&resolver.ZeroconfResolverConfig{
+			Unicast: res,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	network := "ip"
+	switch opts.qType {
+	case dns.TypeA:
+		// A is the default record type, so only restrict the lookup to IPv4
+		// addresses if it was explicitly requested.
+		if opts.explicitType {
+			network = "ip4"
+		}
+	case dns.TypeAAAA:
+		network = "ip6"
+	default:
+		return fmt.Errorf("address lookups do not support record type %s", dns.TypeToString[opts.qType])
+	}
+
+	if opts.trace {
+		trace := resolver.Trace(ctx, res, network, opts.name, nil)
+		if opts.json {
+			return writeJSON(out, toTraceOutput(trace))
+		}
+
+		fmt.Fprint(out, trace.String())
+		return trace.Err
+	}
+
+	result, err := resolver.LookupHostDetailed(ctx, res, network, opts.name)
+	if err != nil {
+		return err
+	}
+
+	addrs := make([]addrOutput, 0, len(result.Addrs))
+	for _, info := range result.Addrs {
+		addr := addrOutput{
+			Addr:   info.Addr.String(),
+			Source: string(info.Source),
+		}
+		if info.Server.IsValid() {
+			addr.Server = info.Server.String()
+			addr.Transport = string(info.Transport)
+			addr.RTT = info.RTT.String()
+		}
+		if info.TTL > 0 {
+			addr.TTL = info.TTL.String()
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if opts.json {
+		return writeJSON(out, addrs)
+	}
+
+	for _, addr := range addrs {
+		fmt.Fprintf(out, "%s\t%s", addr.Addr, addr.Source)
+		if addr.Server != "" {
+			fmt.Fprintf(out, " @%s (%s) ttl=%s rtt=%s", addr.Server, addr.Transport, addr.TTL, addr.RTT)
+		} else if addr.TTL != "" {
+			fmt.Fprintf(out, " ttl=%s", addr.TTL)
+		}
+		fmt.Fprintln(out)
+	}
+
+	return nil
+}
+
+func toTraceOutput(trace *resolver.ResolutionTrace) traceOutput {
+	output := traceOutput{
+		Network:  trace.Network,
+		Host:     trace.Host,
+		Events:   make([]traceEventOutput, 0, len(trace.Events)),
+		Addrs:    addrStrings(trace.Addrs),
+		Duration: trace.Duration.String(),
+	}
+	if trace.Err != nil {
+		output.Error = trace.Err.Error()
+	}
+
+	for _, e := range trace.Events {
+		event := traceEventOutput{
+			Type:   string(e.Type),
+			Name:   e.Name,
+			Target: e.Target,
+			Addrs:  addrStrings(e.Addrs),
+		}
+
+		if e.Type == resolver.TraceEventQuery {
+			event.QType = dns.TypeToString[e.QType]
+			event.Server = e.Server.String()
+			event.Transport = string(e.Transport)
+			if e.Rcode >= 0 {
+				event.Rcode = dns.RcodeToString[e.Rcode]
+			}
+			event.RTT = e.RTT.String()
+			if e.Err != nil {
+				event.Error = e.Err.Error()
+			}
+		}
+
+		output.Events = append(output.Events, event)
+	}
+
+	return output
+}
+
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func rrStrings(rrs []dns.RR) []string {
+	var s []string
+	for _, rr := range rrs {
+		s = append(s, rr.String())
+	}
+	return s
+}
+
+func addrStrings(addrs []netip.Addr) []string {
+	var s []string
+	for _, addr := range addrs {
+		s = append(s, addr.String())
+	}
+	return s
+}
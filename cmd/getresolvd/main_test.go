@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+
+			q := req.Question[0]
+			switch q.Qtype {
+			case dns.TypeA:
+				rr, _ := dns.NewRR(q.Name + " 300 IN A 192.0.2.1")
+				reply.Answer = []dns.RR{rr}
+			case dns.TypeTXT:
+				rr, _ := dns.NewRR(q.Name + " 300 IN TXT \"hello\"")
+				reply.Answer = []dns.RR{rr}
+			}
+
+			_ = w.WriteMsg(reply)
+		}),
+	}
+	go func() {
+		_ = srv.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	server := "@" + pc.LocalAddr().String()
+
+	t.Run("Query", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, run([]string{server, "example", "txt"}, &out))
+
+		require.Contains(t, out.String(), "\"hello\"")
+		require.Contains(t, out.String(), ";; Query time:")
+	})
+
+	t.Run("Query JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, run([]string{"-json", server, "example"}, &out))
+
+		var output queryOutput
+		require.NoError(t, json.Unmarshal(out.Bytes(), &output))
+
+		require.Equal(t, "NOERROR", output.Rcode)
+		require.Len(t, output.Answer, 1)
+		require.Contains(t, output.Answer[0], "192.0.2.1")
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, run([]string{"-lookup", server, "www.example.", "A"}, &out))
+
+		require.Contains(t, out.String(), "192.0.2.1\tdns @"+pc.LocalAddr().String())
+	})
+
+	t.Run("Trace JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, run([]string{"-trace", "-json", server, "www.example.", "A"}, &out))
+
+		var output traceOutput
+		require.NoError(t, json.Unmarshal(out.Bytes(), &output))
+
+		require.Equal(t, []string{"192.0.2.1"}, output.Addrs)
+		require.Equal(t, "query", output.Events[0].Type)
+	})
+
+	t.Run("Unknown Type", func(t *testing.T) {
+		require.Error(t, run([]string{server, "example", "BOGUS"}, &bytes.Buffer{}))
+	})
+}
@@ -0,0 +1,459 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/noisysockets/resolver/blocklist"
+	"github.com/noisysockets/util/ptr"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a declarative description of a resolver chain, that can be
+// loaded from a YAML (or JSON) document and built using FromConfig.
+type Config struct {
+	// Servers are the upstream DNS servers.
+	Servers []ServerConfig `yaml:"servers,omitempty" json:"servers,omitempty"`
+	// Strategy is how the servers are queried, one of "sequential" (the
+	// default), "round-robin", or "parallel".
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	// Routes send lookups for specific domains to other servers (eg. split
+	// DNS). Lookups that don't match a route use Servers.
+	Routes []RouteConfig `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// Hosts configures resolving names using a hosts file, it is disabled if
+	// not set.
+	Hosts *HostsConfig `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	// Search is the list of search domains appended to relative names.
+	Search []string `yaml:"search,omitempty" json:"search,omitempty"`
+	// NDots is the number of dots a name must have to be tried as an absolute
+	// name before the search domains are appended.
+	NDots *int `yaml:"ndots,omitempty" json:"ndots,omitempty"`
+	// Attempts is the number of times lookups are attempted before failing.
+	Attempts *int `yaml:"attempts,omitempty" json:"attempts,omitempty"`
+	// Cache configures caching of answers, it is disabled if not set.
+	Cache *CacheConfig `yaml:"cache,omitempty" json:"cache,omitempty"`
+	// Filters configures filtering of names and answers.
+	Filters *FiltersConfig `yaml:"filters,omitempty" json:"filters,omitempty"`
+	// AddressFamily orders or filters answers by address family, one of
+	// "prefer-ipv4", "prefer-ipv6", "ipv4-only", or "ipv6-only".
+	AddressFamily string `yaml:"addressFamily,omitempty" json:"addressFamily,omitempty"`
+}
+
+// ServerConfig is the configuration of an upstream DNS server.
+type ServerConfig struct {
+	// Address is the IP address (and optional port) of the server.
+	Address string `yaml:"address" json:"address"`
+	// Transport is the transport protocol, one of "udp" (the default), "tcp",
+	// "tls", or "https".
+	Transport string `yaml:"transport,omitempty" json:"transport,omitempty"`
+	// TLSServerName is the name used to verify the server's certificate.
+	TLSServerName string `yaml:"tlsServerName,omitempty" json:"tlsServerName,omitempty"`
+	// SPKIPins are the SPKI pins the server's certificate must match.
+	SPKIPins []string `yaml:"spkiPins,omitempty" json:"spkiPins,omitempty"`
+	// PrivacyProfile is the DNS privacy usage profile, one of "strict" or
+	// "opportunistic".
+	PrivacyProfile string `yaml:"privacyProfile,omitempty" json:"privacyProfile,omitempty"`
+	// Timeout is the maximum duration to wait for a query to complete.
+	Timeout Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Interface is the optional name of the network interface the server is
+	// reached through. Binding to interfaces is only supported on Linux and
+	// macOS, on other platforms the configuration is rejected.
+	Interface string `yaml:"interface,omitempty" json:"interface,omitempty"`
+}
+
+// RouteConfig is the configuration of a route.
+type RouteConfig struct {
+	// Domains is the list of domains (and their subdomains) matched by the
+	// route.
+	Domains []string `yaml:"domains" json:"domains"`
+	// Servers are the DNS servers used for lookups matching the route.
+	Servers []ServerConfig `yaml:"servers" json:"servers"`
+	// Strategy is how the servers are queried (see Config.Strategy).
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	// Search is the list of search domains appended to relative names
+	// before they are routed (eg. the corp search list for a VPN route).
+	Search []string `yaml:"search,omitempty" json:"search,omitempty"`
+	// NDots is the number of dots a name must have to not have the route's
+	// search domains appended.
+	NDots *int `yaml:"ndots,omitempty" json:"ndots,omitempty"`
+}
+
+// HostsConfig is the configuration of hosts file resolution.
+type HostsConfig struct {
+	// Path is the path to the hosts file, by default the system's hosts file
+	// is used.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// CacheConfig is the configuration of the cache.
+type CacheConfig struct {
+	// MinTTL is the minimum duration an answer will be cached for.
+	MinTTL Duration `yaml:"minTTL,omitempty" json:"minTTL,omitempty"`
+	// MaxTTL is the maximum duration an answer will be cached for.
+	MaxTTL Duration `yaml:"maxTTL,omitempty" json:"maxTTL,omitempty"`
+	// MaxEntries is the maximum number of answers that will be cached.
+	MaxEntries int `yaml:"maxEntries,omitempty" json:"maxEntries,omitempty"`
+}
+
+// FiltersConfig is the configuration of name and answer filtering.
+type FiltersConfig struct {
+	// SpecialUse enables handling of special-use domain names (RFC 6761).
+	SpecialUse bool `yaml:"specialUse,omitempty" json:"specialUse,omitempty"`
+	// Rebinding enables DNS rebinding protection, rejecting internal
+	// addresses in answers for names outside of the internal zones.
+	Rebinding bool `yaml:"rebinding,omitempty" json:"rebinding,omitempty"`
+	// InternalZones are the zones allowed to resolve to internal addresses.
+	InternalZones []string `yaml:"internalZones,omitempty" json:"internalZones,omitempty"`
+	// ExcludeScopes are the scopes of addresses removed from answers for names
+	// outside of the internal zones, any of "loopback", "link-local",
+	// "unique-local", or "unroutable".
+	ExcludeScopes []string `yaml:"excludeScopes,omitempty" json:"excludeScopes,omitempty"`
+	// StrictHostnames rejects hostnames that don't conform to the IDNA2008
+	// label rules (see ValidateIDNA2008).
+	StrictHostnames bool `yaml:"strictHostnames,omitempty" json:"strictHostnames,omitempty"`
+	// Blocklists are the blocklists lookups are checked against, blocked names
+	// are answered with NXDOMAIN.
+	Blocklists []BlocklistConfig `yaml:"blocklists,omitempty" json:"blocklists,omitempty"`
+	// BlocklistRefresh is the interval at which the blocklists are reloaded.
+	// Defaults to 24 hours.
+	BlocklistRefresh Duration `yaml:"blocklistRefresh,omitempty" json:"blocklistRefresh,omitempty"`
+}
+
+// BlocklistConfig is the configuration of a blocklist.
+type BlocklistConfig struct {
+	// URL is the location of the blocklist, "http", "https", and "file" URLs
+	// are supported.
+	URL string `yaml:"url" json:"url"`
+	// Format is the format of the blocklist, one of "hosts", "domains", or
+	// "adblock".
+	Format string `yaml:"format" json:"format"`
+}
+
+// Duration is a time.Duration that is represented as a string (eg. "5s") in
+// configuration documents.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// UnmarshalConfig parses a YAML (or JSON) configuration document. Unknown
+// fields are rejected.
+func UnmarshalConfig(data []byte) (*Config, error) {
+	var conf Config
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&conf); err != nil {
+		return nil, fmt.Errorf("failed to parse resolver config: %w", err)
+	}
+
+	return &conf, nil
+}
+
+// MarshalConfig returns the YAML representation of a configuration.
+func MarshalConfig(conf *Config) ([]byte, error) {
+	return yaml.Marshal(conf)
+}
+
+// FromConfig builds a resolver chain from a configuration. IP literals are
+// always resolved, followed by the hosts file (if enabled), then the
+// configured servers and routes.
+func FromConfig(conf *Config) (_ Resolver, err error) {
+	if err := validateConfig(conf); err != nil {
+		return nil, err
+	}
+
+	// Everything created so far is closed if a later step fails (eg. to stop
+	// the blocklist refresh goroutine).
+	var resolver Resolver
+	var pending []Resolver
+	defer func() {
+		if err != nil {
+			_ = closeAll(append(pending, resolver))
+		}
+	}()
+
+	if len(conf.Servers) > 0 {
+		resolver, err = serversFromConfig(conf.Servers, conf.Strategy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(conf.Routes) > 0 {
+		routes := make([]Route, 0, len(conf.Routes))
+		for i, routeConf := range conf.Routes {
+			routeResolver, err := serversFromConfig(routeConf.Servers, routeConf.Strategy)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: %w", i, err)
+			}
+			pending = append(pending, routeResolver)
+
+			routes = append(routes, Route{
+				Domains:  routeConf.Domains,
+				Resolver: routeResolver,
+				Search:   routeConf.Search,
+				NDots:    routeConf.NDots,
+			})
+		}
+
+		resolver = Routing(&RoutingResolverConfig{
+			Routes:  routes,
+			Default: resolver,
+		})
+		pending = nil
+	}
+
+	if conf.Filters != nil && conf.Filters.Rebinding {
+		resolver = Rebinding(resolver, &RebindingResolverConfig{
+			InternalZones: conf.Filters.InternalZones,
+		})
+	}
+
+	if conf.Filters != nil && len(conf.Filters.ExcludeScopes) > 0 {
+		exclude := make([]AddrScope, 0, len(conf.Filters.ExcludeScopes))
+		for _, scope := range conf.Filters.ExcludeScopes {
+			exclude = append(exclude, AddrScope(scope))
+		}
+
+		resolver = ScopeFilter(resolver, &ScopeFilterResolverConfig{
+			Exclude:       exclude,
+			InternalZones: conf.Filters.InternalZones,
+		})
+	}
+
+	if conf.Attempts != nil {
+		resolver = Retry(resolver, &RetryResolverConfig{
+			Attempts: conf.Attempts,
+		})
+	}
+
+	if conf.Cache != nil {
+		cacheConf := &CacheResolverConfig{}
+		if conf.Cache.MinTTL != 0 {
+			cacheConf.MinTTL = (*time.Duration)(&conf.Cache.MinTTL)
+		}
+		if conf.Cache.MaxTTL != 0 {
+			cacheConf.MaxTTL = (*time.Duration)(&conf.Cache.MaxTTL)
+		}
+		if conf.Cache.MaxEntries != 0 {
+			cacheConf.MaxEntries = &conf.Cache.MaxEntries
+		}
+
+		resolver = Cache(resolver, cacheConf)
+	}
+
+	if conf.Filters != nil && len(conf.Filters.Blocklists) > 0 {
+		sources := make([]blocklist.Source, 0, len(conf.Filters.Blocklists))
+		for _, blocklistConf := range conf.Filters.Blocklists {
+			sources = append(sources, blocklist.Source{
+				URL:    blocklistConf.URL,
+				Format: blocklist.Format(blocklistConf.Format),
+			})
+		}
+
+		loaderConf := &blocklist.LoaderConfig{Sources: sources}
+		if conf.Filters.BlocklistRefresh != 0 {
+			loaderConf.RefreshInterval = (*time.Duration)(&conf.Filters.BlocklistRefresh)
+		}
+
+		loader, err := blocklist.NewLoader(context.Background(), loaderConf)
+		if err != nil {
+			return nil, err
+		}
+
+		resolver = Blocklist(resolver, &BlocklistResolverConfig{
+			Matcher: loader,
+		})
+	}
+
+	if len(conf.Search) > 0 {
+		resolver = Relative(resolver, &RelativeResolverConfig{
+			Search: conf.Search,
+			NDots:  conf.NDots,
+		})
+	}
+
+	if conf.Filters != nil && conf.Filters.SpecialUse {
+		resolver = SpecialUse(resolver, nil)
+	}
+
+	resolvers := []Resolver{Literal()}
+
+	if conf.Hosts != nil {
+		hostsConf := &HostsResolverConfig{}
+		if conf.Hosts.Path != "" {
+			f, err := os.Open(conf.Hosts.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open hosts file %q: %w", conf.Hosts.Path, err)
+			}
+			defer f.Close()
+
+			hostsConf.HostsFileReader = f
+		}
+
+		hostsResolver, err := Hosts(hostsConf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create hosts file resolver: %w", err)
+		}
+
+		resolvers = append(resolvers, hostsResolver)
+	}
+
+	resolver = Sequential(append(resolvers, resolver)...)
+
+	if conf.Filters != nil && conf.Filters.StrictHostnames {
+		resolver = Validating(resolver, &ValidatingResolverConfig{
+			Validators: []HostValidatorFunc{ValidateIDNA2008},
+		})
+	}
+
+	if policy := FamilyPolicy(conf.AddressFamily); policy != "" {
+		resolver = Family(resolver, &FamilyResolverConfig{Policy: policy})
+	}
+
+	return resolver, nil
+}
+
+// validateConfig checks the parts of the configuration that can be checked
+// before any resolvers (with their background goroutines, connections etc.)
+// are created.
+func validateConfig(conf *Config) error {
+	if len(conf.Servers) == 0 && len(conf.Routes) == 0 {
+		return errors.New("no servers or routes configured")
+	}
+
+	for i, routeConf := range conf.Routes {
+		if len(routeConf.Domains) == 0 {
+			return fmt.Errorf("route %d has no domains", i)
+		}
+	}
+
+	if conf.Filters != nil {
+		for _, scope := range conf.Filters.ExcludeScopes {
+			switch AddrScope(scope) {
+			case AddrScopeLoopback, AddrScopeLinkLocal, AddrScopeUniqueLocal, AddrScopeUnroutable:
+			default:
+				return fmt.Errorf("unknown address scope %q", scope)
+			}
+		}
+	}
+
+	switch FamilyPolicy(conf.AddressFamily) {
+	case "", FamilyPreferIPv4, FamilyPreferIPv6, FamilyIPv4Only, FamilyIPv6Only:
+	default:
+		return fmt.Errorf("unknown address family policy %q", conf.AddressFamily)
+	}
+
+	return nil
+}
+
+func serversFromConfig(serverConfs []ServerConfig, strategy string) (Resolver, error) {
+	if len(serverConfs) == 0 {
+		return nil, errors.New("no servers configured")
+	}
+
+	resolvers := make([]Resolver, 0, len(serverConfs))
+	for _, serverConf := range serverConfs {
+		resolver, err := serverFromConfig(serverConf)
+		if err != nil {
+			_ = closeAll(resolvers)
+			return nil, fmt.Errorf("server %q: %w", serverConf.Address, err)
+		}
+
+		resolvers = append(resolvers, resolver)
+	}
+
+	switch strategy {
+	case "", "sequential":
+		return Sequential(resolvers...), nil
+	case "round-robin":
+		return RoundRobin(resolvers...), nil
+	case "parallel":
+		return Parallel(resolvers...), nil
+	default:
+		_ = closeAll(resolvers)
+		return nil, fmt.Errorf("unknown strategy %q", strategy)
+	}
+}
+
+func serverFromConfig(serverConf ServerConfig) (Resolver, error) {
+	server, err := netip.ParseAddrPort(serverConf.Address)
+	if err != nil {
+		addr, err := netip.ParseAddr(serverConf.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address: %w", err)
+		}
+		server = netip.AddrPortFrom(addr, 0)
+	}
+
+	conf := DNSResolverConfig{
+		Server:   server,
+		SPKIPins: serverConf.SPKIPins,
+	}
+
+	switch serverConf.Transport {
+	case "":
+	case "udp":
+		conf.Transport = ptr.To(DNSTransportUDP)
+	case "tcp":
+		conf.Transport = ptr.To(DNSTransportTCP)
+	case "tls":
+		conf.Transport = ptr.To(DNSTransportTLS)
+	case "https":
+		conf.Transport = ptr.To(DNSTransportHTTPS)
+	default:
+		return nil, fmt.Errorf("unknown transport %q", serverConf.Transport)
+	}
+
+	if serverConf.TLSServerName != "" {
+		conf.TLSConfig = &tls.Config{ServerName: serverConf.TLSServerName}
+	}
+
+	switch PrivacyProfile(serverConf.PrivacyProfile) {
+	case "":
+	case PrivacyProfileStrict, PrivacyProfileOpportunistic:
+		conf.PrivacyProfile = ptr.To(PrivacyProfile(serverConf.PrivacyProfile))
+	default:
+		return nil, fmt.Errorf("unknown privacy profile %q", serverConf.PrivacyProfile)
+	}
+
+	if serverConf.Timeout != 0 {
+		conf.Timeout = ptr.To(time.Duration(serverConf.Timeout))
+	}
+
+	if serverConf.Interface != "" {
+		if bindToInterface(serverConf.Interface) == nil {
+			return nil, fmt.Errorf("interface %q: %w", serverConf.Interface, errInterfaceBindingUnsupported)
+		}
+		conf.DialContext = DialFromInterface(serverConf.Interface)
+	}
+
+	return DNS(conf), nil
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	newServer := func(addr string) netip.AddrPort {
+		return startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			if req.Question[0].Qtype == dns.TypeA {
+				reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A "+addr)}
+			}
+
+			_ = w.WriteMsg(reply)
+		}))
+	}
+
+	publicServer := newServer("192.0.2.1")
+	corpServer := newServer("10.0.0.1")
+
+	hostsFilePath := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(hostsFilePath, []byte("192.0.2.2 local.example\n"), 0o644))
+
+	doc := fmt.Sprintf(`
+servers:
+  - address: %s
+    transport: tcp
+    timeout: 2s
+routes:
+  - domains: [corp.example]
+    servers:
+      - address: %s
+hosts:
+  path: %s
+search: [corp.example]
+attempts: 2
+cache:
+  maxTTL: 1h
+filters:
+  specialUse: true
+  rebinding: true
+  internalZones: [corp.example]
+`, publicServer, corpServer, hostsFilePath)
+
+	conf, err := resolver.UnmarshalConfig([]byte(doc))
+	require.NoError(t, err)
+
+	require.Equal(t, resolver.Duration(2*time.Second), conf.Servers[0].Timeout)
+	require.Equal(t, resolver.Duration(time.Hour), conf.Cache.MaxTTL)
+
+	res, err := resolver.FromConfig(conf)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	addrs, err := res.LookupNetIP(ctx, "ip4", "www.example.")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+
+	// Routed, and allowed to resolve to internal addresses.
+	addrs, err = res.LookupNetIP(ctx, "ip4", "intranet")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+
+	addrs, err = res.LookupNetIP(ctx, "ip4", "local.example")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.2")}, addrs)
+
+	t.Run("Round Trip", func(t *testing.T) {
+		data, err := resolver.MarshalConfig(conf)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "timeout: 2s")
+
+		roundTripped, err := resolver.UnmarshalConfig(data)
+		require.NoError(t, err)
+		require.Equal(t, conf, roundTripped)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		conf, err := resolver.UnmarshalConfig([]byte(`{"servers": [{"address": "192.0.2.53", "transport": "tls"}], "ndots": 2}`))
+		require.NoError(t, err)
+
+		require.Equal(t, &resolver.Config{
+			Servers: []resolver.ServerConfig{{Address: "192.0.2.53", Transport: "tls"}},
+			NDots:   ptr.To(2),
+		}, conf)
+	})
+
+	t.Run("Blocklists", func(t *testing.T) {
+		blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+		require.NoError(t, os.WriteFile(blocklistPath, []byte("0.0.0.0 ads.example\n"), 0o644))
+
+		res, err := resolver.FromConfig(&resolver.Config{
+			Servers: []resolver.ServerConfig{{Address: publicServer.String()}},
+			Filters: &resolver.FiltersConfig{
+				Blocklists: []resolver.BlocklistConfig{
+					{URL: "file://" + filepath.ToSlash(blocklistPath), Format: "hosts"},
+				},
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, resolver.Close(res))
+		})
+
+		_, err = res.LookupNetIP(ctx, "ip4", "www.ads.example")
+		require.True(t, resolver.IsNXDomain(err))
+
+		addrs, err := res.LookupNetIP(ctx, "ip4", "www.example.")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Strict Hostnames", func(t *testing.T) {
+		res, err := resolver.FromConfig(&resolver.Config{
+			Servers: []resolver.ServerConfig{{Address: publicServer.String()}},
+			Filters: &resolver.FiltersConfig{StrictHostnames: true},
+		})
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "www_example.")
+		require.ErrorIs(t, err, resolver.ErrInvalidHostname)
+
+		addrs, err := res.LookupNetIP(ctx, "ip4", "www.example.")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := resolver.UnmarshalConfig([]byte("servrs: []"))
+		require.Error(t, err)
+
+		_, err = resolver.FromConfig(&resolver.Config{})
+		require.Error(t, err)
+
+		_, err = resolver.FromConfig(&resolver.Config{
+			Servers: []resolver.ServerConfig{{Address: "192.0.2.53", Transport: "carrier-pigeon"}},
+		})
+		require.Error(t, err)
+
+		_, err = resolver.FromConfig(&resolver.Config{
+			Servers:  []resolver.ServerConfig{{Address: "192.0.2.53"}},
+			Strategy: "random",
+		})
+		require.Error(t, err)
+
+		_, err = resolver.FromConfig(&resolver.Config{
+			Servers:       []resolver.ServerConfig{{Address: "192.0.2.53"}},
+			AddressFamily: "ipv5-only",
+		})
+		require.Error(t, err)
+
+		_, err = resolver.FromConfig(&resolver.Config{
+			Servers: []resolver.ServerConfig{{Address: "192.0.2.53"}},
+			Filters: &resolver.FiltersConfig{ExcludeScopes: []string{"galactic"}},
+		})
+		require.Error(t, err)
+
+		// The configuration is validated before any blocklists are loaded.
+		_, err = resolver.FromConfig(&resolver.Config{
+			Servers:       []resolver.ServerConfig{{Address: "192.0.2.53"}},
+			AddressFamily: "ipv5-only",
+			Filters: &resolver.FiltersConfig{
+				Blocklists: []resolver.BlocklistConfig{{URL: "file:///nonexistent/blocklist.txt", Format: "hosts"}},
+			},
+		})
+		require.ErrorContains(t, err, "unknown address family policy")
+
+		if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+			_, err = resolver.FromConfig(&resolver.Config{
+				Servers: []resolver.ServerConfig{{Address: "192.0.2.53", Interface: "wg0"}},
+			})
+			require.ErrorContains(t, err, "not supported")
+		}
+	})
+}
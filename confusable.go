@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+var _ HostValidatorFunc = DetectConfusables
+
+// ErrConfusable is returned when a hostname contains visually confusable
+// characters from multiple scripts (eg. an IDN homograph attack).
+var ErrConfusable = errors.New("hostname contains mixed-script confusables")
+
+// ConfusableError describes a hostname label that mixes scripts.
+type ConfusableError struct {
+	// Host is the hostname that was validated.
+	Host string
+	// Label is the offending label (in Unicode form).
+	Label string
+	// Scripts is the set of scripts found in the label.
+	Scripts []string
+}
+
+func (e *ConfusableError) Error() string {
+	return fmt.Sprintf("%s: label %q mixes scripts %s", ErrConfusable, e.Label, strings.Join(e.Scripts, ", "))
+}
+
+func (e *ConfusableError) Is(target error) bool {
+	return target == ErrConfusable
+}
+
+// scripts that are checked for mixing, characters in the Common and Inherited
+// scripts (eg. digits and hyphens) are compatible with all scripts.
+var scripts = []string{
+	"Latin", "Cyrillic", "Greek", "Armenian", "Georgian", "Cherokee", "Hebrew",
+	"Arabic", "Devanagari", "Thai", "Han", "Hiragana", "Katakana", "Hangul",
+	"Bopomofo",
+}
+
+// allowedScriptCombinations are the multi-script combinations that are in
+// common legitimate use, as described by the "Highly Restrictive" profile of
+// Unicode Technical Standard #39.
+var allowedScriptCombinations = [][]string{
+	{"Han", "Hiragana", "Katakana", "Latin"},
+	{"Bopomofo", "Han", "Latin"},
+	{"Han", "Hangul", "Latin"},
+}
+
+// DetectConfusables is a HostValidatorFunc that rejects hostnames that have
+// labels mixing characters from multiple scripts (eg. a Cyrillic "а" within an
+// otherwise Latin label). Punycode encoded labels are decoded before checking.
+// The returned error is a *ConfusableError.
+func DetectConfusables(host string) error {
+	for _, label := range dns.SplitDomainName(host) {
+		if unicodeLabel, err := idna.ToUnicode(label); err == nil {
+			label = unicodeLabel
+		}
+
+		labelScripts := scriptsOf(label)
+		if len(labelScripts) <= 1 || isAllowedScriptCombination(labelScripts) {
+			continue
+		}
+
+		return &ConfusableError{
+			Host:    host,
+			Label:   label,
+			Scripts: labelScripts,
+		}
+	}
+
+	return nil
+}
+
+func scriptsOf(label string) []string {
+	var found []string
+	for _, c := range label {
+		if c < unicode.MaxASCII && !unicode.IsLetter(c) {
+			continue
+		}
+
+		for _, script := range scripts {
+			if unicode.Is(unicode.Scripts[script], c) && !slices.Contains(found, script) {
+				found = append(found, script)
+			}
+		}
+	}
+	slices.Sort(found)
+	return found
+}
+
+func isAllowedScriptCombination(labelScripts []string) bool {
+	for _, allowed := range allowedScriptCombinations {
+		subset := true
+		for _, script := range labelScripts {
+			if !slices.Contains(allowed, script) {
+				subset = false
+				break
+			}
+		}
+
+		if subset {
+			return true
+		}
+	}
+	return false
+}
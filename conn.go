@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"runtime"
+	"slices"
+)
+
+// dialFromLocalAddr returns a dialer that binds connections to the given local
+// address. The local port is only used for UDP connections, as reusing TCP
+// ports is prone to failure (eg. due to TIME_WAIT).
+func dialFromLocalAddr(localAddr netip.AddrPort) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		switch network {
+		case "udp", "udp4", "udp6":
+			d.LocalAddr = net.UDPAddrFromAddrPort(localAddr)
+		case "tcp", "tcp4", "tcp6":
+			d.LocalAddr = net.TCPAddrFromAddrPort(netip.AddrPortFrom(localAddr.Addr(), 0))
+		}
+
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+// DialByServer returns a dialer that reaches each DNS server using the dial
+// function of the longest prefix containing the server's address, eg. so
+// that one server is reached via a VPN tunnel's userspace dialer while others
+// use the host network. Servers that don't match any prefix are dialed using
+// fallback (or a net.Dialer if nil). Use a full length prefix (eg.
+// netip.PrefixFrom(addr, addr.BitLen())) to match a single server.
+func DialByServer(dials map[netip.Prefix]DialContextFunc, fallback DialContextFunc) DialContextFunc {
+	prefixes := make([]netip.Prefix, 0, len(dials))
+	for prefix := range dials {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int {
+		return cmp.Compare(b.Bits(), a.Bits())
+	})
+
+	fallback = dialOrDefault(fallback)
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if server, err := netip.ParseAddrPort(address); err == nil {
+			addr := server.Addr().Unmap()
+			for _, prefix := range prefixes {
+				if prefix.Contains(addr) {
+					return dials[prefix](ctx, network, address)
+				}
+			}
+		}
+
+		return fallback(ctx, network, address)
+	}
+}
+
+// errInterfaceBindingUnsupported is returned when sockets can't be bound to a
+// network interface on this platform.
+var errInterfaceBindingUnsupported = fmt.Errorf("binding to interfaces is not supported on %s", runtime.GOOS)
+
+// DialFromInterface returns a dialer that binds sockets to the named network
+// interface. Binding is only supported on Linux and macOS, on other platforms
+// every dial fails (rather than silently following the system's routing
+// table).
+func DialFromInterface(name string) DialContextFunc {
+	control := bindToInterface(name)
+	if control == nil {
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, fmt.Errorf("interface %q: %w", name, errInterfaceBindingUnsupported)
+		}
+	}
+
+	return (&net.Dialer{
+		Control: control,
+	}).DialContext
+}
+
+// peerConn is a packet oriented connection that discards any datagrams that
+// do not originate from the expected peer. This protects against off-path
+// spoofing when the dialer returns an unconnected socket.
+type peerConn struct {
+	net.Conn
+	pc   net.PacketConn
+	peer netip.AddrPort
+}
+
+// newPeerConn wraps conn so that only datagrams from peer are read, conn
+// must implement net.PacketConn.
+func newPeerConn(conn net.Conn, peer netip.AddrPort) net.Conn {
+	pc, ok := conn.(net.PacketConn)
+	if !ok {
+		return conn
+	}
+
+	return &peerConn{
+		Conn: conn,
+		pc:   pc,
+		peer: peer,
+	}
+}
+
+func (c *peerConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+	return n, err
+}
+
+func (c *peerConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.pc.ReadFrom(b)
+		if err != nil {
+			return n, addr, err
+		}
+
+		if isAddr(addr, c.peer) {
+			return n, addr, nil
+		}
+	}
+}
+
+func (c *peerConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.pc.WriteTo(b, addr)
+}
+
+func isAddr(addr net.Addr, expected netip.AddrPort) bool {
+	var addrPort netip.AddrPort
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		addrPort = addr.AddrPort()
+	default:
+		var err error
+		addrPort, err = netip.ParseAddrPort(addr.String())
+		if err != nil {
+			return false
+		}
+	}
+
+	return addrPort.Addr().Unmap().WithZone("") == expected.Addr().Unmap().WithZone("") &&
+		addrPort.Port() == expected.Port()
+}
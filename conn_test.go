@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialByServer(t *testing.T) {
+	upstream := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.1"},
+	})
+	t.Cleanup(upstream.Close)
+
+	var tunnelDials []string
+	tunnel := func(ctx context.Context, network, address string) (net.Conn, error) {
+		tunnelDials = append(tunnelDials, address)
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	errUnreachable := errors.New("unreachable")
+	unreachable := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errUnreachable
+	}
+
+	dial := resolver.DialByServer(map[netip.Prefix]resolver.DialContextFunc{
+		netip.MustParsePrefix("127.0.0.0/8"):       unreachable,
+		netip.PrefixFrom(upstream.Addr.Addr(), 32): tunnel,
+	}, unreachable)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:      upstream.Addr,
+		DialContext: dial,
+	})
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	require.Equal(t, []string{upstream.Addr.String()}, tunnelDials)
+
+	// Other servers use the fallback.
+	_, err = dial(context.Background(), "udp", "192.0.2.53:53")
+	require.ErrorIs(t, err, errUnreachable)
+
+	_, err = dial(context.Background(), "udp", "127.0.0.2:53")
+	require.ErrorIs(t, err, errUnreachable)
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var (
+	_ Resolver    = (*ConsulResolver)(nil)
+	_ SRVResolver = (*ConsulResolver)(nil)
+)
+
+// DefaultConsulAgent is the default address of the DNS interface of a local
+// Consul agent.
+var DefaultConsulAgent = netip.MustParseAddrPort("127.0.0.1:8600")
+
+// ConsulResolverConfig is the configuration for a Consul resolver.
+type ConsulResolverConfig struct {
+	// Agent is the address of the DNS interface of the Consul agent.
+	// Defaults to DefaultConsulAgent.
+	Agent *netip.AddrPort
+	// Domain is the domain served by Consul. Defaults to "consul".
+	Domain string
+	// Default is the resolver used for all other names. Defaults to the
+	// system resolver.
+	Default Resolver
+	// DialContext is used to establish a connection to the Consul agent.
+	DialContext DialContextFunc
+	// Logger is an optional logger, queries are logged at debug level.
+	Logger *slog.Logger
+}
+
+// ConsulResolver is a resolver that sends lookups for names within the
+// Consul domain to a Consul agent, and all other lookups to a default
+// resolver.
+type ConsulResolver struct {
+	*routingResolver
+	agent  *dnsResolver
+	domain string
+}
+
+// Consul returns a resolver that sends lookups for names within the Consul
+// domain (eg. "web.service.consul") to a Consul agent, and all other lookups
+// to the default resolver.
+func Consul(conf *ConsulResolverConfig) (*ConsulResolver, error) {
+	conf, err := defaults.WithDefaults(conf, &ConsulResolverConfig{
+		Agent:       ptr.To(DefaultConsulAgent),
+		Domain:      "consul",
+		DialContext: (&net.Dialer{}).DialContext,
+		Logger:      discardLogger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to consul resolver config: %w", err)
+	}
+
+	if conf.Default == nil {
+		conf.Default, err = System(&SystemResolverConfig{
+			DialContext: conf.DialContext,
+			Logger:      conf.Logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	domain := dns.CanonicalName(conf.Domain)
+
+	agent := DNS(DNSResolverConfig{
+		Server:      *conf.Agent,
+		DialContext: conf.DialContext,
+		Logger:      conf.Logger,
+	})
+
+	return &ConsulResolver{
+		routingResolver: Routing(&RoutingResolverConfig{
+			Routes: []Route{{
+				Domains:  []string{domain},
+				Resolver: agent,
+			}},
+			Default: conf.Default,
+		}),
+		agent:  agent,
+		domain: domain,
+	}, nil
+}
+
+// LookupSRV returns the SRV records for the given service, protocol and
+// domain. Only names within the Consul domain are supported, as with
+// net.Resolver, if service and proto are both empty, name is looked up
+// directly (eg. "web.service.consul").
+func (r *ConsulResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if !dns.IsSubDomain(r.domain, dns.CanonicalName(name)) {
+		return "", nil, newError(name, "", fmt.Errorf("not within %s: %w", r.domain, ErrNoSuchHost))
+	}
+
+	return r.agent.LookupSRV(ctx, service, proto, name)
+}
+
+// LookupService returns the addresses and ports of the healthy instances of
+// a Consul service (optionally filtered by tag), in the order returned by
+// the agent.
+func (r *ConsulResolver) LookupService(ctx context.Context, service, tag string) ([]netip.AddrPort, error) {
+	name := service + ".service." + r.domain
+	if tag != "" {
+		name = tag + "." + name
+	}
+
+	_, srvs, err := r.agent.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrPorts []netip.AddrPort
+	var errs []error
+	for _, srv := range srvs {
+		// Targets (eg. "node.node.dc1.consul." or "<hex>.addr.dc1.consul.")
+		// are resolved by the agent.
+		addrs, err := r.LookupNetIP(ctx, "ip", srv.Target)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, addr := range addrs {
+			addrPorts = append(addrPorts, netip.AddrPortFrom(addr, srv.Port))
+		}
+	}
+
+	if len(addrPorts) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return addrPorts, nil
+}
+
+func (r *ConsulResolver) describe() Description {
+	d := r.routingResolver.describe()
+	d.Type = "consul"
+	d.Attributes = map[string]string{"domain": strings.TrimSuffix(r.domain, ".")}
+	return d
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsul(t *testing.T) {
+	agent := dnstest.NewServer(dnstest.Records{
+		"web.service.consul":        {"A 10.0.0.1", "A 10.0.0.2", "SRV 1 1 8080 node1.node.dc1.consul.", "SRV 1 1 8081 node2.node.dc1.consul."},
+		"canary.web.service.consul": {"SRV 1 1 8081 node2.node.dc1.consul."},
+		"node1.node.dc1.consul":     {"A 10.0.0.1"},
+		"node2.node.dc1.consul":     {"A 10.0.0.2"},
+		"_web._tcp.service.consul":  {"SRV 1 1 8080 node1.node.dc1.consul."},
+	})
+	t.Cleanup(agent.Close)
+
+	upstream := new(dnstest.MockResolver)
+	upstream.On("LookupNetIP", mock.Anything, "ip4", "www.example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	res, err := resolver.Consul(&resolver.ConsulResolverConfig{
+		Agent:   &agent.Addr,
+		Default: upstream,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("LookupNetIP", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(ctx, "ip4", "web.service.consul")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")}, addrs)
+
+		addrs, err = res.LookupNetIP(ctx, "ip4", "www.example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("LookupSRV", func(t *testing.T) {
+		_, srvs, err := res.LookupSRV(ctx, "web", "tcp", "service.consul")
+		require.NoError(t, err)
+
+		require.Equal(t, []*net.SRV{{Target: "node1.node.dc1.consul.", Port: 8080, Priority: 1, Weight: 1}}, srvs)
+
+		_, _, err = res.LookupSRV(ctx, "http", "tcp", "example.com")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("LookupService", func(t *testing.T) {
+		addrPorts, err := res.LookupService(ctx, "web", "")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []netip.AddrPort{
+			netip.MustParseAddrPort("10.0.0.1:8080"),
+			netip.MustParseAddrPort("10.0.0.2:8081"),
+		}, addrPorts)
+
+		addrPorts, err = res.LookupService(ctx, "web", "canary")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.AddrPort{netip.MustParseAddrPort("10.0.0.2:8081")}, addrPorts)
+	})
+
+	t.Run("Describe", func(t *testing.T) {
+		require.Equal(t, "consul", resolver.Describe(res).Type)
+	})
+}
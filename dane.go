@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TLSA certificate usages, see RFC 7218 section 2.1.
+const (
+	tlsaUsagePKIXTA = 0
+	tlsaUsagePKIXEE = 1
+	tlsaUsageDANETA = 2
+	tlsaUsageDANEEE = 3
+)
+
+// LookupTLSA looks up the DNSSEC validated TLSA records for a service, the
+// protocol is typically "tcp" (RFC 6698 section 3).
+func (r *dnssecResolver) LookupTLSA(ctx context.Context, host string, port uint16, proto string) ([]*dns.TLSA, SecurityStatus, error) {
+	name, err := dns.TLSAName(dns.Fqdn(host), strconv.Itoa(int(port)), proto)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid TLSA service for %q: %w", host, err)
+	}
+	name = dns.CanonicalName(name)
+
+	reply, status, err := r.lookupSecure(ctx, name, dns.TypeTLSA)
+	if err != nil {
+		return nil, status, err
+	}
+
+	// Only records for the service name (or its aliases) apply.
+	names := aliasChain(reply.Answer, name)
+
+	var records []*dns.TLSA
+	for _, rr := range reply.Answer {
+		if tlsa, ok := rr.(*dns.TLSA); ok && names[dns.CanonicalName(tlsa.Hdr.Name)] {
+			records = append(records, tlsa)
+		}
+	}
+
+	return records, status, nil
+}
+
+// VerifyDANE verifies the certificate chain presented by a TLS server (leaf
+// first) against the TLSA records of the service, as described in RFC 6698
+// and RFC 7671. If the service has no secure TLSA records, an error wrapping
+// ErrDANENotApplicable is returned and callers should fall back to regular
+// PKIX validation.
+func (r *dnssecResolver) VerifyDANE(ctx context.Context, host string, port uint16, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("empty certificate chain: %w", ErrDANEMismatch)
+	}
+
+	records, status, err := r.LookupTLSA(ctx, host, port, "tcp")
+	if err != nil {
+		return err
+	}
+
+	if status != SecurityStatusSecure {
+		return fmt.Errorf("TLSA records are %s: %w", status, ErrDANENotApplicable)
+	}
+
+	var usable []*dns.TLSA
+	for _, tlsa := range records {
+		if tlsa.Usage <= tlsaUsageDANEEE && tlsa.Selector <= 1 && tlsa.MatchingType <= 2 {
+			usable = append(usable, tlsa)
+		}
+	}
+
+	if len(usable) == 0 {
+		return fmt.Errorf("no usable TLSA records for %s: %w", host, ErrDANENotApplicable)
+	}
+
+	hostname := strings.TrimSuffix(host, ".")
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	for _, tlsa := range usable {
+		switch tlsa.Usage {
+		case tlsaUsageDANEEE:
+			// No name checks or expiry checks are performed (RFC 7671 section 5.1).
+			if matchesTLSA(tlsa, chain[0]) {
+				return nil
+			}
+		case tlsaUsageDANETA:
+			for _, cert := range chain {
+				if !matchesTLSA(tlsa, cert) {
+					continue
+				}
+
+				roots := x509.NewCertPool()
+				roots.AddCert(cert)
+
+				if _, err := chain[0].Verify(x509.VerifyOptions{
+					DNSName:       hostname,
+					Roots:         roots,
+					Intermediates: intermediates,
+				}); err == nil {
+					return nil
+				}
+			}
+		case tlsaUsagePKIXEE, tlsaUsagePKIXTA:
+			verifiedChains, err := chain[0].Verify(x509.VerifyOptions{
+				DNSName:       hostname,
+				Intermediates: intermediates,
+			})
+			if err != nil {
+				continue
+			}
+
+			if tlsa.Usage == tlsaUsagePKIXEE {
+				if matchesTLSA(tlsa, chain[0]) {
+					return nil
+				}
+				continue
+			}
+
+			for _, verifiedChain := range verifiedChains {
+				for _, cert := range verifiedChain[1:] {
+					if matchesTLSA(tlsa, cert) {
+						return nil
+					}
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("certificate chain for %s: %w", host, ErrDANEMismatch)
+}
+
+func matchesTLSA(tlsa *dns.TLSA, cert *x509.Certificate) bool {
+	association, err := dns.CertificateToDANE(tlsa.Selector, tlsa.MatchingType, cert)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(association, tlsa.Certificate)
+}
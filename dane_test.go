@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDANE(t *testing.T) {
+	_, cert := newTestCertificate(t, "www.example")
+	_, otherCert := newTestCertificate(t, "www.example")
+
+	association, err := dns.CertificateToDANE(1, 1, cert)
+	require.NoError(t, err)
+
+	root := newSignedZone(t, ".")
+	example := newSignedZone(t, "example.")
+
+	tlsa := example.sign(t, mustRR(t, "_443._tcp.www.example. 300 IN TLSA 3 1 1 "+association))
+
+	server := startDenialServer(t, map[string]denialReply{
+		"_443._tcp.alias.example./TLSA": {answer: [][]dns.RR{
+			example.sign(t, mustRR(t, "_443._tcp.alias.example. 300 IN CNAME _443._tcp.www.example.")),
+			tlsa,
+		}},
+	},
+		root.sign(t, root.dnskey),
+		root.sign(t, example.dnskey.ToDS(dns.SHA256)),
+		example.sign(t, example.dnskey),
+		tlsa,
+		root.sign(t, mustRR(t, "insecure. 300 IN NSEC zzz. NS RRSIG NSEC")),
+		[]dns.RR{mustRR(t, "_443._tcp.www.insecure. 300 IN TLSA 3 1 1 "+association)},
+	)
+
+	res := resolver.DNSSEC(resolver.DNSSECResolverConfig{
+		Upstream: resolver.DNSResolverConfig{
+			Server: server,
+		},
+		TrustAnchors: []*dns.DS{root.dnskey.ToDS(dns.SHA256)},
+	})
+
+	t.Run("Match", func(t *testing.T) {
+		err := res.VerifyDANE(context.Background(), "www.example", 443, []*x509.Certificate{cert})
+		require.NoError(t, err)
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		err := res.VerifyDANE(context.Background(), "www.example", 443, []*x509.Certificate{otherCert})
+		require.ErrorIs(t, err, resolver.ErrDANEMismatch)
+	})
+
+	t.Run("Insecure", func(t *testing.T) {
+		err := res.VerifyDANE(context.Background(), "www.insecure", 443, []*x509.Certificate{cert})
+		require.ErrorIs(t, err, resolver.ErrDANENotApplicable)
+	})
+
+	t.Run("Alias", func(t *testing.T) {
+		records, status, err := res.LookupTLSA(context.Background(), "alias.example", 443, "tcp")
+		require.NoError(t, err)
+
+		require.Equal(t, resolver.SecurityStatusSecure, status)
+		require.Len(t, records, 1)
+		require.Equal(t, "_443._tcp.www.example.", records[0].Hdr.Name)
+	})
+
+	t.Run("Invalid Service", func(t *testing.T) {
+		_, _, err := res.LookupTLSA(context.Background(), "www.example", 443, "bogus")
+		require.Error(t, err)
+		require.NotErrorIs(t, err, resolver.ErrNoSuchHost)
+	})
+}
+
+// newTestCertificate returns a self-signed certificate for hostname.
+func newTestCertificate(t *testing.T, hostname string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hostname},
+		DNSNames:     []string{hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var _ Resolver = (*dedupResolver)(nil)
+
+// AddrOrder is how the addresses of an answer are ordered.
+type AddrOrder string
+
+const (
+	// AddrOrderFirstSeen keeps addresses in the order they were returned by
+	// the underlying resolver (eg. as sorted by RFC 6724).
+	AddrOrderFirstSeen AddrOrder = "first-seen"
+	// AddrOrderCanonical orders addresses numerically, IPv4 addresses before
+	// IPv6 addresses, so that equivalent answers (eg. from different servers)
+	// are always identical.
+	AddrOrderCanonical AddrOrder = "canonical"
+)
+
+// DedupResolverConfig is the configuration for a deduplicating resolver.
+type DedupResolverConfig struct {
+	// Order is how the deduplicated addresses are ordered. Defaults to
+	// AddrOrderFirstSeen.
+	Order *AddrOrder
+}
+
+// dedupResolver is a resolver that removes duplicate addresses from answers.
+type dedupResolver struct {
+	resolver Resolver
+	order    AddrOrder
+}
+
+// Dedup returns a resolver that removes duplicate addresses from the answers
+// of resolver (eg. when combining the answers of servers that overlap), and
+// orders them deterministically. IPv4-mapped IPv6 addresses are considered
+// to be duplicates of the equivalent IPv4 address, the first occurrence is
+// kept.
+func Dedup(resolver Resolver, conf *DedupResolverConfig) *dedupResolver {
+	conf, err := defaults.WithDefaults(conf, &DedupResolverConfig{
+		Order: ptr.To(AddrOrderFirstSeen),
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	return &dedupResolver{
+		resolver: resolver,
+		order:    *conf.Order,
+	}
+}
+
+func (r *dedupResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs = dedupAddrs(addrs)
+
+	if r.order == AddrOrderCanonical {
+		slices.SortStableFunc(addrs, func(a, b netip.Addr) int {
+			return a.Unmap().Compare(b.Unmap())
+		})
+		traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
+	}
+
+	return addrs, nil
+}
+
+// Close closes the underlying resolver.
+func (r *dedupResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *dedupResolver) describe() Description {
+	return Description{
+		Type:       "dedup",
+		Attributes: map[string]string{"order": string(r.order)},
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
+
+// dedupAddrs returns addrs without duplicates (treating IPv4-mapped IPv6
+// addresses as their IPv4 equivalent), keeping the order of first
+// appearance.
+func dedupAddrs(addrs []netip.Addr) []netip.Addr {
+	seen := make(map[netip.Addr]bool, len(addrs))
+	deduped := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		key := addr.Unmap()
+		if !seen[key] {
+			seen[key] = true
+			deduped = append(deduped, addr)
+		}
+	}
+	return deduped
+}
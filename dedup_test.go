@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup(t *testing.T) {
+	a := netip.MustParseAddr("192.0.2.1")
+	b := netip.MustParseAddr("192.0.2.2")
+	v6 := netip.MustParseAddr("2001:db8::1")
+
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "host.example").
+		Return([]netip.Addr{v6, b, a, netip.AddrFrom16(a.As16()), b}, nil)
+
+	ctx := context.Background()
+
+	t.Run("First Seen", func(t *testing.T) {
+		res := resolver.Dedup(inner, nil)
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "host.example")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{v6, b, a}, addrs)
+	})
+
+	t.Run("Canonical", func(t *testing.T) {
+		res := resolver.Dedup(inner, &resolver.DedupResolverConfig{
+			Order: ptr.To(resolver.AddrOrderCanonical),
+		})
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "host.example")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{a, b, v6}, addrs)
+	})
+}
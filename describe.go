@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Description is a structured description of a resolver and the resolvers it
+// is composed of, eg. for diagnostics endpoints and support bundles.
+type Description struct {
+	// Type is the type of resolver (eg. "dns" or "cache").
+	Type string `json:"type"`
+	// Attributes describe the configuration and state of the resolver.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Children are the resolvers this resolver delegates to.
+	Children []Description `json:"children,omitempty"`
+}
+
+// describer is implemented by resolvers that can describe themselves.
+type describer interface {
+	describe() Description
+}
+
+// Describe returns a description of resolver and its topology (eg. backends,
+// protocols, search domains, and cache status).
+func Describe(resolver Resolver) Description {
+	if d, ok := resolver.(describer); ok {
+		return d.describe()
+	}
+
+	return Description{Type: fmt.Sprintf("%T", resolver)}
+}
+
+// String returns the description as an indented tree.
+func (d Description) String() string {
+	var sb strings.Builder
+	d.writeTo(&sb, 0)
+	return sb.String()
+}
+
+func (d Description) writeTo(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(d.Type)
+
+	keys := make([]string, 0, len(d.Attributes))
+	for key := range d.Attributes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(sb, " %s=%s", key, d.Attributes[key])
+	}
+	sb.WriteString("\n")
+
+	for _, child := range d.Children {
+		child.writeTo(sb, depth+1)
+	}
+}
+
+func describeAll(resolvers []Resolver) []Description {
+	descriptions := make([]Description, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		descriptions = append(descriptions, Describe(resolver))
+	}
+	return descriptions
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+	hostsResolver, err := resolver.Hosts(&resolver.HostsResolverConfig{
+		HostsFileReader: strings.NewReader("192.0.2.1 www.example\n"),
+	})
+	require.NoError(t, err)
+
+	res := resolver.Sequential(
+		resolver.Literal(),
+		hostsResolver,
+		resolver.Relative(resolver.Cache(resolver.RoundRobin(
+			resolver.DNS(resolver.DNSResolverConfig{
+				Server:    netip.MustParseAddrPort("192.0.2.53:853"),
+				Transport: ptr.To(resolver.DNSTransportTLS),
+			}),
+			new(dnstest.MockResolver),
+		), nil), &resolver.RelativeResolverConfig{
+			Search: []string{"example."},
+		}),
+	)
+
+	description := resolver.Describe(res)
+
+	require.Equal(t, `sequential
+  literal
+  hosts names=1
+  relative ndots=1 search=example.
+    cache entries=0 max_entries=10000 max_ttl=24h0m0s min_ttl=0s
+      round-robin
+        dns server=192.0.2.53:853 timeout=5s transport=tcp-tls
+        *dnstest.MockResolver
+`, description.String())
+
+	data, err := json.Marshal(description)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `{"type":"dns","attributes":{"server":"192.0.2.53:853","timeout":"5s","transport":"tcp-tls"}}`)
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Rand is a source of randomness, it is implemented by *rand.Rand from
+// math/rand/v2. Injecting a seeded source allows resolver behavior to be
+// tested deterministically, it should never be used in production as it makes
+// spoofing attacks easier.
+type Rand interface {
+	// IntN returns a random integer in [0, n).
+	IntN(n int) int
+}
+
+// Clock is a source of the current time, injecting a fake clock allows
+// time dependent behavior (eg. cache expiry) to be tested deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// systemClock is the default Clock, it uses the system's time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// systemRand is the default Rand, it uses the (randomly seeded) global source
+// of math/rand/v2.
+type systemRand struct{}
+
+func (systemRand) IntN(n int) int { return rand.IntN(n) }
+
+// shuffle shuffles the elements of s using rnd.
+func shuffle[T any](rnd Rand, s []T) []T {
+	for i := len(s) - 1; i > 0; i-- {
+		j := rnd.IntN(i + 1)
+		s[i], s[j] = s[j], s[i]
+	}
+	return s
+}
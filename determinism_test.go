@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestDeterminism(t *testing.T) {
+	t.Run("Cache Clock", func(t *testing.T) {
+		var queries atomic.Int32
+		server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			queries.Add(1)
+
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 60 IN A 192.0.2.1")}
+			_ = w.WriteMsg(reply)
+		}))
+
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		res := resolver.Cache(resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		}), &resolver.CacheResolverConfig{
+			Clock: clock,
+		})
+
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			_, err := res.LookupNetIP(ctx, "ip4", "www.example")
+			require.NoError(t, err)
+		}
+		require.Equal(t, int32(1), queries.Load())
+
+		clock.Advance(61 * time.Second)
+
+		_, err := res.LookupNetIP(ctx, "ip4", "www.example")
+		require.NoError(t, err)
+		require.Equal(t, int32(2), queries.Load())
+	})
+
+	t.Run("Query ID and Case", func(t *testing.T) {
+		var mu sync.Mutex
+		var seen []string
+		server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			mu.Lock()
+			seen = append(seen, fmt.Sprintf("%d %s", req.Id, req.Question[0].Name))
+			mu.Unlock()
+
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 60 IN A 192.0.2.1")}
+			_ = w.WriteMsg(reply)
+		}))
+
+		lookup := func() []string {
+			mu.Lock()
+			seen = nil
+			mu.Unlock()
+
+			res := resolver.DNS(resolver.DNSResolverConfig{
+				Server:            server,
+				CaseRandomization: ptr.To(true),
+				Rand:              rand.New(rand.NewPCG(1, 2)),
+			})
+
+			for i := 0; i < 3; i++ {
+				_, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+				require.NoError(t, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), seen...)
+		}
+
+		first := lookup()
+		require.Len(t, first, 3)
+		require.Equal(t, first, lookup())
+	})
+
+	t.Run("Round Robin", func(t *testing.T) {
+		newResolver := func(addr string) resolver.Resolver {
+			res := new(dnstest.MockResolver)
+			res.On("LookupNetIP", mock.Anything, "ip4", "www.example.com").
+				Return([]netip.Addr{netip.MustParseAddr(addr)}, nil)
+			return res
+		}
+
+		order := func() []netip.Addr {
+			res := resolver.RoundRobin(
+				newResolver("192.0.2.1"),
+				newResolver("192.0.2.2"),
+				newResolver("192.0.2.3"),
+			).WithRand(rand.New(rand.NewPCG(1, 2)))
+
+			var addrs []netip.Addr
+			for i := 0; i < 10; i++ {
+				got, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+				require.NoError(t, err)
+				addrs = append(addrs, got...)
+			}
+			return addrs
+		}
+
+		require.Equal(t, order(), order())
+	})
+}
@@ -40,17 +40,23 @@
 package resolver
 
 import (
+	"cmp"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/netip"
-	"strings"
-	"sync"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
-	"github.com/noisysockets/resolver/internal/addrselect"
+	"github.com/noisysockets/resolver/addrselect"
 	"github.com/noisysockets/util/defaults"
 	"github.com/noisysockets/util/ptr"
 	"golang.org/x/sync/errgroup"
@@ -68,6 +74,20 @@ const (
 	DNSTransportTCP DNSTransport = "tcp"
 	// DNSTransportTLS is DNS over TLS as defined in RFC 7858.
 	DNSTransportTLS DNSTransport = "tcp-tls"
+	// DNSTransportHTTPS is DNS over HTTPS as defined in RFC 8484.
+	DNSTransportHTTPS DNSTransport = "https"
+)
+
+// TruncationPolicy is how truncated (TC=1) replies to UDP queries are handled.
+type TruncationPolicy string
+
+const (
+	// TruncationPolicyTCP retries the query over TCP (RFC 7766).
+	TruncationPolicyTCP TruncationPolicy = "tcp"
+	// TruncationPolicyAccept accepts the partial answer.
+	TruncationPolicyAccept TruncationPolicy = "accept"
+	// TruncationPolicyError fails the query with ErrTruncated.
+	TruncationPolicyError TruncationPolicy = "error"
 )
 
 // DNSResolverConfig is the configuration for a DNS resolver.
@@ -77,49 +97,209 @@ type DNSResolverConfig struct {
 	// Transport is the optional transport protocol used for DNS resolution.
 	// By default, plain DNS over UDP is used.
 	Transport *DNSTransport
+	// CustomTransport is an optional implementation of the transport, used
+	// to send all queries (eg. over a userspace network stack). Transport
+	// still describes the protocol, eg. whether queries are encrypted (for
+	// privacy profiles) or can be truncated.
+	CustomTransport Transport
 	// Timeout is the maximum duration to wait for a query to complete.
 	Timeout *time.Duration
+	// Attempts is the number of times a query sent over UDP is attempted
+	// before failing (so that the next server can be tried), like the
+	// attempts option of resolv.conf. Queries are only retried if no valid
+	// reply was received (eg. on a timeout), each attempt is subject to
+	// Timeout. Defaults to 1.
+	Attempts *int
 	// DialContext is used to establish a connection to a DNS server.
 	DialContext DialContextFunc
 	// TLSConfig is the configuration for the TLS client used for DNS over TLS.
 	TLSConfig *tls.Config
+	// TLSPolicy restricts the TLS versions and algorithms used for DNS over
+	// TLS (eg. to FIPS 140 approved algorithms). Defaults to TLSPolicyDefault.
+	// Unknown policies are rejected (DNS panics), rather than falling back to
+	// the default.
+	TLSPolicy *TLSPolicy
+	// SPKIPins is an optional list of base64 encoded SHA-256 digests of the
+	// server's SubjectPublicKeyInfo (see SPKIPin). If set, the server must
+	// present a certificate matching one of the pins, in addition to passing
+	// regular certificate validation.
+	SPKIPins []string
 	// SingleRequest is used to query A and AAAA records sequentially.
 	// This is mostly useful for avoiding conntrack race issues with DNS over UDP.
 	// If you feel the need to enable this, you should probably just use
 	// DNS over TCP instead.
 	SingleRequest *bool
+	// TrustAD sets the AD and DO bits in queries, asking the server to report
+	// whether answers were authenticated using DNSSEC. The server must be
+	// trusted and reached over a secure channel (eg. DNS over TLS).
+	TrustAD *bool
+	// RequireAD is an optional list of domains for which answers must be
+	// authenticated by the server (AD=1), otherwise lookups will fail with
+	// ErrUnauthenticated. Implies TrustAD.
+	RequireAD []string
+	// CaseRandomization enables randomizing the case of query names sent over
+	// UDP (aka. DNS 0x20), replies that do not echo the query name exactly are
+	// treated as spoofing attempts and the query is retried over TCP.
+	CaseRandomization *bool
+	// MaxCNAMEChain is the maximum number of aliases (CNAME records) that will
+	// be followed when resolving a name. Defaults to 8.
+	MaxCNAMEChain *int
+	// Proxy is an optional function returning the HTTP proxy that DNS over TLS
+	// and DNS over HTTPS connections to a server are tunneled through (using
+	// CONNECT). If it returns a nil URL, no proxy is used. Use
+	// ProxyFromEnvironment to honor the HTTPS_PROXY environment variable.
+	Proxy func(server netip.AddrPort) (*url.URL, error)
+	// LocalAddr is the optional local address (and port) that queries are sent
+	// from, it is ignored if DialContext is set. By default (a zero port), each
+	// query is sent from a new randomly selected ephemeral port, which is
+	// strongly recommended as it makes spoofing attacks harder (RFC 5452).
+	// A fixed port only applies to UDP queries, and causes A and AAAA queries
+	// to be sent sequentially.
+	LocalAddr *netip.AddrPort
+	// ResponseLimits are the limits applied when reading and parsing responses.
+	// By default, responses are limited to 65535 bytes, 1000 records, 256 KiB
+	// of decompressed names, and 4096 compression pointers.
+	ResponseLimits *ResponseLimits
+	// RecursionDesired sets the RD bit in queries, asking the server to
+	// resolve the query recursively. Defaults to true, disable it when
+	// querying authoritative servers directly.
+	RecursionDesired *bool
+	// CheckingDisabled sets the CD bit in queries, asking the server not to
+	// perform DNSSEC validation (eg. because validation is done locally).
+	CheckingDisabled *bool
+	// DNSSECOK sets the DO bit in queries, asking the server to include DNSSEC
+	// records (eg. RRSIGs) in replies.
+	DNSSECOK *bool
+	// TruncationPolicy is how truncated replies to UDP queries are handled.
+	// By default, the query is retried over TCP.
+	TruncationPolicy *TruncationPolicy
+	// TSIGKey is an optional key used to authenticate queries and replies
+	// using TSIG (RFC 8945).
+	TSIGKey *TSIGKey
+	// Logger is an optional logger, queries (with the server used, the return
+	// code, and timing) and retries are logged at debug level.
+	Logger *slog.Logger
+	// Metrics is an optional recorder of query metrics.
+	Metrics MetricsRecorder
+	// Rand is an optional source of randomness for query IDs and case
+	// randomization, for deterministic tests (see Rand). Source ports are
+	// always chosen by the operating system, unless LocalAddr is set.
+	Rand Rand
+	// Clock is an optional source of the current time, used for timing
+	// queries and DNSSEC signature validity. Defaults to the system's time.
+	Clock Clock
+	// QueryLog is an optional log that queries are recorded in. It can be
+	// shared between resolvers.
+	QueryLog *QueryLog
+	// OnQuery is an optional hook called before each query is sent, it may
+	// modify the query. Returning an error aborts the query.
+	OnQuery func(ctx context.Context, req *dns.Msg, info QueryInfo) error
+	// OnResponse is an optional hook called with each reply received, before
+	// it is processed. It may modify the reply.
+	OnResponse func(ctx context.Context, req, reply *dns.Msg, info QueryInfo)
+	// OnError is an optional hook called when a query fails.
+	OnError func(ctx context.Context, req *dns.Msg, err error, info QueryInfo)
+	// Sort is an optional strategy used to order the returned addresses (see
+	// AddrSorter). By default, addresses are sorted according to RFC 6724
+	// (see SortRFC6724).
+	Sort AddrSorter
+	// PrivacyProfile is the optional DNS privacy usage profile (RFC 8310). If
+	// set, Transport defaults to DNS over TLS. Per-domain profiles can be
+	// configured by using multiple resolvers with a routing resolver.
+	PrivacyProfile *PrivacyProfile
 }
 
 // dnsResolver is a DNS resolver.
 type dnsResolver struct {
-	server        netip.AddrPort
-	transport     DNSTransport
-	timeout       time.Duration
-	dialContext   DialContextFunc
-	tlsConfig     *tls.Config
-	singleRequest bool
+	conf              DNSResolverConfig
+	server            netip.AddrPort
+	transport         DNSTransport
+	transports        map[DNSTransport]Transport
+	timeout           time.Duration
+	attempts          int
+	dialContext       DialContextFunc
+	tlsConfig         *tls.Config
+	singleRequest     bool
+	trustAD           bool
+	requireAD         []string
+	caseRandomization bool
+	maxCNAMEChain     int
+	recursionDesired  bool
+	checkingDisabled  bool
+	dnssecOK          bool
+	truncationPolicy  TruncationPolicy
+	privacyProfile    PrivacyProfile
+	authenticated     bool
+	tsigKey           *TSIGKey
+	logger            *slog.Logger
+	metrics           MetricsRecorder
+	rand              Rand
+	clock             Clock
+	queryLog          *QueryLog
+	sort              AddrSorter
+	stats             *serverStatsTracker
+	closed            atomic.Bool
+	onQuery           func(ctx context.Context, req *dns.Msg, info QueryInfo) error
+	onResponse        func(ctx context.Context, req, reply *dns.Msg, info QueryInfo)
+	onError           func(ctx context.Context, req *dns.Msg, err error, info QueryInfo)
 }
 
 // DNS creates a new DNS resolver.
 func DNS(conf DNSResolverConfig) *dnsResolver {
+	// Retained so that derived resolvers can be created (see With).
+	origConf := conf
+
+	if conf.PrivacyProfile != nil && *conf.PrivacyProfile != "" && conf.Transport == nil {
+		conf.Transport = ptr.To(DNSTransportTLS)
+	}
+
 	// Make sure the server port is set.
 	server := conf.Server
 	if server.Port() == 0 {
 		if conf.Transport != nil && *conf.Transport == DNSTransportTLS {
 			server = netip.AddrPortFrom(server.Addr(), 853)
+		} else if conf.Transport != nil && *conf.Transport == DNSTransportHTTPS {
+			server = netip.AddrPortFrom(server.Addr(), 443)
 		} else {
 			server = netip.AddrPortFrom(server.Addr(), 53)
 		}
 	}
 
+	dialContext := (&net.Dialer{}).DialContext
+	if conf.LocalAddr != nil {
+		dialContext = dialFromLocalAddr(*conf.LocalAddr)
+	}
+
+	// Not copied when applying defaults, as it only has unexported fields.
+	queryLog := conf.QueryLog
+
 	withDefaults, err := defaults.WithDefaults(&conf, &DNSResolverConfig{
 		Transport:   ptr.To(DNSTransportUDP),
 		Timeout:     ptr.To(5 * time.Second),
-		DialContext: (&net.Dialer{}).DialContext,
+		DialContext: dialContext,
 		TLSConfig: &tls.Config{
 			ServerName: server.String(),
 		},
-		SingleRequest: ptr.To(false),
+		SingleRequest:     ptr.To(false),
+		TrustAD:           ptr.To(false),
+		CaseRandomization: ptr.To(false),
+		Attempts:          ptr.To(1),
+		MaxCNAMEChain:     ptr.To(8),
+		ResponseLimits: &ResponseLimits{
+			MaxMessageSize:         dns.MaxMsgSize,
+			MaxRecords:             1000,
+			MaxExpandedSize:        256 * 1024,
+			MaxCompressionPointers: 4096,
+		},
+		RecursionDesired: ptr.To(true),
+		CheckingDisabled: ptr.To(false),
+		DNSSECOK:         ptr.To(false),
+		TruncationPolicy: ptr.To(TruncationPolicyTCP),
+		PrivacyProfile:   ptr.To(PrivacyProfile("")),
+		TLSPolicy:        ptr.To(TLSPolicyDefault),
+		Logger:           discardLogger,
+		Metrics:          nopMetricsRecorder{},
+		Clock:            systemClock{},
 	})
 	if err != nil {
 		// Should never happen.
@@ -127,27 +307,107 @@ func DNS(conf DNSResolverConfig) *dnsResolver {
 	}
 	conf = *withDefaults
 
+	// A compliance setting must never fail open.
+	if !conf.TLSPolicy.valid() {
+		panic(fmt.Sprintf("unknown TLS policy %q", *conf.TLSPolicy))
+	}
+
+	tlsConfig := withTLSPolicy(conf.TLSConfig, *conf.TLSPolicy)
+	leafPinned := len(conf.SPKIPins) > 0
+	if leafPinned {
+		tlsConfig = withSPKIPins(tlsConfig, conf.SPKIPins)
+	}
+	if *conf.PrivacyProfile == PrivacyProfileOpportunistic {
+		// Discards the SPKI pin check.
+		tlsConfig = opportunisticTLSConfig(tlsConfig)
+		leafPinned = false
+	}
+	authenticated := isAuthenticated(tlsConfig, leafPinned)
+
+	var requireAD []string
+	for _, domain := range conf.RequireAD {
+		requireAD = append(requireAD, dns.CanonicalName(domain))
+	}
+
+	// Only encrypted connections are tunneled, as UDP can't be proxied and a
+	// proxy would be able to observe (and tamper with) clear text queries.
+	encryptedDialContext := conf.DialContext
+	if conf.Proxy != nil {
+		encryptedDialContext = dialViaProxy(conf.Proxy, conf.DialContext)
+	}
+
+	transports := map[DNSTransport]Transport{
+		DNSTransportUDP: &UDPTransport{
+			DialContext:    conf.DialContext,
+			ResponseLimits: *conf.ResponseLimits,
+			TSIGKey:        conf.TSIGKey,
+			Clock:          conf.Clock,
+		},
+		DNSTransportTCP: &TCPTransport{
+			DialContext:    conf.DialContext,
+			ResponseLimits: *conf.ResponseLimits,
+			TSIGKey:        conf.TSIGKey,
+			Clock:          conf.Clock,
+		},
+		DNSTransportTLS: &TLSTransport{
+			DialContext:    encryptedDialContext,
+			TLSConfig:      tlsConfig,
+			ResponseLimits: *conf.ResponseLimits,
+			TSIGKey:        conf.TSIGKey,
+			Clock:          conf.Clock,
+		},
+		DNSTransportHTTPS: &HTTPSTransport{
+			DialContext:    encryptedDialContext,
+			TLSConfig:      tlsConfig,
+			ResponseLimits: *conf.ResponseLimits,
+			TSIGKey:        conf.TSIGKey,
+			Clock:          conf.Clock,
+		},
+	}
+	if conf.CustomTransport != nil {
+		for transport := range transports {
+			transports[transport] = conf.CustomTransport
+		}
+	}
+
 	return &dnsResolver{
-		server:        server,
-		transport:     *conf.Transport,
-		timeout:       *conf.Timeout,
-		dialContext:   conf.DialContext,
-		tlsConfig:     conf.TLSConfig,
-		singleRequest: *conf.SingleRequest,
+		conf:              origConf,
+		server:            server,
+		transport:         *conf.Transport,
+		transports:        transports,
+		timeout:           *conf.Timeout,
+		attempts:          max(*conf.Attempts, 1),
+		dialContext:       conf.DialContext,
+		tlsConfig:         tlsConfig,
+		singleRequest:     *conf.SingleRequest || (conf.LocalAddr != nil && conf.LocalAddr.Port() != 0),
+		trustAD:           *conf.TrustAD || len(conf.RequireAD) > 0,
+		requireAD:         requireAD,
+		caseRandomization: *conf.CaseRandomization,
+		maxCNAMEChain:     *conf.MaxCNAMEChain,
+		recursionDesired:  *conf.RecursionDesired,
+		checkingDisabled:  *conf.CheckingDisabled,
+		dnssecOK:          *conf.DNSSECOK,
+		truncationPolicy:  *conf.TruncationPolicy,
+		privacyProfile:    *conf.PrivacyProfile,
+		authenticated:     authenticated,
+		tsigKey:           conf.TSIGKey,
+		logger:            conf.Logger,
+		metrics:           conf.Metrics,
+		rand:              conf.Rand,
+		clock:             conf.Clock,
+		queryLog:          queryLog,
+		sort:              conf.Sort,
+		stats:             newServerStatsTracker(conf.Clock),
+		onQuery:           conf.OnQuery,
+		onResponse:        conf.OnResponse,
+		onError:           conf.OnError,
 	}
 }
 
 func (r *dnsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
-	dnsErr := &net.DNSError{
-		Name: host,
-	}
-
 	// If the host is not a valid domain name, return an error.
 	if _, ok := dns.IsDomainName(host); !ok {
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err:        ErrNoSuchHost.Error(),
-			IsNotFound: true,
-		})
+		return nil, newError(host, "", ErrNoSuchHost)
 	}
 
 	name := dns.Fqdn(host)
@@ -161,22 +421,17 @@ func (r *dnsResolver) LookupNetIP(ctx context.Context, network, host string) ([]
 	case "ip6":
 		qTypes = []uint16{dns.TypeAAAA}
 	default:
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err: ErrUnsupportedNetwork.Error(),
-		})
+		return nil, newError(host, "", ErrUnsupportedNetwork)
 	}
 
-	client := &dns.Client{
-		Net:       string(r.transport),
-		TLSConfig: r.tlsConfig,
-		Timeout:   r.timeout,
-	}
+	client := r.newClient()
 
-	var addrsMu sync.Mutex
-	var addrs []netip.Addr
+	// Results are kept per query type, so that the order of the addresses
+	// doesn't depend on which query completes first.
+	results := make([][]netip.Addr, len(qTypes))
 
-	tryOneNameAndAppendResults := func(ctx context.Context, qType uint16) error {
-		reply, err := r.tryOneName(ctx, client, name, qType)
+	tryOneNameAndAppendResults := func(ctx context.Context, i int) error {
+		answers, err := r.lookup(ctx, client, name, qTypes[i])
 		if err != nil {
 			return err
 		}
@@ -191,36 +446,30 @@ func (r *dnsResolver) LookupNetIP(ctx context.Context, network, host string) ([]
 		//
 		// Therefore, we should be able to assume that we can ignore
 		// CNAMEs and that the A and AAAA records we requested are
-		// for the canonical name.
-
-		addrsMu.Lock()
-		defer addrsMu.Unlock()
+		// for the canonical name. Servers that don't follow aliases
+		// are handled by lookup().
 
-		for _, rr := range reply.Answer {
-			switch rr := rr.(type) {
-			case *dns.A:
-				addrs = append(addrs, netip.AddrFrom4([4]byte(rr.A.To4())))
-			case *dns.AAAA:
-				addrs = append(addrs, netip.AddrFrom16([16]byte(rr.AAAA.To16())))
-			}
+		if len(answers) > 0 {
+			recordTTL(ctx, answerTTL(answers))
 		}
 
+		results[i] = addrsFromAnswers(answers, name)
+
 		return nil
 	}
 
 	if r.singleRequest {
-		for _, qType := range qTypes {
-			if err := tryOneNameAndAppendResults(ctx, qType); err != nil {
+		for i := range qTypes {
+			if err := tryOneNameAndAppendResults(ctx, i); err != nil {
 				return nil, err
 			}
 		}
 	} else {
 		g, ctx := errgroup.WithContext(ctx)
 
-		for _, qType := range qTypes {
-			qType := qType
+		for i := range qTypes {
 			g.Go(func() error {
-				return tryOneNameAndAppendResults(ctx, qType)
+				return tryOneNameAndAppendResults(ctx, i)
 			})
 		}
 
@@ -229,84 +478,718 @@ func (r *dnsResolver) LookupNetIP(ctx context.Context, network, host string) ([]
 		}
 	}
 
+	// Servers may repeat records (eg. across the replies of an alias chain).
+	addrs := dedupAddrs(slices.Concat(results...))
 	if len(addrs) > 0 {
-		if network != "ip4" {
+		if r.sort != nil {
+			r.sort(ctx, r.dialContext, addrs)
+			traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
+		} else if network != "ip4" {
 			dial := func(network, address string) (net.Conn, error) {
 				return r.dialContext(ctx, network, address)
 			}
 
 			addrselect.SortByRFC6724(dial, addrs)
+			traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
 		}
 
 		return addrs, nil
 	}
 
-	return nil, extendDNSError(dnsErr, net.DNSError{
-		Err:        ErrNoSuchHost.Error(),
-		IsNotFound: true,
+	// The name exists (otherwise the server would have returned NXDOMAIN),
+	// but it has no addresses.
+	return nil, newError(host, r.server.String(), ErrNoData)
+}
+
+// LookupAddr returns the names mapping to the given address (PTR records).
+// Aliases are followed, so that addresses within RFC 2317 classless
+// in-addr.arpa delegations (where eg. 65.2.0.192.in-addr.arpa is an alias of
+// 65.64/26.2.0.192.in-addr.arpa) are resolved even when the server does not
+// follow the alias itself.
+func (r *dnsResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, newError(addr, "", fmt.Errorf("%w: %w", ErrNoSuchHost, err))
+	}
+
+	answers, dnsErr := r.lookup(ctx, r.newClient(), reverse, dns.TypePTR)
+	if dnsErr != nil {
+		return nil, dnsErr
+	}
+
+	names := ptrsFromAnswers(answers, reverse)
+	if len(names) == 0 {
+		return nil, newError(addr, r.server.String(), ErrNoData)
+	}
+
+	recordTTL(ctx, answerTTL(answers))
+
+	return names, nil
+}
+
+// LookupSRV returns the SRV records for the given service, protocol and
+// domain, sorted by priority and weight. As with net.Resolver, if service
+// and proto are both empty, name is looked up directly.
+func (r *dnsResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	target = dns.CanonicalName(target)
+
+	answers, dnsErr := r.lookup(ctx, r.newClient(), target, dns.TypeSRV)
+	if dnsErr != nil {
+		return "", nil, dnsErr
+	}
+
+	names := aliasChain(answers, target)
+
+	cname := target
+	var srvs []*net.SRV
+	for _, rr := range answers {
+		switch rr := rr.(type) {
+		case *dns.CNAME:
+			if names[dns.CanonicalName(rr.Hdr.Name)] {
+				cname = dns.CanonicalName(rr.Target)
+			}
+		case *dns.SRV:
+			if names[dns.CanonicalName(rr.Hdr.Name)] {
+				srvs = append(srvs, &net.SRV{
+					Target:   rr.Target,
+					Port:     rr.Port,
+					Priority: rr.Priority,
+					Weight:   rr.Weight,
+				})
+			}
+		}
+	}
+
+	if len(srvs) == 0 {
+		return "", nil, newError(target, r.server.String(), ErrNoData)
+	}
+
+	recordTTL(ctx, answerTTL(answers))
+
+	slices.SortStableFunc(srvs, func(a, b *net.SRV) int {
+		if c := cmp.Compare(a.Priority, b.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Weight, a.Weight)
 	})
+
+	return cname, srvs, nil
 }
 
-func (r *dnsResolver) tryOneName(ctx context.Context, client *dns.Client, name string, qType uint16) (*dns.Msg, *net.DNSError) {
-	dnsErr := &net.DNSError{
-		Name:   name,
-		Server: r.server.String(),
+// LookupMX returns the MX records for the given domain, sorted by
+// preference.
+func (r *dnsResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	target := dns.CanonicalName(name)
+
+	answers, dnsErr := r.lookup(ctx, r.newClient(), target, dns.TypeMX)
+	if dnsErr != nil {
+		return nil, dnsErr
 	}
 
-	if client.Timeout != 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, client.Timeout)
-		defer cancel()
+	names := aliasChain(answers, target)
+
+	var mxs []*net.MX
+	for _, rr := range answers {
+		if mx, ok := rr.(*dns.MX); ok && names[dns.CanonicalName(mx.Hdr.Name)] {
+			mxs = append(mxs, &net.MX{Host: mx.Mx, Pref: mx.Preference})
+		}
 	}
 
-	conn, err := r.dialContext(ctx, strings.TrimSuffix(client.Net, "-tls"), r.server.String())
+	if len(mxs) == 0 {
+		return nil, newError(target, r.server.String(), ErrNoData)
+	}
+
+	recordTTL(ctx, answerTTL(answers))
+
+	slices.SortStableFunc(mxs, func(a, b *net.MX) int {
+		return cmp.Compare(a.Pref, b.Pref)
+	})
+
+	return mxs, nil
+}
+
+// With returns a new DNS resolver derived from this one, with the fields set
+// in conf overriding the original configuration. The derived resolver shares
+// the statistics (and query log) of the original, and its transports (and so
+// any pooled connections) unless conf overrides a field they depend on (eg.
+// TLSConfig or DialContext).
+//
+// As unset fields are inherited, With can't be used to clear a field of the
+// original configuration (eg. to remove a hook or the SPKI pins), create a
+// new resolver using DNS instead.
+func (r *dnsResolver) With(conf DNSResolverConfig) *dnsResolver {
+	queryLog := conf.QueryLog
+	if queryLog == nil {
+		queryLog = r.queryLog
+	}
+	shareTransports := !overridesTransports(&conf)
+
+	merged, err := defaults.WithDefaults(&conf, &r.conf)
 	if err != nil {
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err:         err.Error(),
-			IsTimeout:   isTimeout(err),
-			IsTemporary: true,
-		})
+		// Should never happen.
+		panic(err)
+	}
+	// Not merged, as they only have unexported fields.
+	merged.QueryLog = queryLog
+	merged.Server = conf.Server
+	if !merged.Server.IsValid() {
+		merged.Server = r.conf.Server
 	}
 
-	if strings.HasSuffix(client.Net, "-tls") {
-		conn = tls.Client(conn, r.tlsConfig)
-		if err := conn.(*tls.Conn).HandshakeContext(ctx); err != nil {
-			_ = conn.Close()
-			// Handshake errors are not likely to be temporary.
-			return nil, extendDNSError(dnsErr, net.DNSError{
-				Err:       err.Error(),
-				IsTimeout: isTimeout(err),
-			})
+	derived := DNS(*merged)
+	derived.stats = r.stats
+	if shareTransports {
+		derived.transports = r.transports
+	}
+	return derived
+}
+
+// overridesTransports returns whether conf sets any of the fields that the
+// transports of a DNS resolver are built from.
+func overridesTransports(conf *DNSResolverConfig) bool {
+	return conf.Server.IsValid() ||
+		conf.CustomTransport != nil ||
+		conf.DialContext != nil ||
+		conf.TLSConfig != nil ||
+		conf.TLSPolicy != nil ||
+		conf.SPKIPins != nil ||
+		conf.Proxy != nil ||
+		conf.LocalAddr != nil ||
+		conf.ResponseLimits != nil ||
+		conf.TSIGKey != nil ||
+		conf.Clock != nil ||
+		conf.PrivacyProfile != nil
+}
+
+// Clone returns a copy of the resolver, that shares the statistics (and
+// query log) of the original.
+func (r *dnsResolver) Clone() *dnsResolver {
+	return r.With(DNSResolverConfig{})
+}
+
+// Stats returns statistics about the queries sent to each server (this may
+// include servers other than the configured server, eg. when falling back to
+// clear text), sorted by server address.
+func (r *dnsResolver) Stats() []ServerStats {
+	return r.stats.snapshot()
+}
+
+// Exchange sends req to the server and returns the reply, it can be used to
+// query records of any type. The configured transport, privacy profile,
+// limits, TSIG key, and hooks all apply, but the reply's return code is not
+// inspected. Queries sent over UDP are retried up to the configured number of
+// attempts, and truncated replies are handled according to the truncation
+// policy.
+func (r *dnsResolver) Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	client := r.newClient()
+
+	// Each attempt uses a fresh ID, so leave the caller's request untouched.
+	req = req.Copy()
+
+	reply, dnsErr := r.exchangeWithAttempts(ctx, client, req)
+	if dnsErr != nil {
+		return nil, dnsErr
+	}
+
+	if reply.Truncated && client.Net == string(DNSTransportUDP) {
+		switch r.truncationPolicy {
+		case TruncationPolicyError:
+			return nil, newError(questionName(req), r.server.String(), ErrTruncated)
+		case TruncationPolicyTCP:
+			client.Net = string(DNSTransportTCP)
+
+			reply, dnsErr = r.exchange(ctx, client, req)
+			if dnsErr != nil {
+				return nil, dnsErr
+			}
 		}
 	}
-	defer conn.Close()
+
+	return reply, nil
+}
+
+// lookup queries name for records of the given type, following any aliases
+// that the server did not resolve itself. The answer records of all replies
+// are returned.
+func (r *dnsResolver) lookup(ctx context.Context, client *dns.Client, name string, qType uint16) ([]dns.RR, *Error) {
+	aliases := make(map[string]bool)
+	target := dns.CanonicalName(name)
+
+	var answers []dns.RR
+	for {
+		reply, err := r.tryOneName(ctx, client, target, qType)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, reply.Answer...)
+
+		// Follow the alias chain contained in the reply.
+		queried := target
+		for {
+			cname := findCNAME(reply.Answer, target)
+			if cname == nil {
+				break
+			}
+
+			aliases[target] = true
+			traceEvent(ctx, TraceEvent{Type: TraceEventCNAME, Name: target, Target: dns.CanonicalName(cname.Target)})
+			target = dns.CanonicalName(cname.Target)
+
+			if aliases[target] {
+				return nil, newError(name, r.server.String(), ErrCNAMELoop)
+			}
+
+			if len(aliases) > r.maxCNAMEChain {
+				return nil, newError(name, r.server.String(), ErrCNAMEChainTooLong)
+			}
+		}
+
+		// Either the server resolved the alias for us, or the name has no
+		// records of the requested type.
+		if target == queried || hasRecords(reply.Answer, target, qType) {
+			return answers, nil
+		}
+	}
+}
+
+func findCNAME(rrs []dns.RR, owner string) *dns.CNAME {
+	for _, rr := range rrs {
+		if cname, ok := rr.(*dns.CNAME); ok && dns.CanonicalName(cname.Hdr.Name) == owner {
+			return cname
+		}
+	}
+	return nil
+}
+
+func hasRecords(rrs []dns.RR, owner string, rrType uint16) bool {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrType && dns.CanonicalName(rr.Header().Name) == owner {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *dnsResolver) tryOneName(ctx context.Context, client *dns.Client, name string, qType uint16) (*dns.Msg, *Error) {
+	qName := name
+	randomizeCase := r.caseRandomization && client.Net == string(DNSTransportUDP)
+	if randomizeCase {
+		qName = r.randomizeNameCase(name)
+	}
 
 	req := &dns.Msg{}
-	req.SetQuestion(name, qType)
+	req.SetQuestion(qName, qType)
+	r.setID(req)
+	req.RecursionDesired = r.recursionDesired
+	req.CheckingDisabled = r.checkingDisabled
+	if r.trustAD {
+		req.AuthenticatedData = true
+	}
+	// EDNS(0) is always used, so that servers can report extended errors
+	// (RFC 8914).
+	req.SetEdns0(dns.DefaultMsgSize, r.trustAD || r.dnssecOK)
 
-	reply, _, err := client.ExchangeWithConn(req, &dns.Conn{Conn: conn})
-	if err != nil {
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err:         err.Error(),
-			IsTimeout:   isTimeout(err),
-			IsTemporary: true,
-		})
+	reply, dnsErr := r.exchangeWithAttempts(ctx, client, req)
+	if dnsErr != nil {
+		dnsErr.Name = name
+		return nil, dnsErr
 	}
 
+	// A reply that does not echo the exact query name is likely to be spoofed,
+	// so retry the query over TCP.
+	spoofed := randomizeCase && (len(reply.Question) == 0 || reply.Question[0].Name != qName)
+	truncated := reply.Truncated && client.Net == string(DNSTransportUDP)
+
+	if truncated && !spoofed {
+		switch r.truncationPolicy {
+		case TruncationPolicyAccept:
+			truncated = false
+		case TruncationPolicyError:
+			return nil, newError(name, r.server.String(), ErrTruncated)
+		}
+	}
+
+	if spoofed || truncated {
+		r.logger.LogAttrs(ctx, slog.LevelDebug, "Retrying query over TCP",
+			slog.String("name", name),
+			slog.String("type", dns.TypeToString[qType]),
+			slog.Bool("spoofed", spoofed),
+			slog.Bool("truncated", truncated))
+
+		tcpClient := *client
+		tcpClient.Net = string(DNSTransportTCP)
+
+		req.Question[0].Name = name
+		reply, dnsErr = r.exchange(ctx, &tcpClient, req)
+		if dnsErr != nil {
+			return nil, dnsErr
+		}
+	}
+
+	if !reply.AuthenticatedData && r.isADRequired(name) &&
+		(reply.Rcode == dns.RcodeSuccess || reply.Rcode == dns.RcodeNameError) {
+		return nil, newError(name, r.server.String(), ErrUnauthenticated).withExtendedErrors(reply)
+	}
+
+	if dnsErr := rcodeError(name, r.server.String(), reply); dnsErr != nil {
+		return nil, dnsErr
+	}
+
+	return reply, nil
+}
+
+// rcodeError returns the error corresponding to a reply's return code, or nil
+// if the query was successful. Any extended errors included in the reply are
+// attached to the error.
+func rcodeError(name, server string, reply *dns.Msg) *Error {
+	var err error
 	switch reply.Rcode {
 	case dns.RcodeSuccess:
-		return reply, nil
+		return nil
 	case dns.RcodeNameError:
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err:        ErrNoSuchHost.Error(),
-			IsNotFound: true,
-		})
+		err = ErrNoSuchHost
+	case dns.RcodeServerFailure:
+		// SERVFAIL is temporary, and is not cached.
+		err = fmt.Errorf("%w: %w", ErrServFail, ErrServerMisbehaving)
+	case dns.RcodeRefused:
+		err = fmt.Errorf("%w: %w", ErrRefused, ErrServerMisbehaving)
 	default:
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err: fmt.Errorf("unexpected return code %s: %w",
-				dns.RcodeToString[reply.Rcode], ErrServerMisbehaving).Error(),
-			// SERVFAIL is not cached.
-			IsTemporary: reply.Rcode == dns.RcodeServerFailure,
+		err = fmt.Errorf("unexpected return code %s: %w",
+			dns.RcodeToString[reply.Rcode], ErrServerMisbehaving)
+	}
+
+	return newError(name, server, err).withExtendedErrors(reply)
+}
+
+// isADRequired returns true if answers for name must be authenticated.
+func (r *dnsResolver) isADRequired(name string) bool {
+	for _, domain := range r.requireAD {
+		if dns.IsSubDomain(domain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// exchangeWithAttempts sends a query to the server, queries sent over UDP are
+// retried (up to the configured, or per-lookup, number of attempts) until a
+// valid reply is received.
+func (r *dnsResolver) exchangeWithAttempts(ctx context.Context, client *dns.Client, req *dns.Msg) (*dns.Msg, *Error) {
+	attempts := 1
+	if client.Net == string(DNSTransportUDP) {
+		attempts = r.attempts
+		if override, ok := LookupAttempts(ctx); ok {
+			attempts = max(override, 1)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		reply, dnsErr := r.exchange(ctx, client, req)
+		if dnsErr == nil || attempt >= attempts || ctx.Err() != nil ||
+			!(dnsErr.IsTimeout || dnsErr.IsTemporary) {
+			return reply, dnsErr
+		}
+
+		r.logger.LogAttrs(ctx, slog.LevelDebug, "Retrying query",
+			slog.String("name", questionName(req)),
+			slog.Int("attempt", attempt+1),
+			slog.String("error", dnsErr.Err))
+
+		// Use a fresh ID, so that a late reply to the previous attempt
+		// can't be mistaken for a reply to this one.
+		r.setID(req)
+	}
+}
+
+// exchange sends a single query to the server (subject to the privacy
+// profile) and returns the reply, the reply's return code is not inspected.
+func (r *dnsResolver) exchange(ctx context.Context, client *dns.Client, req *dns.Msg) (*dns.Msg, *Error) {
+	if r.closed.Load() {
+		return nil, newError(questionName(req), r.server.String(), net.ErrClosed)
+	}
+
+	if opts := LookupEDNSOptions(ctx); len(opts) > 0 {
+		req = withEDNSOptions(req, opts)
+	}
+
+	encrypted := client.Net == string(DNSTransportTLS) || client.Net == string(DNSTransportHTTPS)
+
+	if r.privacyProfile == PrivacyProfileStrict && (!encrypted || !r.authenticated) {
+		return nil, newError(questionName(req), r.server.String(), ErrPrivacyRequired)
+	}
+
+	reply, dnsErr := r.exchangeWithServer(ctx, client, r.server, req)
+	if dnsErr == nil || !encrypted || r.privacyProfile != PrivacyProfileOpportunistic || ctx.Err() != nil {
+		return reply, dnsErr
+	}
+
+	// Fall back to clear text.
+	fallback := netip.AddrPortFrom(r.server.Addr(), 53)
+
+	r.logger.LogAttrs(ctx, slog.LevelDebug, "Falling back to clear text",
+		slog.String("server", fallback.String()),
+		slog.String("error", dnsErr.Err))
+
+	clearClient := *client
+	clearClient.Net = string(DNSTransportUDP)
+
+	reply, dnsErr = r.exchangeWithServer(ctx, &clearClient, fallback, req)
+	if dnsErr == nil && reply.Truncated {
+		clearClient.Net = string(DNSTransportTCP)
+		reply, dnsErr = r.exchangeWithServer(ctx, &clearClient, fallback, req)
+	}
+
+	return reply, dnsErr
+}
+
+// withEDNSOptions returns a copy of req with the given EDNS(0) options
+// added.
+func withEDNSOptions(req *dns.Msg, opts []EDNSOption) *dns.Msg {
+	req = req.Copy()
+
+	opt := req.IsEdns0()
+	if opt == nil {
+		req.SetEdns0(dns.DefaultMsgSize, false)
+		opt = req.IsEdns0()
+	}
+
+	for _, o := range opts {
+		opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: o.Code, Data: slices.Clone(o.Data)})
+	}
+
+	return req
+}
+
+// exchangeWithServer sends a single query to the given server and returns the
+// reply.
+func (r *dnsResolver) exchangeWithServer(ctx context.Context, client *dns.Client, server netip.AddrPort, req *dns.Msg) (reply *dns.Msg, dnsErr *Error) {
+	name := questionName(req)
+
+	info := QueryInfo{
+		Server:    server,
+		Transport: DNSTransport(client.Net),
+	}
+
+	if r.onQuery != nil {
+		if err := r.onQuery(ctx, req, info); err != nil {
+			dnsErr := newError(name, server.String(), err)
+			if r.onError != nil {
+				r.onError(ctx, req, dnsErr, info)
+			}
+			return nil, dnsErr
+		}
+		// The hook may have modified the question.
+		name = questionName(req)
+	}
+
+	// Hooks should not observe the per-query timeout being cancelled.
+	hookCtx := ctx
+
+	start := r.clock.Now()
+	defer func() {
+		duration := r.clock.Now().Sub(start)
+
+		info.Duration = duration
+		if dnsErr != nil {
+			if r.onError != nil {
+				r.onError(hookCtx, req, dnsErr, info)
+			}
+		} else if r.onResponse != nil {
+			r.onResponse(hookCtx, req, reply, info)
+		}
+
+		attrs := []slog.Attr{
+			slog.String("name", name),
+			slog.String("server", server.String()),
+			slog.String("transport", client.Net),
+			slog.Duration("duration", duration),
+		}
+
+		var qType uint16
+		if len(req.Question) > 0 {
+			qType = req.Question[0].Qtype
+			attrs = append(attrs, slog.String("type", dns.TypeToString[qType]))
+		}
+
+		rcode := -1
+		if reply != nil {
+			rcode = reply.Rcode
+			attrs = append(attrs, slog.String("rcode", dns.RcodeToString[rcode]))
+		}
+
+		var err error
+		if dnsErr != nil {
+			err = dnsErr
+			attrs = append(attrs, slog.String("error", dnsErr.Err))
+		}
+
+		r.logger.LogAttrs(ctx, slog.LevelDebug, "DNS query", attrs...)
+		r.metrics.RecordQuery(server.String(), qType, rcode, duration, err)
+		r.stats.record(server, duration, err)
+
+		if reply != nil && dnsErr == nil {
+			for _, rr := range reply.Answer {
+				var addr netip.Addr
+				switch rr := rr.(type) {
+				case *dns.A:
+					addr = netip.AddrFrom4([4]byte(rr.A.To4()))
+				case *dns.AAAA:
+					addr = netip.AddrFrom16([16]byte(rr.AAAA.To16()))
+				default:
+					continue
+				}
+
+				recordProvenance(ctx, AddrInfo{
+					Source:    AddrSourceDNS,
+					Server:    server,
+					Transport: info.Transport,
+					TTL:       time.Duration(rr.Header().Ttl) * time.Second,
+					RTT:       duration,
+				}, addr)
+			}
+		}
+
+		traceEvent(ctx, TraceEvent{
+			Type:      TraceEventQuery,
+			Time:      start,
+			Name:      name,
+			QType:     qType,
+			Server:    server,
+			Transport: info.Transport,
+			Rcode:     rcode,
+			RTT:       duration,
+			Err:       err,
 		})
+
+		if r.queryLog != nil {
+			r.queryLog.add(QueryLogEntry{
+				Time:     start,
+				Name:     name,
+				Type:     qType,
+				Server:   server,
+				Rcode:    rcode,
+				Duration: duration,
+				Err:      err,
+			})
+		}
+	}()
+
+	timeout := client.Timeout
+	if override, ok := LookupTimeout(ctx); ok {
+		timeout = override
+	}
+
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	transport, ok := r.transports[DNSTransport(client.Net)]
+	if !ok {
+		return nil, newError(name, server.String(), fmt.Errorf("unknown transport %q", client.Net))
+	}
+
+	reply, err := transport.RoundTrip(ctx, server, req)
+	if err != nil {
+		switch {
+		case isLimitExceeded(err):
+			return nil, newError(name, server.String(), fmt.Errorf("%w: %w", err, ErrServerMisbehaving))
+		case errors.Is(err, errTLSHandshake):
+			// Handshake errors are not likely to be temporary.
+			return nil, newError(name, server.String(), err)
+		}
+
+		return nil, newError(name, server.String(), temporary(err))
+	}
+
+	if err := validateReply(req, reply); err != nil {
+		// A misbehaving (or spoofed) reply is worth retrying.
+		return nil, newError(name, server.String(), temporary(err))
+	}
+
+	return reply, nil
+}
+
+// questionName returns the name being queried by req.
+func questionName(req *dns.Msg) string {
+	if len(req.Question) > 0 {
+		return req.Question[0].Name
 	}
+	return ""
+}
+
+// setID sets the ID of req using the injected source of randomness, if any
+// (by default, IDs are chosen using a cryptographically secure source).
+func (r *dnsResolver) setID(req *dns.Msg) {
+	if r.rand != nil {
+		req.Id = uint16(r.rand.IntN(1 << 16))
+		return
+	}
+
+	req.Id = dns.Id()
+}
+
+// randomizeNameCase randomly changes the case of each letter in name (DNS 0x20).
+// See: https://datatracker.ietf.org/doc/html/draft-vixie-dnsext-dns0x20-00
+func (r *dnsResolver) randomizeNameCase(name string) string {
+	intN := rand.IntN
+	if r.rand != nil {
+		intN = r.rand.IntN
+	}
+
+	b := []byte(name)
+	for i, c := range b {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+			if intN(2) == 0 {
+				b[i] = c ^ 0x20
+			}
+		}
+	}
+	return string(b)
+}
+
+func (r *dnsResolver) newClient() *dns.Client {
+	return &dns.Client{
+		Net:       string(r.transport),
+		TLSConfig: r.tlsConfig,
+		Timeout:   r.timeout,
+	}
+}
+
+// Close closes the resolver, subsequent queries fail with net.ErrClosed.
+// Resolvers derived using With or Clone are not affected.
+func (r *dnsResolver) Close() error {
+	r.closed.Store(true)
+
+	for _, transport := range r.transports {
+		if t, ok := transport.(interface{ CloseIdleConnections() }); ok {
+			t.CloseIdleConnections()
+		}
+	}
+
+	return nil
+}
+
+func (r *dnsResolver) describe() Description {
+	attrs := map[string]string{
+		"server":    r.server.String(),
+		"transport": string(r.transport),
+		"timeout":   r.timeout.String(),
+	}
+	if r.privacyProfile != "" {
+		attrs["privacy_profile"] = string(r.privacyProfile)
+	}
+	if r.tsigKey != nil {
+		attrs["tsig"] = r.tsigKey.Name
+	}
+	if r.attempts > 1 {
+		attrs["attempts"] = strconv.Itoa(r.attempts)
+	}
+
+	return Description{Type: "dns", Attributes: attrs}
 }
@@ -13,8 +13,9 @@ import (
 	"context"
 	"net"
 	"net/netip"
+	"slices"
 
-	"github.com/noisysockets/resolver/internal/addrselect"
+	"github.com/noisysockets/resolver/addrselect"
 	"github.com/noisysockets/util/defaults"
 	"github.com/noisysockets/util/ptr"
 )
@@ -94,6 +95,7 @@ func (r *dns64Resolver) LookupNetIP(ctx context.Context, network, host string) (
 	}
 
 	addrselect.SortByRFC6724(dial, addrs)
+	traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
 
 	return addrs, nil
 }
@@ -110,3 +112,16 @@ func (r *dns64Resolver) synthesizeAddr(addr netip.Addr) netip.Addr {
 
 	return netip.AddrFrom16(ipv6Addr)
 }
+
+// Close closes the underlying resolver.
+func (r *dns64Resolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *dns64Resolver) describe() Description {
+	return Description{
+		Type:       "dns64",
+		Attributes: map[string]string{"prefix": r.prefix.String()},
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
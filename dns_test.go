@@ -10,12 +10,23 @@
 package resolver_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/miekg/dns"
 	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
 	"github.com/noisysockets/util/ptr"
 	"github.com/stretchr/testify/require"
 )
@@ -66,3 +77,904 @@ func TestDNSResolver(t *testing.T) {
 		require.ElementsMatch(t, expected, addrs)
 	})
 }
+
+func TestDNSResolverRequireAD(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		q := req.Question[0]
+		reply.Answer = []dns.RR{mustRR(t, q.Name+" 300 IN A 192.0.2.1")}
+		// Only the signed zone is authenticated.
+		reply.AuthenticatedData = req.AuthenticatedData && dns.IsSubDomain("signed.example.", q.Name)
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:    server,
+		RequireAD: []string{"signed.example", "unsigned.example"},
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.signed.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.unsigned.example")
+		require.Error(t, err)
+
+		var dnsErr *net.DNSError
+		require.True(t, errors.As(err, &dnsErr))
+		require.Equal(t, resolver.ErrUnauthenticated.Error(), dnsErr.Err)
+	})
+
+	t.Run("Not Required", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+}
+
+func TestDNSResolverValidation(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		q := req.Question[0]
+		switch q.Name {
+		case "alias.example.":
+			reply.Answer = []dns.RR{
+				mustRR(t, "alias.example. 300 IN CNAME www.example."),
+				mustRR(t, "www.example. 300 IN A 192.0.2.1"),
+			}
+		case "reordered.example.":
+			reply.Answer = []dns.RR{
+				mustRR(t, "www.example. 300 IN A 192.0.2.1"),
+				mustRR(t, "reordered.example. 300 IN CNAME www.example."),
+			}
+		case "question.example.":
+			reply.Question[0].Name = "other.example."
+			reply.Answer = []dns.RR{mustRR(t, "other.example. 300 IN A 192.0.2.1")}
+		case "unsolicited.example.":
+			reply.Answer = []dns.RR{
+				mustRR(t, "unsolicited.example. 300 IN A 192.0.2.1"),
+				mustRR(t, "bank.example. 300 IN A 192.0.2.66"),
+			}
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	})
+
+	for _, host := range []string{"alias.example", "reordered.example"} {
+		t.Run("Valid "+host, func(t *testing.T) {
+			addrs, err := res.LookupNetIP(context.Background(), "ip4", host)
+			require.NoError(t, err)
+
+			require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+		})
+	}
+
+	for _, host := range []string{"question.example", "unsolicited.example"} {
+		t.Run("Invalid "+host, func(t *testing.T) {
+			_, err := res.LookupNetIP(context.Background(), "ip4", host)
+			require.ErrorContains(t, err, resolver.ErrServerMisbehaving.Error())
+		})
+	}
+}
+
+func TestDNSResolverLookupAddr(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		// An RFC 2317 classless delegation of 192.0.2.64/26, the server
+		// doesn't follow aliases itself.
+		q := req.Question[0]
+		switch q.Name {
+		case "65.2.0.192.in-addr.arpa.":
+			reply.Answer = []dns.RR{mustRR(t, "65.2.0.192.in-addr.arpa. 300 IN CNAME 65.64/26.2.0.192.in-addr.arpa.")}
+		case "65.64/26.2.0.192.in-addr.arpa.":
+			reply.Answer = []dns.RR{mustRR(t, "65.64/26.2.0.192.in-addr.arpa. 300 IN PTR host.example.")}
+		case "1.2.0.192.in-addr.arpa.":
+			reply.Answer = []dns.RR{mustRR(t, "1.2.0.192.in-addr.arpa. 300 IN PTR gateway.example.")}
+		default:
+			reply.Rcode = dns.RcodeNameError
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	})
+
+	t.Run("Direct", func(t *testing.T) {
+		names, err := res.LookupAddr(context.Background(), "192.0.2.1")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"gateway.example."}, names)
+	})
+
+	t.Run("Classless Delegation", func(t *testing.T) {
+		names, err := res.LookupAddr(context.Background(), "192.0.2.65")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"host.example."}, names)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := res.LookupAddr(context.Background(), "192.0.2.2")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("Invalid Address", func(t *testing.T) {
+		_, err := res.LookupAddr(context.Background(), "not-an-address")
+		require.Error(t, err)
+	})
+}
+
+func TestDNSResolverLookupSRV(t *testing.T) {
+	server := dnstest.NewServer(dnstest.Records{
+		"_http._tcp.example.com":  {"SRV 20 0 80 backup.example.com.", "SRV 10 5 8080 www.example.com."},
+		"_ldap._tcp.example.com":  {"CNAME _ldap._tcp.corp.example."},
+		"_ldap._tcp.corp.example": {"SRV 0 0 389 ldap.corp.example."},
+	})
+	t.Cleanup(server.Close)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server.Addr,
+	})
+
+	cname, srvs, err := res.LookupSRV(context.Background(), "http", "tcp", "example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, "_http._tcp.example.com.", cname)
+	require.Equal(t, []*net.SRV{
+		{Target: "www.example.com.", Port: 8080, Priority: 10, Weight: 5},
+		{Target: "backup.example.com.", Port: 80, Priority: 20},
+	}, srvs)
+
+	cname, srvs, err = res.LookupSRV(context.Background(), "ldap", "tcp", "example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, "_ldap._tcp.corp.example.", cname)
+	require.Equal(t, []*net.SRV{{Target: "ldap.corp.example.", Port: 389}}, srvs)
+
+	_, _, err = res.LookupSRV(context.Background(), "ftp", "tcp", "example.com")
+	require.True(t, resolver.IsNXDomain(err))
+}
+
+func TestDNSResolverLookupMX(t *testing.T) {
+	server := dnstest.NewServer(dnstest.Records{
+		"example.com":      {"MX 20 mx2.example.com.", "MX 10 mx1.example.com."},
+		"mx1.example.com":  {"A 192.0.2.1"},
+		"mx2.example.com":  {"A 192.0.2.2"},
+		"mail.example.net": {"CNAME example.com."},
+	})
+	t.Cleanup(server.Close)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server.Addr,
+	})
+
+	expected := []*net.MX{
+		{Host: "mx1.example.com.", Pref: 10},
+		{Host: "mx2.example.com.", Pref: 20},
+	}
+
+	mxs, err := res.LookupMX(context.Background(), "example.com")
+	require.NoError(t, err)
+	require.Equal(t, expected, mxs)
+
+	mxs, err = res.LookupMX(context.Background(), "mail.example.net")
+	require.NoError(t, err)
+	require.Equal(t, expected, mxs)
+
+	_, err = res.LookupMX(context.Background(), "mx1.example.com")
+	require.True(t, resolver.IsNoData(err))
+
+	_, err = res.LookupMX(context.Background(), "missing.example.com")
+	require.True(t, resolver.IsNXDomain(err))
+
+	t.Run("Wrapped", func(t *testing.T) {
+		wrapped := resolver.Retry(resolver.Sequential(resolver.Literal(), res), nil)
+
+		hosts, err := resolver.LookupMailHosts(context.Background(), wrapped, "example.com")
+		require.NoError(t, err)
+		require.Equal(t, []string{"mx1.example.com:25", "mx2.example.com:25"}, hosts)
+	})
+}
+
+func TestDNSResolverCaseRandomization(t *testing.T) {
+	var tcpQueries atomic.Int32
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+			tcpQueries.Add(1)
+		} else {
+			// Simulate a spoofed reply that doesn't preserve the query case.
+			reply.Question[0].Name = strings.ToLower(reply.Question[0].Name)
+		}
+
+		reply.Answer = []dns.RR{mustRR(t, reply.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:            server,
+		CaseRandomization: ptr.To(true),
+	})
+
+	// Long enough that the random case is unlikely to be all lowercase.
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "thequickbrownfoxjumpsoverthelazydog.example")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	require.Equal(t, int32(1), tcpQueries.Load())
+}
+
+func TestDNSResolverCaseRandomizationNoQuestion(t *testing.T) {
+	var tcpQueries atomic.Int32
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+
+		if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+			tcpQueries.Add(1)
+
+			reply.SetReply(req)
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+		} else {
+			// Simulate a spoofed reply that omits the question, so that there
+			// is no query case to check.
+			rcode := dns.RcodeNameError
+			if strings.HasPrefix(strings.ToLower(req.Question[0].Name), "formerr.") {
+				rcode = dns.RcodeFormatError
+			}
+
+			reply.SetRcode(req, rcode)
+			reply.Question = nil
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:            server,
+		CaseRandomization: ptr.To(true),
+	})
+
+	t.Run("NXDOMAIN", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "nxdomain.example")
+		require.Error(t, err)
+		require.False(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("FORMERR", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "formerr.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+		require.Equal(t, int32(1), tcpQueries.Load())
+	})
+}
+
+func TestDNSResolverCNAMEChain(t *testing.T) {
+	server := startRecordServer(t,
+		[]dns.RR{mustRR(t, "alias.example. 300 IN CNAME www.example.")},
+		[]dns.RR{mustRR(t, "www.example. 300 IN A 192.0.2.1")},
+		[]dns.RR{mustRR(t, "loop1.example. 300 IN CNAME loop2.example.")},
+		[]dns.RR{mustRR(t, "loop2.example. 300 IN CNAME loop1.example.")},
+		[]dns.RR{mustRR(t, "chain1.example. 300 IN CNAME chain2.example.")},
+		[]dns.RR{mustRR(t, "chain2.example. 300 IN CNAME chain3.example.")},
+		[]dns.RR{mustRR(t, "chain3.example. 300 IN CNAME www.example.")},
+	)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:        server,
+		MaxCNAMEChain: ptr.To(2),
+	})
+
+	t.Run("Follow", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "alias.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Loop", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "loop1.example")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.Equal(t, resolver.ErrCNAMELoop.Error(), dnsErr.Err)
+	})
+
+	t.Run("Too Long", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "chain1.example")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.Equal(t, resolver.ErrCNAMEChainTooLong.Error(), dnsErr.Err)
+	})
+}
+
+func TestDNSResolverLocalAddr(t *testing.T) {
+	sourcePorts := make(chan int, 2)
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		sourcePorts <- w.RemoteAddr().(*net.UDPAddr).Port
+
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	// Find a free local port.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	localAddr := netip.MustParseAddrPort(pc.LocalAddr().String())
+	require.NoError(t, pc.Close())
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:    server,
+		LocalAddr: &localAddr,
+	})
+
+	_, err = res.LookupNetIP(context.Background(), "ip", "www.example")
+	require.Error(t, err)
+
+	require.Equal(t, int(localAddr.Port()), <-sourcePorts)
+	require.Equal(t, int(localAddr.Port()), <-sourcePorts)
+}
+
+func TestDNSResolverResponseLimits(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		for i := 1; i <= 10; i++ {
+			reply.Answer = append(reply.Answer, mustRR(t, fmt.Sprintf("%s 300 IN A 192.0.2.%d", req.Question[0].Name, i)))
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	t.Run("Within Limits", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Len(t, addrs, 10)
+	})
+
+	t.Run("Too Many Records", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+			ResponseLimits: &resolver.ResponseLimits{
+				MaxRecords: 5,
+			},
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.ErrorContains(t, err, resolver.ErrServerMisbehaving.Error())
+	})
+
+	t.Run("Too Large", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+			ResponseLimits: &resolver.ResponseLimits{
+				MaxMessageSize: 100,
+			},
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.ErrorContains(t, err, resolver.ErrServerMisbehaving.Error())
+	})
+}
+
+func TestDNSResolverHeaderFlags(t *testing.T) {
+	flags := make(chan [3]bool, 1)
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		var do bool
+		if opt := req.IsEdns0(); opt != nil {
+			do = opt.Do()
+		}
+		flags <- [3]bool{req.RecursionDesired, req.CheckingDisabled, do}
+
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	t.Run("Defaults", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, [3]bool{true, false, false}, <-flags)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:           server,
+			RecursionDesired: ptr.To(false),
+			CheckingDisabled: ptr.To(true),
+			DNSSECOK:         ptr.To(true),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, [3]bool{false, true, true}, <-flags)
+	})
+}
+
+func TestDNSResolverTruncation(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+			reply.Answer = append(reply.Answer, mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.2"))
+		} else {
+			reply.Truncated = true
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	t.Run("TCP", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Len(t, addrs, 2)
+	})
+
+	t.Run("Accept", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:           server,
+			TruncationPolicy: ptr.To(resolver.TruncationPolicyAccept),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Len(t, addrs, 1)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:           server,
+			TruncationPolicy: ptr.To(resolver.TruncationPolicyError),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.Equal(t, resolver.ErrTruncated.Error(), dnsErr.Err)
+	})
+}
+
+func TestDNSResolverOversizedReply(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		// Larger than the advertised UDP buffer size, but not truncated.
+		for i := 0; i < 200; i++ {
+			reply.Answer = append(reply.Answer, mustRR(t, fmt.Sprintf("%s 300 IN A 192.0.%d.%d", req.Question[0].Name, i/250, i%250+1)))
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	t.Run("TCP", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Len(t, addrs, 200)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:           server,
+			TruncationPolicy: ptr.To(resolver.TruncationPolicyError),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.Equal(t, resolver.ErrTruncated.Error(), dnsErr.Err)
+	})
+}
+
+func TestDNSResolverAttempts(t *testing.T) {
+	var queries atomic.Int32
+	var idsMu sync.Mutex
+	ids := map[uint16]bool{}
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		idsMu.Lock()
+		ids[req.Id] = true
+		idsMu.Unlock()
+
+		// Drop the first two queries.
+		if queries.Add(1) <= 2 {
+			return
+		}
+
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	t.Run("Single Attempt", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:  server,
+			Timeout: ptr.To(100 * time.Millisecond),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.True(t, dnsErr.IsTimeout)
+		require.Equal(t, int32(1), queries.Load())
+	})
+
+	t.Run("Retried", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:   server,
+			Timeout:  ptr.To(100 * time.Millisecond),
+			Attempts: ptr.To(3),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+		require.Equal(t, int32(3), queries.Load())
+	})
+
+	t.Run("Fresh IDs", func(t *testing.T) {
+		queries.Store(0)
+		idsMu.Lock()
+		clear(ids)
+		idsMu.Unlock()
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:   server,
+			Timeout:  ptr.To(100 * time.Millisecond),
+			Attempts: ptr.To(3),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		idsMu.Lock()
+		defer idsMu.Unlock()
+		require.Len(t, ids, 3)
+	})
+
+	t.Run("Per Lookup Override", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:  server,
+			Timeout: ptr.To(100 * time.Millisecond),
+		})
+
+		ctx := resolver.WithLookupAttempts(context.Background(), 3)
+		addrs, err := res.LookupNetIP(ctx, "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+		require.Equal(t, int32(3), queries.Load())
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:   server,
+			Timeout:  ptr.To(100 * time.Millisecond),
+			Attempts: ptr.To(2),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.Error(t, err)
+		require.Equal(t, int32(2), queries.Load())
+	})
+
+	t.Run("TCP Not Retried", func(t *testing.T) {
+		queries.Store(0)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:    server,
+			Transport: ptr.To(resolver.DNSTransportTCP),
+			Timeout:   ptr.To(100 * time.Millisecond),
+			Attempts:  ptr.To(3),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.Error(t, err)
+		require.Equal(t, int32(1), queries.Load())
+	})
+}
+
+func TestDNSResolverNegativeAnswers(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		q := req.Question[0]
+		switch {
+		case q.Name != "www.example.":
+			reply.Rcode = dns.RcodeNameError
+		case q.Qtype == dns.TypeA:
+			reply.Answer = []dns.RR{mustRR(t, "www.example. 300 IN A 192.0.2.1")}
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	})
+
+	t.Run("NXDOMAIN", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip", "missing.example")
+		require.True(t, resolver.IsNXDomain(err))
+		require.False(t, resolver.IsNoData(err))
+	})
+
+	t.Run("NODATA", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip6", "www.example")
+		require.True(t, resolver.IsNoData(err))
+		require.False(t, resolver.IsNXDomain(err))
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.True(t, dnsErr.IsNotFound)
+	})
+}
+
+func TestDNSResolverLogger(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Rcode = dns.RcodeNameError
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	var buf bytes.Buffer
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+		Logger: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+
+	_, err := res.LookupNetIP(context.Background(), "ip4", "missing.example")
+	require.Error(t, err)
+
+	require.Contains(t, buf.String(), `msg="DNS query" name=missing.example. server=`+server.String())
+	require.Contains(t, buf.String(), "transport=udp")
+	require.Contains(t, buf.String(), "type=A rcode=NXDOMAIN")
+}
+
+func TestDNSResolverHooks(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	errBlocked := errors.New("blocked")
+
+	var queries, responses, failures atomic.Int32
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+		OnQuery: func(_ context.Context, req *dns.Msg, info resolver.QueryInfo) error {
+			queries.Add(1)
+
+			require.Equal(t, server, info.Server)
+			require.Equal(t, resolver.DNSTransportUDP, info.Transport)
+
+			if req.Question[0].Name == "blocked.example." {
+				return errBlocked
+			}
+			return nil
+		},
+		OnResponse: func(_ context.Context, _, reply *dns.Msg, info resolver.QueryInfo) {
+			responses.Add(1)
+
+			require.NotZero(t, info.Duration)
+
+			// Rewrite the answer.
+			reply.Answer[0].(*dns.A).A = net.ParseIP("192.0.2.2")
+		},
+		OnError: func(_ context.Context, _ *dns.Msg, err error, _ resolver.QueryInfo) {
+			failures.Add(1)
+
+			require.ErrorIs(t, err, errBlocked)
+		},
+	})
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.2")}, addrs)
+
+	_, err = res.LookupNetIP(context.Background(), "ip4", "blocked.example")
+	require.ErrorIs(t, err, errBlocked)
+
+	require.Equal(t, int32(2), queries.Load())
+	require.Equal(t, int32(1), responses.Load())
+	require.Equal(t, int32(1), failures.Load())
+}
+
+func TestDNSResolverExchange(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		if req.Question[0].Name == "example." {
+			reply.Answer = []dns.RR{mustRR(t, "example. 300 IN MX 10 mail.example.")}
+		} else {
+			reply.Rcode = dns.RcodeNameError
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	})
+
+	req := &dns.Msg{}
+	req.SetQuestion("example.", dns.TypeMX)
+
+	reply, err := res.Exchange(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, reply.Answer, 1)
+	require.Equal(t, "mail.example.", reply.Answer[0].(*dns.MX).Mx)
+
+	// The return code is not inspected.
+	req.SetQuestion("missing.example.", dns.TypeMX)
+
+	reply, err = res.Exchange(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, dns.RcodeNameError, reply.Rcode)
+}
+
+func TestDNSResolverCancellation(t *testing.T) {
+	t.Run("UDP", func(t *testing.T) {
+		// Never reply to queries.
+		server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {}))
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:  server,
+			Timeout: ptr.To(time.Minute),
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := res.LookupNetIP(ctx, "ip4", "www.example")
+		require.ErrorIs(t, err, context.Canceled)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("TLS Handshake", func(t *testing.T) {
+		// Accept connections, but never complete the TLS handshake.
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = lis.Close()
+		})
+
+		go func() {
+			for {
+				conn, err := lis.Accept()
+				if err != nil {
+					return
+				}
+				t.Cleanup(func() {
+					_ = conn.Close()
+				})
+			}
+		}()
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:    netip.MustParseAddrPort(lis.Addr().String()),
+			Transport: ptr.To(resolver.DNSTransportTLS),
+			Timeout:   ptr.To(time.Minute),
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err = res.LookupNetIP(ctx, "ip4", "www.example")
+		require.ErrorIs(t, err, context.Canceled)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestDNSResolverWith(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	var transports []resolver.DNSTransport
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+		OnQuery: func(_ context.Context, _ *dns.Msg, info resolver.QueryInfo) error {
+			transports = append(transports, info.Transport)
+			return nil
+		},
+	})
+
+	derived := res.With(resolver.DNSResolverConfig{
+		Transport: ptr.To(resolver.DNSTransportTCP),
+		Timeout:   ptr.To(time.Second),
+	})
+
+	for _, r := range []resolver.Resolver{res, derived, res.Clone()} {
+		_, err := r.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+	}
+
+	// The hook is inherited, but the transport is overridden.
+	require.Equal(t, []resolver.DNSTransport{
+		resolver.DNSTransportUDP,
+		resolver.DNSTransportTCP,
+		resolver.DNSTransportUDP,
+	}, transports)
+
+	// Statistics are shared.
+	require.Equal(t, uint64(3), res.Stats()[0].Queries)
+	require.Equal(t, res.Stats(), derived.Stats())
+}
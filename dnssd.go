@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dnssdServicesName is the name used to enumerate the service types
+// advertised on a domain (RFC 6763 section 9).
+const dnssdServicesName = "_services._dns-sd._udp."
+
+// MDNSService is a service instance advertised using DNS-Based Service
+// Discovery (RFC 6763).
+type MDNSService struct {
+	// Instance is the user friendly name of the service instance, eg.
+	// "Living Room Printer". It may contain any UTF-8 characters, but must be
+	// at most 63 bytes.
+	Instance string
+	// Service is the service type, eg. "_http._tcp".
+	Service string
+	// Port is the port the service is listening on.
+	Port uint16
+	// Text is optional metadata about the service, as "key=value" (or "key",
+	// for boolean attributes) strings (RFC 6763 section 6).
+	Text []string
+}
+
+// Name returns the fully qualified name of the service instance, eg.
+// "Living Room Printer._http._tcp.local.".
+func (s *MDNSService) Name() string {
+	return escapeLabel(s.Instance) + "." + s.serviceName()
+}
+
+func (s *MDNSService) serviceName() string {
+	return dns.CanonicalName(s.Service + ".local.")
+}
+
+func (s *MDNSService) validate() error {
+	if s.Instance == "" || len(s.Instance) > 63 {
+		return fmt.Errorf("invalid instance name: %q", s.Instance)
+	}
+
+	labels := dns.SplitDomainName(s.Service)
+	if len(labels) != 2 || len(labels[0]) < 2 || len(labels[0]) > 16 || labels[0][0] != '_' ||
+		(labels[1] != "_tcp" && labels[1] != "_udp") {
+		return fmt.Errorf("invalid service type: %q", s.Service)
+	}
+
+	for _, kv := range s.Text {
+		if kv == "" || kv[0] == '=' || len(kv) > 255 {
+			return fmt.Errorf("invalid text attribute: %q", kv)
+		}
+	}
+
+	return nil
+}
+
+// records returns the records advertising the service on hostname.
+func (s *MDNSService) records(hostname string, ttl uint32) []dns.RR {
+	name := s.Name()
+	service := s.serviceName()
+
+	// An empty TXT record must contain a single empty string (RFC 6763
+	// section 6.1).
+	txt := s.Text
+	if len(txt) == 0 {
+		txt = []string{""}
+	}
+
+	return []dns.RR{
+		&dns.PTR{
+			Hdr: dns.RR_Header{Name: dnssdServicesName + "local.", Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl},
+			Ptr: service,
+		},
+		&dns.PTR{
+			Hdr: dns.RR_Header{Name: service, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl},
+			Ptr: name,
+		},
+		&dns.SRV{
+			Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeSRV, Class: dns.ClassINET | mdnsCacheFlush, Ttl: ttl},
+			Port:   s.Port,
+			Target: hostname,
+		},
+		&dns.TXT{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET | mdnsCacheFlush, Ttl: ttl},
+			Txt: txt,
+		},
+	}
+}
+
+// escapeLabel returns the presentation format of a single label, escaped the
+// same way as names unpacked from the wire, so they can be compared.
+func escapeLabel(label string) string {
+	var sb strings.Builder
+	for i := 0; i < len(label); i++ {
+		switch c := label[i]; {
+		case strings.IndexByte(`.()"; @\`, c) >= 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// Register advertises a service instance, running on the local machine, over
+// mDNS. If the responder is serving, the service is announced once probing
+// shows that no other host is using its instance name (if one is, the service
+// is withdrawn). Registering an instance that is already registered replaces
+// it.
+func (r *MDNSResponder) Register(service *MDNSService) error {
+	if err := service.validate(); err != nil {
+		return err
+	}
+
+	var added []dns.RR
+
+	r.mu.Lock()
+	r.remove(service.Name())
+	for _, rr := range service.records(r.hostname, r.ttl) {
+		// The service type enumeration record is shared by instances.
+		if !slices.ContainsFunc(r.records, func(existing dns.RR) bool {
+			return dns.IsDuplicate(existing, rr)
+		}) {
+			r.records = append(r.records, rr)
+			added = append(added, rr)
+		}
+	}
+	if r.announce && len(added) > 0 {
+		for _, c := range r.conns {
+			go r.claim(c, r.startProbe(c, added), added)
+		}
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Deregister stops advertising a service instance, peers are told to forget
+// about it if the responder is serving. It returns false if the instance was
+// not registered.
+func (r *MDNSResponder) Deregister(service *MDNSService) bool {
+	r.mu.Lock()
+	removed := r.remove(service.Name())
+	conns := r.connections()
+	r.mu.Unlock()
+
+	if len(removed) == 0 {
+		return false
+	}
+
+	if r.announce {
+		for _, c := range conns {
+			r.advertise(c, removed, true)
+		}
+	}
+
+	return true
+}
+
+// remove removes the records of a service instance, along with the service
+// type enumeration record if it was the last instance of its type. The
+// removed records are returned, r.mu must be held.
+func (r *MDNSResponder) remove(name string) []dns.RR {
+	var removed []dns.RR
+	var service string
+
+	r.records = slices.DeleteFunc(r.records, func(rr dns.RR) bool {
+		owned := strings.EqualFold(rr.Header().Name, name)
+		if ptr, ok := rr.(*dns.PTR); ok && strings.EqualFold(ptr.Ptr, name) {
+			service = ptr.Header().Name
+			owned = true
+		}
+		if owned {
+			removed = append(removed, rr)
+		}
+		return owned
+	})
+
+	if service == "" {
+		return removed
+	}
+
+	inUse := slices.ContainsFunc(r.records, func(rr dns.RR) bool {
+		return rr.Header().Rrtype == dns.TypePTR && strings.EqualFold(rr.Header().Name, service)
+	})
+	if !inUse {
+		r.records = slices.DeleteFunc(r.records, func(rr dns.RR) bool {
+			ptr, ok := rr.(*dns.PTR)
+			if ok && strings.EqualFold(ptr.Ptr, service) {
+				removed = append(removed, rr)
+			}
+			return ok && strings.EqualFold(ptr.Ptr, service)
+		})
+	}
+
+	return removed
+}
+
+// connections returns the connections being served, r.mu must be held.
+func (r *MDNSResponder) connections() []*mdnsConn {
+	conns := make([]*mdnsConn, 0, len(r.conns))
+	for _, c := range r.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
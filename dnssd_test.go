@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMDNSServiceRegistration(t *testing.T) {
+	responder, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+		Hostname: "node1",
+		Addrs:    []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+		Announce: ptr.To(false),
+	})
+	require.NoError(t, err)
+
+	service := &resolver.MDNSService{
+		Instance: "Living Room. Printer",
+		Service:  "_ipp._tcp",
+		Port:     631,
+		Text:     []string{"txtvers=1", "color"},
+	}
+
+	require.Equal(t, `Living\ Room\.\ Printer._ipp._tcp.local.`, service.Name())
+	require.NoError(t, responder.Register(service))
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- responder.Serve(ctx, pc)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	client := &dns.Client{Timeout: 500 * time.Millisecond}
+	addr := pc.LocalAddr().String()
+
+	t.Run("Enumerate Types", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("_services._dns-sd._udp.local.", dns.TypePTR)
+
+		reply, _, err := client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "_ipp._tcp.local.", reply.Answer[0].(*dns.PTR).Ptr)
+	})
+
+	t.Run("Browse", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("_ipp._tcp.local.", dns.TypePTR)
+
+		reply, _, err := client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, service.Name(), reply.Answer[0].(*dns.PTR).Ptr)
+
+		// The SRV, TXT, and address records are included as additional records.
+		var types []uint16
+		for _, rr := range reply.Extra {
+			types = append(types, rr.Header().Rrtype)
+		}
+		require.ElementsMatch(t, []uint16{dns.TypeSRV, dns.TypeTXT, dns.TypeA}, types)
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion(service.Name(), dns.TypeSRV)
+
+		reply, _, err := client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		require.Len(t, reply.Answer, 1)
+		srv := reply.Answer[0].(*dns.SRV)
+		require.Equal(t, "node1.local.", srv.Target)
+		require.Equal(t, uint16(631), srv.Port)
+
+		req = new(dns.Msg).SetQuestion(service.Name(), dns.TypeTXT)
+
+		reply, _, err = client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, []string{"txtvers=1", "color"}, reply.Answer[0].(*dns.TXT).Txt)
+	})
+
+	t.Run("Deregister", func(t *testing.T) {
+		require.True(t, responder.Deregister(service))
+		require.False(t, responder.Deregister(service))
+
+		req := new(dns.Msg).SetQuestion("_services._dns-sd._udp.local.", dns.TypePTR)
+
+		_, _, err := client.Exchange(req, addr)
+		require.Error(t, err)
+	})
+}
+
+func TestMDNSServiceValidation(t *testing.T) {
+	responder, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+		Hostname: "node1",
+		Addrs:    []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+	})
+	require.NoError(t, err)
+
+	require.Error(t, responder.Register(&resolver.MDNSService{Instance: "a", Service: "http._tcp"}))
+	require.Error(t, responder.Register(&resolver.MDNSService{Instance: "a", Service: "_http._sctp"}))
+	require.Error(t, responder.Register(&resolver.MDNSService{Instance: "", Service: "_http._tcp"}))
+	require.Error(t, responder.Register(&resolver.MDNSService{Instance: "a", Service: "_http._tcp", Text: []string{"=x"}}))
+}
@@ -0,0 +1,773 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver/addrselect"
+)
+
+var _ Resolver = (*dnssecResolver)(nil)
+
+// SecurityStatus is the DNSSEC security status of an answer, as defined in
+// RFC 4035 section 4.3.
+type SecurityStatus string
+
+const (
+	// SecurityStatusSecure means that a chain of trust from a trust anchor to
+	// the answer was successfully validated.
+	SecurityStatusSecure SecurityStatus = "secure"
+	// SecurityStatusInsecure means that there is proof that no chain of trust
+	// exists for the answer (eg. the zone is unsigned).
+	SecurityStatusInsecure SecurityStatus = "insecure"
+	// SecurityStatusBogus means that a chain of trust should exist for the
+	// answer, but it could not be validated.
+	SecurityStatusBogus SecurityStatus = "bogus"
+)
+
+// RootTrustAnchors are the DS records of the IANA root zone key signing keys.
+// See: https://data.iana.org/root-anchors/root-anchors.xml
+var RootTrustAnchors = []*dns.DS{
+	{
+		Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET},
+		KeyTag:     20326,
+		Algorithm:  dns.RSASHA256,
+		DigestType: dns.SHA256,
+		Digest:     "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	},
+	{
+		Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET},
+		KeyTag:     38696,
+		Algorithm:  dns.RSASHA256,
+		DigestType: dns.SHA256,
+		Digest:     "683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+	},
+}
+
+// DNSSECResolverConfig is the configuration for a DNSSEC validating resolver.
+type DNSSECResolverConfig struct {
+	// Upstream is the configuration of the recursive DNS server used to fetch
+	// records and signatures. The upstream does not need to perform validation
+	// itself.
+	Upstream DNSResolverConfig
+	// TrustAnchors is an optional list of DS records to use as trust anchors.
+	// By default, the IANA root zone trust anchors are used.
+	TrustAnchors []*dns.DS
+}
+
+// maxCachedChains is the maximum number of chains of trust (one per validated
+// name) that are cached.
+const maxCachedChains = 4096
+
+// dnssecResolver is a resolver that validates answers using DNSSEC.
+type dnssecResolver struct {
+	upstream    *dnsResolver
+	anchors     map[string][]*dns.DS
+	dialContext DialContextFunc
+	mu          sync.Mutex
+	chains      map[string]*chainOfTrust
+}
+
+// chainOfTrust is the result of validating the chain of trust for a name.
+type chainOfTrust struct {
+	// zone is the closest validated zone apex enclosing the name.
+	zone string
+	// keys are the validated zone signing keys of the zone.
+	keys    []*dns.DNSKEY
+	status  SecurityStatus
+	expires time.Time
+}
+
+// DNSSEC returns a resolver that performs DNSSEC validation of answers, by
+// fetching DNSKEY, DS and RRSIG records and validating them up to a trust
+// anchor.
+func DNSSEC(conf DNSSECResolverConfig) *dnssecResolver {
+	upstream := DNS(conf.Upstream)
+
+	trustAnchors := conf.TrustAnchors
+	if len(trustAnchors) == 0 {
+		trustAnchors = RootTrustAnchors
+	}
+
+	anchors := make(map[string][]*dns.DS)
+	for _, ds := range trustAnchors {
+		zone := dns.CanonicalName(ds.Hdr.Name)
+		anchors[zone] = append(anchors[zone], ds)
+	}
+
+	return &dnssecResolver{
+		upstream:    upstream,
+		anchors:     anchors,
+		dialContext: upstream.dialContext,
+		chains:      make(map[string]*chainOfTrust),
+	}
+}
+
+func (r *dnssecResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, _, err := r.LookupNetIPSecure(ctx, network, host)
+	return addrs, err
+}
+
+// LookupNetIPSecure looks up host and returns the validated addresses along
+// with the DNSSEC security status of the answer. Bogus answers are never
+// returned, instead an error wrapping ErrBogus is returned.
+func (r *dnssecResolver) LookupNetIPSecure(ctx context.Context, network, host string) ([]netip.Addr, SecurityStatus, error) {
+	server := r.upstream.server.String()
+
+	if _, ok := dns.IsDomainName(host); !ok {
+		return nil, "", newError(host, server, ErrNoSuchHost)
+	}
+
+	name := dns.CanonicalName(host)
+
+	var qTypes []uint16
+	switch network {
+	case "ip":
+		qTypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	case "ip4":
+		qTypes = []uint16{dns.TypeA}
+	case "ip6":
+		qTypes = []uint16{dns.TypeAAAA}
+	default:
+		return nil, "", newError(host, server, ErrUnsupportedNetwork)
+	}
+
+	status := SecurityStatusSecure
+	var addrs []netip.Addr
+	var nxDomain bool
+	for _, qType := range qTypes {
+		reply, replyStatus, err := r.lookupSecure(ctx, name, qType)
+		if err != nil {
+			return nil, replyStatus, err
+		}
+		status = weakestStatus(status, replyStatus)
+
+		if reply.Rcode == dns.RcodeNameError {
+			nxDomain = true
+			continue
+		}
+
+		addrs = append(addrs, addrsFromAnswers(reply.Answer, name)...)
+	}
+
+	if nxDomain {
+		return nil, status, newError(host, server, ErrNoSuchHost)
+	}
+
+	if len(addrs) == 0 {
+		return nil, status, newError(host, server, ErrNoData)
+	}
+
+	if network != "ip4" {
+		dial := func(network, address string) (net.Conn, error) {
+			return r.dialContext(ctx, network, address)
+		}
+
+		addrselect.SortByRFC6724(dial, addrs)
+		traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
+	}
+
+	return addrs, status, nil
+}
+
+// lookupSecure queries name for records of the given type and validates the
+// reply, bogus replies are never returned.
+func (r *dnssecResolver) lookupSecure(ctx context.Context, name string, qType uint16) (*dns.Msg, SecurityStatus, error) {
+	reply, err := r.query(ctx, name, qType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	status, err := r.validateReply(ctx, name, qType, reply)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if status == SecurityStatusBogus {
+		return nil, status, newError(name, r.upstream.server.String(), ErrBogus)
+	}
+
+	return reply, status, nil
+}
+
+// query sends a DNSSEC enabled query to the upstream server.
+func (r *dnssecResolver) query(ctx context.Context, name string, qType uint16) (*dns.Msg, error) {
+	req := &dns.Msg{}
+	req.SetQuestion(name, qType)
+	r.upstream.setID(req)
+	req.SetEdns0(dns.DefaultMsgSize, true)
+	// We will perform validation ourselves.
+	req.CheckingDisabled = true
+
+	// Validation records are often too large for UDP, so go through Exchange
+	// which retries truncated replies over TCP.
+	reply, err := r.upstream.Exchange(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// NXDOMAIN replies are validated (using the denial of existence proofs).
+	if reply.Rcode != dns.RcodeNameError {
+		if dnsErr := rcodeError(name, r.upstream.server.String(), reply); dnsErr != nil {
+			return nil, dnsErr
+		}
+	}
+
+	return reply, nil
+}
+
+// validateReply determines the security status of a reply.
+func (r *dnssecResolver) validateReply(ctx context.Context, name string, qType uint16, reply *dns.Msg) (SecurityStatus, error) {
+	rrsets, sigs := groupRRsets(reply.Answer)
+
+	// Negative answers are validated using the signed NSEC/NSEC3 records in the
+	// authority section.
+	if len(rrsets) == 0 {
+		rrsets, sigs = groupRRsets(reply.Ns)
+
+		var denial []rrsetKey
+		for key := range rrsets {
+			if key.rrType == dns.TypeNSEC || key.rrType == dns.TypeNSEC3 {
+				denial = append(denial, key)
+			}
+		}
+
+		if len(denial) == 0 {
+			return r.unsignedStatus(ctx, name)
+		}
+
+		status := SecurityStatusSecure
+		for _, key := range denial {
+			rrsetStatus, _, err := r.validateRRset(ctx, rrsets[key], sigs[key])
+			if err != nil {
+				return "", err
+			}
+			status = weakestStatus(status, rrsetStatus)
+		}
+
+		if status != SecurityStatusSecure {
+			return status, nil
+		}
+
+		// Validly signed records are not enough, they must also prove the
+		// nonexistence of the name or type that was asked for.
+		nsecs, nsec3s := denialRecords(rrsets)
+		proven, optOut := proveDenial(name, qType, reply.Rcode == dns.RcodeNameError, nsecs, nsec3s)
+		if !proven {
+			return SecurityStatusBogus, nil
+		}
+
+		if optOut {
+			return SecurityStatusInsecure, nil
+		}
+
+		return SecurityStatusSecure, nil
+	}
+
+	status := SecurityStatusSecure
+	for key, rrset := range rrsets {
+		rrsetStatus, sig, err := r.validateRRset(ctx, rrset, sigs[key])
+		if err != nil {
+			return "", err
+		}
+
+		// An RRset synthesized from a wildcard is only secure if there is
+		// proof that no closer match exists (RFC 4035 section 5.3.4).
+		if rrsetStatus == SecurityStatusSecure && isWildcardExpansion(key.name, sig) {
+			rrsetStatus, err = r.validateWildcardExpansion(ctx, key.name, sig, reply.Ns)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		status = weakestStatus(status, rrsetStatus)
+	}
+
+	return status, nil
+}
+
+// validateRRset determines the security status of a single RRset. If it is
+// secure, the signature that was verified is returned too.
+func (r *dnssecResolver) validateRRset(ctx context.Context, rrset []dns.RR, sigs []*dns.RRSIG) (SecurityStatus, *dns.RRSIG, error) {
+	owner := dns.CanonicalName(rrset[0].Header().Name)
+	if len(sigs) == 0 {
+		status, err := r.unsignedStatus(ctx, owner)
+		return status, nil, err
+	}
+
+	signer := dns.CanonicalName(sigs[0].SignerName)
+	if !dns.IsSubDomain(signer, owner) {
+		return SecurityStatusBogus, nil, nil
+	}
+
+	chain, err := r.chainOfTrust(ctx, signer)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if chain.status != SecurityStatusSecure {
+		return chain.status, nil, nil
+	}
+
+	// The signer must be the apex of a validated zone.
+	if chain.zone != signer {
+		return SecurityStatusBogus, nil, nil
+	}
+
+	sig := r.verifiedSignature(rrset, sigs, chain.keys)
+	if sig == nil {
+		return SecurityStatusBogus, nil, nil
+	}
+
+	return SecurityStatusSecure, sig, nil
+}
+
+// validateWildcardExpansion determines the security status of an RRset at
+// name that was synthesized from a wildcard, using the signed NSEC/NSEC3
+// records in the authority section.
+func (r *dnssecResolver) validateWildcardExpansion(ctx context.Context, name string, sig *dns.RRSIG, ns []dns.RR) (SecurityStatus, error) {
+	rrsets, sigs := groupRRsets(ns)
+
+	status := SecurityStatusSecure
+	var proofs int
+	for key, rrset := range rrsets {
+		if key.rrType != dns.TypeNSEC && key.rrType != dns.TypeNSEC3 {
+			delete(rrsets, key)
+			continue
+		}
+
+		rrsetStatus, _, err := r.validateRRset(ctx, rrset, sigs[key])
+		if err != nil {
+			return "", err
+		}
+		status = weakestStatus(status, rrsetStatus)
+		proofs++
+	}
+
+	if proofs == 0 {
+		return SecurityStatusBogus, nil
+	}
+
+	if status != SecurityStatusSecure {
+		return status, nil
+	}
+
+	nsecs, nsec3s := denialRecords(rrsets)
+	proven, optOut := proveWildcardExpansion(name, int(sig.Labels), nsecs, nsec3s)
+	if !proven {
+		return SecurityStatusBogus, nil
+	}
+
+	if optOut {
+		return SecurityStatusInsecure, nil
+	}
+
+	return SecurityStatusSecure, nil
+}
+
+// unsignedStatus determines the security status of unsigned data for name,
+// which is only acceptable if name is proven to be in an insecure zone.
+func (r *dnssecResolver) unsignedStatus(ctx context.Context, name string) (SecurityStatus, error) {
+	chain, err := r.chainOfTrust(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if chain.status == SecurityStatusInsecure {
+		return SecurityStatusInsecure, nil
+	}
+
+	return SecurityStatusBogus, nil
+}
+
+// chainOfTrust walks the DNS tree from the closest trust anchor down to name,
+// validating each delegation along the way.
+func (r *dnssecResolver) chainOfTrust(ctx context.Context, name string) (*chainOfTrust, error) {
+	if chain, ok := r.cachedChain(name); ok {
+		return chain, nil
+	}
+
+	var anchor string
+	for zone := range r.anchors {
+		if dns.IsSubDomain(zone, name) && (anchor == "" || dns.CountLabel(zone) > dns.CountLabel(anchor)) {
+			anchor = zone
+		}
+	}
+
+	if anchor == "" {
+		return r.cacheChain(name, &chainOfTrust{status: SecurityStatusInsecure}, time.Hour), nil
+	}
+
+	chain, ok := r.cachedChain(anchor)
+	if !ok {
+		keys, ttl, status, err := r.fetchKeys(ctx, anchor, r.anchors[anchor])
+		if err != nil {
+			return nil, err
+		}
+
+		chain = r.cacheChain(anchor, &chainOfTrust{
+			zone:   anchor,
+			keys:   keys,
+			status: status,
+		}, ttl)
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - dns.CountLabel(anchor) - 1; i >= 0 && chain.status == SecurityStatusSecure; i-- {
+		child := dns.Fqdn(strings.Join(labels[i:], "."))
+
+		if cached, ok := r.cachedChain(child); ok {
+			chain = cached
+			continue
+		}
+
+		next, ttl, err := r.delegate(ctx, chain, child)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = r.cacheChain(child, next, ttl)
+	}
+
+	return chain, nil
+}
+
+// delegate validates the (potential) delegation from the parent zone to child.
+func (r *dnssecResolver) delegate(ctx context.Context, parent *chainOfTrust, child string) (*chainOfTrust, time.Duration, error) {
+	reply, err := r.query(ctx, child, dns.TypeDS)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	bogus := &chainOfTrust{zone: parent.zone, status: SecurityStatusBogus}
+
+	rrsets, sigs := groupRRsets(reply.Answer)
+	if dsSet, ok := rrsets[rrsetKey{name: child, rrType: dns.TypeDS}]; ok {
+		if err := r.verifyRRset(dsSet, sigs[rrsetKey{name: child, rrType: dns.TypeDS}], parent.keys); err != nil {
+			return bogus, 0, nil
+		}
+
+		var dsRecords []*dns.DS
+		for _, rr := range dsSet {
+			dsRecords = append(dsRecords, rr.(*dns.DS))
+		}
+
+		keys, ttl, status, err := r.fetchKeys(ctx, child, dsRecords)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return &chainOfTrust{zone: child, keys: keys, status: status}, ttl, nil
+	}
+
+	// No DS records, so we require an authenticated denial of existence.
+	rrsets, sigs = groupRRsets(reply.Ns)
+
+	ttl := time.Hour
+	for key, rrset := range rrsets {
+		if key.rrType != dns.TypeNSEC && key.rrType != dns.TypeNSEC3 {
+			delete(rrsets, key)
+			continue
+		}
+
+		if err := r.verifyRRset(rrset, sigs[key], parent.keys); err != nil {
+			return bogus, 0, nil
+		}
+
+		ttl = min(ttl, ttlOf(rrset))
+	}
+
+	nsecs, nsec3s := denialRecords(rrsets)
+
+	var types []uint16
+	if match := findNSEC(nsecs, func(nsec *dns.NSEC) bool { return dns.CanonicalName(nsec.Hdr.Name) == child }); match != nil {
+		types = match.TypeBitMap
+	} else if match := findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Matches(nsec3, child) }); match != nil {
+		types = match.TypeBitMap
+	}
+
+	if hasType(types, dns.TypeDS) {
+		// The DS records exist but were not returned.
+		return bogus, 0, nil
+	}
+
+	proven, optOut := proveDenial(child, dns.TypeDS, reply.Rcode == dns.RcodeNameError, nsecs, nsec3s)
+	if !proven {
+		return bogus, 0, nil
+	}
+
+	// A proven insecure delegation, or an opt-out range that may contain
+	// insecure delegations.
+	if isDelegation(types) || optOut {
+		return &chainOfTrust{zone: child, status: SecurityStatusInsecure}, ttl, nil
+	}
+
+	// Not a zone cut (or the name does not exist), the parent zone continues.
+	return parent, ttl, nil
+}
+
+// fetchKeys retrieves and validates the DNSKEY RRset of zone against the
+// given DS records.
+func (r *dnssecResolver) fetchKeys(ctx context.Context, zone string, dsRecords []*dns.DS) ([]*dns.DNSKEY, time.Duration, SecurityStatus, error) {
+	var supported []*dns.DS
+	for _, ds := range dsRecords {
+		if isSupportedAlgorithm(ds.Algorithm) && isSupportedDigest(ds.DigestType) {
+			supported = append(supported, ds)
+		}
+	}
+
+	// RFC 4035 section 5.2, if none of the DS records are supported, the zone
+	// is treated as insecure.
+	if len(supported) == 0 {
+		return nil, time.Hour, SecurityStatusInsecure, nil
+	}
+
+	reply, err := r.query(ctx, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	rrsets, sigs := groupRRsets(reply.Answer)
+	key := rrsetKey{name: zone, rrType: dns.TypeDNSKEY}
+	keySet, ok := rrsets[key]
+	if !ok {
+		return nil, 0, SecurityStatusBogus, nil
+	}
+
+	var keys, secureEntryPoints []*dns.DNSKEY
+	for _, rr := range keySet {
+		dnskey := rr.(*dns.DNSKEY)
+		if dnskey.Flags&dns.ZONE == 0 {
+			continue
+		}
+		keys = append(keys, dnskey)
+
+		for _, ds := range supported {
+			if dnskey.KeyTag() != ds.KeyTag || dnskey.Algorithm != ds.Algorithm {
+				continue
+			}
+
+			if digest := dnskey.ToDS(ds.DigestType); digest != nil && strings.EqualFold(digest.Digest, ds.Digest) {
+				secureEntryPoints = append(secureEntryPoints, dnskey)
+			}
+		}
+	}
+
+	if len(secureEntryPoints) == 0 {
+		return nil, 0, SecurityStatusBogus, nil
+	}
+
+	// The DNSKEY RRset must be self-signed by a secure entry point.
+	if err := r.verifyRRset(keySet, sigs[key], secureEntryPoints); err != nil {
+		return nil, 0, SecurityStatusBogus, nil
+	}
+
+	return keys, ttlOf(keySet), SecurityStatusSecure, nil
+}
+
+func (r *dnssecResolver) cachedChain(name string) (*chainOfTrust, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chain, ok := r.chains[name]
+	if !ok || r.upstream.clock.Now().After(chain.expires) {
+		return nil, false
+	}
+
+	return chain, true
+}
+
+func (r *dnssecResolver) cacheChain(name string, chain *chainOfTrust, ttl time.Duration) *chainOfTrust {
+	// Bogus results are not cached, so that transient failures can recover.
+	if chain.status == SecurityStatusBogus {
+		return chain
+	}
+
+	// Don't modify shared (already cached) chains.
+	chain = &chainOfTrust{
+		zone:    chain.zone,
+		keys:    chain.keys,
+		status:  chain.status,
+		expires: r.upstream.clock.Now().Add(ttl),
+	}
+
+	r.mu.Lock()
+	if len(r.chains) >= maxCachedChains {
+		r.evictChains()
+	}
+	r.chains[name] = chain
+	r.mu.Unlock()
+
+	return chain
+}
+
+// evictChains removes expired chains of trust from the cache, and if it is
+// still full, randomly selected entries. The mutex must be held.
+func (r *dnssecResolver) evictChains() {
+	now := r.upstream.clock.Now()
+	for name, chain := range r.chains {
+		if now.After(chain.expires) {
+			delete(r.chains, name)
+		}
+	}
+
+	// Map iteration order is randomized.
+	for name := range r.chains {
+		if len(r.chains) < maxCachedChains {
+			break
+		}
+		delete(r.chains, name)
+	}
+}
+
+type rrsetKey struct {
+	name   string
+	rrType uint16
+}
+
+// groupRRsets groups the records of a message section into RRsets, along with
+// their covering signatures.
+func groupRRsets(rrs []dns.RR) (map[rrsetKey][]dns.RR, map[rrsetKey][]*dns.RRSIG) {
+	rrsets := make(map[rrsetKey][]dns.RR)
+	sigs := make(map[rrsetKey][]*dns.RRSIG)
+
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			key := rrsetKey{name: dns.CanonicalName(hdr.Name), rrType: sig.TypeCovered}
+			sigs[key] = append(sigs[key], sig)
+			continue
+		}
+
+		if hdr.Rrtype == dns.TypeOPT {
+			continue
+		}
+
+		key := rrsetKey{name: dns.CanonicalName(hdr.Name), rrType: hdr.Rrtype}
+		rrsets[key] = append(rrsets[key], rr)
+	}
+
+	return rrsets, sigs
+}
+
+// verifyRRset verifies that at least one of the signatures over rrset is
+// valid and was made by one of the given keys.
+func (r *dnssecResolver) verifyRRset(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) error {
+	if r.verifiedSignature(rrset, sigs, keys) == nil {
+		return ErrBogus
+	}
+
+	return nil
+}
+
+// verifiedSignature returns the first of the signatures over rrset that is
+// valid and was made by one of the given keys, or nil if there is none.
+func (r *dnssecResolver) verifiedSignature(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) *dns.RRSIG {
+	now := r.upstream.clock.Now()
+	for _, sig := range sigs {
+		if !sig.ValidityPeriod(now) {
+			continue
+		}
+
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm ||
+				dns.CanonicalName(key.Hdr.Name) != dns.CanonicalName(sig.SignerName) {
+				continue
+			}
+
+			if err := sig.Verify(key, rrset); err == nil {
+				return sig
+			}
+		}
+	}
+
+	return nil
+}
+
+func weakestStatus(a, b SecurityStatus) SecurityStatus {
+	if a == SecurityStatusBogus || b == SecurityStatusBogus {
+		return SecurityStatusBogus
+	}
+
+	if a == SecurityStatusInsecure || b == SecurityStatusInsecure {
+		return SecurityStatusInsecure
+	}
+
+	return SecurityStatusSecure
+}
+
+func isSupportedAlgorithm(alg uint8) bool {
+	switch alg {
+	case dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512,
+		dns.ECDSAP256SHA256, dns.ECDSAP384SHA384, dns.ED25519:
+		return true
+	default:
+		return false
+	}
+}
+
+func isSupportedDigest(digestType uint8) bool {
+	switch digestType {
+	case dns.SHA1, dns.SHA256, dns.SHA384:
+		return true
+	default:
+		return false
+	}
+}
+
+func hasType(types []uint16, t uint16) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func ttlOf(rrset []dns.RR) time.Duration {
+	ttl := rrset[0].Header().Ttl
+	for _, rr := range rrset[1:] {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// Close discards all cached chains of trust and closes the upstream resolver.
+func (r *dnssecResolver) Close() error {
+	r.mu.Lock()
+	clear(r.chains)
+	r.mu.Unlock()
+
+	return r.upstream.Close()
+}
+
+func (r *dnssecResolver) describe() Description {
+	anchors := make([]string, 0, len(r.anchors))
+	for zone := range r.anchors {
+		anchors = append(anchors, zone)
+	}
+	slices.Sort(anchors)
+
+	return Description{
+		Type:       "dnssec",
+		Attributes: map[string]string{"trust_anchors": strings.Join(anchors, ",")},
+		Children:   []Description{Describe(r.upstream)},
+	}
+}
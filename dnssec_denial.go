@@ -0,0 +1,380 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// NSEC3 hash algorithm and flags (RFC 5155 section 11).
+const (
+	nsec3HashSHA1 = 1
+	nsec3OptOut   = 0x01
+)
+
+// maxNSEC3Iterations is the maximum number of additional NSEC3 hash
+// iterations, records with more are treated as insecure without hashing
+// anything (RFC 9276 section 3.2).
+const maxNSEC3Iterations = 100
+
+// denialRecords returns the NSEC and NSEC3 records among the given RRsets.
+func denialRecords(rrsets map[rrsetKey][]dns.RR) ([]*dns.NSEC, []*dns.NSEC3) {
+	var nsecs []*dns.NSEC
+	var nsec3s []*dns.NSEC3
+	for _, rrset := range rrsets {
+		for _, rr := range rrset {
+			switch rr := rr.(type) {
+			case *dns.NSEC:
+				nsecs = append(nsecs, rr)
+			case *dns.NSEC3:
+				nsec3s = append(nsec3s, rr)
+			}
+		}
+	}
+
+	return nsecs, nsec3s
+}
+
+// proveDenial checks that the (already validated) NSEC or NSEC3 records prove
+// that name does not exist (if nxDomain is set), or that there are no records
+// of type qType at name. If the proof relies on an NSEC3 opt-out range, or on
+// NSEC3 records with too many iterations, optOut is set and the answer can
+// only be considered insecure.
+func proveDenial(name string, qType uint16, nxDomain bool, nsecs []*dns.NSEC, nsec3s []*dns.NSEC3) (proven, optOut bool) {
+	if len(nsecs) > 0 && proveDenialNSEC(name, qType, nxDomain, nsecs) {
+		return true, false
+	}
+
+	if tooManyNSEC3Iterations(nsec3s) {
+		return true, true
+	}
+
+	if len(nsec3s) > 0 {
+		return proveDenialNSEC3(name, qType, nxDomain, nsec3s)
+	}
+
+	return false, false
+}
+
+// proveDenialNSEC implements the NSEC denial of existence proofs of RFC 4035
+// section 5.4.
+func proveDenialNSEC(name string, qType uint16, nxDomain bool, nsecs []*dns.NSEC) bool {
+	if nxDomain {
+		for _, nsec := range nsecs {
+			if dns.CanonicalName(nsec.Hdr.Name) == name {
+				return false
+			}
+		}
+
+		cover := findNSEC(nsecs, func(nsec *dns.NSEC) bool { return nsecCovers(nsec, name) })
+		if cover == nil {
+			return false
+		}
+
+		// There must also be no wildcard that could have been expanded.
+		wildcard := wildcardOf(nsecClosestEncloser(cover, name))
+		return findNSEC(nsecs, func(nsec *dns.NSEC) bool { return nsecCovers(nsec, wildcard) }) != nil
+	}
+
+	if match := findNSEC(nsecs, func(nsec *dns.NSEC) bool { return dns.CanonicalName(nsec.Hdr.Name) == name }); match != nil {
+		// The parent side NSEC record of a delegation only proves the absence
+		// of DS records.
+		if qType != dns.TypeDS && isDelegation(match.TypeBitMap) {
+			return false
+		}
+
+		return !hasType(match.TypeBitMap, qType) && !hasType(match.TypeBitMap, dns.TypeCNAME)
+	}
+
+	cover := findNSEC(nsecs, func(nsec *dns.NSEC) bool { return nsecCovers(nsec, name) })
+	if cover == nil {
+		return false
+	}
+
+	// An empty non-terminal, the next name is a descendant of name.
+	next := dns.CanonicalName(cover.NextDomain)
+	if next != name && dns.IsSubDomain(name, next) {
+		return true
+	}
+
+	// A wildcard without records of the given type.
+	wildcard := wildcardOf(nsecClosestEncloser(cover, name))
+	match := findNSEC(nsecs, func(nsec *dns.NSEC) bool { return dns.CanonicalName(nsec.Hdr.Name) == wildcard })
+	return match != nil && !hasType(match.TypeBitMap, qType) && !hasType(match.TypeBitMap, dns.TypeCNAME)
+}
+
+// proveDenialNSEC3 implements the NSEC3 denial of existence proofs of RFC 5155
+// section 8.
+func proveDenialNSEC3(name string, qType uint16, nxDomain bool, nsec3s []*dns.NSEC3) (proven, optOut bool) {
+	match := findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Matches(nsec3, name) })
+
+	if nxDomain {
+		if match != nil {
+			return false, false
+		}
+
+		// Section 8.4, the next closer name and the wildcard at the closest
+		// encloser must not exist.
+		ce, nextCloser := nsec3ClosestEncloser(name, nsec3s)
+		if nextCloser == nil {
+			return false, false
+		}
+
+		wildcard := wildcardOf(ce)
+		if findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Covers(nsec3, wildcard) }) == nil {
+			return false, false
+		}
+
+		return true, nextCloser.Flags&nsec3OptOut != 0
+	}
+
+	// Sections 8.5 and 8.6, a matching NSEC3 record without the type.
+	if match != nil {
+		if qType != dns.TypeDS && isDelegation(match.TypeBitMap) {
+			return false, false
+		}
+
+		return !hasType(match.TypeBitMap, qType) && !hasType(match.TypeBitMap, dns.TypeCNAME), false
+	}
+
+	ce, nextCloser := nsec3ClosestEncloser(name, nsec3s)
+	if nextCloser == nil {
+		return false, false
+	}
+
+	// Section 8.6, no DS records for a name within an opt-out range.
+	if qType == dns.TypeDS && nextCloser.Flags&nsec3OptOut != 0 {
+		return true, true
+	}
+
+	// Section 8.7, a wildcard without records of the given type.
+	wildcard := wildcardOf(ce)
+	match = findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Matches(nsec3, wildcard) })
+	if match == nil {
+		return false, false
+	}
+
+	return !hasType(match.TypeBitMap, qType) && !hasType(match.TypeBitMap, dns.TypeCNAME), false
+}
+
+// isWildcardExpansion returns whether an RRset at name, with the given
+// verified signature, was synthesized from a wildcard. The signature's label
+// count excludes the wildcard label (RFC 4034 section 3.1.3).
+func isWildcardExpansion(name string, sig *dns.RRSIG) bool {
+	labels := dns.CountLabel(name)
+	if strings.HasPrefix(name, "*.") {
+		labels--
+	}
+	return int(sig.Labels) < labels
+}
+
+// proveWildcardExpansion checks that the (already validated) NSEC or NSEC3
+// records prove that there is no closer match for name than the wildcard at
+// its ancestor with the given number of labels (RFC 4035 section 5.3.4 and
+// RFC 5155 section 8.8). If the proof relies on an NSEC3 opt-out range, or on
+// NSEC3 records with too many iterations, optOut is set and the answer can
+// only be considered insecure.
+func proveWildcardExpansion(name string, labels int, nsecs []*dns.NSEC, nsec3s []*dns.NSEC3) (proven, optOut bool) {
+	if findNSEC(nsecs, func(nsec *dns.NSEC) bool { return nsecCovers(nsec, name) }) != nil {
+		return true, false
+	}
+
+	if tooManyNSEC3Iterations(nsec3s) {
+		return true, true
+	}
+
+	// The next closer name is one label longer than the closest encloser.
+	split := dns.SplitDomainName(name)
+	nextCloser := dns.Fqdn(strings.Join(split[len(split)-labels-1:], "."))
+	cover := findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Covers(nsec3, nextCloser) })
+	if cover == nil {
+		return false, false
+	}
+
+	return true, cover.Flags&nsec3OptOut != 0
+}
+
+// nsec3ClosestEncloser finds the closest encloser proof for name (RFC 5155
+// section 8.3), that is the closest ancestor of name with a matching NSEC3
+// record, along with the NSEC3 record covering the next closer name.
+func nsec3ClosestEncloser(name string, nsec3s []*dns.NSEC3) (string, *dns.NSEC3) {
+	labels := dns.SplitDomainName(name)
+	for i := 1; i <= len(labels); i++ {
+		ce := dns.Fqdn(strings.Join(labels[i:], "."))
+		if findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Matches(nsec3, ce) }) == nil {
+			continue
+		}
+
+		nextCloser := dns.Fqdn(strings.Join(labels[i-1:], "."))
+		cover := findNSEC3(nsec3s, func(nsec3 *dns.NSEC3) bool { return nsec3Covers(nsec3, nextCloser) })
+		return ce, cover
+	}
+
+	return "", nil
+}
+
+// nsecCovers returns whether name falls strictly between the owner and next
+// name of an NSEC record, in canonical order.
+func nsecCovers(nsec *dns.NSEC, name string) bool {
+	owner := dns.CanonicalName(nsec.Hdr.Name)
+	next := dns.CanonicalName(nsec.NextDomain)
+
+	// Names below a delegation are not part of the zone.
+	if isDelegation(nsec.TypeBitMap) && dns.IsSubDomain(owner, name) {
+		return false
+	}
+
+	if canonicalCompare(owner, name) >= 0 {
+		return false
+	}
+
+	if canonicalCompare(owner, next) < 0 {
+		return canonicalCompare(name, next) < 0
+	}
+
+	// The last NSEC record of the zone, its next name is the zone apex.
+	return dns.IsSubDomain(next, name)
+}
+
+// nsecClosestEncloser returns the closest encloser of a name covered by an
+// NSEC record, the longest ancestor it shares with the owner or next name.
+func nsecClosestEncloser(nsec *dns.NSEC, name string) string {
+	common := max(dns.CompareDomainName(name, nsec.Hdr.Name), dns.CompareDomainName(name, nsec.NextDomain))
+
+	labels := dns.SplitDomainName(name)
+	return dns.Fqdn(strings.Join(labels[len(labels)-common:], "."))
+}
+
+// tooManyNSEC3Iterations returns whether any of the NSEC3 records use more
+// than maxNSEC3Iterations additional hash iterations.
+func tooManyNSEC3Iterations(nsec3s []*dns.NSEC3) bool {
+	return slices.ContainsFunc(nsec3s, func(nsec3 *dns.NSEC3) bool {
+		return nsec3.Iterations > maxNSEC3Iterations
+	})
+}
+
+// nsec3Hashes returns the owner, next and name hashes for an NSEC3 record, ok
+// is false if name is outside the zone of the record or the hash algorithm is
+// not supported.
+func nsec3Hashes(nsec3 *dns.NSEC3, name string) (owner, next, hash string, ok bool) {
+	if nsec3.Hash != nsec3HashSHA1 {
+		return "", "", "", false
+	}
+
+	labels := dns.SplitDomainName(nsec3.Hdr.Name)
+	if len(labels) == 0 {
+		return "", "", "", false
+	}
+
+	zone := dns.Fqdn(strings.Join(labels[1:], "."))
+	if !dns.IsSubDomain(zone, name) {
+		return "", "", "", false
+	}
+
+	hash = dns.HashName(name, nsec3.Hash, nsec3.Iterations, nsec3.Salt)
+	return strings.ToUpper(labels[0]), strings.ToUpper(nsec3.NextDomain), hash, hash != ""
+}
+
+func nsec3Matches(nsec3 *dns.NSEC3, name string) bool {
+	owner, _, hash, ok := nsec3Hashes(nsec3, name)
+	return ok && hash == owner
+}
+
+// nsec3Covers returns whether the hash of name falls strictly between the
+// owner and next hashes of an NSEC3 record.
+func nsec3Covers(nsec3 *dns.NSEC3, name string) bool {
+	owner, next, hash, ok := nsec3Hashes(nsec3, name)
+	if !ok || hash == owner {
+		return false
+	}
+
+	if owner < next {
+		return hash < next && hash > owner
+	}
+
+	// The last NSEC3 record of the zone wraps around.
+	return hash > owner || hash < next
+}
+
+// wildcardOf returns the wildcard name directly below zone.
+func wildcardOf(zone string) string {
+	if zone == "." {
+		return "*."
+	}
+	return "*." + zone
+}
+
+// isDelegation returns whether a type bitmap belongs to a delegation point
+// (rather than a zone apex).
+func isDelegation(types []uint16) bool {
+	return hasType(types, dns.TypeNS) && !hasType(types, dns.TypeSOA)
+}
+
+func findNSEC(nsecs []*dns.NSEC, fn func(*dns.NSEC) bool) *dns.NSEC {
+	for _, nsec := range nsecs {
+		if fn(nsec) {
+			return nsec
+		}
+	}
+	return nil
+}
+
+func findNSEC3(nsec3s []*dns.NSEC3, fn func(*dns.NSEC3) bool) *dns.NSEC3 {
+	for _, nsec3 := range nsec3s {
+		if fn(nsec3) {
+			return nsec3
+		}
+	}
+	return nil
+}
+
+// canonicalCompare compares two domain names in canonical DNS name order
+// (RFC 4034 section 6.1).
+func canonicalCompare(a, b string) int {
+	la, lb := canonicalLabels(a), canonicalLabels(b)
+	for i := 0; i < len(la) && i < len(lb); i++ {
+		if c := bytes.Compare(la[i], lb[i]); c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(la), len(lb))
+}
+
+// canonicalLabels returns the lowercased wire format labels of name, starting
+// from the rightmost label.
+func canonicalLabels(name string) [][]byte {
+	buf := make([]byte, 256)
+	n, err := dns.PackDomainName(dns.Fqdn(name), buf, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+
+	var labels [][]byte
+	for off := 0; off < n && buf[off] != 0; off += int(buf[off]) + 1 {
+		label := buf[off+1 : off+1+int(buf[off])]
+		for i, c := range label {
+			if 'A' <= c && c <= 'Z' {
+				label[i] = c + ('a' - 'A')
+			}
+		}
+		labels = append(labels, label)
+	}
+
+	// The most significant label comes first.
+	slices.Reverse(labels)
+
+	return labels
+}
@@ -0,0 +1,387 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSSECResolverDenialNSEC(t *testing.T) {
+	root := newSignedZone(t, ".")
+	example := newSignedZone(t, "example.")
+
+	nsecApex := example.sign(t, mustRR(t, "example. 300 IN NSEC a.b.example. NS SOA RRSIG NSEC DNSKEY"))
+	nsecAB := example.sign(t, mustRR(t, "a.b.example. 300 IN NSEC sub.example. A RRSIG NSEC"))
+	nsecSub := example.sign(t, mustRR(t, "sub.example. 300 IN NSEC *.wild.example. NS RRSIG NSEC"))
+	nsecWild := example.sign(t, mustRR(t, "*.wild.example. 300 IN NSEC www.example. TXT RRSIG NSEC"))
+	nsecWWW := example.sign(t, mustRR(t, "www.example. 300 IN NSEC example. A RRSIG NSEC"))
+
+	server := startDenialServer(t, map[string]denialReply{
+		"www.example./AAAA":     {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecWWW}},
+		"www.example./A":        {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecWWW}},
+		"wrong.example./AAAA":   {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecApex}},
+		"b.example./A":          {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecApex}},
+		"foo.wild.example./A":   {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecWild}},
+		"missing.example./A":    {rcode: dns.RcodeNameError, ns: [][]dns.RR{nsecAB, nsecApex}},
+		"nowildcard.example./A": {rcode: dns.RcodeNameError, ns: [][]dns.RR{nsecAB}},
+		"zzz.example./A":        {rcode: dns.RcodeNameError, ns: [][]dns.RR{nsecAB, nsecApex}},
+		"sub.example./DS":       {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecSub}},
+		"other.example./DS":     {rcode: dns.RcodeSuccess, ns: [][]dns.RR{nsecWWW}},
+	},
+		root.sign(t, root.dnskey),
+		root.sign(t, example.dnskey.ToDS(dns.SHA256)),
+		example.sign(t, example.dnskey),
+		example.sign(t, mustRR(t, "www.example. 300 IN A 192.0.2.1")),
+		[]dns.RR{mustRR(t, "host.sub.example. 300 IN A 192.0.2.2")},
+		[]dns.RR{mustRR(t, "host.other.example. 300 IN A 192.0.2.3")},
+	)
+
+	res := resolver.DNSSEC(resolver.DNSSECResolverConfig{
+		Upstream: resolver.DNSResolverConfig{
+			Server: server,
+		},
+		TrustAnchors: []*dns.DS{root.dnskey.ToDS(dns.SHA256)},
+	})
+
+	tests := []struct {
+		name    string
+		network string
+		host    string
+		err     error
+		status  resolver.SecurityStatus
+		addrs   []netip.Addr
+	}{
+		{name: "NoData", network: "ip6", host: "www.example", err: resolver.ErrNoData, status: resolver.SecurityStatusSecure},
+		{name: "NoData type exists", network: "ip4", host: "www.example", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "NoData unrelated NSEC", network: "ip6", host: "wrong.example", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "Empty non-terminal", network: "ip4", host: "b.example", err: resolver.ErrNoData, status: resolver.SecurityStatusSecure},
+		{name: "Wildcard NoData", network: "ip4", host: "foo.wild.example", err: resolver.ErrNoData, status: resolver.SecurityStatusSecure},
+		{name: "NXDOMAIN", network: "ip4", host: "missing.example", err: resolver.ErrNoSuchHost, status: resolver.SecurityStatusSecure},
+		{name: "NXDOMAIN without wildcard proof", network: "ip4", host: "nowildcard.example", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "NXDOMAIN not covered", network: "ip4", host: "zzz.example", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "Insecure delegation", network: "ip4", host: "host.sub.example", status: resolver.SecurityStatusInsecure, addrs: []netip.Addr{netip.MustParseAddr("192.0.2.2")}},
+		{name: "Unproven delegation", network: "ip4", host: "host.other.example", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, status, err := res.LookupNetIPSecure(context.Background(), tt.network, tt.host)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.status, status)
+			require.Equal(t, tt.addrs, addrs)
+		})
+	}
+}
+
+func TestDNSSECResolverDenialNSEC3(t *testing.T) {
+	root := newSignedZone(t, ".")
+	zone := newSignedZone(t, "nsec3.")
+
+	names := map[string][]uint16{
+		"nsec3.":        {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+		"www.nsec3.":    {dns.TypeA, dns.TypeRRSIG},
+		"wild.nsec3.":   nil,
+		"*.wild.nsec3.": {dns.TypeTXT, dns.TypeRRSIG},
+		"a.nsec3.":      {dns.TypeA, dns.TypeRRSIG},
+		"b.nsec3.":      {dns.TypeA, dns.TypeRRSIG},
+		"c.nsec3.":      {dns.TypeA, dns.TypeRRSIG},
+		"d.nsec3.":      {dns.TypeA, dns.TypeRRSIG},
+		"e.nsec3.":      {dns.TypeA, dns.TypeRRSIG},
+		"f.nsec3.":      {dns.TypeA, dns.TypeRRSIG},
+	}
+
+	chain := newNSEC3Chain(t, zone, names, false)
+	optOutChain := newNSEC3Chain(t, zone, names, true)
+
+	// The proofs below rely on the next closer names and wildcards being covered
+	// by distinct records.
+	require.NotEqual(t, chain.cover(t, "missing.nsec3.")[0], chain.cover(t, "*.nsec3.")[0])
+
+	// A record with an expensive number of iterations, covering every name.
+	costly := zone.sign(t, &dns.NSEC3{
+		Hdr:        dns.RR_Header{Name: strings.Repeat("0", 32) + ".nsec3.", Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+		Hash:       dns.SHA1,
+		Iterations: 2500,
+		HashLength: 20,
+		NextDomain: strings.Repeat("V", 32),
+	})
+
+	server := startDenialServer(t, map[string]denialReply{
+		"www.nsec3./AAAA": {rcode: dns.RcodeSuccess, ns: [][]dns.RR{chain.match(t, "www.nsec3.")}},
+		"www.nsec3./A":    {rcode: dns.RcodeSuccess, ns: [][]dns.RR{chain.match(t, "www.nsec3.")}},
+		"foo.wild.nsec3./A": {rcode: dns.RcodeSuccess, ns: [][]dns.RR{
+			chain.match(t, "wild.nsec3."), chain.cover(t, "foo.wild.nsec3."), chain.match(t, "*.wild.nsec3."),
+		}},
+		"missing.nsec3./A": {rcode: dns.RcodeNameError, ns: [][]dns.RR{
+			chain.match(t, "nsec3."), chain.cover(t, "missing.nsec3."), chain.cover(t, "*.nsec3."),
+		}},
+		"noencloser.nsec3./A": {rcode: dns.RcodeNameError, ns: [][]dns.RR{
+			chain.cover(t, "noencloser.nsec3."), chain.cover(t, "*.nsec3."),
+		}},
+		"nonextcloser.nsec3./A": {rcode: dns.RcodeNameError, ns: [][]dns.RR{
+			chain.match(t, "nsec3."), chain.cover(t, "*.nsec3."),
+		}},
+		"nowildcard.nsec3./A": {rcode: dns.RcodeNameError, ns: [][]dns.RR{
+			chain.match(t, "nsec3."), chain.cover(t, "nowildcard.nsec3."),
+		}},
+		"unsigned.nsec3./DS": {rcode: dns.RcodeSuccess, ns: [][]dns.RR{
+			optOutChain.match(t, "nsec3."), optOutChain.cover(t, "unsigned.nsec3."),
+		}},
+		"unproven.nsec3./DS": {rcode: dns.RcodeSuccess, ns: [][]dns.RR{
+			optOutChain.cover(t, "unproven.nsec3."),
+		}},
+		"costly.nsec3./A": {rcode: dns.RcodeNameError, ns: [][]dns.RR{costly}},
+	},
+		root.sign(t, root.dnskey),
+		root.sign(t, zone.dnskey.ToDS(dns.SHA256)),
+		zone.sign(t, zone.dnskey),
+		zone.sign(t, mustRR(t, "www.nsec3. 300 IN A 192.0.2.1")),
+		[]dns.RR{mustRR(t, "host.unsigned.nsec3. 300 IN A 192.0.2.2")},
+		[]dns.RR{mustRR(t, "host.unproven.nsec3. 300 IN A 192.0.2.3")},
+	)
+
+	res := resolver.DNSSEC(resolver.DNSSECResolverConfig{
+		Upstream: resolver.DNSResolverConfig{
+			Server: server,
+		},
+		TrustAnchors: []*dns.DS{root.dnskey.ToDS(dns.SHA256)},
+	})
+
+	tests := []struct {
+		name    string
+		network string
+		host    string
+		err     error
+		status  resolver.SecurityStatus
+		addrs   []netip.Addr
+	}{
+		{name: "NoData", network: "ip6", host: "www.nsec3", err: resolver.ErrNoData, status: resolver.SecurityStatusSecure},
+		{name: "NoData type exists", network: "ip4", host: "www.nsec3", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "Wildcard NoData", network: "ip4", host: "foo.wild.nsec3", err: resolver.ErrNoData, status: resolver.SecurityStatusSecure},
+		{name: "NXDOMAIN", network: "ip4", host: "missing.nsec3", err: resolver.ErrNoSuchHost, status: resolver.SecurityStatusSecure},
+		{name: "NXDOMAIN without closest encloser", network: "ip4", host: "noencloser.nsec3", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "NXDOMAIN without next closer", network: "ip4", host: "nonextcloser.nsec3", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "NXDOMAIN without wildcard proof", network: "ip4", host: "nowildcard.nsec3", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "Opt-out delegation", network: "ip4", host: "host.unsigned.nsec3", status: resolver.SecurityStatusInsecure, addrs: []netip.Addr{netip.MustParseAddr("192.0.2.2")}},
+		{name: "Too many iterations", network: "ip4", host: "costly.nsec3", err: resolver.ErrNoSuchHost, status: resolver.SecurityStatusInsecure},
+		{name: "Opt-out without closest encloser", network: "ip4", host: "host.unproven.nsec3", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, status, err := res.LookupNetIPSecure(context.Background(), tt.network, tt.host)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.status, status)
+			require.Equal(t, tt.addrs, addrs)
+		})
+	}
+}
+
+func TestDNSSECResolverWildcardExpansion(t *testing.T) {
+	root := newSignedZone(t, ".")
+	example := newSignedZone(t, "example.")
+	zone := newSignedZone(t, "nsec3.")
+
+	nsecWild := example.sign(t, mustRR(t, "*.wild.example. 300 IN NSEC www.example. A RRSIG NSEC"))
+	chain := newNSEC3Chain(t, zone, map[string][]uint16{
+		"nsec3.":        {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+		"wild.nsec3.":   nil,
+		"*.wild.nsec3.": {dns.TypeA, dns.TypeRRSIG},
+	}, false)
+
+	// expand returns the signed wildcard RRset rewritten to the owner name.
+	expand := func(rrs []dns.RR, name string) []dns.RR {
+		var expanded []dns.RR
+		for _, rr := range rrs {
+			rr = dns.Copy(rr)
+			rr.Header().Name = name
+			expanded = append(expanded, rr)
+		}
+		return expanded
+	}
+
+	wildExample := example.sign(t, mustRR(t, "*.wild.example. 300 IN A 192.0.2.1"))
+	wildNSEC3 := zone.sign(t, mustRR(t, "*.wild.nsec3. 300 IN A 192.0.2.2"))
+
+	server := startDenialServer(t, map[string]denialReply{
+		"host.wild.example./A": {
+			answer: [][]dns.RR{expand(wildExample, "host.wild.example.")},
+			ns:     [][]dns.RR{nsecWild},
+		},
+		"unproven.wild.example./A": {
+			answer: [][]dns.RR{expand(wildExample, "unproven.wild.example.")},
+		},
+		"host.wild.nsec3./A": {
+			answer: [][]dns.RR{expand(wildNSEC3, "host.wild.nsec3.")},
+			ns:     [][]dns.RR{chain.cover(t, "host.wild.nsec3.")},
+		},
+		"unproven.wild.nsec3./A": {
+			answer: [][]dns.RR{expand(wildNSEC3, "unproven.wild.nsec3.")},
+			ns:     [][]dns.RR{chain.match(t, "wild.nsec3.")},
+		},
+	},
+		root.sign(t, root.dnskey),
+		root.sign(t, example.dnskey.ToDS(dns.SHA256)),
+		root.sign(t, zone.dnskey.ToDS(dns.SHA256)),
+		example.sign(t, example.dnskey),
+		zone.sign(t, zone.dnskey),
+	)
+
+	res := resolver.DNSSEC(resolver.DNSSECResolverConfig{
+		Upstream: resolver.DNSResolverConfig{
+			Server: server,
+		},
+		TrustAnchors: []*dns.DS{root.dnskey.ToDS(dns.SHA256)},
+	})
+
+	tests := []struct {
+		name   string
+		host   string
+		err    error
+		status resolver.SecurityStatus
+		addrs  []netip.Addr
+	}{
+		{name: "NSEC", host: "host.wild.example", status: resolver.SecurityStatusSecure, addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}},
+		{name: "NSEC without proof", host: "unproven.wild.example", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+		{name: "NSEC3", host: "host.wild.nsec3", status: resolver.SecurityStatusSecure, addrs: []netip.Addr{netip.MustParseAddr("192.0.2.2")}},
+		{name: "NSEC3 without next closer", host: "unproven.wild.nsec3", err: resolver.ErrBogus, status: resolver.SecurityStatusBogus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, status, err := res.LookupNetIPSecure(context.Background(), "ip4", tt.host)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.status, status)
+			require.Equal(t, tt.addrs, addrs)
+		})
+	}
+}
+
+type denialReply struct {
+	rcode  int
+	answer [][]dns.RR
+	ns     [][]dns.RR
+}
+
+// startDenialServer starts a local DNS server answering from the given RRsets,
+// with the negative replies in denials (keyed by "name/TYPE") taking priority.
+func startDenialServer(t *testing.T, denials map[string]denialReply, rrsets ...[]dns.RR) netip.AddrPort {
+	records := recordHandler(rrsets...)
+
+	return startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		q := req.Question[0]
+		denial, ok := denials[dns.CanonicalName(q.Name)+"/"+dns.TypeToString[q.Qtype]]
+		if !ok {
+			records(w, req)
+			return
+		}
+
+		reply := &dns.Msg{}
+		reply.SetRcode(req, denial.rcode)
+		for _, rrs := range denial.answer {
+			reply.Answer = append(reply.Answer, rrs...)
+		}
+		for _, rrs := range denial.ns {
+			reply.Ns = append(reply.Ns, rrs...)
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+}
+
+// nsec3Chain is a signed NSEC3 chain, hashed without a salt or additional
+// iterations.
+type nsec3Chain struct {
+	records [][]dns.RR
+}
+
+func newNSEC3Chain(t *testing.T, zone *signedZone, names map[string][]uint16, optOut bool) *nsec3Chain {
+	hashes := make(map[string][]uint16, len(names))
+	for name, types := range names {
+		hashes[dns.HashName(name, dns.SHA1, 0, "")] = types
+	}
+
+	sorted := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		sorted = append(sorted, hash)
+	}
+	slices.Sort(sorted)
+
+	var flags uint8
+	if optOut {
+		flags = 1
+	}
+
+	chain := &nsec3Chain{}
+	for i, hash := range sorted {
+		nsec3 := &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(hash) + "." + zone.dnskey.Hdr.Name, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+			Hash:       dns.SHA1,
+			Flags:      flags,
+			HashLength: 20,
+			NextDomain: sorted[(i+1)%len(sorted)],
+			TypeBitMap: hashes[hash],
+		}
+		chain.records = append(chain.records, zone.sign(t, nsec3))
+	}
+
+	return chain
+}
+
+// match returns the signed NSEC3 record matching name.
+func (c *nsec3Chain) match(t *testing.T, name string) []dns.RR {
+	hash := dns.HashName(name, dns.SHA1, 0, "")
+	for _, rrs := range c.records {
+		if strings.EqualFold(dns.SplitDomainName(rrs[0].Header().Name)[0], hash) {
+			return rrs
+		}
+	}
+
+	t.Fatalf("no NSEC3 record matches %s", name)
+	return nil
+}
+
+// cover returns the signed NSEC3 record covering name.
+func (c *nsec3Chain) cover(t *testing.T, name string) []dns.RR {
+	hash := dns.HashName(name, dns.SHA1, 0, "")
+	for _, rrs := range c.records {
+		owner := strings.ToUpper(dns.SplitDomainName(rrs[0].Header().Name)[0])
+		next := rrs[0].(*dns.NSEC3).NextDomain
+		if (owner < hash && hash < next) || (next <= owner && (hash > owner || hash < next)) {
+			return rrs
+		}
+	}
+
+	t.Fatalf("no NSEC3 record covers %s", name)
+	return nil
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSSECResolver(t *testing.T) {
+	root := newSignedZone(t, ".")
+	example := newSignedZone(t, "example.")
+	imposter := newSignedZone(t, "example.")
+
+	server := startRecordServer(t,
+		root.sign(t, root.dnskey),
+		root.sign(t, example.dnskey.ToDS(dns.SHA256)),
+		example.sign(t, example.dnskey),
+		example.sign(t, mustRR(t, "www.example. 300 IN A 192.0.2.1")),
+		example.sign(t, mustRR(t, "www.example. 300 IN NSEC zzz.example. A RRSIG NSEC")),
+		imposter.sign(t, mustRR(t, "bogus.example. 300 IN A 192.0.2.66")),
+		example.sign(t, mustRR(t, "bogus.example. 300 IN NSEC www.example. A RRSIG NSEC")),
+		root.sign(t, mustRR(t, "insecure. 300 IN NSEC zzz. NS RRSIG NSEC")),
+		[]dns.RR{mustRR(t, "www.insecure. 300 IN A 192.0.2.2")},
+	)
+
+	res := resolver.DNSSEC(resolver.DNSSECResolverConfig{
+		Upstream: resolver.DNSResolverConfig{
+			Server: server,
+		},
+		TrustAnchors: []*dns.DS{root.dnskey.ToDS(dns.SHA256)},
+	})
+
+	t.Run("Secure", func(t *testing.T) {
+		addrs, status, err := res.LookupNetIPSecure(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, resolver.SecurityStatusSecure, status)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Insecure", func(t *testing.T) {
+		addrs, status, err := res.LookupNetIPSecure(context.Background(), "ip4", "www.insecure")
+		require.NoError(t, err)
+
+		require.Equal(t, resolver.SecurityStatusInsecure, status)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.2")}, addrs)
+	})
+
+	t.Run("Bogus", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "bogus.example")
+		require.Error(t, err)
+
+		var dnsErr *net.DNSError
+		require.True(t, errors.As(err, &dnsErr))
+		require.Equal(t, resolver.ErrBogus.Error(), dnsErr.Err)
+	})
+}
+
+func TestDNSSECResolverTruncated(t *testing.T) {
+	root := newSignedZone(t, ".")
+	example := newSignedZone(t, "example.")
+
+	records := recordHandler(
+		root.sign(t, root.dnskey),
+		root.sign(t, example.dnskey.ToDS(dns.SHA256)),
+		example.sign(t, example.dnskey),
+		example.sign(t, mustRR(t, "www.example. 300 IN A 192.0.2.1")),
+	)
+
+	// DNSKEY replies are too large for UDP, and are only answered over TCP.
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		if _, ok := w.LocalAddr().(*net.UDPAddr); ok && req.Question[0].Qtype == dns.TypeDNSKEY {
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			reply.Truncated = true
+			_ = w.WriteMsg(reply)
+			return
+		}
+
+		records(w, req)
+	}))
+
+	res := resolver.DNSSEC(resolver.DNSSECResolverConfig{
+		Upstream: resolver.DNSResolverConfig{
+			Server: server,
+		},
+		TrustAnchors: []*dns.DS{root.dnskey.ToDS(dns.SHA256)},
+	})
+
+	addrs, status, err := res.LookupNetIPSecure(context.Background(), "ip4", "www.example")
+	require.NoError(t, err)
+
+	require.Equal(t, resolver.SecurityStatusSecure, status)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+}
+
+type signedZone struct {
+	dnskey *dns.DNSKEY
+	signer crypto.Signer
+}
+
+func newSignedZone(t *testing.T, zone string) *signedZone {
+	dnskey := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 300},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	privateKey, err := dnskey.Generate(256)
+	require.NoError(t, err)
+
+	return &signedZone{
+		dnskey: dnskey,
+		signer: privateKey.(crypto.Signer),
+	}
+}
+
+// sign returns the given records along with a covering RRSIG.
+func (z *signedZone) sign(t *testing.T, rrs ...dns.RR) []dns.RR {
+	hdr := rrs[0].Header()
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: hdr.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: hdr.Ttl},
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+		KeyTag:     z.dnskey.KeyTag(),
+		SignerName: z.dnskey.Hdr.Name,
+		Algorithm:  z.dnskey.Algorithm,
+	}
+	require.NoError(t, sig.Sign(z.signer, rrs))
+
+	return append(rrs, sig)
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	require.NoError(t, err)
+	return rr
+}
+
+// startRecordServer starts a local DNS server answering from the given
+// RRsets (each optionally followed by its signatures). Aliases are returned
+// for any query type, and negative answers include any NSEC records for the
+// queried name.
+func startRecordServer(t *testing.T, rrsets ...[]dns.RR) netip.AddrPort {
+	return startTestServer(t, recordHandler(rrsets...))
+}
+
+func recordHandler(rrsets ...[]dns.RR) dns.HandlerFunc {
+	records := map[string][]dns.RR{}
+	for _, rrs := range rrsets {
+		key := dns.CanonicalName(rrs[0].Header().Name) + "/" + dns.TypeToString[rrs[0].Header().Rrtype]
+		records[key] = append(records[key], rrs...)
+	}
+
+	return func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		q := req.Question[0]
+		if rrs, ok := records[dns.CanonicalName(q.Name)+"/"+dns.TypeToString[q.Qtype]]; ok {
+			reply.Answer = rrs
+		} else if rrs, ok := records[dns.CanonicalName(q.Name)+"/CNAME"]; ok {
+			reply.Answer = rrs
+		} else {
+			reply.Ns = records[dns.CanonicalName(q.Name)+"/NSEC"]
+		}
+
+		_ = w.WriteMsg(reply)
+	}
+}
+
+// startTestServer starts a local UDP and TCP DNS server using the given
+// handler.
+func startTestServer(t *testing.T, handler dns.Handler) netip.AddrPort {
+	pc, lis, err := dnstest.ListenLoopback()
+	require.NoError(t, err)
+
+	addrPort := netip.MustParseAddrPort(pc.LocalAddr().String())
+
+	for _, server := range []*dns.Server{
+		{PacketConn: pc, Handler: handler},
+		{Listener: lis, Handler: handler},
+	} {
+		go func() {
+			_ = server.ActivateAndServe()
+		}()
+		t.Cleanup(func() {
+			_ = server.Shutdown()
+		})
+	}
+
+	return addrPort
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package dnstest
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// MessageBuilder constructs DNS messages fluently, eg.
+//
+//	dnstest.Answer().
+//		CNAME("www.example.com", "web.example.com", 300).
+//		A("web.example.com", "192.0.2.1", 60).
+//		Msg()
+//
+// Names are made fully qualified. Invalid addresses cause a panic, as the
+// builder is intended to be used with constant values in tests.
+type MessageBuilder struct {
+	msg dns.Msg
+}
+
+// Answer returns a new builder for a successful response, records are added
+// to the answer section.
+func Answer() *MessageBuilder {
+	return &MessageBuilder{}
+}
+
+// A adds an A record.
+func (b *MessageBuilder) A(name, addr string, ttl uint32) *MessageBuilder {
+	ip := netip.MustParseAddr(addr)
+	if !ip.Is4() {
+		panic(fmt.Sprintf("dnstest: %s is not an IPv4 address", addr))
+	}
+
+	return b.RR(&dns.A{Hdr: header(name, dns.TypeA, ttl), A: ip.AsSlice()})
+}
+
+// AAAA adds an AAAA record.
+func (b *MessageBuilder) AAAA(name, addr string, ttl uint32) *MessageBuilder {
+	ip := netip.MustParseAddr(addr)
+	if !ip.Is6() {
+		panic(fmt.Sprintf("dnstest: %s is not an IPv6 address", addr))
+	}
+
+	return b.RR(&dns.AAAA{Hdr: header(name, dns.TypeAAAA, ttl), AAAA: ip.AsSlice()})
+}
+
+// CNAME adds a CNAME record, aliasing name to target.
+func (b *MessageBuilder) CNAME(name, target string, ttl uint32) *MessageBuilder {
+	return b.RR(&dns.CNAME{Hdr: header(name, dns.TypeCNAME, ttl), Target: dns.Fqdn(target)})
+}
+
+// MX adds an MX record.
+func (b *MessageBuilder) MX(name string, preference uint16, mx string, ttl uint32) *MessageBuilder {
+	return b.RR(&dns.MX{Hdr: header(name, dns.TypeMX, ttl), Preference: preference, Mx: dns.Fqdn(mx)})
+}
+
+// NS adds an NS record.
+func (b *MessageBuilder) NS(name, ns string, ttl uint32) *MessageBuilder {
+	return b.RR(&dns.NS{Hdr: header(name, dns.TypeNS, ttl), Ns: dns.Fqdn(ns)})
+}
+
+// PTR adds a PTR record.
+func (b *MessageBuilder) PTR(name, ptr string, ttl uint32) *MessageBuilder {
+	return b.RR(&dns.PTR{Hdr: header(name, dns.TypePTR, ttl), Ptr: dns.Fqdn(ptr)})
+}
+
+// SRV adds an SRV record.
+func (b *MessageBuilder) SRV(name string, priority, weight, port uint16, target string, ttl uint32) *MessageBuilder {
+	return b.RR(&dns.SRV{
+		Hdr:      header(name, dns.TypeSRV, ttl),
+		Priority: priority,
+		Weight:   weight,
+		Port:     port,
+		Target:   dns.Fqdn(target),
+	})
+}
+
+// TXT adds a TXT record.
+func (b *MessageBuilder) TXT(name string, txt []string, ttl uint32) *MessageBuilder {
+	return b.RR(&dns.TXT{Hdr: header(name, dns.TypeTXT, ttl), Txt: txt})
+}
+
+// RR adds arbitrary records to the answer section.
+func (b *MessageBuilder) RR(rrs ...dns.RR) *MessageBuilder {
+	b.msg.Answer = append(b.msg.Answer, rrs...)
+	return b
+}
+
+// SOA adds an SOA record for zone to the authority section, as included in
+// negative responses. The TTL is also used as the negative caching TTL.
+func (b *MessageBuilder) SOA(zone string, ttl uint32) *MessageBuilder {
+	zone = dns.Fqdn(zone)
+
+	b.msg.Ns = append(b.msg.Ns, &dns.SOA{
+		Hdr:     header(zone, dns.TypeSOA, ttl),
+		Ns:      "ns." + zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  1,
+		Refresh: 7200,
+		Retry:   3600,
+		Expire:  1209600,
+		Minttl:  ttl,
+	})
+	return b
+}
+
+// Rcode sets the response code (eg. dns.RcodeServerFailure).
+func (b *MessageBuilder) Rcode(rcode int) *MessageBuilder {
+	b.msg.Rcode = rcode
+	return b
+}
+
+// NXDomain sets the response code to NXDOMAIN.
+func (b *MessageBuilder) NXDomain() *MessageBuilder {
+	return b.Rcode(dns.RcodeNameError)
+}
+
+// Authoritative sets the authoritative answer (AA) flag.
+func (b *MessageBuilder) Authoritative() *MessageBuilder {
+	b.msg.Authoritative = true
+	return b
+}
+
+// Authenticated sets the authenticated data (AD) flag.
+func (b *MessageBuilder) Authenticated() *MessageBuilder {
+	b.msg.AuthenticatedData = true
+	return b
+}
+
+// Truncated sets the truncated (TC) flag.
+func (b *MessageBuilder) Truncated() *MessageBuilder {
+	b.msg.Truncated = true
+	return b
+}
+
+// Msg returns a copy of the message.
+func (b *MessageBuilder) Msg() *dns.Msg {
+	return b.msg.Copy()
+}
+
+// Reply returns a copy of the message, as a reply to req.
+func (b *MessageBuilder) Reply(req *dns.Msg) *dns.Msg {
+	return replyTo(&b.msg, req)
+}
+
+// RRs returns a copy of the records in the answer section, eg. to add to a
+// Server.
+func (b *MessageBuilder) RRs() []dns.RR {
+	return b.Msg().Answer
+}
+
+// Addrs returns the addresses of the A and AAAA records in the answer
+// section, eg. for use as the return value of a mock resolver.
+func (b *MessageBuilder) Addrs() []netip.Addr {
+	var addrs []netip.Addr
+	for _, rr := range b.msg.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			addrs = append(addrs, netip.AddrFrom4([4]byte(rr.A.To4())))
+		case *dns.AAAA:
+			addrs = append(addrs, netip.AddrFrom16([16]byte(rr.AAAA.To16())))
+		}
+	}
+	return addrs
+}
+
+// Handler returns a dns.Handler that replies to every query with the message.
+func (b *MessageBuilder) Handler() dns.Handler {
+	msg := b.Msg()
+	return dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		_ = w.WriteMsg(replyTo(msg, req))
+	})
+}
+
+// replyTo returns a copy of msg as a reply to req, SetReply resets the response
+// code so it is restored.
+func replyTo(msg, req *dns.Msg) *dns.Msg {
+	reply := msg.Copy()
+	reply.SetReply(req)
+	reply.Rcode = msg.Rcode
+	return reply
+}
+
+func header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: dns.Fqdn(name), Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
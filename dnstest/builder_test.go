@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package dnstest_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	answer := dnstest.Answer().
+		CNAME("www.example.com", "web.example.com", 300).
+		A("web.example.com", "192.0.2.1", 60).
+		AAAA("web.example.com", "2001:db8::1", 60).
+		Authoritative()
+
+	t.Run("Msg", func(t *testing.T) {
+		msg := answer.Msg()
+		require.True(t, msg.Authoritative)
+		require.Len(t, msg.Answer, 3)
+		require.Equal(t, "www.example.com.", msg.Answer[0].Header().Name)
+		require.Equal(t, "web.example.com.", msg.Answer[0].(*dns.CNAME).Target)
+		require.Equal(t, uint32(60), msg.Answer[1].Header().Ttl)
+	})
+
+	t.Run("Addrs", func(t *testing.T) {
+		require.Equal(t, []netip.Addr{
+			netip.MustParseAddr("192.0.2.1"),
+			netip.MustParseAddr("2001:db8::1"),
+		}, answer.Addrs())
+	})
+
+	t.Run("Reply", func(t *testing.T) {
+		req := &dns.Msg{}
+		req.SetQuestion("missing.example.com.", dns.TypeA)
+
+		reply := dnstest.Answer().SOA("example.com", 60).NXDomain().Reply(req)
+		require.Equal(t, req.Id, reply.Id)
+		require.True(t, reply.Response)
+		require.Equal(t, dns.RcodeNameError, reply.Rcode)
+		require.Len(t, reply.Ns, 1)
+	})
+
+	t.Run("Server", func(t *testing.T) {
+		srv := dnstest.NewServer(nil)
+		t.Cleanup(srv.Close)
+
+		srv.Add(answer.RRs()...)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: srv.Addr,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+		require.NoError(t, err)
+		require.ElementsMatch(t, answer.Addrs(), addrs)
+	})
+
+	t.Run("Invalid Address", func(t *testing.T) {
+		require.Panics(t, func() {
+			dnstest.Answer().A("www.example.com", "2001:db8::1", 60)
+		})
+	})
+}
@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package dnstest provides an in-process authoritative DNS server for
+// testing, seeded from a map of records or a zone file.
+package dnstest
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultTTL is the TTL of records that do not specify one.
+const DefaultTTL = 300
+
+// Records is a map of names to records, in presentation format without the
+// owner name (eg. "A 192.0.2.1" or "3600 MX 10 mail.example.com.").
+type Records map[string][]string
+
+// Server is an authoritative DNS server listening on a local UDP and TCP
+// port. Queries for names with a CNAME record are answered with the chain of
+// aliases, names without records of the requested type are answered with
+// NODATA, and unknown names with NXDOMAIN. If the records contain SOA
+// records, the server is only authoritative for those zones (queries for
+// other names are refused), and the SOA record is included in negative
+// answers.
+type Server struct {
+	// Addr is the address the server is listening on (for both UDP and
+	// TCP).
+	Addr netip.AddrPort
+
+	servers []*dns.Server
+
+	mu      sync.Mutex
+	records map[string][]dns.RR
+	zones   []*dns.SOA
+	queries []dns.Question
+}
+
+// NewServer starts a server serving records. It panics if the records are
+// invalid, or the server fails to start. The caller should call Close when
+// finished, to shut it down.
+func NewServer(records Records) *Server {
+	var rrs []dns.RR
+	for name, values := range records {
+		for _, value := range values {
+			rr, err := dns.NewRR(fmt.Sprintf("$TTL %d\n%s %s", DefaultTTL, dns.Fqdn(name), value))
+			if err != nil {
+				panic(fmt.Sprintf("dnstest: invalid record %q for %s: %v", value, name, err))
+			}
+			rrs = append(rrs, rr)
+		}
+	}
+
+	return newServer(rrs)
+}
+
+// NewZoneServer starts a server serving the records of a zone in zone file
+// format (RFC 1035). Relative names are relative to the origin of the zone
+// ($ORIGIN), or the root if there is none. It panics if the zone is invalid,
+// or the server fails to start. The caller should call Close when finished,
+// to shut it down.
+func NewZoneServer(zone string) *Server {
+	zp := dns.NewZoneParser(strings.NewReader(zone), ".", "")
+	zp.SetDefaultTTL(DefaultTTL)
+
+	var rrs []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		panic(fmt.Sprintf("dnstest: invalid zone: %v", err))
+	}
+
+	return newServer(rrs)
+}
+
+func newServer(rrs []dns.RR) *Server {
+	s := &Server{
+		records: make(map[string][]dns.RR),
+	}
+
+	s.Add(rrs...)
+
+	pc, lis, err := ListenLoopback()
+	if err != nil {
+		panic(fmt.Sprintf("dnstest: %v", err))
+	}
+
+	s.Addr = netip.MustParseAddrPort(pc.LocalAddr().String())
+
+	var started sync.WaitGroup
+	s.servers = []*dns.Server{
+		{PacketConn: pc, Handler: s, NotifyStartedFunc: started.Done},
+		{Listener: lis, Handler: s, NotifyStartedFunc: started.Done},
+	}
+
+	started.Add(len(s.servers))
+	for _, server := range s.servers {
+		go func() {
+			_ = server.ActivateAndServe()
+		}()
+	}
+	started.Wait()
+
+	return s
+}
+
+// listenAttempts is the number of ports tried by ListenLoopback.
+const listenAttempts = 16
+
+// ListenLoopback listens for UDP and TCP on the same, randomly chosen,
+// loopback port. The TCP port may already be in use by another socket, in
+// which case another port is tried.
+func ListenLoopback() (net.PacketConn, net.Listener, error) {
+	var err error
+	for i := 0; i < listenAttempts; i++ {
+		var pc net.PacketConn
+		pc, err = net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to listen on udp: %w", err)
+		}
+
+		var lis net.Listener
+		lis, err = net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			return pc, lis, nil
+		}
+
+		_ = pc.Close()
+	}
+
+	return nil, nil, fmt.Errorf("failed to listen on tcp: %w", err)
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	for _, server := range s.servers {
+		_ = server.Shutdown()
+	}
+}
+
+// Add adds records to the server, eg. records created using Answer.
+func (s *Server) Add(rrs ...dns.RR) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rr := range rrs {
+		name := dns.CanonicalName(rr.Header().Name)
+		s.records[name] = append(s.records[name], dns.Copy(rr))
+
+		if soa, ok := rr.(*dns.SOA); ok {
+			s.zones = append(s.zones, soa)
+		}
+	}
+}
+
+// Queries returns the questions received by the server, in the order they
+// were received.
+func (s *Server) Queries() []dns.Question {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]dns.Question(nil), s.queries...)
+}
+
+// ServeDNS answers a single query, it implements dns.Handler.
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	reply := s.answer(req)
+
+	if _, ok := w.LocalAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = max(int(opt.UDPSize()), dns.MinMsgSize)
+		}
+		reply.Truncate(size)
+	}
+
+	_ = w.WriteMsg(reply)
+}
+
+func (s *Server) answer(req *dns.Msg) *dns.Msg {
+	reply := &dns.Msg{}
+	reply.SetReply(req)
+
+	if req.Opcode != dns.OpcodeQuery {
+		reply.Rcode = dns.RcodeNotImplemented
+		return reply
+	}
+
+	if len(req.Question) != 1 {
+		reply.Rcode = dns.RcodeFormatError
+		return reply
+	}
+
+	q := req.Question[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries = append(s.queries, q)
+
+	if opt := req.IsEdns0(); opt != nil {
+		reply.SetEdns0(opt.UDPSize(), false)
+	}
+
+	name := dns.CanonicalName(q.Name)
+	if len(s.zones) > 0 && s.zoneOf(name) == nil {
+		reply.Rcode = dns.RcodeRefused
+		return reply
+	}
+	reply.Authoritative = true
+
+	// Follow aliases, as long as they are within our zones. The owner name
+	// of the records is the name as it was queried (or as it appears in the
+	// alias), to preserve its case.
+	owner := q.Name
+	seen := make(map[string]bool)
+	for !seen[name] {
+		seen[name] = true
+
+		rrs, ok := s.records[name]
+		if !ok && !s.isEmptyNonTerminal(name) {
+			reply.Rcode = dns.RcodeNameError
+			break
+		}
+
+		var cname dns.RR
+		var answers []dns.RR
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == q.Qtype || q.Qtype == dns.TypeANY {
+				answers = append(answers, rr)
+			} else if rr.Header().Rrtype == dns.TypeCNAME {
+				cname = rr
+			}
+		}
+
+		if len(answers) > 0 {
+			for _, rr := range answers {
+				reply.Answer = append(reply.Answer, withOwner(rr, owner))
+			}
+			return reply
+		}
+
+		if cname == nil {
+			break
+		}
+		reply.Answer = append(reply.Answer, withOwner(cname, owner))
+
+		owner = cname.(*dns.CNAME).Target
+		name = dns.CanonicalName(owner)
+		if len(s.zones) > 0 && s.zoneOf(name) == nil {
+			// The alias points outside of our zones.
+			return reply
+		}
+	}
+
+	if soa := s.zoneOf(name); soa != nil {
+		reply.Ns = append(reply.Ns, dns.Copy(soa))
+	}
+
+	return reply
+}
+
+// withOwner returns a copy of rr with the given owner name.
+func withOwner(rr dns.RR, owner string) dns.RR {
+	rr = dns.Copy(rr)
+	rr.Header().Name = owner
+	return rr
+}
+
+// zoneOf returns the SOA record of the closest zone containing name, or nil
+// if there is none.
+func (s *Server) zoneOf(name string) *dns.SOA {
+	var closest *dns.SOA
+	for _, soa := range s.zones {
+		if dns.IsSubDomain(soa.Hdr.Name, name) &&
+			(closest == nil || dns.CountLabel(soa.Hdr.Name) > dns.CountLabel(closest.Hdr.Name)) {
+			closest = soa
+		}
+	}
+	return closest
+}
+
+// isEmptyNonTerminal returns whether name has no records of its own, but
+// there are records for names below it (RFC 8020).
+func (s *Server) isEmptyNonTerminal(name string) bool {
+	for other := range s.records {
+		if other != name && dns.IsSubDomain(name, other) {
+			return true
+		}
+	}
+	return false
+}
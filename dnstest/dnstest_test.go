@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package dnstest_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("Records", func(t *testing.T) {
+		srv := dnstest.NewServer(dnstest.Records{
+			"www.example.com":   {"A 192.0.2.1", "AAAA 2001:db8::1"},
+			"alias.example.com": {"CNAME www.example.com."},
+		})
+		t.Cleanup(srv.Close)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: srv.Addr,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "alias.example.com")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []netip.Addr{
+			netip.MustParseAddr("192.0.2.1"),
+			netip.MustParseAddr("2001:db8::1"),
+		}, addrs)
+
+		_, err = res.LookupNetIP(context.Background(), "ip", "missing.example.com")
+		require.True(t, resolver.IsNXDomain(err))
+
+		require.Len(t, srv.Queries(), 4)
+	})
+
+	t.Run("Zone", func(t *testing.T) {
+		srv := dnstest.NewZoneServer(`
+$ORIGIN example.com.
+@       3600 IN SOA ns.example.com. admin.example.com. 1 7200 3600 1209600 60
+www          IN A   192.0.2.1
+mail    60   IN A   192.0.2.2
+@            IN MX  10 mail
+a.b          IN A   192.0.2.3
+`)
+		t.Cleanup(srv.Close)
+
+		client := &dns.Client{}
+		exchange := func(name string, qType uint16) *dns.Msg {
+			req := &dns.Msg{}
+			req.SetQuestion(name, qType)
+
+			reply, _, err := client.Exchange(req, srv.Addr.String())
+			require.NoError(t, err)
+
+			return reply
+		}
+
+		t.Run("Answer", func(t *testing.T) {
+			reply := exchange("WWW.example.com.", dns.TypeA)
+			require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+			require.True(t, reply.Authoritative)
+			require.Len(t, reply.Answer, 1)
+			require.Equal(t, "WWW.example.com.", reply.Answer[0].Header().Name)
+		})
+
+		t.Run("Relative Names", func(t *testing.T) {
+			reply := exchange("example.com.", dns.TypeMX)
+			require.Len(t, reply.Answer, 1)
+			require.Equal(t, "mail.example.com.", reply.Answer[0].(*dns.MX).Mx)
+		})
+
+		t.Run("No Data", func(t *testing.T) {
+			reply := exchange("www.example.com.", dns.TypeAAAA)
+			require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+			require.Empty(t, reply.Answer)
+			require.Len(t, reply.Ns, 1)
+			require.IsType(t, &dns.SOA{}, reply.Ns[0])
+		})
+
+		t.Run("Empty Non-Terminal", func(t *testing.T) {
+			reply := exchange("b.example.com.", dns.TypeA)
+			require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+			require.Empty(t, reply.Answer)
+		})
+
+		t.Run("NXDOMAIN", func(t *testing.T) {
+			reply := exchange("missing.example.com.", dns.TypeA)
+			require.Equal(t, dns.RcodeNameError, reply.Rcode)
+			require.Len(t, reply.Ns, 1)
+		})
+
+		t.Run("Refused", func(t *testing.T) {
+			reply := exchange("www.example.org.", dns.TypeA)
+			require.Equal(t, dns.RcodeRefused, reply.Rcode)
+		})
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		srv := dnstest.NewServer(dnstest.Records{
+			"www.example.com": {"A 192.0.2.1"},
+		})
+		t.Cleanup(srv.Close)
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:    srv.Addr,
+			Transport: ptr.To(resolver.DNSTransportTCP),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+}
+
+func TestListenLoopback(t *testing.T) {
+	// Exhaust enough pairs that some TCP ports are likely to collide.
+	for i := 0; i < 64; i++ {
+		pc, lis, err := dnstest.ListenLoopback()
+		require.NoError(t, err)
+
+		require.Equal(t, pc.LocalAddr().String(), lis.Addr().String())
+
+		require.NoError(t, pc.Close())
+		require.NoError(t, lis.Close())
+	}
+}
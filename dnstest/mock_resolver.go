@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package dnstest
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	_ resolver.Resolver     = (*MockResolver)(nil)
+	_ resolver.AddrResolver = (*MockResolver)(nil)
+	_ resolver.SRVResolver  = (*MockResolver)(nil)
+	_ resolver.MailResolver = (*MockResolver)(nil)
+	_ resolver.Exchanger    = (*MockResolver)(nil)
+)
+
+// MockResolver is a mock implementation of resolver.Resolver (and of the
+// optional AddrResolver, SRVResolver, MailResolver and Exchanger interfaces,
+// as well as the net.Resolver style lookup methods), for use in unit tests. Expectations are
+// set using the methods of the embedded mock.Mock, eg.
+//
+//	res := new(dnstest.MockResolver)
+//	res.On("LookupNetIP", mock.Anything, "ip", "www.example.com").
+//		Return(dnstest.Answer().A("www.example.com", "192.0.2.1", 60).Addrs(), nil)
+//
+// A nil return value may be given for any of the slices.
+type MockResolver struct {
+	mock.Mock
+}
+
+func (m *MockResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	args := m.Called(ctx, network, host)
+	addrs, _ := args.Get(0).([]netip.Addr)
+	return addrs, args.Error(1)
+}
+
+func (m *MockResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	args := m.Called(ctx, host)
+	addrs, _ := args.Get(0).([]string)
+	return addrs, args.Error(1)
+}
+
+func (m *MockResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	args := m.Called(ctx, network, host)
+	ips, _ := args.Get(0).([]net.IP)
+	return ips, args.Error(1)
+}
+
+func (m *MockResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	args := m.Called(ctx, host)
+	addrs, _ := args.Get(0).([]net.IPAddr)
+	return addrs, args.Error(1)
+}
+
+func (m *MockResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	args := m.Called(ctx, addr)
+	names, _ := args.Get(0).([]string)
+	return names, args.Error(1)
+}
+
+func (m *MockResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	args := m.Called(ctx, service, proto, name)
+	srvs, _ := args.Get(1).([]*net.SRV)
+	return args.String(0), srvs, args.Error(2)
+}
+
+func (m *MockResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	args := m.Called(ctx, name)
+	mxs, _ := args.Get(0).([]*net.MX)
+	return mxs, args.Error(1)
+}
+
+func (m *MockResolver) Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	args := m.Called(ctx, req)
+	reply, _ := args.Get(0).(*dns.Msg)
+	return reply, args.Error(1)
+}
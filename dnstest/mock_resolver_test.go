@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package dnstest_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockResolver(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupNetIP", mock.Anything, "ip", "www.example.com").
+		Return(dnstest.Answer().A("www.example.com", "192.0.2.1", 60).Addrs(), nil)
+	res.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, &net.DNSError{Err: resolver.ErrNoSuchHost.Error(), IsNotFound: true})
+	res.On("LookupHost", mock.Anything, "www.example.com").
+		Return([]string{"192.0.2.1"}, nil)
+
+	var _ resolver.Resolver = res
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+
+	addrs, err = res.LookupNetIP(context.Background(), "ip", "missing.example.com")
+	require.True(t, resolver.IsNXDomain(err))
+	require.Nil(t, addrs)
+
+	hosts, err := res.LookupHost(context.Background(), "www.example.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"192.0.2.1"}, hosts)
+
+	res.AssertExpectations(t)
+}
+
+func TestMockResolverOptionalInterfaces(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupAddr", mock.Anything, "192.0.2.1").
+		Return([]string{"www.example.com."}, nil)
+	res.On("LookupSRV", mock.Anything, "sip", "udp", "example.com").
+		Return("_sip._udp.example.com.", []*net.SRV{{Target: "sip.example.com.", Port: 5060}}, nil)
+	res.On("LookupMX", mock.Anything, "example.com").
+		Return(nil, &net.DNSError{Err: resolver.ErrNoSuchHost.Error(), IsNotFound: true})
+	res.On("Exchange", mock.Anything, mock.Anything).
+		Return(new(dns.Msg), nil)
+
+	names, err := res.LookupAddr(context.Background(), "192.0.2.1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"www.example.com."}, names)
+
+	cname, srvs, err := res.LookupSRV(context.Background(), "sip", "udp", "example.com")
+	require.NoError(t, err)
+	require.Equal(t, "_sip._udp.example.com.", cname)
+	require.Len(t, srvs, 1)
+
+	mxs, err := res.LookupMX(context.Background(), "example.com")
+	require.True(t, resolver.IsNXDomain(err))
+	require.Nil(t, mxs)
+
+	reply, err := res.Exchange(context.Background(), new(dns.Msg))
+	require.NoError(t, err)
+	require.NotNil(t, reply)
+
+	res.AssertExpectations(t)
+}
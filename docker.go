@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver/internal/dnsconfig"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var _ Resolver = (*dockerResolver)(nil)
+
+// DockerEmbeddedDNSServer is the address of Docker's embedded DNS server, as
+// configured in the resolv.conf of containers attached to user-defined
+// networks.
+var DockerEmbeddedDNSServer = netip.MustParseAddrPort("127.0.0.11:53")
+
+// DockerResolverConfig is the configuration for a Docker resolver.
+type DockerResolverConfig struct {
+	// Server is the address of the embedded DNS server. Defaults to
+	// DockerEmbeddedDNSServer.
+	Server *netip.AddrPort
+	// Upstream is an optional resolver used for names that are not container
+	// names. By default, all lookups are sent to the embedded DNS server,
+	// which forwards them to the host's DNS servers (as it does for programs
+	// using the stdlib).
+	Upstream Resolver
+	// ContainerDomains are domains (and their subdomains) that are always
+	// resolved by the embedded DNS server, in addition to single-label
+	// names (eg. container names and network aliases). Defaults to
+	// "docker.internal".
+	ContainerDomains []string
+	// ResolvConfPath is the optional path to the resolv.conf file that search
+	// domains and options are read from. By default, the system's
+	// resolv.conf is used.
+	ResolvConfPath string
+	// HostsFilePath is the optional path to the hosts file (Docker writes the
+	// container's own hostname to it). By default, the system's hosts file is
+	// used.
+	HostsFilePath string
+	// DialContext is used to establish a connection to the embedded DNS
+	// server.
+	DialContext DialContextFunc
+	// Logger is an optional logger, queries and retries are logged at debug
+	// level.
+	Logger *slog.Logger
+}
+
+// dockerResolver is a resolver that sends lookups for container names to
+// Docker's embedded DNS server, and all other lookups upstream.
+type dockerResolver struct {
+	embedded Resolver
+	upstream Resolver
+	domains  []string
+}
+
+// Docker returns a resolver that mimics the behavior of the stdlib inside a
+// Docker container attached to a user-defined network, where resolv.conf
+// points to the embedded DNS server (127.0.0.11). Container names, network
+// aliases and service names are resolved by the embedded DNS server, and
+// the search domains and options (eg. ndots:0) written by Docker are
+// respected.
+//
+// If an upstream resolver is configured (eg. a DNS over HTTPS server), only
+// container names are resolved by the embedded DNS server, all other names
+// are looked up using the upstream resolver.
+func Docker(conf *DockerResolverConfig) (Resolver, error) {
+	containerDomains := []string{"docker.internal"}
+	if conf != nil && conf.ContainerDomains != nil {
+		containerDomains = conf.ContainerDomains
+	}
+
+	conf, err := defaults.WithDefaults(conf, &DockerResolverConfig{
+		Server:         ptr.To(DockerEmbeddedDNSServer),
+		ResolvConfPath: dnsconfig.Location,
+		DialContext:    (&net.Dialer{}).DialContext,
+		Logger:         discardLogger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to docker resolver config: %w", err)
+	}
+
+	dnsConf, err := dnsconfig.Read(conf.ResolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS configuration: %w", err)
+	}
+
+	var timeout *time.Duration
+	if dnsConf.Timeout > 0 {
+		timeout = &dnsConf.Timeout
+	}
+
+	transport := DNSTransportUDP
+	if dnsConf.UseTCP {
+		transport = DNSTransportTCP
+	}
+
+	var attempts *int
+	if dnsConf.Attempts > 0 {
+		attempts = &dnsConf.Attempts
+	}
+
+	embedded := Retry(DNS(DNSResolverConfig{
+		Server:        *conf.Server,
+		Transport:     &transport,
+		Timeout:       timeout,
+		DialContext:   conf.DialContext,
+		SingleRequest: &dnsConf.SingleRequest,
+		TrustAD:       &dnsConf.TrustAD,
+		Logger:        conf.Logger,
+	}), &RetryResolverConfig{
+		Attempts: attempts,
+		Logger:   conf.Logger,
+	})
+
+	var resolver Resolver = embedded
+	if conf.Upstream != nil {
+		r := &dockerResolver{
+			embedded: embedded,
+			upstream: conf.Upstream,
+		}
+		for _, domain := range containerDomains {
+			r.domains = append(r.domains, dns.CanonicalName(domain))
+		}
+		resolver = r
+	}
+
+	if len(dnsConf.Search) > 0 {
+		resolver = Relative(resolver, &RelativeResolverConfig{
+			Search: dnsConf.Search,
+			NDots:  ptr.To(max(dnsConf.NDots, 0)),
+		})
+	}
+
+	hostsResolver, err := Hosts(&HostsResolverConfig{
+		HostsFilePath: conf.HostsFilePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hosts file resolver: %w", err)
+	}
+
+	return Sequential(Literal(), hostsResolver, resolver), nil
+}
+
+func (r *dockerResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if r.isContainerName(host) {
+		return r.embedded.LookupNetIP(ctx, network, host)
+	}
+
+	return r.upstream.LookupNetIP(ctx, network, host)
+}
+
+// Close closes the embedded and upstream resolvers.
+func (r *dockerResolver) Close() error {
+	return closeAll([]Resolver{r.embedded, r.upstream})
+}
+
+func (r *dockerResolver) describe() Description {
+	return Description{
+		Type:     "docker",
+		Children: []Description{Describe(r.embedded), Describe(r.upstream)},
+	}
+}
+
+// isContainerName returns whether host is a name that only the embedded DNS
+// server can resolve.
+func (r *dockerResolver) isContainerName(host string) bool {
+	name := dns.CanonicalName(host)
+	if dns.CountLabel(name) == 1 {
+		return true
+	}
+
+	for _, domain := range r.domains {
+		if dns.IsSubDomain(domain, name) {
+			return true
+		}
+	}
+
+	return false
+}
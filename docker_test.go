@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocker(t *testing.T) {
+	// The resolv.conf written by Docker for containers on user-defined
+	// networks.
+	dir := t.TempDir()
+	resolvConfPath := filepath.Join(dir, "resolv.conf")
+	require.NoError(t, os.WriteFile(resolvConfPath, []byte("nameserver 127.0.0.11\nsearch corp.example\noptions ndots:0\n"), 0o644))
+
+	hostsFilePath := filepath.Join(dir, "hosts")
+	require.NoError(t, os.WriteFile(hostsFilePath, []byte("172.18.0.3\tself\n"), 0o644))
+
+	embedded := dnstest.NewServer(dnstest.Records{
+		"db":                   {"A 172.18.0.2"},
+		"host.docker.internal": {"A 192.168.65.254"},
+		"www.example.com":      {"A 192.0.2.1"},
+	})
+	t.Cleanup(embedded.Close)
+
+	ctx := context.Background()
+
+	t.Run("Embedded", func(t *testing.T) {
+		res, err := resolver.Docker(&resolver.DockerResolverConfig{
+			Server:         &embedded.Addr,
+			ResolvConfPath: resolvConfPath,
+			HostsFilePath:  hostsFilePath,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resolver.Close(res) })
+
+		for host, expected := range map[string]string{
+			"db":              "172.18.0.2",
+			"self":            "172.18.0.3",
+			"www.example.com": "192.0.2.1",
+		} {
+			addrs, err := res.LookupNetIP(ctx, "ip4", host)
+			require.NoError(t, err, host)
+
+			require.Equal(t, []netip.Addr{netip.MustParseAddr(expected)}, addrs, host)
+		}
+	})
+
+	t.Run("Upstream", func(t *testing.T) {
+		upstream := new(dnstest.MockResolver)
+		upstream.On("LookupNetIP", mock.Anything, "ip4", "www.example.com.").
+			Return([]netip.Addr{netip.MustParseAddr("192.0.2.2")}, nil)
+
+		res, err := resolver.Docker(&resolver.DockerResolverConfig{
+			Server:         &embedded.Addr,
+			Upstream:       upstream,
+			ResolvConfPath: resolvConfPath,
+			HostsFilePath:  hostsFilePath,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resolver.Close(res) })
+
+		for host, expected := range map[string]string{
+			"db":                   "172.18.0.2",
+			"host.docker.internal": "192.168.65.254",
+			"www.example.com":      "192.0.2.2",
+		} {
+			addrs, err := res.LookupNetIP(ctx, "ip4", host)
+			require.NoError(t, err, host)
+
+			require.Equal(t, []netip.Addr{netip.MustParseAddr(expected)}, addrs, host)
+		}
+
+		upstream.AssertExpectations(t)
+	})
+}
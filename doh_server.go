@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+var _ http.Handler = (*DNSServer)(nil)
+
+// dohContentType is the media type of DNS over HTTPS messages.
+const dohContentType = "application/dns-message"
+
+// ServeHTTP answers a DNS over HTTPS query (RFC 8484), both GET and POST
+// requests are supported. It implements http.Handler, so the server can be
+// mounted on an existing HTTP server (eg. at "/dns-query"). TLS is expected to
+// be terminated by the HTTP server.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc8484
+func (s *DNSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var p []byte
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		p, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil || len(p) == 0 {
+			http.Error(w, "missing or malformed dns parameter", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != dohContentType {
+			http.Error(w, fmt.Sprintf("unsupported content type, expected %s", dohContentType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var err error
+		p, err = io.ReadAll(io.LimitReader(r.Body, dns.MaxMsgSize+1))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(p) > dns.MaxMsgSize {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &dns.Msg{}
+	if err := req.Unpack(p); err != nil {
+		http.Error(w, "malformed DNS message", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		ctx = WithClientAddr(ctx, addrPort.Addr().Unmap())
+	}
+
+	reply := s.answer(ctx, req)
+
+	p, err := reply.Pack()
+	if err != nil {
+		s.logger.Debug("Failed to pack reply", slog.Any("error", err))
+		http.Error(w, "failed to pack reply", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", dohContentType)
+	if len(reply.Answer) > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", uint32(answerTTL(reply.Answer).Seconds())))
+	}
+	if _, err := w.Write(p); err != nil {
+		s.logger.Debug("Failed to write reply",
+			slog.String("remote", r.RemoteAddr), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSServerHTTP(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupNetIP", mock.Anything, "ip4", "www.example.com.").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	srv := httptest.NewServer(resolver.NewDNSServer(res, nil))
+	t.Cleanup(srv.Close)
+
+	req := &dns.Msg{}
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	req.Id = 0
+
+	p, err := req.Pack()
+	require.NoError(t, err)
+
+	checkReply := func(t *testing.T, resp *http.Response) {
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/dns-message", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		reply := &dns.Msg{}
+		require.NoError(t, reply.Unpack(body))
+
+		require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "192.0.2.1", reply.Answer[0].(*dns.A).A.String())
+	}
+
+	t.Run("GET", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/dns-query?dns=" + base64.RawURLEncoding.EncodeToString(p))
+		require.NoError(t, err)
+
+		checkReply(t, resp)
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+"/dns-query", "application/dns-message", bytes.NewReader(p))
+		require.NoError(t, err)
+
+		checkReply(t, resp)
+	})
+
+	t.Run("Bad Request", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/dns-query?dns=invalid!")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Unsupported Media Type", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+"/dns-query", "text/plain", bytes.NewReader(p))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+
+	t.Run("Method Not Allowed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+"/dns-query", nil)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
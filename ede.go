@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ExtendedError is an Extended DNS Error (EDE) reported by a server, as
+// defined in RFC 8914.
+type ExtendedError struct {
+	// InfoCode is the extended error code (eg. 15 for "Blocked").
+	InfoCode uint16
+	// ExtraText is optional, human readable, additional information.
+	ExtraText string
+}
+
+func (e ExtendedError) String() string {
+	s := fmt.Sprintf("EDE %d", e.InfoCode)
+	if desc, ok := dns.ExtendedErrorCodeToString[e.InfoCode]; ok {
+		s += " " + desc
+	}
+	if e.ExtraText != "" {
+		s += ": " + e.ExtraText
+	}
+	return s
+}
+
+// extendedErrors returns the extended errors included in a reply.
+func extendedErrors(reply *dns.Msg) []ExtendedError {
+	opt := reply.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+
+	var extendedErrs []ExtendedError
+	for _, option := range opt.Option {
+		if ede, ok := option.(*dns.EDNS0_EDE); ok {
+			extendedErrs = append(extendedErrs, ExtendedError{
+				InfoCode:  ede.InfoCode,
+				ExtraText: ede.ExtraText,
+			})
+		}
+	}
+	return extendedErrs
+}
+
+// withExtendedErrors attaches the extended errors included in reply (if any)
+// to the error (see withExtendedErrorCodes).
+func (e *Error) withExtendedErrors(reply *dns.Msg) *Error {
+	return e.withExtendedErrorCodes(extendedErrors(reply))
+}
+
+// withExtendedErrorCodes attaches the extended errors to the error. Only the
+// info codes are added to its message, the extra text is supplied by the
+// server and so is only kept in the ExtendedErrors field.
+func (e *Error) withExtendedErrorCodes(extendedErrs []ExtendedError) *Error {
+	e.ExtendedErrors = extendedErrs
+	if len(e.ExtendedErrors) == 0 {
+		return e
+	}
+
+	descs := make([]string, len(e.ExtendedErrors))
+	for i, extendedErr := range e.ExtendedErrors {
+		descs[i] = ExtendedError{InfoCode: extendedErr.InfoCode}.String()
+	}
+	e.Err = fmt.Sprintf("%s (%s)", e.Err, strings.Join(descs, "; "))
+
+	return e
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendedErrors(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Rcode = dns.RcodeNameError
+
+		// Extended errors are only included if the query used EDNS(0).
+		if req.IsEdns0() != nil {
+			reply.SetEdns0(dns.DefaultMsgSize, false)
+			opt := reply.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_EDE{
+				InfoCode:  dns.ExtendedErrorCodeBlocked,
+				ExtraText: "listed in malware blocklist",
+			})
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	})
+
+	_, err := res.LookupNetIP(context.Background(), "ip4", "malware.example")
+	require.ErrorIs(t, err, resolver.ErrNoSuchHost)
+
+	var dnsErr *resolver.Error
+	require.ErrorAs(t, err, &dnsErr)
+
+	require.Equal(t, []resolver.ExtendedError{{
+		InfoCode:  dns.ExtendedErrorCodeBlocked,
+		ExtraText: "listed in malware blocklist",
+	}}, dnsErr.ExtendedErrors)
+
+	// The server supplied text is not included in the message.
+	require.ErrorContains(t, err, "no such host (EDE 15 Blocked)")
+	require.NotContains(t, err.Error(), "listed in malware blocklist")
+}
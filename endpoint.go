@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"cmp"
+	"context"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var _ EndpointResolver = (*dnsResolver)(nil)
+
+// maxSVCBAliasChain is the maximum number of AliasMode records followed when
+// resolving an endpoint.
+const maxSVCBAliasChain = 8
+
+// Exchanger sends DNS queries, it is implemented by DNS resolvers (see
+// DNSResolverConfig).
+type Exchanger interface {
+	// Exchange sends req and returns the reply.
+	Exchange(ctx context.Context, req *dns.Msg) (*dns.Msg, error)
+}
+
+// EndpointResolver looks up service bindings and addresses.
+type EndpointResolver interface {
+	Resolver
+	Exchanger
+}
+
+// Endpoint is a network endpoint of a service.
+type Endpoint struct {
+	// Target is the name the addresses of the endpoint were resolved from.
+	// The TLS server name should remain the origin's host.
+	Target string
+	// Port is the port to connect to.
+	Port uint16
+	// Addrs are the addresses of the endpoint.
+	Addrs []netip.Addr
+	// ALPN is the set of application protocols supported by the endpoint, in
+	// the order of the server's preference.
+	ALPN []string
+	// ECHConfigList is the TLS Encrypted Client Hello configuration of the
+	// endpoint, if any.
+	ECHConfigList []byte
+	// Priority is the priority of the service binding record the endpoint was
+	// derived from, it is zero for the fallback endpoint (which is derived
+	// from the origin's address records).
+	Priority uint16
+}
+
+// LookupEndpoint resolves the endpoints of a service, implementing the client
+// algorithm of RFC 9460. Service bindings (HTTPS records for the "http" and
+// "https" schemes, SVCB records otherwise) are returned in priority order,
+// followed by a fallback endpoint using the addresses of host.
+//
+// As HTTPS records indicate the origin supports HTTPS, "http" origins are
+// upgraded (eg. port 80 becomes 443). The fallback endpoint is omitted if any
+// service binding advertises Encrypted Client Hello, so that an attacker
+// can't force a downgrade by blocking the preferred endpoints.
+func LookupEndpoint(ctx context.Context, resolver EndpointResolver, scheme, host string, port uint16) ([]Endpoint, error) {
+	host = dns.CanonicalName(host)
+
+	qType := dns.TypeSVCB
+	defaultALPN := false
+	switch scheme {
+	case "http":
+		scheme = "https"
+		if port == 80 {
+			port = 443
+		}
+		fallthrough
+	case "https":
+		qType = dns.TypeHTTPS
+		defaultALPN = true
+	}
+
+	// Origins on the default port of HTTPS use the host's name directly,
+	// otherwise the name is prefixed with the port and scheme.
+	qName := host
+	if qType != dns.TypeHTTPS || port != 443 {
+		qName = "_" + strconv.Itoa(int(port)) + "._" + scheme + "." + host
+	}
+
+	bindings, err := lookupServiceBindings(ctx, resolver, qName, qType)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []Endpoint
+	fallback := true
+	for _, svcb := range bindings {
+		endpoint, ok := endpointFromSVCB(ctx, resolver, svcb, port, defaultALPN)
+		if !ok {
+			continue
+		}
+		if len(endpoint.ECHConfigList) > 0 {
+			fallback = false
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if fallback {
+		addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil && len(endpoints) == 0 {
+			return nil, err
+		}
+		if err == nil {
+			endpoint := Endpoint{Target: host, Port: port, Addrs: addrs}
+			if defaultALPN {
+				endpoint.ALPN = []string{"http/1.1"}
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// lookupServiceBindings returns the ServiceMode records of name (following
+// any AliasMode records), sorted by priority. Failing to look up the records
+// is not an error, as clients should fall back to the origin's addresses.
+func lookupServiceBindings(ctx context.Context, resolver Exchanger, name string, qType uint16) ([]*dns.SVCB, error) {
+	for i := 0; i < maxSVCBAliasChain; i++ {
+		req := &dns.Msg{}
+		req.SetQuestion(name, qType)
+
+		reply, err := resolver.Exchange(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, newError(name, "", ctx.Err())
+			}
+			return nil, nil
+		}
+		if reply.Rcode != dns.RcodeSuccess {
+			return nil, nil
+		}
+
+		var alias *dns.SVCB
+		var bindings []*dns.SVCB
+		for _, rr := range reply.Answer {
+			var svcb *dns.SVCB
+			switch rr := rr.(type) {
+			case *dns.HTTPS:
+				svcb = &rr.SVCB
+			case *dns.SVCB:
+				svcb = rr
+			default:
+				continue
+			}
+
+			if svcb.Priority == 0 {
+				alias = svcb
+			} else {
+				bindings = append(bindings, svcb)
+			}
+		}
+
+		// ServiceMode records take precedence over AliasMode records.
+		if len(bindings) > 0 || alias == nil {
+			slices.SortStableFunc(bindings, func(a, b *dns.SVCB) int {
+				return cmp.Compare(a.Priority, b.Priority)
+			})
+			return bindings, nil
+		}
+
+		// An alias to the root means the service is not available.
+		if alias.Target == "." {
+			return nil, nil
+		}
+
+		name = dns.CanonicalName(alias.Target)
+	}
+
+	return nil, nil
+}
+
+// endpointFromSVCB returns the endpoint described by a ServiceMode record,
+// it returns false if the record can't be used (eg. it has mandatory
+// parameters that aren't supported, or the target has no addresses).
+func endpointFromSVCB(ctx context.Context, resolver Resolver, svcb *dns.SVCB, port uint16, defaultALPN bool) (Endpoint, bool) {
+	target := dns.CanonicalName(svcb.Target)
+	if svcb.Target == "." {
+		target = dns.CanonicalName(svcb.Hdr.Name)
+	}
+
+	endpoint := Endpoint{
+		Target:   target,
+		Port:     port,
+		Priority: svcb.Priority,
+	}
+
+	var hints []netip.Addr
+	for _, kv := range svcb.Value {
+		switch kv := kv.(type) {
+		case *dns.SVCBMandatory:
+			for _, key := range kv.Code {
+				if !isSupportedSVCBKey(key) {
+					return Endpoint{}, false
+				}
+			}
+		case *dns.SVCBAlpn:
+			endpoint.ALPN = append(endpoint.ALPN, kv.Alpn...)
+		case *dns.SVCBNoDefaultAlpn:
+			defaultALPN = false
+		case *dns.SVCBPort:
+			endpoint.Port = kv.Port
+		case *dns.SVCBIPv4Hint:
+			for _, ip := range kv.Hint {
+				if addr, ok := netip.AddrFromSlice(ip.To4()); ok {
+					hints = append(hints, addr)
+				}
+			}
+		case *dns.SVCBIPv6Hint:
+			for _, ip := range kv.Hint {
+				if addr, ok := netip.AddrFromSlice(ip); ok {
+					hints = append(hints, addr)
+				}
+			}
+		case *dns.SVCBECHConfig:
+			endpoint.ECHConfigList = kv.ECH
+		}
+	}
+
+	if defaultALPN && !slices.Contains(endpoint.ALPN, "http/1.1") {
+		endpoint.ALPN = append(endpoint.ALPN, "http/1.1")
+	}
+
+	// Address hints are only used if the target can't be resolved.
+	addrs, err := resolver.LookupNetIP(ctx, "ip", strings.TrimSuffix(target, "."))
+	if err != nil {
+		addrs = hints
+	}
+	if len(addrs) == 0 {
+		return Endpoint{}, false
+	}
+	endpoint.Addrs = addrs
+
+	return endpoint, true
+}
+
+// isSupportedSVCBKey returns true if the service parameter key is understood
+// by LookupEndpoint.
+func isSupportedSVCBKey(key dns.SVCBKey) bool {
+	switch key {
+	case dns.SVCB_MANDATORY, dns.SVCB_ALPN, dns.SVCB_NO_DEFAULT_ALPN, dns.SVCB_PORT,
+		dns.SVCB_IPV4HINT, dns.SVCB_IPV6HINT, dns.SVCB_ECHCONFIG:
+		return true
+	default:
+		return false
+	}
+}
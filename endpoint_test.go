@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupEndpoint(t *testing.T) {
+	srv := dnstest.NewServer(dnstest.Records{
+		"www.example":           {"HTTPS 1 . alpn=h2 port=8443", "A 192.0.2.1"},
+		"alias.example":         {"HTTPS 0 svc.example.", "A 192.0.2.2"},
+		"svc.example":           {"HTTPS 1 . ipv4hint=192.0.2.9"},
+		"ech.example":           {"HTTPS 1 . ech=AEX+DQ==", "A 192.0.2.3"},
+		"plain.example":         {"A 192.0.2.4"},
+		"_853._dns.dns.example": {"SVCB 1 dns.example. alpn=dot"},
+		"dns.example":           {"A 192.0.2.5"},
+	})
+	t.Cleanup(srv.Close)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: srv.Addr,
+	})
+
+	ctx := context.Background()
+
+	t.Run("Service Mode", func(t *testing.T) {
+		endpoints, err := resolver.LookupEndpoint(ctx, res, "https", "www.example", 443)
+		require.NoError(t, err)
+
+		require.Equal(t, []resolver.Endpoint{
+			{
+				Target:   "www.example.",
+				Port:     8443,
+				Addrs:    []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+				ALPN:     []string{"h2", "http/1.1"},
+				Priority: 1,
+			},
+			{
+				Target: "www.example.",
+				Port:   443,
+				Addrs:  []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+				ALPN:   []string{"http/1.1"},
+			},
+		}, endpoints)
+	})
+
+	t.Run("Alias Mode", func(t *testing.T) {
+		endpoints, err := resolver.LookupEndpoint(ctx, res, "https", "alias.example", 443)
+		require.NoError(t, err)
+
+		require.Len(t, endpoints, 2)
+		require.Equal(t, "svc.example.", endpoints[0].Target)
+		// The target has no addresses, so the hints are used.
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.9")}, endpoints[0].Addrs)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.2")}, endpoints[1].Addrs)
+	})
+
+	t.Run("ECH Disables Fallback", func(t *testing.T) {
+		endpoints, err := resolver.LookupEndpoint(ctx, res, "https", "ech.example", 443)
+		require.NoError(t, err)
+
+		require.Len(t, endpoints, 1)
+		require.NotEmpty(t, endpoints[0].ECHConfigList)
+	})
+
+	t.Run("HTTP Upgrade", func(t *testing.T) {
+		endpoints, err := resolver.LookupEndpoint(ctx, res, "http", "www.example", 80)
+		require.NoError(t, err)
+
+		require.Equal(t, uint16(8443), endpoints[0].Port)
+		require.Equal(t, uint16(443), endpoints[1].Port)
+	})
+
+	t.Run("No Service Bindings", func(t *testing.T) {
+		endpoints, err := resolver.LookupEndpoint(ctx, res, "https", "plain.example", 8443)
+		require.NoError(t, err)
+
+		require.Equal(t, []resolver.Endpoint{{
+			Target: "plain.example.",
+			Port:   8443,
+			Addrs:  []netip.Addr{netip.MustParseAddr("192.0.2.4")},
+			ALPN:   []string{"http/1.1"},
+		}}, endpoints)
+	})
+
+	t.Run("SVCB", func(t *testing.T) {
+		endpoints, err := resolver.LookupEndpoint(ctx, res, "dns", "dns.example", 853)
+		require.NoError(t, err)
+
+		require.Equal(t, []resolver.Endpoint{
+			{
+				Target:   "dns.example.",
+				Port:     853,
+				Addrs:    []netip.Addr{netip.MustParseAddr("192.0.2.5")},
+				ALPN:     []string{"dot"},
+				Priority: 1,
+			},
+			{
+				Target: "dns.example.",
+				Port:   853,
+				Addrs:  []netip.Addr{netip.MustParseAddr("192.0.2.5")},
+			},
+		}, endpoints)
+	})
+
+	t.Run("No Such Host", func(t *testing.T) {
+		_, err := resolver.LookupEndpoint(ctx, res, "https", "missing.example", 443)
+		require.True(t, resolver.IsNXDomain(err))
+	})
+}
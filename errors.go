@@ -43,32 +43,132 @@ import (
 	"context"
 	"errors"
 	"net"
-	"os"
-
-	"dario.cat/mergo"
 )
 
 var (
 	ErrNoSuchHost          = errors.New("no such host")
+	ErrNoData              = errors.New("no records of the requested type")
+	ErrTimeout             = errors.New("i/o timeout")
+	ErrServFail            = errors.New("server failure")
+	ErrRefused             = errors.New("query refused")
+	ErrSecureFailure       = errors.New("secure lookup failed")
 	ErrServerMisbehaving   = errors.New("server misbehaving")
 	ErrUnsupportedNetwork  = errors.New("unsupported network")
 	ErrUnsupportedProtocol = errors.New("unsupported protocol")
+	ErrUnauthenticated     = errors.New("answer not authenticated")
+	ErrBogus               = errors.New("DNSSEC validation failure")
+	ErrDANENotApplicable   = errors.New("no usable TLSA records")
+	ErrDANEMismatch        = errors.New("certificate does not match TLSA records")
+	ErrRebinding           = errors.New("answer contains internal addresses")
+	ErrCNAMELoop           = errors.New("CNAME loop detected")
+	ErrCNAMEChainTooLong   = errors.New("CNAME chain too long")
+	ErrTruncated           = errors.New("response truncated")
+	ErrSPKIPinMismatch     = errors.New("server certificate does not match SPKI pins")
+	ErrPrivacyRequired     = errors.New("authenticated encrypted transport required")
+	ErrNoQuorum            = errors.New("resolvers did not agree on an answer")
+	ErrServiceUnavailable  = errors.New("service not available")
 )
 
-func extendDNSError(dst *net.DNSError, src net.DNSError) *net.DNSError {
-	if err := mergo.Merge(dst, src); err != nil {
-		panic(err)
+// Error is a lookup error. It wraps a net.DNSError, so that callers written
+// against the standard library continue to work, along with the errors that
+// caused it, which can be inspected using errors.Is and errors.As.
+//
+// Causes are classified using the following sentinel errors:
+//   - ErrNoSuchHost: the name does not exist (NXDOMAIN).
+//   - ErrNoData: the name exists, but has no records of the requested type.
+//   - ErrTimeout: the server did not respond in time.
+//   - ErrServFail: the server failed to answer the query (SERVFAIL).
+//   - ErrRefused: the server refused to answer the query (REFUSED).
+//   - ErrTruncated: the response was truncated.
+//   - ErrSecureFailure: the answer failed DNSSEC validation, or was not
+//     authenticated when it was required to be.
+type Error struct {
+	net.DNSError
+	// ExtendedErrors are the Extended DNS Errors (RFC 8914) included in the
+	// server's reply, if any.
+	ExtendedErrors []ExtendedError
+	causes         []error
+}
+
+// newError returns a lookup error for name (queried on server, which may be
+// empty) caused by err. The flags of the underlying net.DNSError are derived
+// from err.
+func newError(name, server string, err error) *Error {
+	causes := []error{err}
+	if isTimeout(err) && !errors.Is(err, ErrTimeout) {
+		causes = append(causes, ErrTimeout)
 	}
+	if (errors.Is(err, ErrBogus) || errors.Is(err, ErrUnauthenticated)) && !errors.Is(err, ErrSecureFailure) {
+		causes = append(causes, ErrSecureFailure)
+	}
+
+	var temporary interface{ Temporary() bool }
+	isTemporary := errors.Is(err, ErrServFail) || errors.Is(err, ErrTruncated) ||
+		(errors.As(err, &temporary) && temporary.Temporary())
+
+	return &Error{
+		DNSError: net.DNSError{
+			Err:         err.Error(),
+			Name:        name,
+			Server:      server,
+			IsTimeout:   isTimeout(err) || errors.Is(err, ErrTimeout),
+			IsTemporary: isTemporary,
+			IsNotFound:  errors.Is(err, ErrNoSuchHost) || errors.Is(err, ErrNoData),
+		},
+		causes: causes,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.DNSError.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return append([]error{&e.DNSError}, e.causes...)
+}
+
+// temporaryError marks an error as temporary (ie. worth retrying).
+type temporaryError struct {
+	error
+}
+
+// temporary marks err as temporary.
+func temporary(err error) error {
+	return &temporaryError{err}
+}
+
+func (e *temporaryError) Temporary() bool {
+	return true
+}
+
+func (e *temporaryError) Unwrap() error {
+	return e.error
+}
+
+// IsNXDomain returns true if err reports that the name does not exist
+// (NXDOMAIN).
+func IsNXDomain(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.Is(err, ErrNoSuchHost) ||
+		(errors.As(err, &dnsErr) && dnsErr.IsNotFound && dnsErr.Err == ErrNoSuchHost.Error())
+}
 
-	return dst
+// IsNoData returns true if err reports that the name exists, but has no
+// records of the requested type (NODATA).
+func IsNoData(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.Is(err, ErrNoData) ||
+		(errors.As(err, &dnsErr) && dnsErr.IsNotFound && dnsErr.Err == ErrNoData.Error())
 }
 
 func isTimeout(err error) bool {
-	return errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err)
+	var timeout interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout())
 }
 
 func isTemporary(err error) bool {
-	if dnsErr, ok := err.(*net.DNSError); ok {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
 		return dnsErr.Temporary()
 	}
 	return false
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		switch req.Question[0].Name {
+		case "servfail.example.":
+			reply.Rcode = dns.RcodeServerFailure
+		case "refused.example.":
+			reply.Rcode = dns.RcodeRefused
+		case "nxdomain.example.":
+			reply.Rcode = dns.RcodeNameError
+		case "timeout.example.":
+			// Never reply.
+			return
+		default:
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:    server,
+		Timeout:   ptr.To(100 * time.Millisecond),
+		RequireAD: []string{"signed.example"},
+	})
+
+	tests := []struct {
+		host      string
+		sentinels []error
+		notFound  bool
+		temporary bool
+		timeout   bool
+	}{
+		{host: "servfail.example", sentinels: []error{resolver.ErrServFail, resolver.ErrServerMisbehaving}, temporary: true},
+		{host: "refused.example", sentinels: []error{resolver.ErrRefused, resolver.ErrServerMisbehaving}},
+		{host: "nxdomain.example", sentinels: []error{resolver.ErrNoSuchHost}, notFound: true},
+		{host: "timeout.example", sentinels: []error{resolver.ErrTimeout}, temporary: true, timeout: true},
+		{host: "www.signed.example", sentinels: []error{resolver.ErrUnauthenticated, resolver.ErrSecureFailure}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			_, err := res.LookupNetIP(context.Background(), "ip4", tt.host)
+			require.Error(t, err)
+
+			for _, sentinel := range tt.sentinels {
+				require.ErrorIs(t, err, sentinel)
+			}
+
+			var typedErr *resolver.Error
+			require.ErrorAs(t, err, &typedErr)
+
+			// Compatible with the standard library.
+			var dnsErr *net.DNSError
+			require.ErrorAs(t, err, &dnsErr)
+			require.Equal(t, tt.notFound, dnsErr.IsNotFound)
+			require.Equal(t, tt.temporary, dnsErr.Temporary())
+			require.Equal(t, tt.timeout, dnsErr.Timeout())
+
+			require.Equal(t, dnsErr.Error(), err.Error())
+			require.False(t, errors.Is(err, resolver.ErrNoData))
+		})
+	}
+}
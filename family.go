@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+)
+
+var _ Resolver = (*familyResolver)(nil)
+
+// FamilyPolicy is how addresses are steered by address family, regardless of
+// the network requested by callers.
+type FamilyPolicy string
+
+const (
+	// FamilyPreferIPv4 orders IPv4 addresses before IPv6 addresses.
+	FamilyPreferIPv4 FamilyPolicy = "prefer-ipv4"
+	// FamilyPreferIPv6 orders IPv6 addresses before IPv4 addresses.
+	FamilyPreferIPv6 FamilyPolicy = "prefer-ipv6"
+	// FamilyIPv4Only only returns IPv4 addresses.
+	FamilyIPv4Only FamilyPolicy = "ipv4-only"
+	// FamilyIPv6Only only returns IPv6 addresses.
+	FamilyIPv6Only FamilyPolicy = "ipv6-only"
+)
+
+// FamilyResolverConfig is the configuration for an address family resolver.
+type FamilyResolverConfig struct {
+	// Policy is how addresses are ordered or filtered by address family.
+	Policy FamilyPolicy
+}
+
+// familyResolver is a resolver that orders or filters addresses by family.
+type familyResolver struct {
+	resolver Resolver
+	policy   FamilyPolicy
+}
+
+// Family returns a resolver that orders or filters the addresses returned by
+// resolver according to their address family, so that deployments can steer
+// families without changing the network passed at every call site. With an
+// "only" policy, lookups for the other family fail with ErrNoData without
+// being sent upstream. Ordering is stable, so the order within each family is
+// preserved.
+func Family(resolver Resolver, conf *FamilyResolverConfig) *familyResolver {
+	var policy FamilyPolicy
+	if conf != nil {
+		policy = conf.Policy
+	}
+
+	return &familyResolver{
+		resolver: resolver,
+		policy:   policy,
+	}
+}
+
+func (r *familyResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	switch r.policy {
+	case FamilyIPv4Only:
+		if network == "ip6" {
+			return nil, newError(host, "", ErrNoData)
+		}
+		if network == "ip" {
+			network = "ip4"
+		}
+	case FamilyIPv6Only:
+		if network == "ip4" {
+			return nil, newError(host, "", ErrNoData)
+		}
+		if network == "ip" {
+			network = "ip6"
+		}
+	}
+
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.policy == FamilyPreferIPv4 || r.policy == FamilyPreferIPv6 {
+		slices.SortStableFunc(addrs, func(a, b netip.Addr) int {
+			return familyRank(r.policy, a) - familyRank(r.policy, b)
+		})
+		traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
+	}
+
+	return addrs, nil
+}
+
+// familyRank returns the sort rank of addr's family under policy (lower
+// ranks are preferred).
+func familyRank(policy FamilyPolicy, addr netip.Addr) int {
+	if addr.Unmap().Is4() == (policy == FamilyPreferIPv4) {
+		return 0
+	}
+	return 1
+}
+
+// Close closes the underlying resolver.
+func (r *familyResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *familyResolver) describe() Description {
+	return Description{
+		Type:       "family",
+		Attributes: map[string]string{"policy": string(r.policy)},
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFamily(t *testing.T) {
+	v6 := netip.MustParseAddr("2001:db8::1")
+	v4 := netip.MustParseAddr("192.0.2.1")
+
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "host.example").
+		Return([]netip.Addr{v6, v4}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip4", "host.example").
+		Return([]netip.Addr{v4}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip6", "host.example").
+		Return([]netip.Addr{v6}, nil)
+
+	ctx := context.Background()
+
+	t.Run("Prefer IPv4", func(t *testing.T) {
+		res := resolver.Family(inner, &resolver.FamilyResolverConfig{Policy: resolver.FamilyPreferIPv4})
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "host.example")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{v4, v6}, addrs)
+	})
+
+	t.Run("Prefer IPv6", func(t *testing.T) {
+		res := resolver.Family(inner, &resolver.FamilyResolverConfig{Policy: resolver.FamilyPreferIPv6})
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "host.example")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{v6, v4}, addrs)
+	})
+
+	t.Run("IPv4 Only", func(t *testing.T) {
+		res := resolver.Family(inner, &resolver.FamilyResolverConfig{Policy: resolver.FamilyIPv4Only})
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "host.example")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{v4}, addrs)
+
+		_, err = res.LookupNetIP(ctx, "ip6", "host.example")
+		require.True(t, resolver.IsNoData(err))
+	})
+
+	t.Run("IPv6 Only", func(t *testing.T) {
+		res := resolver.Family(inner, &resolver.FamilyResolverConfig{Policy: resolver.FamilyIPv6Only})
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "host.example")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{v6}, addrs)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "host.example")
+		require.True(t, resolver.IsNoData(err))
+	})
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var _ Resolver = (*faultInjectionResolver)(nil)
+
+// FaultInjectionResolverConfig is the configuration for a fault injection
+// resolver. Rates are the fraction (between 0 and 1) of lookups affected.
+type FaultInjectionResolverConfig struct {
+	// Latency is added to every lookup. Defaults to 0.
+	Latency *time.Duration
+	// Jitter is the maximum amount of random latency added to every lookup,
+	// on top of Latency. Defaults to 0.
+	Jitter *time.Duration
+	// TimeoutRate is the rate of lookups that time out, as if the query had
+	// been dropped. Such lookups block until the context is done or
+	// DropTimeout has elapsed. Defaults to 0.
+	TimeoutRate *float64
+	// DropTimeout is how long lookups that time out block for. Defaults to 5
+	// seconds.
+	DropTimeout *time.Duration
+	// ServFailRate is the rate of lookups that fail with ErrServFail.
+	// Defaults to 0.
+	ServFailRate *float64
+	// WrongAnswerRate is the rate of successful lookups whose answer is
+	// replaced with WrongAnswers. Defaults to 0.
+	WrongAnswerRate *float64
+	// WrongAnswers are the addresses returned in place of the real answer,
+	// the real answer is kept if none are of the requested address family.
+	// Defaults to 192.0.2.1 and 2001:db8::1 (documentation addresses).
+	WrongAnswers []netip.Addr
+	// Rand is an optional source of randomness, for deterministic tests (see
+	// Rand).
+	Rand Rand
+}
+
+// faultInjectionResolver is a resolver that injects faults into lookups.
+type faultInjectionResolver struct {
+	resolver        Resolver
+	latency         time.Duration
+	jitter          time.Duration
+	timeoutRate     float64
+	dropTimeout     time.Duration
+	servFailRate    float64
+	wrongAnswerRate float64
+	wrongAnswers    []netip.Addr
+	rand            Rand
+}
+
+// FaultInjection returns a resolver that injects latency, timeouts, SERVFAILs
+// and wrong answers into lookups made using resolver, so that applications
+// can test their resilience to DNS misbehavior. It should never be used in
+// production.
+func FaultInjection(resolver Resolver, conf *FaultInjectionResolverConfig) *faultInjectionResolver {
+	// Fields with unexported fields are lost when applying defaults.
+	wrongAnswers := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}
+	var rnd Rand = systemRand{}
+	if conf != nil {
+		if len(conf.WrongAnswers) > 0 {
+			wrongAnswers = conf.WrongAnswers
+		}
+		if conf.Rand != nil {
+			rnd = conf.Rand
+		}
+	}
+
+	conf, err := defaults.WithDefaults(conf, &FaultInjectionResolverConfig{
+		Latency:         ptr.To(time.Duration(0)),
+		Jitter:          ptr.To(time.Duration(0)),
+		TimeoutRate:     ptr.To(0.0),
+		DropTimeout:     ptr.To(5 * time.Second),
+		ServFailRate:    ptr.To(0.0),
+		WrongAnswerRate: ptr.To(0.0),
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	return &faultInjectionResolver{
+		resolver:        resolver,
+		latency:         *conf.Latency,
+		jitter:          *conf.Jitter,
+		timeoutRate:     *conf.TimeoutRate,
+		dropTimeout:     *conf.DropTimeout,
+		servFailRate:    *conf.ServFailRate,
+		wrongAnswerRate: *conf.WrongAnswerRate,
+		wrongAnswers:    wrongAnswers,
+		rand:            rnd,
+	}
+}
+
+func (r *faultInjectionResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	latency := r.latency
+	if r.jitter > 0 {
+		latency += time.Duration(r.rand.IntN(int(r.jitter)))
+	}
+	if err := sleep(ctx, latency); err != nil {
+		return nil, newError(host, "", err)
+	}
+
+	if r.chance(r.timeoutRate) {
+		if err := sleep(ctx, r.dropTimeout); err != nil {
+			return nil, newError(host, "", err)
+		}
+		return nil, newError(host, "", temporary(ErrTimeout))
+	}
+
+	if r.chance(r.servFailRate) {
+		return nil, newError(host, "", ErrServFail)
+	}
+
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	if err != nil || !r.chance(r.wrongAnswerRate) {
+		return addrs, err
+	}
+
+	var wrongAnswers []netip.Addr
+	for _, addr := range r.wrongAnswers {
+		if (network == "ip4" && !addr.Is4()) || (network == "ip6" && !addr.Is6()) {
+			continue
+		}
+		wrongAnswers = append(wrongAnswers, addr)
+	}
+
+	// None of the wrong answers are of the requested family.
+	if len(wrongAnswers) == 0 {
+		return addrs, nil
+	}
+
+	return wrongAnswers, nil
+}
+
+// Close closes the underlying resolver.
+func (r *faultInjectionResolver) Close() error {
+	return Close(r.resolver)
+}
+
+// chance returns true with the given probability.
+func (r *faultInjectionResolver) chance(rate float64) bool {
+	const precision = 1 << 30
+	return rate > 0 && float64(r.rand.IntN(precision)) < rate*precision
+}
+
+func (r *faultInjectionResolver) describe() Description {
+	return Description{
+		Type: "fault-injection",
+		Attributes: map[string]string{
+			"latency":           r.latency.String(),
+			"jitter":            r.jitter.String(),
+			"timeout_rate":      strconv.FormatFloat(r.timeoutRate, 'g', -1, 64),
+			"servfail_rate":     strconv.FormatFloat(r.servFailRate, 'g', -1, 64),
+			"wrong_answer_rate": strconv.FormatFloat(r.wrongAnswerRate, 'g', -1, 64),
+		},
+		Children: []Description{Describe(r.resolver)},
+	}
+}
+
+// sleep waits for d to elapse, or for ctx to be done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjectionResolver(t *testing.T) {
+	upstream := new(dnstest.MockResolver)
+	upstream.On("LookupNetIP", mock.Anything, mock.Anything, "www.example.com").
+		Return([]netip.Addr{netip.MustParseAddr("203.0.113.1")}, nil)
+
+	t.Run("Passthrough", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, nil)
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("203.0.113.1")}, addrs)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, &resolver.FaultInjectionResolverConfig{
+			Latency: ptr.To(50 * time.Millisecond),
+		})
+
+		start := time.Now()
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, &resolver.FaultInjectionResolverConfig{
+			TimeoutRate: ptr.To(1.0),
+			DropTimeout: ptr.To(10 * time.Millisecond),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.ErrorIs(t, err, resolver.ErrTimeout)
+
+		var dnsErr *net.DNSError
+		require.True(t, errors.As(err, &dnsErr))
+		require.True(t, dnsErr.Timeout())
+		require.True(t, dnsErr.Temporary())
+	})
+
+	t.Run("ServFail", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, &resolver.FaultInjectionResolverConfig{
+			ServFailRate: ptr.To(1.0),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.ErrorIs(t, err, resolver.ErrServFail)
+	})
+
+	t.Run("Wrong Answer", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, &resolver.FaultInjectionResolverConfig{
+			WrongAnswerRate: ptr.To(1.0),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Wrong Answer Other Family", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, &resolver.FaultInjectionResolverConfig{
+			WrongAnswerRate: ptr.To(1.0),
+			WrongAnswers:    []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip6", "www.example.com")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("203.0.113.1")}, addrs)
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		res := resolver.FaultInjection(upstream, &resolver.FaultInjectionResolverConfig{
+			ServFailRate: ptr.To(0.25),
+			Rand:         rand.New(rand.NewPCG(1, 2)),
+		})
+
+		var failures int
+		for i := 0; i < 1000; i++ {
+			if _, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com"); err != nil {
+				failures++
+			}
+		}
+		require.InDelta(t, 250, failures, 50)
+	})
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+
+	"github.com/noisysockets/util/address"
+)
+
+var _ Resolver = (*getAddrInfoResolver)(nil)
+
+// maxConcurrentGetAddrInfo is the maximum number of concurrent getaddrinfo
+// calls (the same limit the net package applies to cgo lookups). Each call
+// ties up an OS thread until it returns, even if the lookup was abandoned.
+const maxConcurrentGetAddrInfo = 500
+
+// getAddrInfoSem bounds the number of concurrent getaddrinfo calls, across
+// all resolvers.
+var getAddrInfoSem = make(chan struct{}, maxConcurrentGetAddrInfo)
+
+// getAddrInfoResolver is a resolver that uses the operating system's resolver.
+type getAddrInfoResolver struct{}
+
+// GetAddrInfo returns a Resolver that uses the C library's getaddrinfo(3), so
+// that lookups go through the system's name service switch (eg. LDAP or NIS
+// modules) exactly as they would for other programs. It is intended as a
+// final fallback, eg:
+//
+//	Sequential(system, GetAddrInfo())
+//
+// On Windows, GetAddrInfoExW is called directly (without cgo), so that
+// lookups respect the Name Resolution Policy Table (NRPT), per-adapter
+// policies, and the system's DNS cache. Elsewhere the backend requires cgo on
+// a unix platform, otherwise an error wrapping errors.ErrUnsupported is
+// returned.
+func GetAddrInfo() (Resolver, error) {
+	if !getAddrInfoSupported {
+		return nil, fmt.Errorf("getaddrinfo backend is not available in this build: %w", errors.ErrUnsupported)
+	}
+
+	return &getAddrInfoResolver{}, nil
+}
+
+func (r *getAddrInfoResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if network != "ip" && network != "ip4" && network != "ip6" {
+		return nil, newError(host, "", ErrUnsupportedNetwork)
+	}
+
+	type result struct {
+		addrs []netip.Addr
+		err   error
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, newError(host, "", ctx.Err())
+	case getAddrInfoSem <- struct{}{}:
+	}
+
+	// The C library call can't be interrupted, so it's abandoned (rather than
+	// canceled) if the context is done first. It keeps its slot until it
+	// returns, so abandoned calls can't pile up.
+	resultCh := make(chan result, 1)
+	go func() {
+		defer func() { <-getAddrInfoSem }()
+
+		addrs, err := getAddrInfo(network, host)
+		resultCh <- result{addrs: addrs, err: err}
+	}()
+
+	var res result
+	select {
+	case <-ctx.Done():
+		return nil, newError(host, "", ctx.Err())
+	case res = <-resultCh:
+	}
+	if res.err != nil {
+		return nil, newError(host, "", res.err)
+	}
+
+	addrs := address.FilterByNetwork(dedupAddrs(res.addrs), network)
+	if len(addrs) == 0 {
+		return nil, newError(host, "", ErrNoData)
+	}
+
+	recordProvenance(ctx, AddrInfo{Source: AddrSourceOS}, addrs...)
+
+	return addrs, nil
+}
+
+func (r *getAddrInfoResolver) describe() Description {
+	return Description{Type: "getaddrinfo"}
+}
+
+// zoneFromIndex returns the zone for an IPv6 scope id, preferring the
+// interface name.
+func zoneFromIndex(index int) string {
+	if index == 0 {
+		return ""
+	}
+	if ifi, err := net.InterfaceByIndex(index); err == nil {
+		return ifi.Name
+	}
+	return strconv.Itoa(index)
+}
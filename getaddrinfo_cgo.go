@@ -0,0 +1,79 @@
+//go:build cgo && unix
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+/*
+#include <sys/types.h>
+#include <sys/socket.h>
+#include <netdb.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"net/netip"
+	"syscall"
+	"unsafe"
+)
+
+const getAddrInfoSupported = true
+
+// getAddrInfo looks up host using getaddrinfo(3).
+func getAddrInfo(network, host string) ([]netip.Addr, error) {
+	var hints C.struct_addrinfo
+	hints.ai_socktype = C.SOCK_STREAM
+	switch network {
+	case "ip4":
+		hints.ai_family = C.AF_INET
+	case "ip6":
+		hints.ai_family = C.AF_INET6
+	default:
+		hints.ai_family = C.AF_UNSPEC
+	}
+
+	chost := C.CString(host)
+	defer C.free(unsafe.Pointer(chost))
+
+	var res *C.struct_addrinfo
+	rv, errno := C.getaddrinfo(chost, nil, &hints, &res)
+	if rv != 0 {
+		switch rv {
+		case C.EAI_NONAME:
+			return nil, ErrNoSuchHost
+		case C.EAI_AGAIN:
+			return nil, ErrServFail
+		case C.EAI_SYSTEM:
+			if errno == nil {
+				errno = syscall.EMFILE
+			}
+			return nil, errno
+		default:
+			return nil, errors.New(C.GoString(C.gai_strerror(rv)))
+		}
+	}
+	defer C.freeaddrinfo(res)
+
+	var addrs []netip.Addr
+	for r := res; r != nil; r = r.ai_next {
+		switch r.ai_family {
+		case C.AF_INET:
+			sa := (*syscall.RawSockaddrInet4)(unsafe.Pointer(r.ai_addr))
+			addrs = append(addrs, netip.AddrFrom4(sa.Addr))
+		case C.AF_INET6:
+			sa := (*syscall.RawSockaddrInet6)(unsafe.Pointer(r.ai_addr))
+			addrs = append(addrs, netip.AddrFrom16(sa.Addr).WithZone(zoneFromIndex(int(sa.Scope_id))))
+		}
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows && (!cgo || !unix)
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"errors"
+	"net/netip"
+)
+
+const getAddrInfoSupported = false
+
+func getAddrInfo(network, host string) ([]netip.Addr, error) {
+	return nil, errors.ErrUnsupported
+}
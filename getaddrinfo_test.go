@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAddrInfo(t *testing.T) {
+	res, err := resolver.GetAddrInfo()
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("getaddrinfo is not available in this build")
+	}
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("Localhost", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(ctx, "ip4", "localhost")
+		require.NoError(t, err)
+
+		require.Contains(t, addrs, netip.MustParseAddr("127.0.0.1"))
+	})
+
+	t.Run("Literal", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(ctx, "ip", "192.0.2.1")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Unsupported Network", func(t *testing.T) {
+		_, err := res.LookupNetIP(ctx, "tcp", "localhost")
+		require.ErrorIs(t, err, resolver.ErrUnsupportedNetwork)
+	})
+}
@@ -0,0 +1,114 @@
+//go:build windows
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"net/netip"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const getAddrInfoSupported = true
+
+var (
+	modws2_32           = windows.NewLazySystemDLL("ws2_32.dll")
+	procGetAddrInfoExW  = modws2_32.NewProc("GetAddrInfoExW")
+	procFreeAddrInfoExW = modws2_32.NewProc("FreeAddrInfoExW")
+	startupWinsockOnce  sync.Once
+	errStartupWinsock   error
+)
+
+// nsAll queries all available namespaces (eg. the hosts file, DNS, and NetBIOS).
+const nsAll = 0
+
+// addrinfoExW is the ADDRINFOEXW structure.
+type addrinfoExW struct {
+	Flags     int32
+	Family    int32
+	Socktype  int32
+	Protocol  int32
+	Addrlen   uintptr
+	Canonname *uint16
+	Addr      *windows.RawSockaddrAny
+	Blob      unsafe.Pointer
+	Bufferlen uintptr
+	Provider  *windows.GUID
+	Next      *addrinfoExW
+}
+
+// getAddrInfo looks up host using GetAddrInfoExW, so that lookups respect the
+// Name Resolution Policy Table (NRPT), per-adapter DNS settings, and the
+// system's DNS cache.
+func getAddrInfo(network, host string) ([]netip.Addr, error) {
+	startupWinsockOnce.Do(func() {
+		var data windows.WSAData
+		errStartupWinsock = windows.WSAStartup(uint32(0x202), &data)
+	})
+	if errStartupWinsock != nil {
+		return nil, errStartupWinsock
+	}
+
+	if err := procGetAddrInfoExW.Find(); err != nil {
+		return nil, err
+	}
+
+	name, err := windows.UTF16PtrFromString(host)
+	if err != nil {
+		return nil, ErrNoSuchHost
+	}
+
+	hints := addrinfoExW{Socktype: windows.SOCK_STREAM}
+	switch network {
+	case "ip4":
+		hints.Family = windows.AF_INET
+	case "ip6":
+		hints.Family = windows.AF_INET6
+	default:
+		hints.Family = windows.AF_UNSPEC
+	}
+
+	var res *addrinfoExW
+	rv, _, _ := syscall.SyscallN(procGetAddrInfoExW.Addr(),
+		uintptr(unsafe.Pointer(name)), 0, nsAll, 0,
+		uintptr(unsafe.Pointer(&hints)), uintptr(unsafe.Pointer(&res)),
+		0, 0, 0, 0)
+	if rv != 0 {
+		switch errno := syscall.Errno(rv); errno {
+		case windows.WSAHOST_NOT_FOUND:
+			return nil, ErrNoSuchHost
+		case windows.WSANO_DATA:
+			return nil, ErrNoData
+		case windows.WSATRY_AGAIN:
+			return nil, ErrServFail
+		default:
+			return nil, errno
+		}
+	}
+	defer syscall.SyscallN(procFreeAddrInfoExW.Addr(), uintptr(unsafe.Pointer(res)))
+
+	var addrs []netip.Addr
+	for r := res; r != nil; r = r.Next {
+		switch r.Family {
+		case windows.AF_INET:
+			sa := (*windows.RawSockaddrInet4)(unsafe.Pointer(r.Addr))
+			addrs = append(addrs, netip.AddrFrom4(sa.Addr))
+		case windows.AF_INET6:
+			sa := (*windows.RawSockaddrInet6)(unsafe.Pointer(r.Addr))
+			addrs = append(addrs, netip.AddrFrom16(sa.Addr).WithZone(zoneFromIndex(int(sa.Scope_id))))
+		}
+	}
+
+	return addrs, nil
+}
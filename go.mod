@@ -3,22 +3,24 @@ module github.com/noisysockets/resolver
 go 1.22.4
 
 require (
-	dario.cat/mergo v1.0.0
 	github.com/avast/retry-go/v4 v4.6.0
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/miekg/dns v1.1.61
 	github.com/noisysockets/util v0.1.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/net v0.26.0
 	golang.org/x/sync v0.7.0
 	golang.org/x/sys v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	dario.cat/mergo v1.0.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/jinzhu/copier v0.4.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
 	golang.org/x/mod v0.18.0 // indirect
-	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
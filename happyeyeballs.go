@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var _ ContextDialer = (*Dialer)(nil)
+
+// DialerConfig is the configuration for a Happy Eyeballs dialer.
+type DialerConfig struct {
+	// DialContext is used to connect to individual addresses. Defaults to a
+	// net.Dialer.
+	DialContext DialContextFunc
+	// ResolutionDelay is how long to wait for IPv6 addresses, once IPv4
+	// addresses have been resolved, before connecting. Defaults to 50ms.
+	ResolutionDelay *time.Duration
+	// ConnectionAttemptDelay is how long to wait for a connection attempt to
+	// succeed before starting the next one (in parallel). Defaults to 250ms.
+	ConnectionAttemptDelay *time.Duration
+	// FirstAddressFamilyCount is the number of IPv6 addresses to try before
+	// alternating between IPv4 and IPv6 addresses. Defaults to 1.
+	FirstAddressFamilyCount *int
+}
+
+// Dialer establishes connections to hosts using Happy Eyeballs Version 2
+// (RFC 8305). IPv4 and IPv6 addresses are resolved in parallel, and
+// connection attempts are staggered across interleaved address families, so
+// that a broken path (eg. blackholed IPv6) delays connecting by no more than
+// a fraction of a second. Losing connection attempts are canceled.
+type Dialer struct {
+	resolver                Resolver
+	dialContext             DialContextFunc
+	resolutionDelay         time.Duration
+	connectionAttemptDelay  time.Duration
+	firstAddressFamilyCount int
+}
+
+// NewDialer creates a new Happy Eyeballs dialer, that resolves hosts using
+// resolver.
+func NewDialer(resolver Resolver, conf *DialerConfig) (*Dialer, error) {
+	conf, err := defaults.WithDefaults(conf, &DialerConfig{
+		DialContext:             (&net.Dialer{}).DialContext,
+		ResolutionDelay:         ptr.To(50 * time.Millisecond),
+		ConnectionAttemptDelay:  ptr.To(250 * time.Millisecond),
+		FirstAddressFamilyCount: ptr.To(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to dialer config: %w", err)
+	}
+
+	return &Dialer{
+		resolver:                resolver,
+		dialContext:             conf.DialContext,
+		resolutionDelay:         *conf.ResolutionDelay,
+		connectionAttemptDelay:  *conf.ConnectionAttemptDelay,
+		firstAddressFamilyCount: max(*conf.FirstAddressFamilyCount, 1),
+	}, nil
+}
+
+// Dial connects to the address on the named network, see DialContext.
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network, which must be
+// one of "tcp", "tcp4", "tcp6", "udp", "udp4" or "udp6". The address is a
+// host and port, the host may be a name or an IP literal.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var families []string
+	switch network {
+	case "tcp", "udp":
+		families = []string{"ip6", "ip4"}
+	case "tcp4", "udp4":
+		families = []string{"ip4"}
+	case "tcp6", "udp6":
+		families = []string{"ip6"}
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	host, service, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	port, err := net.LookupPort(network, service)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type lookupResult struct {
+		family string
+		addrs  []netip.Addr
+		err    error
+	}
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+
+	lookups := make(chan lookupResult, len(families))
+	dials := make(chan dialResult)
+
+	var v6, v4 []netip.Addr
+	var lookupErr, dialErr error
+	pendingLookups := 0
+	// Connection attempts start once IPv6 addresses are resolved, or the
+	// resolution delay elapses after IPv4 addresses are resolved.
+	ready := false
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr.Unmap().Is4() {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+		ready = true
+	} else {
+		for _, family := range families {
+			pendingLookups++
+			go func() {
+				addrs, err := d.resolver.LookupNetIP(ctx, family, host)
+				lookups <- lookupResult{family: family, addrs: addrs, err: err}
+			}()
+		}
+	}
+
+	var v6Picked int
+	var lastV4 bool
+	next := func() netip.Addr {
+		var addr netip.Addr
+		if len(v6) > 0 && (len(v4) == 0 || v6Picked < d.firstAddressFamilyCount || lastV4) {
+			addr, v6 = v6[0], v6[1:]
+			v6Picked++
+			lastV4 = false
+		} else {
+			addr, v4 = v4[0], v4[1:]
+			lastV4 = true
+		}
+		return addr
+	}
+
+	var resolutionDelay, attemptDelay <-chan time.Time
+	inflight := 0
+	for {
+		if ready && attemptDelay == nil && len(v6)+len(v4) > 0 {
+			addrPort := netip.AddrPortFrom(next(), uint16(port))
+
+			inflight++
+			go func() {
+				conn, err := d.dialContext(ctx, network, addrPort.String())
+				select {
+				case dials <- dialResult{conn: conn, err: err}:
+				case <-ctx.Done():
+					if conn != nil {
+						_ = conn.Close()
+					}
+				}
+			}()
+
+			attemptDelay = time.After(d.connectionAttemptDelay)
+		}
+
+		if inflight == 0 && pendingLookups == 0 && len(v6)+len(v4) == 0 {
+			if dialErr != nil {
+				return nil, dialErr
+			}
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("no addresses for %s", host)}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+		case res := <-lookups:
+			pendingLookups--
+			if res.err != nil {
+				if lookupErr == nil {
+					lookupErr = res.err
+				}
+			} else {
+				for _, addr := range res.addrs {
+					if addr.Unmap().Is4() {
+						v4 = append(v4, addr)
+					} else {
+						v6 = append(v6, addr)
+					}
+				}
+			}
+
+			if res.family == "ip6" || pendingLookups == 0 {
+				ready = true
+			} else if !ready && resolutionDelay == nil {
+				resolutionDelay = time.After(d.resolutionDelay)
+			}
+		case <-resolutionDelay:
+			ready = true
+			resolutionDelay = nil
+		case <-attemptDelay:
+			attemptDelay = nil
+		case res := <-dials:
+			inflight--
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if dialErr == nil {
+				dialErr = res.err
+			}
+			// Start the next attempt without waiting for the delay to elapse.
+			attemptDelay = nil
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialer(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = lis.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	port := lis.Addr().(*net.TCPAddr).Port
+
+	// IPv6 is blackholed, connection attempts never complete.
+	blackholeV6 := func(ctx context.Context, network, address string) (net.Conn, error) {
+		addrPort := netip.MustParseAddrPort(address)
+		if addrPort.Addr().Is6() {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	t.Run("Blackholed IPv6", func(t *testing.T) {
+		res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+			if network == "ip6" {
+				return []netip.Addr{netip.MustParseAddr("2001:db8::1")}, nil
+			}
+			return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+		})
+
+		d, err := resolver.NewDialer(res, &resolver.DialerConfig{
+			DialContext:            blackholeV6,
+			ConnectionAttemptDelay: ptr.To(50 * time.Millisecond),
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("host.example", strconv.Itoa(port)))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+
+		require.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Resolution Delay", func(t *testing.T) {
+		// The IPv6 lookup never completes, so connecting must not wait for it.
+		res := lookupFunc(func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+			if network == "ip6" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+		})
+
+		d, err := resolver.NewDialer(res, &resolver.DialerConfig{
+			ResolutionDelay: ptr.To(10 * time.Millisecond),
+		})
+		require.NoError(t, err)
+
+		conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("host.example", strconv.Itoa(port)))
+		require.NoError(t, err)
+		_ = conn.Close()
+	})
+
+	t.Run("Literal", func(t *testing.T) {
+		d, err := resolver.NewDialer(resolver.Literal(), nil)
+		require.NoError(t, err)
+
+		conn, err := d.Dial("tcp4", lis.Addr().String())
+		require.NoError(t, err)
+		_ = conn.Close()
+	})
+
+	t.Run("Lookup Failure", func(t *testing.T) {
+		d, err := resolver.NewDialer(resolver.Literal(), nil)
+		require.NoError(t, err)
+
+		_, err = d.Dial("tcp", "missing.example:80")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("Unsupported Network", func(t *testing.T) {
+		d, err := resolver.NewDialer(resolver.Literal(), nil)
+		require.NoError(t, err)
+
+		_, err = d.Dial("unix", "/tmp/socket")
+		require.Error(t, err)
+	})
+}
+
+// lookupFunc adapts a function to the Resolver interface.
+type lookupFunc func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+func (f lookupFunc) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return f(ctx, network, host)
+}
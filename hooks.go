@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"net/netip"
+	"time"
+)
+
+// QueryInfo is metadata about a query sent to a DNS server, passed to the
+// query lifecycle hooks of a DNS resolver.
+type QueryInfo struct {
+	// Server is the DNS server the query is sent to.
+	Server netip.AddrPort
+	// Transport is the transport protocol used for the query (this may differ
+	// from the configured transport, eg. when retrying a truncated reply
+	// over TCP).
+	Transport DNSTransport
+	// Duration is how long the query took, it is zero for OnQuery hooks.
+	Duration time.Duration
+}
@@ -16,11 +16,13 @@ import (
 	"net"
 	"net/netip"
 	"os"
+	"slices"
+	"strconv"
 	"sync"
 
 	"github.com/miekg/dns"
-	"github.com/noisysockets/resolver/internal/addrselect"
-	"github.com/noisysockets/resolver/internal/hostsfile"
+	"github.com/noisysockets/resolver/addrselect"
+	"github.com/noisysockets/resolver/hostsfile"
 	"github.com/noisysockets/util/address"
 	"github.com/noisysockets/util/defaults"
 	"github.com/noisysockets/util/ptr"
@@ -37,80 +39,130 @@ type HostsResolverConfig struct {
 	// NoHostsFile disables the use of the hosts file.
 	// This is useful when operating with only ephemeral hosts.
 	NoHostsFile *bool
+	// HostsFilePath is the optional path to the hosts file.
+	// By default, the OS's default hosts file is used.
+	HostsFilePath string
+	// Watch reloads the hosts file whenever it changes, so that edits take
+	// effect without a restart. It is ignored if HostsFileReader is provided.
+	// The resolver must be closed to stop watching the hosts file.
+	Watch *bool
 }
 
 type HostsResolver struct {
 	mu          sync.RWMutex
 	nameToAddr  map[string][]netip.Addr
+	ephemeral   map[string][]netip.Addr
 	dialContext DialContextFunc
+	stopWatch   context.CancelFunc
 }
 
 func Hosts(conf *HostsResolverConfig) (*HostsResolver, error) {
 	conf, err := defaults.WithDefaults(conf, &HostsResolverConfig{
-		DialContext: (&net.Dialer{}).DialContext,
-		NoHostsFile: ptr.To(false),
+		DialContext:   (&net.Dialer{}).DialContext,
+		NoHostsFile:   ptr.To(false),
+		HostsFilePath: hostsfile.Location,
+		Watch:         ptr.To(false),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to apply defaults to hosts resolver config: %w", err)
 	}
 
-	addrsByName := make(map[string][]netip.Addr)
-	if !*conf.NoHostsFile {
-		// Don't incur the cost of opening the hosts file if a reader is already provided.
-		if conf.HostsFileReader == nil {
-			f, err := os.Open(hostsfile.Location)
-			if err != nil {
-				return nil, fmt.Errorf("failed to open hosts file: %w", err)
-			}
-			defer f.Close()
+	r := &HostsResolver{
+		nameToAddr:  make(map[string][]netip.Addr),
+		ephemeral:   make(map[string][]netip.Addr),
+		dialContext: conf.DialContext,
+	}
+
+	if *conf.NoHostsFile {
+		return r, nil
+	}
+
+	if conf.HostsFileReader == nil && *conf.Watch {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		snapshots, err := hostsfile.Watch(ctx, conf.HostsFilePath)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to watch hosts file: %w", err)
+		}
 
-			conf.HostsFileReader = f
+		if err := r.load(<-snapshots); err != nil {
+			cancel()
+			return nil, err
 		}
 
-		h, err := hostsfile.Decode(conf.HostsFileReader)
+		go func() {
+			for h := range snapshots {
+				// Keep the previous contents if the new ones are invalid.
+				_ = r.load(h)
+			}
+		}()
+
+		r.stopWatch = cancel
+		return r, nil
+	}
+
+	// Don't incur the cost of opening the hosts file if a reader is already provided.
+	if conf.HostsFileReader == nil {
+		f, err := os.Open(conf.HostsFilePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse hosts file: %w", err)
+			return nil, fmt.Errorf("failed to open hosts file: %w", err)
 		}
+		defer f.Close()
 
-		for _, record := range h.Records() {
-			for _, name := range record.Hostnames {
-				name = dns.Fqdn(name)
+		conf.HostsFileReader = f
+	}
 
-				addr, err := netip.ParseAddr(record.IpAddress.String())
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse IP address: %w", err)
-				}
+	h, err := hostsfile.Decode(conf.HostsFileReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hosts file: %w", err)
+	}
+
+	if err := r.load(h); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
 
-				addrsByName[name] = append(addrsByName[name], addr)
+// load replaces the addresses from the hosts file with the contents of h,
+// ephemeral hosts are retained.
+func (r *HostsResolver) load(h hostsfile.Hostsfile) error {
+	addrsByName := make(map[string][]netip.Addr)
+	for _, record := range h.Records() {
+		for _, name := range record.Hostnames {
+			name = dns.Fqdn(name)
+
+			addr, err := netip.ParseAddr(record.IpAddress.String())
+			if err != nil {
+				return fmt.Errorf("failed to parse IP address: %w", err)
 			}
+
+			addrsByName[name] = append(addrsByName[name], addr)
 		}
 	}
 
-	return &HostsResolver{
-		nameToAddr:  addrsByName,
-		dialContext: conf.DialContext,
-	}, nil
-}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-func (r *HostsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
-	dnsErr := &net.DNSError{
-		Name: host,
+	for name, addrs := range r.ephemeral {
+		addrsByName[name] = addrs
 	}
+	r.nameToAddr = addrsByName
 
+	return nil
+}
+
+func (r *HostsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
 	r.mu.RLock()
 	addrs, ok := r.nameToAddr[dns.Fqdn(host)]
 	r.mu.RUnlock()
 	if !ok {
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err:        ErrNoSuchHost.Error(),
-			IsNotFound: true,
-		})
+		return nil, newError(host, "", ErrNoSuchHost)
 	}
 
 	if network != "ip" && network != "ip4" && network != "ip6" {
-		return nil, extendDNSError(dnsErr, net.DNSError{
-			Err: ErrUnsupportedNetwork.Error(),
-		})
+		return nil, newError(host, "", ErrUnsupportedNetwork)
 	}
 
 	addrs = address.FilterByNetwork(addrs, network)
@@ -121,8 +173,11 @@ func (r *HostsResolver) LookupNetIP(ctx context.Context, network, host string) (
 		}
 
 		addrselect.SortByRFC6724(dial, addrs)
+		traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
 	}
 
+	recordProvenance(ctx, AddrInfo{Source: AddrSourceHosts}, addrs...)
+
 	return addrs, nil
 }
 
@@ -130,6 +185,7 @@ func (r *HostsResolver) LookupNetIP(ctx context.Context, network, host string) (
 func (r *HostsResolver) AddHost(host string, addrs ...netip.Addr) {
 	r.mu.Lock()
 	r.nameToAddr[dns.Fqdn(host)] = addrs
+	r.ephemeral[dns.Fqdn(host)] = addrs
 	r.mu.Unlock()
 }
 
@@ -137,5 +193,25 @@ func (r *HostsResolver) AddHost(host string, addrs ...netip.Addr) {
 func (r *HostsResolver) RemoveHost(host string) {
 	r.mu.Lock()
 	delete(r.nameToAddr, dns.Fqdn(host))
+	delete(r.ephemeral, dns.Fqdn(host))
 	r.mu.Unlock()
 }
+
+// Close stops watching the hosts file, if it is being watched.
+func (r *HostsResolver) Close() error {
+	if r.stopWatch != nil {
+		r.stopWatch()
+	}
+	return nil
+}
+
+func (r *HostsResolver) describe() Description {
+	r.mu.RLock()
+	names := len(r.nameToAddr)
+	r.mu.RUnlock()
+
+	return Description{
+		Type:       "hosts",
+		Attributes: map[string]string{"names": strconv.Itoa(names)},
+	}
+}
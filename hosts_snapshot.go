@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/noisysockets/resolver/hostsfile"
+)
+
+// HostsSnapshotConfig is the configuration for a hosts file snapshot.
+type HostsSnapshotConfig struct {
+	// Network is the type of addresses to include, one of "ip", "ip4" or
+	// "ip6". Defaults to "ip".
+	Network string
+	// Concurrency is the maximum number of lookups in flight at once.
+	// Defaults to 16.
+	Concurrency *int
+	// IgnoreErrors omits hosts that fail to resolve from the snapshot,
+	// rather than failing the snapshot.
+	IgnoreErrors bool
+}
+
+// HostsSnapshot resolves hosts and returns a hosts file mapping each of them
+// to its current addresses, eg. for pre-seeding containers or air-gapped
+// machines with pinned addresses. Records are ordered by the position of
+// each host in hosts, hosts sharing an address share a record. The snapshot
+// can be written out using hostsfile.Encode (or Hostsfile.Save).
+func HostsSnapshot(ctx context.Context, resolver Resolver, hosts []string, conf *HostsSnapshotConfig) (hostsfile.Hostsfile, error) {
+	if conf == nil {
+		conf = &HostsSnapshotConfig{}
+	}
+
+	results := LookupHosts(ctx, resolver, hosts, &LookupHostsConfig{
+		Network:     conf.Network,
+		Concurrency: conf.Concurrency,
+	})
+
+	var h hostsfile.Hostsfile
+	var errs []error
+	for _, host := range hosts {
+		result, ok := results[host]
+		if !ok {
+			continue
+		}
+		// Only the first occurrence of each host is added.
+		delete(results, host)
+
+		if result.Err != nil {
+			if !conf.IgnoreErrors {
+				errs = append(errs, result.Err)
+			}
+			continue
+		}
+
+		for _, addr := range result.Addrs {
+			if err := h.AddRecord(addr.String(), host); err != nil {
+				return hostsfile.Hostsfile{}, fmt.Errorf("failed to add record for %s: %w", host, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return hostsfile.Hostsfile{}, errors.Join(errs...)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/resolver/hostsfile"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostsSnapshot(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "www.example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", "api.example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", "missing.example.com").
+		Return([]netip.Addr(nil), resolver.ErrNoSuchHost)
+
+	ctx := context.Background()
+
+	t.Run("Snapshot", func(t *testing.T) {
+		h, err := resolver.HostsSnapshot(ctx, inner, []string{"www.example.com", "api.example.com", "www.example.com"}, nil)
+		require.NoError(t, err)
+
+		var sb strings.Builder
+		require.NoError(t, hostsfile.Encode(&sb, h))
+
+		require.Equal(t, "192.0.2.1\twww.example.com api.example.com\n2001:db8::1\twww.example.com\n", sb.String())
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		_, err := resolver.HostsSnapshot(ctx, inner, []string{"www.example.com", "missing.example.com"}, nil)
+		require.ErrorIs(t, err, resolver.ErrNoSuchHost)
+	})
+
+	t.Run("Ignore Errors", func(t *testing.T) {
+		h, err := resolver.HostsSnapshot(ctx, inner, []string{"missing.example.com", "api.example.com"}, &resolver.HostsSnapshotConfig{
+			IgnoreErrors: true,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, h.Records(), 1)
+		require.True(t, h.Records()[0].Matches("api.example.com"))
+	})
+}
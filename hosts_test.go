@@ -13,9 +13,12 @@ import (
 	"context"
 	"net/netip"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,3 +58,37 @@ func TestHostsResolver(t *testing.T) {
 	_, err = res.LookupNetIP(context.Background(), "ip", "api2.testserver.local")
 	require.Error(t, err)
 }
+
+func TestHostsResolverWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.1 foo.example\n"), 0o644))
+
+	res, err := resolver.Hosts(&resolver.HostsResolverConfig{
+		HostsFilePath: path,
+		Watch:         ptr.To(true),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, res.Close())
+	})
+
+	res.AddHost("ephemeral.example", netip.MustParseAddr("192.0.2.3"))
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "foo.example")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.2 bar.example\n"), 0o644))
+
+	require.Eventually(t, func() bool {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "bar.example")
+		return err == nil && len(addrs) == 1 && addrs[0] == netip.MustParseAddr("192.0.2.2")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = res.LookupNetIP(context.Background(), "ip4", "foo.example")
+	require.True(t, resolver.IsNXDomain(err))
+
+	addrs, err = res.LookupNetIP(context.Background(), "ip4", "ephemeral.example")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.3")}, addrs)
+}
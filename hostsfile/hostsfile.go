@@ -0,0 +1,458 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Portions of this file are based on code originally:
+ *
+ * Copyright (c) 2014 Kevin Burke
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package hostsfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// Represents a hosts file. Records match a single line in the file.
+type Hostsfile struct {
+	records []*Record
+}
+
+// Records returns an array of all entries in the hostsfile.
+func (h *Hostsfile) Records() []*Record {
+	return h.records
+}
+
+// AddRecord adds hostnames for ip. If there is already a record for ip, any
+// missing hostnames are added to it, otherwise a new record is appended.
+func (h *Hostsfile) AddRecord(ip string, hostnames ...string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid IP address: %w", err)
+	}
+
+	if len(hostnames) == 0 {
+		return errors.New("no hostnames")
+	}
+
+	names := make([]string, 0, len(hostnames))
+	for _, name := range hostnames {
+		// Hostnames are separated by whitespace, and may not start a comment.
+		if _, ok := dns.IsDomainName(name); !ok || strings.ContainsFunc(name, unicode.IsSpace) || strings.HasPrefix(name, "#") {
+			return fmt.Errorf("invalid hostname: %q", name)
+		}
+		if name = dns.CanonicalName(name); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, r := range h.records {
+		if r.IpAddress.IP == nil || !r.hasAddr(addr) {
+			continue
+		}
+
+		for _, name := range names {
+			if !slices.Contains(r.Hostnames, name) {
+				r.Hostnames = append(r.Hostnames, name)
+			}
+		}
+		return nil
+	}
+
+	h.records = append(h.records, &Record{
+		IpAddress: net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()},
+		Hostnames: names,
+	})
+	return nil
+}
+
+// RemoveByHostname removes hostname from all records, records that are left
+// without any hostnames are removed. It returns whether any record was
+// modified.
+func (h *Hostsfile) RemoveByHostname(hostname string) bool {
+	name := dns.CanonicalName(hostname)
+
+	var modified bool
+	h.records = slices.DeleteFunc(h.records, func(r *Record) bool {
+		if !slices.Contains(r.Hostnames, name) {
+			return false
+		}
+		modified = true
+
+		r.Hostnames = slices.DeleteFunc(r.Hostnames, func(n string) bool { return n == name })
+		return len(r.Hostnames) == 0
+	})
+
+	return modified
+}
+
+// RemoveByIP removes all records for ip. It returns whether any record was
+// removed.
+func (h *Hostsfile) RemoveByIP(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	n := len(h.records)
+	h.records = slices.DeleteFunc(h.records, func(r *Record) bool {
+		return r.IpAddress.IP != nil && r.hasAddr(addr)
+	})
+
+	return len(h.records) != n
+}
+
+// Save atomically replaces the file at path with the encoded hosts file, by
+// writing to a temporary file in the same directory and renaming it. The
+// permissions of the original file are retained. If path is a symlink, the
+// file it points to is replaced. If the file can't be renamed over because it
+// is a mount point (eg. a bind-mounted /etc/hosts in a container), it is
+// truncated and rewritten in place instead.
+func (h *Hostsfile) Save(path string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to resolve hosts file path: %w", err)
+	}
+
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat hosts file: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := Encode(f, *h); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write hosts file: %w", err)
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync hosts file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close hosts file: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		if errors.Is(err, syscall.EBUSY) {
+			return h.saveInPlace(path)
+		}
+		return fmt.Errorf("failed to replace hosts file: %w", err)
+	}
+
+	return nil
+}
+
+// saveInPlace truncates and rewrites the file at path. It is not atomic, so
+// is only used when the file can't be replaced.
+func (h *Hostsfile) saveInPlace(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open hosts file: %w", err)
+	}
+
+	if err := Encode(f, *h); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write hosts file: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync hosts file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close hosts file: %w", err)
+	}
+
+	return nil
+}
+
+// A single line in the hosts file
+type Record struct {
+	IpAddress net.IPAddr
+	Hostnames []string
+	comment   string
+	isBlank   bool
+	// raw is the line as it was decoded, so that unmodified lines can be
+	// encoded verbatim.
+	raw string
+}
+
+func (r *Record) Matches(hostname string) bool {
+	for _, hn := range r.Hostnames {
+		if hn == dns.CanonicalName(hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseError is an error encountered while parsing a specific line of a
+// hosts file.
+type ParseError struct {
+	// Line is the line number (starting at 1).
+	Line int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeOptions configures how a hosts file is decoded.
+type DecodeOptions struct {
+	// MaxLineLength is the maximum length of a line in bytes.
+	// Defaults to bufio.MaxScanTokenSize.
+	MaxLineLength int
+	// MaxRecords is the maximum number of address records, zero means no limit.
+	MaxRecords int
+	// MergeDuplicates merges the hostnames of records that share an IP address
+	// into the first record with that address.
+	MergeDuplicates bool
+	// Strict reports all parse errors (with line numbers), rather than
+	// aborting on the first error.
+	Strict bool
+}
+
+// Decodes the raw text of a hostsfile into a Hostsfile struct. Comments,
+// blank lines and formatting are retained, so that the hosts file can be
+// written back using Encode.
+//
+// Interface example from the image package.
+func Decode(rdr io.Reader) (Hostsfile, error) {
+	return DecodeWithOptions(rdr, nil)
+}
+
+// DecodeWithOptions is like Decode, but with configurable limits and
+// error handling.
+func DecodeWithOptions(rdr io.Reader, opts *DecodeOptions) (Hostsfile, error) {
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
+
+	maxLineLength := opts.MaxLineLength
+	if maxLineLength <= 0 {
+		maxLineLength = bufio.MaxScanTokenSize
+	}
+
+	var h Hostsfile
+	var errs []error
+	byAddr := make(map[string]*Record)
+
+	scanner := bufio.NewScanner(rdr)
+	scanner.Buffer(make([]byte, 0, min(maxLineLength, 4096)), maxLineLength)
+
+	var lineNumber, addrRecords int
+	for scanner.Scan() {
+		lineNumber++
+
+		r, err := decodeLine(scanner.Text())
+		if err != nil {
+			if !opts.Strict {
+				return Hostsfile{}, err
+			}
+
+			errs = append(errs, &ParseError{Line: lineNumber, Err: err})
+			continue
+		}
+		r.raw = strings.ReplaceAll(scanner.Text(), "\r", "")
+
+		if r.IpAddress.IP != nil {
+			if opts.MergeDuplicates {
+				if existing, ok := byAddr[r.IpAddress.String()]; ok {
+					for _, name := range r.Hostnames {
+						if !slices.Contains(existing.Hostnames, name) {
+							existing.Hostnames = append(existing.Hostnames, name)
+						}
+					}
+					continue
+				}
+				byAddr[r.IpAddress.String()] = r
+			}
+
+			addrRecords++
+			if opts.MaxRecords > 0 && addrRecords > opts.MaxRecords {
+				err := &ParseError{Line: lineNumber, Err: fmt.Errorf("too many records (max %d)", opts.MaxRecords)}
+				if !opts.Strict {
+					return Hostsfile{}, err
+				}
+
+				errs = append(errs, err)
+				break
+			}
+		}
+
+		h.records = append(h.records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		if !opts.Strict {
+			return Hostsfile{}, err
+		}
+
+		errs = append(errs, &ParseError{Line: lineNumber + 1, Err: err})
+	}
+
+	if len(errs) > 0 {
+		return Hostsfile{}, errors.Join(errs...)
+	}
+
+	return h, nil
+}
+
+func decodeLine(rawLine string) (*Record, error) {
+	// Tolerate CRLF line endings, and any other stray carriage returns.
+	line := strings.TrimSpace(strings.ReplaceAll(rawLine, "\r", ""))
+
+	r := new(Record)
+	if len(line) == 0 {
+		r.isBlank = true
+		return r, nil
+	}
+
+	if line[0] == '#' {
+		// comment line or blank line: skip it.
+		r.comment = line
+		return r, nil
+	}
+
+	vals := strings.Fields(line)
+	if len(vals) <= 1 {
+		return nil, fmt.Errorf("invalid hostsfile entry: %s", line)
+	}
+
+	// Only literal addresses are accepted, a hostname would otherwise be
+	// resolved (potentially over the network).
+	addr, err := netip.ParseAddr(vals[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address: %q", vals[0])
+	}
+
+	r = &Record{
+		IpAddress: net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()},
+	}
+	for i := 1; i < len(vals); i++ {
+		name := vals[i]
+		if len(name) > 0 && name[0] == '#' {
+			// beginning of a comment. rest of the line is bunk
+			r.comment = strings.Join(vals[i:], " ")
+			break
+		}
+		if _, ok := dns.IsDomainName(name); ok {
+			r.Hostnames = append(r.Hostnames, dns.CanonicalName(name))
+		}
+	}
+
+	return r, nil
+}
+
+// hasAddr returns whether the record is for addr.
+func (r *Record) hasAddr(addr netip.Addr) bool {
+	ip, ok := netip.AddrFromSlice(r.IpAddress.IP)
+	return ok && ip.Unmap().WithZone(r.IpAddress.Zone) == addr.Unmap()
+}
+
+// Encode writes h to w in the hosts file format. Lines that have not been
+// modified since they were decoded are written verbatim (preserving comments,
+// blank lines and formatting), other lines are written as the address
+// followed by a tab and the hostnames.
+func Encode(w io.Writer, h Hostsfile) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range h.records {
+		if _, err := bw.WriteString(r.String() + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// String returns the record as a line of a hosts file (without a trailing
+// newline).
+func (r *Record) String() string {
+	if r.raw != "" && r.unmodified() {
+		return r.raw
+	}
+
+	if r.IpAddress.IP == nil {
+		return r.comment
+	}
+
+	hostnames := make([]string, 0, len(r.Hostnames))
+	for _, name := range r.Hostnames {
+		hostnames = append(hostnames, strings.TrimSuffix(name, "."))
+	}
+
+	line := r.IpAddress.String() + "\t" + strings.Join(hostnames, " ")
+	if r.comment != "" {
+		line += " " + r.comment
+	}
+
+	return line
+}
+
+// unmodified returns whether the record still matches the line it was
+// decoded from.
+func (r *Record) unmodified() bool {
+	orig, err := decodeLine(r.raw)
+	if err != nil {
+		return false
+	}
+
+	return orig.IpAddress.String() == r.IpAddress.String() &&
+		slices.Equal(orig.Hostnames, r.Hostnames) &&
+		orig.comment == r.comment &&
+		orig.isBlank == r.isBlank
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Portions of this file are based on code originally:
+ *
+ * Copyright (c) 2014 Kevin Burke
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package hostsfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+	sampledata := "127.0.0.1 foobar\n# this is a comment\n\n10.0.0.1 anotheralias"
+	h, err := Decode(strings.NewReader(sampledata))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	firstRecord := h.records[0]
+
+	require.Equal(t, firstRecord.IpAddress.IP.String(), "127.0.0.1")
+	require.Equal(t, firstRecord.Matches("foobar"), true)
+	require.Equal(t, len(firstRecord.Hostnames), 1)
+
+	require.Equal(t, h.records[1].comment, "# this is a comment")
+	require.Equal(t, h.records[2].isBlank, true)
+
+	aliasSample := "127.0.0.1 name alias1 alias2 alias3"
+	h, err = Decode(strings.NewReader(aliasSample))
+	require.NoError(t, err)
+	hns := h.records[0].Hostnames
+	require.Equal(t, len(hns), 4)
+	require.Contains(t, hns, "alias3.")
+
+	badline := strings.NewReader("blah")
+	h, err = Decode(badline)
+	if err == nil {
+		t.Error("expected Decode(\"blah\") to return invalid, got no error")
+	}
+	if err.Error() != "invalid hostsfile entry: blah" {
+		t.Errorf("expected Decode(\"blah\") to return invalid, got %s", err.Error())
+	}
+
+	h, err = Decode(strings.NewReader("##\n127.0.0.1\tlocalhost    2nd-alias"))
+	require.NoError(t, err)
+	require.Contains(t, h.records[1].Hostnames, "2nd-alias.")
+
+	h, err = Decode(strings.NewReader("##\n127.0.0.1\tlocalhost # a comment"))
+	require.NoError(t, err)
+	require.NotContains(t, h.records[0].Hostnames, "#.")
+	require.NotContains(t, h.records[0].Hostnames, "a.")
+}
+
+func TestDecodeWithOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CRLF And Tabs", func(t *testing.T) {
+		h, err := DecodeWithOptions(strings.NewReader("127.0.0.1\tfoo\r\n\r\n10.0.0.1 \t bar\r\n"), nil)
+		require.NoError(t, err)
+		require.Len(t, h.records, 3)
+		require.Equal(t, []string{"foo."}, h.records[0].Hostnames)
+		require.True(t, h.records[1].isBlank)
+		require.Equal(t, []string{"bar."}, h.records[2].Hostnames)
+	})
+
+	t.Run("Max Line Length", func(t *testing.T) {
+		_, err := DecodeWithOptions(strings.NewReader("127.0.0.1 "+strings.Repeat("a", 64)), &DecodeOptions{
+			MaxLineLength: 32,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Max Records", func(t *testing.T) {
+		_, err := DecodeWithOptions(strings.NewReader("# comment\n127.0.0.1 foo\n10.0.0.1 bar\n"), &DecodeOptions{
+			MaxRecords: 1,
+		})
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		require.Equal(t, 3, parseErr.Line)
+	})
+
+	t.Run("Merge Duplicates", func(t *testing.T) {
+		h, err := DecodeWithOptions(strings.NewReader("127.0.0.1 foo\n10.0.0.1 bar\n127.0.0.1 baz foo\n"), &DecodeOptions{
+			MergeDuplicates: true,
+			MaxRecords:      2,
+		})
+		require.NoError(t, err)
+		require.Len(t, h.records, 2)
+		require.Equal(t, []string{"foo.", "baz."}, h.records[0].Hostnames)
+	})
+
+	t.Run("Literal Addresses Only", func(t *testing.T) {
+		_, err := DecodeWithOptions(strings.NewReader("localhost foo\n"), nil)
+		require.ErrorContains(t, err, `invalid IP address: "localhost"`)
+
+		h, err := DecodeWithOptions(strings.NewReader("fe80::1%eth0 foo\n"), nil)
+		require.NoError(t, err)
+		require.Equal(t, "fe80::1%eth0", h.records[0].IpAddress.String())
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		_, err := DecodeWithOptions(strings.NewReader("blah\n127.0.0.1 foo\nnot-an-ip bar\n"), &DecodeOptions{
+			Strict: true,
+		})
+		require.Error(t, err)
+		require.ErrorContains(t, err, "line 1: invalid hostsfile entry: blah")
+		require.ErrorContains(t, err, "line 3:")
+	})
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Round Trip", func(t *testing.T) {
+		sampledata := "# The hosts file.\n127.0.0.1\tlocalhost   Localhost.localdomain\n\n  \n::1 localhost # IPv6\n#\n10.0.0.1 foo\tbar\n"
+
+		h, err := Decode(strings.NewReader(sampledata))
+		require.NoError(t, err)
+
+		var sb strings.Builder
+		require.NoError(t, Encode(&sb, h))
+
+		require.Equal(t, sampledata, sb.String())
+	})
+
+	t.Run("Modified", func(t *testing.T) {
+		h, err := Decode(strings.NewReader("# comment\n127.0.0.1   foo # keep\n10.0.0.1 bar\n"))
+		require.NoError(t, err)
+
+		h.records[1].Hostnames = append(h.records[1].Hostnames, "baz.")
+
+		var sb strings.Builder
+		require.NoError(t, Encode(&sb, h))
+
+		require.Equal(t, "# comment\n127.0.0.1\tfoo baz # keep\n10.0.0.1 bar\n", sb.String())
+	})
+}
+
+func TestEdit(t *testing.T) {
+	t.Parallel()
+
+	h, err := Decode(strings.NewReader("# comment\n127.0.0.1 localhost\n10.0.0.1 foo bar\n"))
+	require.NoError(t, err)
+
+	t.Run("Add Record", func(t *testing.T) {
+		require.NoError(t, h.AddRecord("10.0.0.2", "baz", "baz"))
+		require.NoError(t, h.AddRecord("10.0.0.1", "qux", "foo"))
+
+		require.Error(t, h.AddRecord("invalid", "baz"))
+		require.Error(t, h.AddRecord("10.0.0.3", "in valid"))
+		require.Error(t, h.AddRecord("10.0.0.3"))
+	})
+
+	t.Run("Remove By Hostname", func(t *testing.T) {
+		require.True(t, h.RemoveByHostname("bar"))
+		require.False(t, h.RemoveByHostname("missing"))
+	})
+
+	t.Run("Remove By IP", func(t *testing.T) {
+		require.True(t, h.RemoveByIP("10.0.0.2"))
+		require.False(t, h.RemoveByIP("10.0.0.2"))
+	})
+
+	var sb strings.Builder
+	require.NoError(t, Encode(&sb, h))
+	require.Equal(t, "# comment\n127.0.0.1 localhost\n10.0.0.1\tfoo qux\n", sb.String())
+
+	t.Run("Save", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts")
+		require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0o600))
+
+		require.NoError(t, h.Save(path))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, sb.String(), string(data))
+
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("Save Symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "hosts.real")
+		require.NoError(t, os.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0o600))
+
+		path := filepath.Join(dir, "hosts")
+		if err := os.Symlink(target, path); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		require.NoError(t, h.Save(path))
+
+		fi, err := os.Lstat(path)
+		require.NoError(t, err)
+		require.NotZero(t, fi.Mode()&os.ModeSymlink)
+
+		data, err := os.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, sb.String(), string(data))
+	})
+}
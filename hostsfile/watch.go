@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package hostsfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchOptions configures how a hosts file is watched.
+type WatchOptions struct {
+	// PollInterval is the interval at which the hosts file is checked for
+	// changes, when file system notifications are not available. Defaults to
+	// 5 seconds.
+	PollInterval time.Duration
+	// DecodeOptions configures how the hosts file is decoded.
+	DecodeOptions *DecodeOptions
+}
+
+// Watch watches the hosts file at path, sending a freshly decoded snapshot of
+// it every time it changes. The current contents are sent first. Changes that
+// fail to decode are skipped (keeping the previous snapshot). The channel is
+// closed once ctx is done.
+func Watch(ctx context.Context, path string) (<-chan Hostsfile, error) {
+	return WatchWithOptions(ctx, path, nil)
+}
+
+// WatchWithOptions is like Watch, but with configurable options.
+func WatchWithOptions(ctx context.Context, path string, opts *WatchOptions) (<-chan Hostsfile, error) {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, data, err := load(path, opts.DecodeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch the directory rather than the file, as the file is often
+	// replaced rather than modified (eg. by Save). If path is a symlink, the
+	// directory of the file it points to is watched too. If file system
+	// notifications are not available, fall back to polling.
+	names := watchedNames(path)
+	watcher, err := newWatcher()
+	if err == nil {
+		for _, name := range names {
+			if err = watcher.Add(filepath.Dir(name)); err != nil {
+				_ = watcher.Close()
+				watcher = nil
+				break
+			}
+		}
+	}
+
+	var events <-chan fsnotify.Event
+	var watchErrs <-chan error
+	var poll <-chan time.Time
+	var ticker *time.Ticker
+	if watcher != nil {
+		events = watcher.Events
+		watchErrs = watcher.Errors
+	} else {
+		ticker = time.NewTicker(pollInterval)
+		poll = ticker.C
+	}
+
+	snapshots := make(chan Hostsfile, 1)
+	snapshots <- h
+
+	go func() {
+		defer close(snapshots)
+		if watcher != nil {
+			defer watcher.Close()
+		} else {
+			defer ticker.Stop()
+		}
+
+		var settled <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				// Wait for the changes to settle, as a single write (or
+				// replacement) is often reported as several events.
+				if slices.Contains(names, filepath.Clean(event.Name)) {
+					settled = time.After(settleDelay)
+				}
+				continue
+			case <-watchErrs:
+				// Most likely dropped events, check for changes anyway.
+			case <-settled:
+				settled = nil
+			case <-poll:
+			}
+
+			// The symlink may have been pointed somewhere else.
+			if watcher != nil {
+				if next := watchedNames(path); !slices.Equal(next, names) {
+					for _, name := range next {
+						_ = watcher.Add(filepath.Dir(name))
+					}
+					names = next
+				}
+			}
+
+			next, nextData, err := load(path, opts.DecodeOptions)
+			if err != nil || bytes.Equal(nextData, data) {
+				continue
+			}
+			data = nextData
+
+			select {
+			case snapshots <- next:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return snapshots, nil
+}
+
+// watchedNames returns the names of the files whose changes affect the hosts
+// file at path, that is path itself and the file it resolves to (if it is a
+// symlink).
+func watchedNames(path string) []string {
+	names := []string{path}
+	if target, err := filepath.EvalSymlinks(path); err == nil && target != path {
+		names = append(names, target)
+	}
+	return names
+}
+
+// settleDelay is how long to wait after a file system notification before
+// reading the hosts file.
+const settleDelay = 50 * time.Millisecond
+
+// newWatcher is overridden in tests, to exercise the polling fallback.
+var newWatcher = func() (*fsnotify.Watcher, error) {
+	return fsnotify.NewWatcher()
+}
+
+// load reads and decodes the hosts file at path, returning its raw contents
+// too so that changes can be detected.
+func load(path string, opts *DecodeOptions) (Hostsfile, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Hostsfile{}, nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	h, err := DecodeWithOptions(bytes.NewReader(data), opts)
+	if err != nil {
+		return Hostsfile{}, nil, fmt.Errorf("failed to decode hosts file: %w", err)
+	}
+
+	return h, data, nil
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package hostsfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	t.Run("Notify", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts")
+		testWatch(t, path, nil)
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "hosts")
+		require.NoError(t, os.WriteFile(target, nil, 0o644))
+
+		path := filepath.Join(t.TempDir(), "hosts")
+		if err := os.Symlink(target, path); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		testWatch(t, path, nil)
+	})
+
+	t.Run("Poll", func(t *testing.T) {
+		orig := newWatcher
+		newWatcher = func() (*fsnotify.Watcher, error) {
+			return nil, errors.New("not supported")
+		}
+		t.Cleanup(func() { newWatcher = orig })
+
+		path := filepath.Join(t.TempDir(), "hosts")
+		testWatch(t, path, &WatchOptions{PollInterval: 10 * time.Millisecond})
+	})
+}
+
+func testWatch(t *testing.T, path string, opts *WatchOptions) {
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 foo\n"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots, err := WatchWithOptions(ctx, path, opts)
+	require.NoError(t, err)
+
+	// Snapshots of partially written files may be observed, so wait for
+	// the expected one.
+	waitFor := func(hostname string) Hostsfile {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case h, ok := <-snapshots:
+				require.True(t, ok)
+				for _, r := range h.Records() {
+					if r.Matches(hostname) {
+						return h
+					}
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", hostname)
+			}
+		}
+	}
+
+	waitFor("foo")
+
+	// Modified in place.
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 bar\n"), 0o644))
+
+	h := waitFor("bar")
+
+	// Replaced.
+	require.NoError(t, h.AddRecord("10.0.0.1", "baz"))
+	require.NoError(t, h.Save(path))
+
+	h = waitFor("baz")
+	require.Len(t, h.Records(), 2)
+
+	cancel()
+
+	require.Eventually(t, func() bool {
+		_, ok := <-snapshots
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
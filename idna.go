@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var _ HostValidatorFunc = ValidateIDNA2008
+
+// ErrInvalidHostname is returned when a hostname fails strict validation.
+var ErrInvalidHostname = errors.New("invalid hostname")
+
+// HostnameError describes why a hostname failed strict validation.
+type HostnameError struct {
+	// Host is the hostname that was validated.
+	Host string
+	// Label is the offending label, it is empty if the hostname as a whole is
+	// invalid (eg. it is too long).
+	Label string
+	// Reason describes the violated rule.
+	Reason string
+}
+
+func (e *HostnameError) Error() string {
+	if e.Label == "" {
+		return fmt.Sprintf("%s %q: %s", ErrInvalidHostname, e.Host, e.Reason)
+	}
+	return fmt.Sprintf("%s %q: label %q %s", ErrInvalidHostname, e.Host, e.Label, e.Reason)
+}
+
+func (e *HostnameError) Is(target error) bool {
+	return target == ErrInvalidHostname
+}
+
+// strictIDNA is the IDNA2008 profile used to validate labels, it applies the
+// UTS #46 lookup mapping (eg. case folding) and enforces the bidi and
+// contextual joiner rules. Lengths are checked separately, so that violations
+// are reported in detail.
+var strictIDNA = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.CheckHyphens(true),
+	idna.CheckJoiners(true),
+	idna.StrictDomainName(true),
+)
+
+// ValidateIDNA2008 is a HostValidatorFunc that enforces the IDNA2008 label
+// rules (RFC 5890 and RFC 5891), which are considerably stricter than the
+// syntax accepted by DNS resolvers: labels must be at most 63 octets (once
+// encoded), must not begin or end with a hyphen, must not have hyphens in the
+// third and fourth positions (unless they are A-labels), and must not contain
+// disallowed code points (eg. underscores, spaces, or symbols). Punycode
+// encoded labels are decoded and validated. IP literals are accepted. The
+// returned error is a *HostnameError.
+func ValidateIDNA2008(host string) error {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+
+	name := strings.TrimSuffix(host, ".")
+	if name == "" {
+		return &HostnameError{Host: host, Reason: "is empty"}
+	}
+
+	var length int
+	for _, label := range strings.Split(name, ".") {
+		if reason := checkLabel(label); reason != "" {
+			return &HostnameError{Host: host, Label: label, Reason: reason}
+		}
+
+		asciiLabel, err := strictIDNA.ToASCII(label)
+		if err != nil {
+			return &HostnameError{Host: host, Label: label, Reason: strings.TrimPrefix(err.Error(), "idna: ")}
+		}
+
+		if len(asciiLabel) > 63 {
+			return &HostnameError{Host: host, Label: label, Reason: "exceeds 63 octets"}
+		}
+
+		length += len(asciiLabel) + 1
+	}
+
+	// The length of a name excludes the trailing dot, and must leave room
+	// for the length octets of its wire encoding.
+	if length-1 > 253 {
+		return &HostnameError{Host: host, Reason: "exceeds 253 octets"}
+	}
+
+	return nil
+}
+
+// checkLabel checks the hyphen placement rules of a label, it returns the
+// violated rule (if any).
+func checkLabel(label string) string {
+	switch {
+	case label == "":
+		return "is empty"
+	case strings.HasPrefix(label, "-"):
+		return "begins with a hyphen"
+	case strings.HasSuffix(label, "-"):
+		return "ends with a hyphen"
+	case len(label) >= 4 && label[2:4] == "--" && !strings.EqualFold(label[:2], "xn"):
+		return "has hyphens in the third and fourth positions"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateIDNA2008(t *testing.T) {
+	valid := []string{
+		"example.com",
+		"Example.COM.",
+		"a-b.example",
+		"xn--bcher-kva.example",
+		"bücher.example",
+		"例え.テスト",
+		"192.0.2.1",
+		"2001:db8::1",
+		strings.Repeat("a", 63) + ".example",
+	}
+
+	for _, host := range valid {
+		t.Run("Valid "+host, func(t *testing.T) {
+			require.NoError(t, resolver.ValidateIDNA2008(host))
+		})
+	}
+
+	invalid := []struct {
+		host   string
+		label  string
+		reason string
+	}{
+		{"", "", "is empty"},
+		{"www..example", "", "is empty"},
+		{"-www.example", "-www", "begins with a hyphen"},
+		{"www-.example", "www-", "ends with a hyphen"},
+		{"ab--cd.example", "ab--cd", "has hyphens in the third and fourth positions"},
+		{"_dmarc.example", "_dmarc", "disallowed rune"},
+		{"foo bar.example", "foo bar", "disallowed rune"},
+		{"xn--zz.example", "xn--zz", "invalid label"},
+		{strings.Repeat("a", 64) + ".example", strings.Repeat("a", 64), "exceeds 63 octets"},
+		{strings.Repeat(strings.Repeat("a", 63)+".", 4) + "example", "", "exceeds 253 octets"},
+	}
+
+	for _, tt := range invalid {
+		t.Run("Invalid "+tt.host, func(t *testing.T) {
+			err := resolver.ValidateIDNA2008(tt.host)
+			require.ErrorIs(t, err, resolver.ErrInvalidHostname)
+
+			var hostnameErr *resolver.HostnameError
+			require.True(t, errors.As(err, &hostnameErr))
+			require.Equal(t, tt.host, hostnameErr.Host)
+			if tt.label != "" {
+				require.Equal(t, tt.label, hostnameErr.Label)
+			}
+			require.Contains(t, hostnameErr.Reason, tt.reason)
+		})
+	}
+
+	t.Run("Validating Resolver", func(t *testing.T) {
+		inner := new(dnstest.MockResolver)
+		inner.On("LookupNetIP", mock.Anything, "ip", "www.example.com").Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+		res := resolver.Validating(inner, &resolver.ValidatingResolverConfig{
+			Validators: []resolver.HostValidatorFunc{resolver.ValidateIDNA2008},
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(context.Background(), "ip", "www_example.com")
+		require.ErrorIs(t, err, resolver.ErrInvalidHostname)
+
+		inner.AssertNumberOfCalls(t, "LookupNetIP", 1)
+	})
+}
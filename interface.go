@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+var _ Resolver = (*interfaceResolver)(nil)
+
+type interfaceKey struct{}
+
+// WithInterface returns a copy of ctx indicating that the caller intends to
+// use the named network interface for the connection that follows the lookup.
+func WithInterface(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, interfaceKey{}, name)
+}
+
+// Interface returns the network interface name stored in ctx, if any.
+func Interface(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(interfaceKey{}).(string)
+	return name, ok
+}
+
+// InterfaceScope is the DNS configuration of a single network interface.
+type InterfaceScope struct {
+	// Interface is the name of the network interface.
+	Interface string
+	// Servers is the list of DNS servers associated with the interface.
+	Servers []netip.AddrPort
+	// Transport is the optional transport protocol used for DNS resolution.
+	Transport *DNSTransport
+	// Timeout is the maximum duration to wait for a query to complete.
+	Timeout *time.Duration
+	// Resolver optionally replaces the DNS servers with a custom resolver.
+	Resolver Resolver
+}
+
+// InterfaceResolverConfig is the configuration for an interface scoped
+// resolver.
+type InterfaceResolverConfig struct {
+	// Scopes is the list of per interface DNS configurations.
+	Scopes []InterfaceScope
+	// Default is an optional resolver used when the caller has not specified
+	// an interface, or the interface has no associated scope.
+	Default Resolver
+}
+
+// interfaceResolver is a resolver that maintains a resolver per network
+// interface.
+type interfaceResolver struct {
+	scopes          map[string]Resolver
+	defaultResolver Resolver
+}
+
+// InterfaceScoped returns a resolver that maintains a resolver per network
+// interface, and routes lookups based on the interface the caller intends to
+// use (see WithInterface). Queries to an interface's DNS servers are sent
+// using sockets bound to that interface, this is only supported on Linux and
+// macOS. On other platforms (eg. Windows) scopes must use a custom Resolver.
+func InterfaceScoped(conf *InterfaceResolverConfig) (*interfaceResolver, error) {
+	if conf == nil {
+		conf = &InterfaceResolverConfig{}
+	}
+
+	scopes := make(map[string]Resolver, len(conf.Scopes))
+	var created []Resolver
+	for _, scope := range conf.Scopes {
+		if err := validateInterfaceScope(scope, scopes); err != nil {
+			_ = closeAll(created)
+			return nil, err
+		}
+
+		if scope.Resolver != nil {
+			scopes[scope.Interface] = scope.Resolver
+			continue
+		}
+
+		dialContext := DialFromInterface(scope.Interface)
+
+		var resolvers []Resolver
+		for _, server := range scope.Servers {
+			resolvers = append(resolvers, DNS(DNSResolverConfig{
+				Server:      server,
+				Transport:   scope.Transport,
+				Timeout:     scope.Timeout,
+				DialContext: dialContext,
+			}))
+		}
+
+		scopes[scope.Interface] = Sequential(resolvers...)
+		created = append(created, scopes[scope.Interface])
+	}
+
+	return &interfaceResolver{
+		scopes:          scopes,
+		defaultResolver: conf.Default,
+	}, nil
+}
+
+func validateInterfaceScope(scope InterfaceScope, scopes map[string]Resolver) error {
+	// Binding to an empty interface name removes the binding, so queries
+	// would silently use the default route.
+	if scope.Interface == "" {
+		return errors.New("scope has no interface name")
+	}
+
+	if _, ok := scopes[scope.Interface]; ok {
+		return fmt.Errorf("duplicate scope for interface %q", scope.Interface)
+	}
+
+	if scope.Resolver != nil {
+		return nil
+	}
+
+	if len(scope.Servers) == 0 {
+		return fmt.Errorf("scope for interface %q has no servers or resolver", scope.Interface)
+	}
+
+	if bindToInterface(scope.Interface) == nil {
+		return fmt.Errorf("scope for interface %q: %w", scope.Interface, errInterfaceBindingUnsupported)
+	}
+
+	return nil
+}
+
+func (r *interfaceResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	resolver := r.defaultResolver
+	if name, ok := Interface(ctx); ok {
+		if scoped, ok := r.scopes[name]; ok {
+			resolver = scoped
+		}
+	}
+
+	if resolver == nil {
+		return nil, newError(host, "", ErrNoSuchHost)
+	}
+
+	return resolver.LookupNetIP(ctx, network, host)
+}
+
+// Close closes the resolvers of every interface scope and the default
+// resolver.
+func (r *interfaceResolver) Close() error {
+	resolvers := make([]Resolver, 0, len(r.scopes)+1)
+	for _, resolver := range r.scopes {
+		resolvers = append(resolvers, resolver)
+	}
+	return closeAll(append(resolvers, r.defaultResolver))
+}
+
+func (r *interfaceResolver) describe() Description {
+	names := make([]string, 0, len(r.scopes))
+	for name := range r.scopes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	d := Description{Type: "interface-scoped"}
+	for _, name := range names {
+		d.Children = append(d.Children, Description{
+			Type:       "scope",
+			Attributes: map[string]string{"interface": name},
+			Children:   []Description{Describe(r.scopes[name])},
+		})
+	}
+
+	if r.defaultResolver != nil {
+		d.Children = append(d.Children, Description{
+			Type:     "default",
+			Children: []Description{Describe(r.defaultResolver)},
+		})
+	}
+
+	return d
+}
@@ -0,0 +1,43 @@
+//go:build darwin
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"net"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToInterface returns a dialer control function that binds sockets to
+// the named network interface.
+func bindToInterface(name string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return err
+		}
+
+		var bindErr error
+		if err := c.Control(func(fd uintptr) {
+			if strings.HasSuffix(network, "6") {
+				bindErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_BOUND_IF, ifi.Index)
+			} else {
+				bindErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BOUND_IF, ifi.Index)
+			}
+		}); err != nil {
+			return err
+		}
+		return bindErr
+	}
+}
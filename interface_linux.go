@@ -0,0 +1,32 @@
+//go:build linux
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToInterface returns a dialer control function that binds sockets to
+// the named network interface.
+func bindToInterface(name string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var bindErr error
+		if err := c.Control(func(fd uintptr) {
+			bindErr = unix.BindToDevice(int(fd), name)
+		}); err != nil {
+			return err
+		}
+		return bindErr
+	}
+}
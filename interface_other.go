@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"syscall"
+)
+
+// bindToInterface is not supported on this platform, so interface scopes
+// without a custom resolver and per-server interfaces are rejected.
+func bindToInterface(name string) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceResolver(t *testing.T) {
+	wg0 := new(dnstest.MockResolver)
+	wg0.On("LookupNetIP", mock.Anything, "ip", "example.com").Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+
+	defaultRes := new(dnstest.MockResolver)
+	defaultRes.On("LookupNetIP", mock.Anything, "ip", "example.com").Return([]netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil)
+
+	res, err := resolver.InterfaceScoped(&resolver.InterfaceResolverConfig{
+		Scopes: []resolver.InterfaceScope{
+			{Interface: "wg0", Resolver: wg0},
+		},
+		Default: defaultRes,
+	})
+	require.NoError(t, err)
+
+	t.Run("Scoped", func(t *testing.T) {
+		ctx := resolver.WithInterface(context.Background(), "wg0")
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+	})
+
+	t.Run("Unscoped", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("93.184.216.34")}, addrs)
+	})
+
+	t.Run("Unknown Interface", func(t *testing.T) {
+		ctx := resolver.WithInterface(context.Background(), "eth1")
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("93.184.216.34")}, addrs)
+	})
+}
+
+func TestInterfaceResolverInvalidScopes(t *testing.T) {
+	_, err := resolver.InterfaceScoped(&resolver.InterfaceResolverConfig{
+		Scopes: []resolver.InterfaceScope{
+			{Interface: "wg0"},
+		},
+	})
+	require.ErrorContains(t, err, "no servers or resolver")
+
+	_, err = resolver.InterfaceScoped(&resolver.InterfaceResolverConfig{
+		Scopes: []resolver.InterfaceScope{
+			{Interface: "wg0", Resolver: new(dnstest.MockResolver)},
+			{Interface: "wg0", Resolver: new(dnstest.MockResolver)},
+		},
+	})
+	require.ErrorContains(t, err, "duplicate scope")
+
+	_, err = resolver.InterfaceScoped(&resolver.InterfaceResolverConfig{
+		Scopes: []resolver.InterfaceScope{
+			{Servers: []netip.AddrPort{netip.MustParseAddrPort("192.0.2.53:53")}},
+		},
+	})
+	require.ErrorContains(t, err, "no interface name")
+}
@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package dnswire performs defensive checks on DNS messages in wire format,
+// prior to them being fully parsed.
+package dnswire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+const headerSize = 12
+
+var (
+	// ErrLimitExceeded is returned when a message exceeds the configured limits.
+	ErrLimitExceeded = errors.New("message exceeds parsing limits")
+	// ErrMalformed is returned when a message is not well formed.
+	ErrMalformed = errors.New("malformed message")
+)
+
+// Limits are the limits applied when checking a message, zero values mean
+// no limit.
+type Limits struct {
+	// MaxRecords is the maximum number of records (across all sections).
+	MaxRecords int
+	// MaxExpandedSize is the maximum total size, in bytes, of all the domain
+	// names in the message once decompressed.
+	MaxExpandedSize int
+	// MaxPointers is the maximum total number of compression pointers
+	// followed while walking all the domain names in the message.
+	MaxPointers int
+	// MaxNameLength is the maximum length, in bytes, of a single domain name
+	// once decompressed (in wire format). Names are always limited to 255
+	// bytes.
+	MaxNameLength int
+}
+
+// Check walks a message in wire format, verifying that it is well formed and
+// within the given limits. Only the framing of the message is checked, the
+// contents of records are left to the full parser.
+func Check(msg []byte, limits Limits) error {
+	if len(msg) < headerSize {
+		return fmt.Errorf("short header: %w", ErrMalformed)
+	}
+
+	qdCount := int(binary.BigEndian.Uint16(msg[4:]))
+	rrCount := int(binary.BigEndian.Uint16(msg[6:])) +
+		int(binary.BigEndian.Uint16(msg[8:])) +
+		int(binary.BigEndian.Uint16(msg[10:]))
+
+	if limits.MaxRecords > 0 && rrCount > limits.MaxRecords {
+		return fmt.Errorf("%d records: %w", rrCount, ErrLimitExceeded)
+	}
+
+	w := &walker{msg: msg, off: headerSize, limits: limits}
+
+	for i := 0; i < qdCount; i++ {
+		if err := w.name(); err != nil {
+			return err
+		}
+
+		// Type and class.
+		if err := w.skip(4); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < rrCount; i++ {
+		if err := w.record(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type walker struct {
+	msg      []byte
+	off      int
+	expanded int
+	pointers int
+	limits   Limits
+}
+
+func (w *walker) skip(n int) error {
+	if w.off+n > len(w.msg) {
+		return fmt.Errorf("truncated message: %w", ErrMalformed)
+	}
+	w.off += n
+	return nil
+}
+
+func (w *walker) record() error {
+	if err := w.name(); err != nil {
+		return err
+	}
+
+	if w.off+10 > len(w.msg) {
+		return fmt.Errorf("truncated record header: %w", ErrMalformed)
+	}
+
+	rrType := binary.BigEndian.Uint16(w.msg[w.off:])
+	rdLength := int(binary.BigEndian.Uint16(w.msg[w.off+8:]))
+	w.off += 10
+
+	end := w.off + rdLength
+	if end > len(w.msg) {
+		return fmt.Errorf("truncated record data: %w", ErrMalformed)
+	}
+
+	// Account for the (possibly compressed) names embedded in record data.
+	var err error
+	switch rrType {
+	case dns.TypeCNAME, dns.TypeNS, dns.TypePTR, dns.TypeDNAME, dns.TypeMB,
+		dns.TypeMD, dns.TypeMF, dns.TypeMG, dns.TypeMR:
+		err = w.name()
+	case dns.TypeMX, dns.TypeKX, dns.TypeAFSDB, dns.TypeRT:
+		if err = w.skip(2); err == nil {
+			err = w.name()
+		}
+	case dns.TypeSRV:
+		if err = w.skip(6); err == nil {
+			err = w.name()
+		}
+	case dns.TypeSOA, dns.TypeMINFO, dns.TypeRP:
+		if err = w.name(); err == nil {
+			err = w.name()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if w.off > end {
+		return fmt.Errorf("record data overflow: %w", ErrMalformed)
+	}
+	w.off = end
+
+	return nil
+}
+
+// name walks a domain name at the current offset, following compression
+// pointers, and advances past it.
+func (w *walker) name() error {
+	off := w.off
+	next := -1
+	length := 0
+	pointers := 0
+
+	for {
+		if off >= len(w.msg) {
+			return fmt.Errorf("truncated name: %w", ErrMalformed)
+		}
+
+		c := int(w.msg[off])
+		switch c & 0xC0 {
+		case 0x00:
+			length += c + 1
+			if length > 255 {
+				return fmt.Errorf("name too long: %w", ErrMalformed)
+			}
+
+			if w.limits.MaxNameLength > 0 && length > w.limits.MaxNameLength {
+				return fmt.Errorf("name length %d: %w", length, ErrLimitExceeded)
+			}
+
+			if c == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+
+				w.off = next
+				w.expanded += length
+				if w.limits.MaxExpandedSize > 0 && w.expanded > w.limits.MaxExpandedSize {
+					return fmt.Errorf("expanded size of names: %w", ErrLimitExceeded)
+				}
+
+				return nil
+			}
+
+			off += c + 1
+		case 0xC0:
+			if off+1 >= len(w.msg) {
+				return fmt.Errorf("truncated compression pointer: %w", ErrMalformed)
+			}
+
+			ptr := int(binary.BigEndian.Uint16(w.msg[off:]) & 0x3FFF)
+			// Pointers must refer to a prior occurrence of a name (RFC 1035
+			// section 4.1.4), loops are caught by the length and pointer
+			// limits.
+			if ptr >= off {
+				return fmt.Errorf("forward compression pointer: %w", ErrMalformed)
+			}
+
+			if pointers++; pointers > 126 {
+				return fmt.Errorf("too many compression pointers: %w", ErrMalformed)
+			}
+
+			if w.pointers++; w.limits.MaxPointers > 0 && w.pointers > w.limits.MaxPointers {
+				return fmt.Errorf("compression pointers followed: %w", ErrLimitExceeded)
+			}
+
+			if next < 0 {
+				next = off + 2
+			}
+			off = ptr
+		default:
+			// 0x40 and 0x80 are reserved.
+			return fmt.Errorf("invalid label type: %w", ErrMalformed)
+		}
+	}
+}
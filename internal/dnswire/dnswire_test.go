@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package dnswire_test
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver/internal/dnswire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	msg := &dns.Msg{}
+	msg.SetQuestion("www.example.com.", dns.TypeA)
+	msg.Compress = true
+	for _, s := range []string{
+		"www.example.com. 300 IN CNAME web.example.com.",
+		"web.example.com. 300 IN A 192.0.2.1",
+		"web.example.com. 300 IN A 192.0.2.2",
+		"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+	} {
+		rr, err := dns.NewRR(s)
+		require.NoError(t, err)
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	packed, err := msg.Pack()
+	require.NoError(t, err)
+
+	t.Run("Valid", func(t *testing.T) {
+		require.NoError(t, dnswire.Check(packed, dnswire.Limits{}))
+	})
+
+	t.Run("Max Records", func(t *testing.T) {
+		err := dnswire.Check(packed, dnswire.Limits{MaxRecords: 3})
+		require.ErrorIs(t, err, dnswire.ErrLimitExceeded)
+	})
+
+	t.Run("Max Expanded Size", func(t *testing.T) {
+		err := dnswire.Check(packed, dnswire.Limits{MaxExpandedSize: 64})
+		require.ErrorIs(t, err, dnswire.ErrLimitExceeded)
+	})
+
+	t.Run("Max Name Length", func(t *testing.T) {
+		require.NoError(t, dnswire.Check(packed, dnswire.Limits{MaxNameLength: 32}))
+
+		err := dnswire.Check(packed, dnswire.Limits{MaxNameLength: 16})
+		require.ErrorIs(t, err, dnswire.ErrLimitExceeded)
+	})
+
+	t.Run("Max Pointers", func(t *testing.T) {
+		// Every name is a pointer to the previous one, so the number of
+		// pointers followed grows quadratically with the number of names.
+		chain := pointerChain(100)
+		require.NoError(t, dnswire.Check(chain, dnswire.Limits{}))
+
+		err := dnswire.Check(chain, dnswire.Limits{MaxPointers: 4096})
+		require.ErrorIs(t, err, dnswire.ErrLimitExceeded)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		err := dnswire.Check(packed[:len(packed)-5], dnswire.Limits{})
+		require.ErrorIs(t, err, dnswire.ErrMalformed)
+	})
+
+	t.Run("Pointer Loop", func(t *testing.T) {
+		// A question whose name is a label followed by a pointer back to itself.
+		loop := []byte{
+			0, 1, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0,
+			1, 'a', 0xC0, 12,
+			0, 1, 0, 1,
+		}
+
+		err := dnswire.Check(loop, dnswire.Limits{})
+		require.ErrorIs(t, err, dnswire.ErrMalformed)
+	})
+}
+
+func FuzzCheck(f *testing.F) {
+	msg := &dns.Msg{}
+	msg.SetQuestion("www.example.com.", dns.TypeA)
+	msg.Compress = true
+	rr, err := dns.NewRR("www.example.com. 300 IN CNAME web.example.com.")
+	require.NoError(f, err)
+	msg.Answer = append(msg.Answer, rr)
+
+	packed, err := msg.Pack()
+	require.NoError(f, err)
+
+	f.Add(packed)
+	f.Add(pointerChain(100))
+
+	limits := dnswire.Limits{
+		MaxRecords:      64,
+		MaxExpandedSize: 4096,
+		MaxPointers:     256,
+		MaxNameLength:   128,
+	}
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		if err := dnswire.Check(msg, limits); err != nil {
+			return
+		}
+
+		// Messages that pass the check must be safe to hand to the full
+		// parser, which must respect the same limits.
+		var parsed dns.Msg
+		if err := parsed.Unpack(msg); err != nil {
+			return
+		}
+
+		require.LessOrEqual(t, len(parsed.Answer)+len(parsed.Ns)+len(parsed.Extra), limits.MaxRecords)
+	})
+}
+
+// pointerChain returns a message with n questions, where the name of every
+// question after the first is a compression pointer to the previous one.
+func pointerChain(n int) []byte {
+	msg := []byte{0, 1, 0x81, 0x80, byte(n >> 8), byte(n), 0, 0, 0, 0, 0, 0}
+
+	prev := len(msg)
+	msg = append(msg, 1, 'a', 0, 0, 1, 0, 1)
+	for i := 1; i < n; i++ {
+		off := len(msg)
+		msg = append(msg, 0xC0|byte(prev>>8), byte(prev), 0, 1, 0, 1)
+		prev = off
+	}
+
+	return msg
+}
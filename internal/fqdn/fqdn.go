@@ -31,7 +31,7 @@ import (
 	"net"
 	"os"
 
-	"github.com/noisysockets/resolver/internal/hostsfile"
+	"github.com/noisysockets/resolver/hostsfile"
 )
 
 // ErrFqdnNotFound is returned when fully qualified hostname cannot be found.
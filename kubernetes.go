@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+)
+
+var (
+	_ Resolver        = (*KubernetesResolver)(nil)
+	_ ServiceResolver = (*net.Resolver)(nil)
+	_ ServiceResolver = (*ZoneResolver)(nil)
+	_ ServiceResolver = (*dnsResolver)(nil)
+)
+
+// kubernetesNamespacePath is where the namespace of a pod is mounted, along
+// with its service account credentials.
+const kubernetesNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// ServiceResolver looks up addresses and SRV records, it is implemented by
+// *net.Resolver, *ZoneResolver and DNS resolvers.
+type ServiceResolver interface {
+	Resolver
+	SRVResolver
+}
+
+// KubernetesResolverConfig is the configuration for a Kubernetes resolver.
+type KubernetesResolverConfig struct {
+	// Namespace is the namespace that unqualified service names are relative
+	// to. By default, the namespace of the pod is used (or "default", if not
+	// running in a pod).
+	Namespace string
+	// ClusterDomain is the domain of the cluster. Defaults to
+	// "cluster.local".
+	ClusterDomain string
+}
+
+// KubernetesEndpoint is an endpoint of a Kubernetes service.
+type KubernetesEndpoint struct {
+	// Target is the hostname of the endpoint (eg.
+	// "web-0.web.default.svc.cluster.local." for a pod of a stateful set).
+	Target string
+	// Addr is the address and port of the endpoint.
+	Addr netip.AddrPort
+}
+
+// KubernetesResolver resolves the names of Kubernetes services and pods,
+// following the conventions of the cluster DNS specification. It qualifies
+// short names directly (rather than relying on the ndots:5 search path of
+// pods), and looks up the endpoints of headless services for client-side
+// load balancing.
+type KubernetesResolver struct {
+	resolver      ServiceResolver
+	namespace     string
+	clusterDomain string
+}
+
+// Kubernetes returns a resolver for the services and pods of a Kubernetes
+// cluster, using resolver (eg. a DNS resolver for the cluster's DNS service).
+func Kubernetes(resolver ServiceResolver, conf *KubernetesResolverConfig) (*KubernetesResolver, error) {
+	conf, err := defaults.WithDefaults(conf, &KubernetesResolverConfig{
+		ClusterDomain: "cluster.local",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to kubernetes resolver config: %w", err)
+	}
+
+	if conf.Namespace == "" {
+		conf.Namespace = "default"
+
+		namespace, err := os.ReadFile(kubernetesNamespacePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		if ns := strings.TrimSpace(string(namespace)); ns != "" {
+			conf.Namespace = ns
+		}
+	}
+
+	if _, ok := dns.IsDomainName(conf.Namespace); !ok || strings.Contains(conf.Namespace, ".") {
+		return nil, fmt.Errorf("invalid namespace: %q", conf.Namespace)
+	}
+
+	return &KubernetesResolver{
+		resolver:      resolver,
+		namespace:     conf.Namespace,
+		clusterDomain: dns.CanonicalName(conf.ClusterDomain),
+	}, nil
+}
+
+// LookupNetIP looks up host, short names are qualified first:
+//
+//   - "service" is looked up as "service.<namespace>.svc.<cluster domain>".
+//   - Names ending in ".svc" (eg. "service.namespace.svc" or
+//     "pod.service.namespace.svc") have the cluster domain appended.
+//
+// All other names are looked up as is.
+func (r *KubernetesResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return r.resolver.LookupNetIP(ctx, network, r.qualify(host))
+}
+
+// LookupEndpoints returns the endpoints of a service. If portName is set,
+// the endpoints are looked up using the service's SRV records (eg.
+// "_http._tcp.service.namespace.svc.cluster.local"), and so include the
+// port of each endpoint (which may differ between pods). Otherwise the
+// addresses of the service are looked up (which, for a headless service, are
+// the addresses of its ready pods), and port is used for every endpoint.
+//
+// The service name is qualified as for LookupNetIP, eg. "service" or
+// "service.namespace".
+func (r *KubernetesResolver) LookupEndpoints(ctx context.Context, service, portName, proto string, port uint16) ([]KubernetesEndpoint, error) {
+	name := r.qualify(service)
+	if labels := dns.SplitDomainName(service); len(labels) == 2 {
+		// A service in another namespace.
+		name = dns.Fqdn(service + ".svc." + r.clusterDomain)
+	}
+
+	if portName == "" {
+		addrs, err := r.resolver.LookupNetIP(ctx, "ip", name)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoints := make([]KubernetesEndpoint, 0, len(addrs))
+		for _, addr := range addrs {
+			endpoints = append(endpoints, KubernetesEndpoint{
+				Target: name,
+				Addr:   netip.AddrPortFrom(addr, port),
+			})
+		}
+
+		return endpoints, nil
+	}
+
+	_, srvs, err := r.resolver.LookupSRV(ctx, portName, proto, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []KubernetesEndpoint
+	var errs []error
+	for _, srv := range srvs {
+		addrs, err := r.resolver.LookupNetIP(ctx, "ip", srv.Target)
+		if err != nil {
+			// The pod may have gone away since the SRV records were generated.
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, addr := range addrs {
+			endpoints = append(endpoints, KubernetesEndpoint{
+				Target: dns.Fqdn(srv.Target),
+				Addr:   netip.AddrPortFrom(addr, srv.Port),
+			})
+		}
+	}
+
+	if len(endpoints) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return endpoints, nil
+}
+
+// Close closes the underlying resolver.
+func (r *KubernetesResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *KubernetesResolver) describe() Description {
+	return Description{
+		Type: "kubernetes",
+		Attributes: map[string]string{
+			"namespace":      r.namespace,
+			"cluster_domain": r.clusterDomain,
+		},
+		Children: []Description{Describe(r.resolver)},
+	}
+}
+
+// qualify returns the fully qualified name of a (possibly short) service or
+// pod name.
+func (r *KubernetesResolver) qualify(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+
+	labels := dns.SplitDomainName(host)
+	switch {
+	case len(labels) == 1:
+		return dns.Fqdn(host + "." + r.namespace + ".svc." + r.clusterDomain)
+	case len(labels) > 1 && strings.EqualFold(labels[len(labels)-1], "svc"):
+		return dns.Fqdn(host + "." + r.clusterDomain)
+	default:
+		return dns.Fqdn(host)
+	}
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+const clusterZone = `
+$ORIGIN cluster.local.
+web.default.svc          IN A   10.0.0.1
+                         IN A   10.0.0.2
+web-0.web.default.svc    IN A   10.0.0.1
+web-1.web.default.svc    IN A   10.0.0.2
+_http._tcp.web.default.svc IN SRV 0 50 8080 web-0.web.default.svc.cluster.local.
+                           IN SRV 0 50 8081 web-1.web.default.svc.cluster.local.
+db.storage.svc           IN A   10.0.1.1
+`
+
+func TestKubernetes(t *testing.T) {
+	zone, err := resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFileReader: strings.NewReader(clusterZone),
+	})
+	require.NoError(t, err)
+
+	res, err := resolver.Kubernetes(zone, &resolver.KubernetesResolverConfig{
+		Namespace: "default",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("LookupNetIP", func(t *testing.T) {
+		for host, expected := range map[string][]netip.Addr{
+			"web":                          {netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")},
+			"web-1.web.default.svc":        {netip.MustParseAddr("10.0.0.2")},
+			"db.storage.svc":               {netip.MustParseAddr("10.0.1.1")},
+			"db.storage.svc.cluster.local": {netip.MustParseAddr("10.0.1.1")},
+		} {
+			addrs, err := res.LookupNetIP(ctx, "ip4", host)
+			require.NoError(t, err, host)
+
+			require.ElementsMatch(t, expected, addrs, host)
+		}
+
+		_, err := res.LookupNetIP(ctx, "ip4", "missing")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("Headless SRV", func(t *testing.T) {
+		endpoints, err := res.LookupEndpoints(ctx, "web", "http", "tcp", 0)
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []resolver.KubernetesEndpoint{
+			{Target: "web-0.web.default.svc.cluster.local.", Addr: netip.MustParseAddrPort("10.0.0.1:8080")},
+			{Target: "web-1.web.default.svc.cluster.local.", Addr: netip.MustParseAddrPort("10.0.0.2:8081")},
+		}, endpoints)
+	})
+
+	t.Run("Addresses", func(t *testing.T) {
+		endpoints, err := res.LookupEndpoints(ctx, "db.storage", "", "", 5432)
+		require.NoError(t, err)
+
+		require.Equal(t, []resolver.KubernetesEndpoint{
+			{Target: "db.storage.svc.cluster.local.", Addr: netip.MustParseAddrPort("10.0.1.1:5432")},
+		}, endpoints)
+	})
+
+	t.Run("Invalid Namespace", func(t *testing.T) {
+		_, err := resolver.Kubernetes(zone, &resolver.KubernetesResolverConfig{
+			Namespace: "a.b",
+		})
+		require.Error(t, err)
+	})
+}
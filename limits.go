@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver/internal/dnswire"
+)
+
+var errLimitExceeded = errors.New("response exceeds limits")
+
+// aLongTimeAgo is a deadline in the past, used to unblock pending I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// ResponseLimits are limits applied when reading and parsing responses, to
+// prevent a malicious server from causing excessive memory usage. Zero values
+// mean no limit.
+type ResponseLimits struct {
+	// MaxMessageSize is the maximum size of a response message in bytes.
+	MaxMessageSize int
+	// MaxRecords is the maximum number of records in a response (across the
+	// answer, authority and additional sections).
+	MaxRecords int
+	// MaxExpandedSize is the maximum total size in bytes of all the domain
+	// names in a response, once decompressed.
+	MaxExpandedSize int
+	// MaxCompressionPointers is the maximum total number of compression
+	// pointers followed while decompressing the domain names in a response,
+	// bounding the work done for maliciously compressed messages.
+	MaxCompressionPointers int
+	// MaxNameLength is the maximum length in bytes of a single domain name in
+	// a response, once decompressed (in wire format). Names are always limited
+	// to 255 bytes.
+	MaxNameLength int
+}
+
+// exchangeWithConn sends a query over conn and reads the reply, enforcing the
+// response limits before the reply is parsed. If tsigKey is not nil, the query
+// is signed (at the time given by clock) and the reply must carry a valid
+// signature.
+func exchangeWithConn(ctx context.Context, conn net.Conn, req *dns.Msg, limits ResponseLimits, tsigKey *TSIGKey, clock Clock) (reply *dns.Msg, err error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	// Unblock any pending reads or writes as soon as the context is done,
+	// rather than waiting for the deadline.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(aLongTimeAgo)
+	})
+	defer func() {
+		stop()
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	co := &dns.Conn{Conn: conn}
+
+	var requestMAC string
+	if tsigKey != nil {
+		var buf []byte
+		var err error
+		buf, requestMAC, err = tsigKey.signRequest(req, clockOrDefault(clock).Now())
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := co.Write(buf); err != nil {
+			return nil, err
+		}
+	} else if err := co.WriteMsg(req); err != nil {
+		return nil, err
+	}
+
+	_, isPacketConn := conn.(net.PacketConn)
+
+	bufSize := dns.MinMsgSize
+	if opt := req.IsEdns0(); opt != nil && int(opt.UDPSize()) > bufSize {
+		bufSize = int(opt.UDPSize())
+	}
+
+	for {
+		var p []byte
+		var oversized bool
+		if isPacketConn {
+			// One extra byte so that oversized datagrams can be detected.
+			p = make([]byte, bufSize+1)
+			n, err := conn.Read(p)
+			if err != nil {
+				return nil, err
+			}
+			p = p[:n]
+			oversized = n > bufSize
+		} else {
+			var length uint16
+			if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+				return nil, err
+			}
+
+			if limits.MaxMessageSize > 0 && int(length) > limits.MaxMessageSize {
+				return nil, fmt.Errorf("message size %d: %w", length, errLimitExceeded)
+			}
+
+			p = make([]byte, length)
+			if _, err := io.ReadFull(conn, p); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(p) < 2 {
+			return nil, dns.ErrShortRead
+		}
+
+		// Ignore replies with mismatched IDs, as they might be responses to
+		// earlier queries that timed out.
+		if binary.BigEndian.Uint16(p) != req.Id {
+			if isPacketConn {
+				continue
+			}
+			return nil, dns.ErrId
+		}
+
+		// The rest of an oversized datagram was discarded, so treat it as a
+		// truncated reply (and let the truncation policy decide whether to
+		// retry over TCP).
+		if oversized {
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			reply.Truncated = true
+			return reply, nil
+		}
+
+		return unpackReply(p, limits, tsigKey, requestMAC)
+	}
+}
+
+// unpackReply unpacks a reply, enforcing the response limits before it is
+// parsed. If tsigKey is not nil, the reply must carry a valid signature.
+func unpackReply(p []byte, limits ResponseLimits, tsigKey *TSIGKey, requestMAC string) (*dns.Msg, error) {
+	if err := checkLimits(p, limits); err != nil {
+		return nil, err
+	}
+
+	if tsigKey != nil {
+		if err := tsigKey.verifyReply(p, requestMAC); err != nil {
+			return nil, err
+		}
+	}
+
+	reply := &dns.Msg{}
+	if err := reply.Unpack(p); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// checkLimits checks that a message in wire format is within the limits,
+// without fully parsing it.
+func checkLimits(msg []byte, limits ResponseLimits) error {
+	if limits.MaxMessageSize > 0 && len(msg) > limits.MaxMessageSize {
+		return fmt.Errorf("message size %d: %w", len(msg), errLimitExceeded)
+	}
+
+	return dnswire.Check(msg, dnswire.Limits{
+		MaxRecords:      limits.MaxRecords,
+		MaxExpandedSize: limits.MaxExpandedSize,
+		MaxPointers:     limits.MaxCompressionPointers,
+		MaxNameLength:   limits.MaxNameLength,
+	})
+}
+
+func isLimitExceeded(err error) bool {
+	return errors.Is(err, errLimitExceeded) || errors.Is(err, dnswire.ErrLimitExceeded)
+}
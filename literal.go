@@ -11,7 +11,6 @@ package resolver
 
 import (
 	"context"
-	"net"
 	"net/netip"
 
 	"github.com/miekg/dns"
@@ -46,20 +45,23 @@ func (r *literalResolver) LookupNetIP(ctx context.Context, network, host string)
 	}
 
 	if network != "ip" && network != "ip4" && network != "ip6" {
-		return nil, &net.DNSError{
-			Err:  ErrUnsupportedNetwork.Error(),
-			Name: host,
-		}
+		return nil, newError(host, "", ErrUnsupportedNetwork)
+	}
+
+	if len(addrs) == 0 {
+		return nil, newError(host, "", ErrNoSuchHost)
 	}
 
 	addrs = address.FilterByNetwork(addrs, network)
 	if len(addrs) == 0 {
-		return nil, &net.DNSError{
-			Err:        ErrNoSuchHost.Error(),
-			Name:       host,
-			IsNotFound: true,
-		}
+		return nil, newError(host, "", ErrNoData)
 	}
 
+	recordProvenance(ctx, AddrInfo{Source: AddrSourceLiteral}, addrs...)
+
 	return addrs, nil
 }
+
+func (r *literalResolver) describe() Description {
+	return Description{Type: "literal"}
+}
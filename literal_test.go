@@ -44,7 +44,12 @@ func TestLiteralResolver(t *testing.T) {
 
 	t.Run("Domain Name", func(t *testing.T) {
 		_, err := res.LookupNetIP(context.Background(), "ip", "example.com")
-		require.Error(t, err)
+		require.True(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("Wrong Family", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip6", "10.0.0.1")
+		require.True(t, resolver.IsNoData(err))
 	})
 
 	t.Run("Localhost", func(t *testing.T) {
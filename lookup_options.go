@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type lookupTimeoutKey struct{}
+
+// WithLookupTimeout returns a copy of ctx that overrides the per-query timeout
+// of DNS resolvers for lookups made with it (eg. so interactive lookups can
+// fail fast, while background jobs are more patient).
+func WithLookupTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, lookupTimeoutKey{}, timeout)
+}
+
+// LookupTimeout returns the per-query timeout override stored in ctx, if any.
+func LookupTimeout(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(lookupTimeoutKey{}).(time.Duration)
+	return timeout, ok
+}
+
+type lookupAttemptsKey struct{}
+
+// WithLookupAttempts returns a copy of ctx that overrides the number of
+// attempts made by retry resolvers (and by DNS resolvers, for queries sent
+// over UDP) for lookups made with it.
+func WithLookupAttempts(ctx context.Context, attempts int) context.Context {
+	return context.WithValue(ctx, lookupAttemptsKey{}, attempts)
+}
+
+// LookupAttempts returns the number of attempts override stored in ctx, if
+// any.
+func LookupAttempts(ctx context.Context) (int, bool) {
+	attempts, ok := ctx.Value(lookupAttemptsKey{}).(int)
+	return attempts, ok
+}
+
+type ednsOptionsKey struct{}
+
+// EDNSOption is an EDNS(0) option (RFC 6891).
+type EDNSOption struct {
+	// Code is the option code.
+	Code uint16
+	// Data is the option payload.
+	Data []byte
+}
+
+// WithEDNSOptions returns a copy of ctx that attaches the given EDNS(0)
+// options to every query sent by DNS resolvers for lookups made with it (eg.
+// to experiment with new or proprietary options). The options are added to
+// any already attached to ctx. Caching resolvers only share answers between
+// lookups made with the same options.
+func WithEDNSOptions(ctx context.Context, opts ...EDNSOption) context.Context {
+	return context.WithValue(ctx, ednsOptionsKey{}, slices.Concat(LookupEDNSOptions(ctx), opts))
+}
+
+// LookupEDNSOptions returns the EDNS(0) options attached to ctx, if any.
+func LookupEDNSOptions(ctx context.Context) []EDNSOption {
+	opts, _ := ctx.Value(ednsOptionsKey{}).([]EDNSOption)
+	return opts
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupOptions(t *testing.T) {
+	t.Run("Timeout", func(t *testing.T) {
+		// Never reply to queries.
+		server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {}))
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:  server,
+			Timeout: ptr.To(time.Minute),
+		})
+
+		ctx := resolver.WithLookupTimeout(context.Background(), 50*time.Millisecond)
+
+		start := time.Now()
+		_, err := res.LookupNetIP(ctx, "ip4", "www.example")
+		require.ErrorIs(t, err, resolver.ErrTimeout)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("Attempts", func(t *testing.T) {
+		inner := new(dnstest.MockResolver)
+		inner.On("LookupNetIP", mock.Anything, mock.Anything, "example.com").Return([]netip.Addr{}, &net.DNSError{
+			Err:         resolver.ErrServerMisbehaving.Error(),
+			IsTemporary: true,
+		})
+
+		res := resolver.Retry(inner, nil)
+
+		ctx := resolver.WithLookupAttempts(context.Background(), 4)
+
+		_, err := res.LookupNetIP(ctx, "ip", "example.com")
+		require.Error(t, err)
+
+		inner.AssertNumberOfCalls(t, "LookupNetIP", 4)
+
+		attempts, ok := resolver.LookupAttempts(ctx)
+		require.True(t, ok)
+		require.Equal(t, 4, attempts)
+	})
+
+	t.Run("EDNS Options", func(t *testing.T) {
+		received := make(chan []dns.EDNS0, 2)
+		server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			if opt := req.IsEdns0(); opt != nil {
+				received <- opt.Option
+			}
+
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+			_ = w.WriteMsg(reply)
+		}))
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		})
+
+		ctx := resolver.WithEDNSOptions(context.Background(), resolver.EDNSOption{Code: 65001, Data: []byte("a")})
+		ctx = resolver.WithEDNSOptions(ctx, resolver.EDNSOption{Code: 65002, Data: []byte("b")})
+
+		_, err := res.LookupNetIP(ctx, "ip4", "www.example")
+		require.NoError(t, err)
+
+		opts := <-received
+		require.Len(t, opts, 2)
+		require.Equal(t, &dns.EDNS0_LOCAL{Code: 65001, Data: []byte("a")}, opts[0])
+		require.Equal(t, &dns.EDNS0_LOCAL{Code: 65002, Data: []byte("b")}, opts[1])
+
+		require.Len(t, resolver.LookupEDNSOptions(ctx), 2)
+	})
+}
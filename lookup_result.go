@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// AddrSource is where an address returned by a lookup came from.
+type AddrSource string
+
+const (
+	// AddrSourceUnknown is an address of unknown provenance (eg. synthesized
+	// by a resolver that doesn't report it).
+	AddrSourceUnknown AddrSource = "unknown"
+	// AddrSourceLiteral is an IP literal (or localhost).
+	AddrSourceLiteral AddrSource = "literal"
+	// AddrSourceHosts is an address from a hosts file.
+	AddrSourceHosts AddrSource = "hosts"
+	// AddrSourceCache is an address from a cache.
+	AddrSourceCache AddrSource = "cache"
+	// AddrSourceZone is an address from a local zone file.
+	AddrSourceZone AddrSource = "zone"
+	// AddrSourceOS is an address from the operating system's resolver (eg.
+	// getaddrinfo).
+	AddrSourceOS AddrSource = "os"
+	// AddrSourceDNS is an address from a DNS server.
+	AddrSourceDNS AddrSource = "dns"
+)
+
+// AddrInfo is an address returned by a lookup, along with its provenance.
+type AddrInfo struct {
+	// Addr is the address.
+	Addr netip.Addr
+	// Source is where the address came from.
+	Source AddrSource
+	// Server is the DNS server that returned the address.
+	Server netip.AddrPort
+	// Transport is the transport protocol used to query the DNS server.
+	Transport DNSTransport
+	// TTL is the remaining time to live of the address (for addresses from
+	// a DNS server or a cache).
+	TTL time.Duration
+	// RTT is the round trip time of the query that returned the address.
+	RTT time.Duration
+}
+
+// LookupResult is the result of a detailed lookup.
+type LookupResult struct {
+	// Host is the name that was looked up.
+	Host string
+	// Addrs are the addresses, in the order returned by the resolver.
+	Addrs []AddrInfo
+}
+
+// LookupHostDetailed looks up host using resolver, returning the provenance
+// of each address (eg. the hosts file, a cache, or which DNS server).
+func LookupHostDetailed(ctx context.Context, resolver Resolver, network, host string) (*LookupResult, error) {
+	recorder := &provenanceRecorder{
+		infos: make(map[netip.Addr]AddrInfo),
+	}
+
+	addrs, err := resolver.LookupNetIP(context.WithValue(ctx, provenanceRecorderKey{}, recorder), network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LookupResult{
+		Host:  host,
+		Addrs: make([]AddrInfo, 0, len(addrs)),
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for _, addr := range addrs {
+		info, ok := recorder.infos[addr]
+		if !ok {
+			info = AddrInfo{Source: AddrSourceUnknown}
+		}
+		info.Addr = addr
+
+		result.Addrs = append(result.Addrs, info)
+	}
+
+	return result, nil
+}
+
+type provenanceRecorderKey struct{}
+
+type provenanceRecorder struct {
+	mu    sync.Mutex
+	infos map[netip.Addr]AddrInfo
+}
+
+// recordProvenance records where addrs came from, if the lookup is detailed.
+// The first source recorded for an address wins.
+func recordProvenance(ctx context.Context, info AddrInfo, addrs ...netip.Addr) {
+	recorder, ok := ctx.Value(provenanceRecorderKey{}).(*provenanceRecorder)
+	if !ok {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for _, addr := range addrs {
+		if _, ok := recorder.infos[addr]; !ok {
+			recorder.infos[addr] = info
+		}
+	}
+}
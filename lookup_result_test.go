@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupHostDetailed(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		if req.Question[0].Qtype == dns.TypeA {
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	hostsResolver, err := resolver.Hosts(&resolver.HostsResolverConfig{
+		HostsFileReader: strings.NewReader("192.0.2.2 local.example\n"),
+	})
+	require.NoError(t, err)
+
+	res := resolver.Sequential(
+		resolver.Literal(),
+		hostsResolver,
+		resolver.Cache(resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+		}), nil),
+	)
+
+	t.Run("DNS", func(t *testing.T) {
+		result, err := resolver.LookupHostDetailed(context.Background(), res, "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, "www.example", result.Host)
+		require.Len(t, result.Addrs, 1)
+
+		info := result.Addrs[0]
+		require.Equal(t, netip.MustParseAddr("192.0.2.1"), info.Addr)
+		require.Equal(t, resolver.AddrSourceDNS, info.Source)
+		require.Equal(t, server, info.Server)
+		require.Equal(t, resolver.DNSTransportUDP, info.Transport)
+		require.Equal(t, 300*time.Second, info.TTL)
+		require.NotZero(t, info.RTT)
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		result, err := resolver.LookupHostDetailed(context.Background(), res, "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Len(t, result.Addrs, 1)
+		require.Equal(t, resolver.AddrSourceCache, result.Addrs[0].Source)
+		require.LessOrEqual(t, result.Addrs[0].TTL, 300*time.Second)
+	})
+
+	t.Run("Hosts", func(t *testing.T) {
+		result, err := resolver.LookupHostDetailed(context.Background(), res, "ip", "local.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []resolver.AddrInfo{{
+			Addr:   netip.MustParseAddr("192.0.2.2"),
+			Source: resolver.AddrSourceHosts,
+		}}, result.Addrs)
+	})
+
+	t.Run("Literal", func(t *testing.T) {
+		result, err := resolver.LookupHostDetailed(context.Background(), res, "ip", "192.0.2.3")
+		require.NoError(t, err)
+
+		require.Equal(t, []resolver.AddrInfo{{
+			Addr:   netip.MustParseAddr("192.0.2.3"),
+			Source: resolver.AddrSourceLiteral,
+		}}, result.Addrs)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := resolver.LookupHostDetailed(context.Background(), hostsResolver, "ip", "missing.example")
+		require.ErrorIs(t, err, resolver.ErrNoSuchHost)
+	})
+}
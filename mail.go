@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	_ MailResolver = (*net.Resolver)(nil)
+	_ MailResolver = (*ZoneResolver)(nil)
+	_ MailResolver = (*dnsResolver)(nil)
+	_ MailResolver = (*sequentialResolver)(nil)
+	_ MailResolver = (*retryResolver)(nil)
+)
+
+// smtpPort is the port mail exchangers accept mail on.
+const smtpPort = "25"
+
+// MailResolver looks up mail exchangers and their addresses, it is
+// implemented by *net.Resolver, *ZoneResolver and DNS resolvers (including
+// when wrapped by Sequential or Retry).
+type MailResolver interface {
+	Resolver
+	// LookupMX returns the MX records for the given domain.
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// LookupMailHosts returns the hosts that accept mail for domain, as
+// host:port pairs in the order they should be tried (by MX preference).
+//
+// As described in RFC 5321 section 5.1, if domain has no MX records but does
+// have addresses, it is used as an implicit MX. If domain publishes a Null
+// MX (RFC 7505), an error wrapping ErrServiceUnavailable is returned.
+func LookupMailHosts(ctx context.Context, resolver MailResolver, domain string) ([]string, error) {
+	mxs, err := resolver.LookupMX(ctx, domain)
+	if err != nil {
+		// The standard library doesn't distinguish NXDOMAIN from NODATA, if the
+		// domain doesn't exist the address lookup will fail too.
+		if !IsNXDomain(err) && !IsNoData(err) {
+			return nil, err
+		}
+
+		if _, err := resolver.LookupNetIP(ctx, "ip", domain); err != nil {
+			return nil, err
+		}
+
+		return []string{net.JoinHostPort(strings.TrimSuffix(domain, "."), smtpPort)}, nil
+	}
+
+	if isNullMX(mxs) {
+		return nil, newError(domain, "", ErrServiceUnavailable)
+	}
+
+	mxs = slices.Clone(mxs)
+	slices.SortStableFunc(mxs, func(a, b *net.MX) int {
+		return cmp.Compare(a.Pref, b.Pref)
+	})
+
+	var hosts []string
+	for _, mx := range mxs {
+		if mx.Host == "." {
+			continue
+		}
+
+		host := net.JoinHostPort(strings.TrimSuffix(mx.Host, "."), smtpPort)
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts, nil
+}
+
+// isNullMX returns true if mxs is a Null MX record (RFC 7505), indicating the
+// domain does not accept mail.
+func isNullMX(mxs []*net.MX) bool {
+	return len(mxs) == 1 && mxs[0].Host == "."
+}
+
+// MailDomainVerdict is the result of validating a mail domain.
+type MailDomainVerdict string
+
+const (
+	// MailDomainValid is a domain with at least one mail host that has a
+	// publicly routable address.
+	MailDomainValid MailDomainVerdict = "valid"
+	// MailDomainInvalid is a syntactically invalid domain name.
+	MailDomainInvalid MailDomainVerdict = "invalid"
+	// MailDomainNotFound is a domain that does not exist.
+	MailDomainNotFound MailDomainVerdict = "not-found"
+	// MailDomainNullMX is a domain that publishes a Null MX (RFC 7505), to
+	// declare that it does not accept mail.
+	MailDomainNullMX MailDomainVerdict = "null-mx"
+	// MailDomainNoMailHosts is a domain with neither MX nor address records.
+	MailDomainNoMailHosts MailDomainVerdict = "no-mail-hosts"
+	// MailDomainUnroutable is a domain whose mail hosts don't resolve, or only
+	// resolve to addresses that aren't publicly routable (eg. loopback or
+	// private addresses).
+	MailDomainUnroutable MailDomainVerdict = "unroutable"
+)
+
+// ValidateMailDomain checks whether domain can receive mail, eg. to validate
+// the email address entered in a signup form. An error is only returned if
+// no verdict could be reached (eg. due to a timeout), in which case callers
+// should retry later rather than reject the domain.
+//
+// As the standard library doesn't distinguish NXDOMAIN from NODATA, domains
+// without any records are reported as MailDomainNotFound when resolver is a
+// *net.Resolver.
+func ValidateMailDomain(ctx context.Context, resolver MailResolver, domain string) (MailDomainVerdict, error) {
+	if _, ok := dns.IsDomainName(domain); !ok || strings.Trim(domain, ".") == "" {
+		return MailDomainInvalid, nil
+	}
+
+	hosts, err := LookupMailHosts(ctx, resolver, domain)
+	switch {
+	case errors.Is(err, ErrServiceUnavailable):
+		return MailDomainNullMX, nil
+	case IsNXDomain(err):
+		return MailDomainNotFound, nil
+	case IsNoData(err):
+		return MailDomainNoMailHosts, nil
+	case err != nil:
+		return "", err
+	}
+
+	var lookupErr error
+	for _, hostPort := range hosts {
+		host, _, _ := net.SplitHostPort(hostPort)
+
+		addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			if !IsNXDomain(err) && !IsNoData(err) {
+				lookupErr = err
+			}
+			continue
+		}
+
+		if slices.ContainsFunc(addrs, isRoutableAddr) {
+			return MailDomainValid, nil
+		}
+	}
+
+	if lookupErr != nil {
+		return "", lookupErr
+	}
+
+	return MailDomainUnroutable, nil
+}
+
+// isRoutableAddr returns true if addr is a publicly routable unicast address.
+func isRoutableAddr(addr netip.Addr) bool {
+	return addr.Unmap().IsGlobalUnicast() && !isInternalAddr(addr)
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupMailHosts(t *testing.T) {
+	res, err := resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFileReader: strings.NewReader(`$ORIGIN example.
+$TTL 300
+mail      IN MX 20 mx2.example.
+          IN MX 10 mx1.example.
+mx1       IN A  192.0.2.1
+mx2       IN A  192.0.2.2
+implicit  IN A  192.0.2.3
+nomail    IN MX 0 .
+          IN A  192.0.2.4
+noaddrs   IN TXT "hello"
+`),
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("MX", func(t *testing.T) {
+		hosts, err := resolver.LookupMailHosts(ctx, res, "mail.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"mx1.example:25", "mx2.example:25"}, hosts)
+	})
+
+	t.Run("Implicit MX", func(t *testing.T) {
+		hosts, err := resolver.LookupMailHosts(ctx, res, "implicit.example.")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"implicit.example:25"}, hosts)
+	})
+
+	t.Run("Null MX", func(t *testing.T) {
+		_, err := resolver.LookupMailHosts(ctx, res, "nomail.example")
+		require.ErrorIs(t, err, resolver.ErrServiceUnavailable)
+	})
+
+	t.Run("No Addresses", func(t *testing.T) {
+		_, err := resolver.LookupMailHosts(ctx, res, "noaddrs.example")
+		require.True(t, resolver.IsNoData(err))
+	})
+
+	t.Run("No Such Domain", func(t *testing.T) {
+		_, err := resolver.LookupMailHosts(ctx, res, "missing.example")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+}
+
+func TestValidateMailDomain(t *testing.T) {
+	res, err := resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFileReader: strings.NewReader(`$ORIGIN example.
+$TTL 300
+mail      IN MX 10 mx1.example.
+mx1       IN A  192.0.2.1
+implicit  IN A  192.0.2.3
+nomail    IN MX 0 .
+noaddrs   IN TXT "hello"
+internal  IN MX 10 loopback.example.
+          IN MX 20 missing.example.
+loopback  IN A  127.0.0.1
+`),
+	})
+	require.NoError(t, err)
+
+	tests := map[string]resolver.MailDomainVerdict{
+		"mail.example":     resolver.MailDomainValid,
+		"implicit.example": resolver.MailDomainValid,
+		"nomail.example":   resolver.MailDomainNullMX,
+		"noaddrs.example":  resolver.MailDomainNoMailHosts,
+		"missing.example":  resolver.MailDomainNotFound,
+		"internal.example": resolver.MailDomainUnroutable,
+		"bad..example":     resolver.MailDomainInvalid,
+	}
+
+	for domain, expected := range tests {
+		t.Run(domain, func(t *testing.T) {
+			verdict, err := resolver.ValidateMailDomain(context.Background(), res, domain)
+			require.NoError(t, err)
+
+			require.Equal(t, expected, verdict)
+		})
+	}
+}
@@ -0,0 +1,707 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// mdnsPort is the well known mDNS port.
+	mdnsPort = 5353
+	// mdnsMaxMsgSize is the maximum size of an mDNS message (RFC 6762 section 17).
+	mdnsMaxMsgSize = 9000
+	// mdnsCacheFlush is the cache-flush bit of the class of a unique record
+	// (RFC 6762 section 10.2).
+	mdnsCacheFlush = 1 << 15
+	// mdnsUnicastResponse is the unicast-response bit of the class of a
+	// question (RFC 6762 section 5.4).
+	mdnsUnicastResponse = 1 << 15
+	// mdnsLegacyTTL is the maximum TTL of records sent in response to legacy
+	// unicast queries (RFC 6762 section 6.7).
+	mdnsLegacyTTL = 10
+)
+
+var (
+	mdnsIPv4Group = netip.AddrPortFrom(netip.AddrFrom4([4]byte{224, 0, 0, 251}), mdnsPort)
+	mdnsIPv6Group = netip.AddrPortFrom(netip.MustParseAddr("ff02::fb"), mdnsPort)
+)
+
+// ErrNameConflict is returned when serving, if another host on the link is
+// already using the hostname (or another unique name) of an mDNS responder.
+var ErrNameConflict = errors.New("mDNS name conflict")
+
+// MDNSResponderConfig is the configuration for an mDNS responder.
+type MDNSResponderConfig struct {
+	// Hostname is the name to advertise, without the ".local" suffix.
+	// Defaults to the first label of the OS's hostname.
+	Hostname string
+	// Addrs are the addresses to advertise for the hostname. Defaults to the
+	// addresses of the machine's (non-loopback) network interfaces.
+	Addrs []netip.Addr
+	// Interface is the optional name of the network interface to listen on
+	// when using ListenAndServe. Defaults to all of the (non-loopback)
+	// multicast capable interfaces.
+	Interface string
+	// TTL is the TTL of the advertised records. Defaults to 2 minutes
+	// (RFC 6762 section 10).
+	TTL *time.Duration
+	// Announce probes the link for other hosts using the hostname (and the
+	// names of registered services) when serving begins, then sends
+	// unsolicited announcements, and goodbyes when serving ends, so peers
+	// learn of changes promptly. Defaults to true.
+	Announce *bool
+	// Logger is an optional logger, failures to answer queries are logged at
+	// debug level, and conflicts with the names of services registered while
+	// serving at warning level.
+	Logger *slog.Logger
+}
+
+// MDNSResponder is a multicast DNS (RFC 6762) responder that advertises the
+// hostname and addresses of the local machine on ".local", so that peers
+// (eg. in a mesh network) can discover each other without a central DNS
+// server. Each link is only told about the addresses assigned on it.
+//
+// Before claiming its names, the responder probes the link to make sure no
+// other host is using them (RFC 6762 section 8.1). If one is, serving fails
+// with ErrNameConflict, and a different hostname should be chosen.
+type MDNSResponder struct {
+	hostname string
+	iface    string
+	ttl      uint32
+	announce bool
+	logger   *slog.Logger
+	// addrs are the addresses of the address (and reverse mapping) records.
+	addrs   map[dns.RR]netip.Addr
+	mu      sync.RWMutex
+	records []dns.RR
+	conns   map[net.PacketConn]*mdnsConn
+}
+
+// NewMDNSResponder creates a new mDNS responder.
+func NewMDNSResponder(conf *MDNSResponderConfig) (*MDNSResponder, error) {
+	var addrs []netip.Addr
+	if conf != nil {
+		addrs = slices.Clone(conf.Addrs)
+	}
+
+	conf, err := defaults.WithDefaults(conf, &MDNSResponderConfig{
+		TTL:      ptr.To(2 * time.Minute),
+		Announce: ptr.To(true),
+		Logger:   discardLogger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to mDNS responder config: %w", err)
+	}
+
+	hostname := conf.Hostname
+	if hostname == "" {
+		hostname, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %w", err)
+		}
+		hostname, _, _ = strings.Cut(hostname, ".")
+	}
+	hostname = strings.TrimSuffix(strings.TrimSuffix(dns.Fqdn(hostname), ".local."), ".")
+	hostname = dns.CanonicalName(hostname + ".local.")
+
+	if _, ok := dns.IsDomainName(hostname); !ok || dns.CountLabel(hostname) != 2 {
+		return nil, fmt.Errorf("invalid hostname: %q", conf.Hostname)
+	}
+
+	if len(addrs) == 0 {
+		addrs, err = interfaceAddrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get interface addresses: %w", err)
+		}
+	}
+
+	r := &MDNSResponder{
+		hostname: hostname,
+		iface:    conf.Interface,
+		ttl:      uint32(conf.TTL.Seconds()),
+		announce: *conf.Announce,
+		logger:   conf.Logger,
+		addrs:    make(map[dns.RR]netip.Addr),
+		conns:    make(map[net.PacketConn]*mdnsConn),
+	}
+
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+
+		var rr dns.RR
+		hdr := dns.RR_Header{Name: hostname, Class: dns.ClassINET | mdnsCacheFlush, Ttl: r.ttl}
+		if addr.Is4() {
+			hdr.Rrtype = dns.TypeA
+			rr = &dns.A{Hdr: hdr, A: addr.AsSlice()}
+		} else {
+			hdr.Rrtype = dns.TypeAAAA
+			rr = &dns.AAAA{Hdr: hdr, AAAA: addr.AsSlice()}
+		}
+
+		reverse, err := dns.ReverseAddr(addr.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reverse name of %s: %w", addr, err)
+		}
+
+		ptr := &dns.PTR{
+			Hdr: dns.RR_Header{Name: reverse, Rrtype: dns.TypePTR, Class: dns.ClassINET | mdnsCacheFlush, Ttl: r.ttl},
+			Ptr: hostname,
+		}
+
+		r.records = append(r.records, rr, ptr)
+		r.addrs[rr] = addr
+		r.addrs[ptr] = addr
+	}
+
+	return r, nil
+}
+
+// Hostname returns the fully qualified name being advertised, eg. "host.local.".
+func (r *MDNSResponder) Hostname() string {
+	return r.hostname
+}
+
+// ListenAndServe joins the mDNS multicast groups (IPv4 and IPv6), on each of
+// the multicast capable network interfaces (or the configured interface), and
+// answers queries until ctx is canceled.
+func (r *MDNSResponder) ListenAndServe(ctx context.Context) error {
+	ifaces, err := multicastInterfaces(r.iface)
+	if err != nil {
+		return err
+	}
+
+	var conns []*mdnsConn
+	var errs []error
+	for _, group := range []netip.AddrPort{mdnsIPv4Group, mdnsIPv6Group} {
+		c, err := listenMDNS(group, ifaces)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to join %s: %w", group.Addr(), err))
+			continue
+		}
+		conns = append(conns, c)
+	}
+
+	// The host may only have one of IPv4 or IPv6 connectivity.
+	if len(conns) == 0 {
+		return errors.Join(errs...)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, c := range conns {
+		g.Go(func() error {
+			return r.serve(ctx, c)
+		})
+	}
+
+	return g.Wait()
+}
+
+// Serve answers queries received on pc until ctx is canceled, multicast
+// responses are sent to the mDNS group of the same address family as pc.
+// The connection is closed when Serve returns.
+func (r *MDNSResponder) Serve(ctx context.Context, pc net.PacketConn) error {
+	return r.serve(ctx, newMDNSConn(pc, nil))
+}
+
+func (r *MDNSResponder) serve(ctx context.Context, c *mdnsConn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.ctx = ctx
+
+	// Keep track of the connection, so that services registered while
+	// serving can be announced. Probing starts before any messages are read,
+	// so that conflicting responses aren't missed.
+	var p *mdnsProbe
+	r.mu.Lock()
+	r.conns[c.pc] = c
+	if r.announce {
+		p = r.startProbe(c, r.records)
+	}
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, c.pc)
+		r.mu.Unlock()
+	}()
+
+	// Set if another host is using one of our names.
+	var conflictErr error
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var claimed bool
+		if r.announce {
+			err := r.probe(ctx, c, p)
+			if errors.Is(err, ErrNameConflict) {
+				conflictErr = err
+				_ = c.pc.Close()
+				return
+			}
+			claimed = err == nil
+
+			// Announce twice, one second apart (RFC 6762 section 8.3).
+			for i := 0; i < 2 && claimed; i++ {
+				r.advertise(c, r.snapshot(), false)
+				if err := sleep(ctx, time.Second); err != nil {
+					break
+				}
+			}
+		}
+
+		<-ctx.Done()
+
+		if claimed {
+			r.advertise(c, r.snapshot(), true)
+		}
+
+		_ = c.pc.Close()
+	}()
+
+	buf := make([]byte, mdnsMaxMsgSize)
+	for {
+		n, ifIndex, from, err := c.readFrom(buf)
+		if err != nil {
+			canceled := ctx.Err() != nil
+			cancel()
+			<-done
+
+			if conflictErr != nil {
+				return conflictErr
+			}
+			if canceled && errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to read query: %w", err)
+		}
+
+		var req dns.Msg
+		if err := req.Unpack(buf[:n]); err != nil {
+			r.logger.Debug("Failed to unpack query",
+				slog.String("remote", from.String()), slog.Any("error", err))
+			continue
+		}
+
+		r.observe(c, &req)
+
+		legacy := true
+		if addr, ok := from.(*net.UDPAddr); ok && addr.Port == mdnsPort {
+			legacy = false
+		}
+
+		reply, unicast := r.answer(c, &req, legacy, r.linkFilter(ifIndex))
+		if reply == nil {
+			continue
+		}
+
+		to := net.Addr(c.group)
+		if legacy || unicast {
+			to = from
+		}
+
+		r.send(c, ifIndex, to, reply)
+	}
+}
+
+// answer answers an mDNS query, it returns nil if there is nothing to answer.
+// Only the records for which onLink returns true are included, and names
+// being probed for on the connection are not answered for. Responses are
+// requested to be sent via unicast if all of the answered questions had the
+// unicast-response bit set.
+func (r *MDNSResponder) answer(c *mdnsConn, req *dns.Msg, legacy bool, onLink func(dns.RR) bool) (*dns.Msg, bool) {
+	// Responses, and messages with unknown opcodes or rcodes, are silently
+	// ignored (RFC 6762 section 18).
+	if req.Response || req.Opcode != dns.OpcodeQuery || req.Rcode != dns.RcodeSuccess {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	visible := func(rr dns.RR) bool {
+		return onLink(rr) && !c.probing(rr.Header().Name)
+	}
+
+	reply := &dns.Msg{}
+	reply.Response = true
+	reply.Authoritative = true
+
+	unicast := true
+	for _, q := range req.Question {
+		class := q.Qclass &^ mdnsUnicastResponse
+		if class != dns.ClassINET && class != dns.ClassANY {
+			continue
+		}
+
+		var matched []dns.RR
+		var owned bool
+		for _, rr := range r.records {
+			if !visible(rr) || !strings.EqualFold(rr.Header().Name, q.Name) {
+				continue
+			}
+			owned = true
+
+			if q.Qtype == dns.TypeANY || q.Qtype == rr.Header().Rrtype {
+				matched = append(matched, rr)
+			}
+		}
+
+		if len(matched) == 0 {
+			if owned {
+				// Assert that the requested type doesn't exist (RFC 6762 section 6.1).
+				reply.Extra = append(reply.Extra, r.nsec(q.Name, visible))
+			}
+			continue
+		}
+
+		answers := suppressKnownAnswers(matched, req.Answer)
+		reply.Answer = append(reply.Answer, answers...)
+
+		if len(answers) > 0 && q.Qclass&mdnsUnicastResponse == 0 {
+			unicast = false
+		}
+	}
+
+	if len(reply.Answer) == 0 && len(reply.Extra) == 0 {
+		return nil, false
+	}
+
+	r.additional(reply, visible)
+
+	if legacy {
+		// Legacy unicast responses must look like regular DNS responses
+		// (RFC 6762 section 6.7).
+		reply.Id = req.Id
+		reply.Question = req.Question
+		reply.Answer = legacyRecords(reply.Answer)
+		reply.Extra = legacyRecords(reply.Extra)
+	}
+
+	return reply, unicast
+}
+
+// linkFilter returns a function reporting whether a record belongs on the
+// link of the interface with the given index. Address (and reverse mapping)
+// records only belong on the link their address is assigned on (RFC 6762
+// section 15.1). If the interface is unknown, or none of the advertised
+// addresses are assigned on it (eg. they were configured explicitly), all of
+// the records belong on it.
+func (r *MDNSResponder) linkFilter(ifIndex int) func(dns.RR) bool {
+	all := func(dns.RR) bool { return true }
+
+	if ifIndex == 0 {
+		return all
+	}
+
+	ifi, err := net.InterfaceByIndex(ifIndex)
+	if err != nil {
+		return all
+	}
+
+	linkAddrs, err := addrsOf(ifi)
+	if err != nil {
+		return all
+	}
+
+	var advertised bool
+	for _, addr := range r.addrs {
+		if slices.Contains(linkAddrs, addr) {
+			advertised = true
+			break
+		}
+	}
+	if !advertised {
+		return all
+	}
+
+	return func(rr dns.RR) bool {
+		addr, ok := r.addrs[rr]
+		return !ok || slices.Contains(linkAddrs, addr)
+	}
+}
+
+// additional adds the records a querier is likely to need next to the
+// additional section of reply, to save it another query. That is the SRV and
+// TXT records of service instances (RFC 6763 section 12), and the addresses
+// of hosts (RFC 6762 section 6.2). Only records for which visible returns
+// true are added.
+func (r *MDNSResponder) additional(reply *dns.Msg, visible func(dns.RR) bool) {
+	// Records added along the way are themselves processed, eg. the target
+	// of an SRV record added for a PTR answer.
+	pending := slices.Clone(reply.Answer)
+
+	include := func(name string, types ...uint16) {
+		for _, rr := range r.records {
+			if visible(rr) && strings.EqualFold(rr.Header().Name, name) && slices.Contains(types, rr.Header().Rrtype) &&
+				!slices.Contains(reply.Answer, rr) && !slices.Contains(reply.Extra, rr) {
+				reply.Extra = append(reply.Extra, rr)
+				pending = append(pending, rr)
+			}
+		}
+	}
+
+	for len(pending) > 0 {
+		rr := pending[0]
+		pending = pending[1:]
+
+		switch rr := rr.(type) {
+		case *dns.PTR:
+			include(rr.Ptr, dns.TypeSRV, dns.TypeTXT)
+		case *dns.SRV:
+			include(rr.Target, dns.TypeA, dns.TypeAAAA)
+		case *dns.A, *dns.AAAA:
+			include(rr.Header().Name, dns.TypeA, dns.TypeAAAA)
+		}
+	}
+}
+
+// nsec returns a negative response asserting the record types that exist
+// for name (RFC 6762 section 6.1), of the records for which visible returns
+// true.
+func (r *MDNSResponder) nsec(name string, visible func(dns.RR) bool) *dns.NSEC {
+	var types []uint16
+	for _, rr := range r.records {
+		if visible(rr) && strings.EqualFold(rr.Header().Name, name) && !slices.Contains(types, rr.Header().Rrtype) {
+			types = append(types, rr.Header().Rrtype)
+		}
+	}
+	slices.Sort(types)
+
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET | mdnsCacheFlush, Ttl: r.ttl},
+		NextDomain: name,
+		TypeBitMap: types,
+	}
+}
+
+// snapshot returns a copy of the advertised records.
+func (r *MDNSResponder) snapshot() []dns.RR {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.records)
+}
+
+// unsolicited returns an unsolicited response containing records, if goodbye
+// is true the records have a TTL of zero so that peers remove them from their
+// caches (RFC 6762 section 10.1).
+func unsolicited(rrs []dns.RR, goodbye bool) *dns.Msg {
+	msg := &dns.Msg{}
+	msg.Response = true
+	msg.Authoritative = true
+
+	for _, rr := range rrs {
+		if goodbye {
+			rr = dns.Copy(rr)
+			rr.Header().Ttl = 0
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	return msg
+}
+
+// advertise multicasts an unsolicited response containing records on c (see
+// unsolicited).
+func (r *MDNSResponder) advertise(c *mdnsConn, rrs []dns.RR, goodbye bool) {
+	r.multicast(c, rrs, func(rrs []dns.RR) *dns.Msg {
+		return unsolicited(rrs, goodbye)
+	})
+}
+
+// multicast sends the message built from records to the mDNS group. If the
+// interfaces of the connection are known, a message is sent on each of them
+// containing only the records that belong on its link.
+func (r *MDNSResponder) multicast(c *mdnsConn, rrs []dns.RR, build func(rrs []dns.RR) *dns.Msg) {
+	if len(c.ifaces) == 0 {
+		if len(rrs) > 0 {
+			r.send(c, 0, c.group, build(rrs))
+		}
+		return
+	}
+
+	for _, ifi := range c.ifaces {
+		onLink := r.linkFilter(ifi.Index)
+
+		var linkRecords []dns.RR
+		for _, rr := range rrs {
+			if onLink(rr) {
+				linkRecords = append(linkRecords, rr)
+			}
+		}
+
+		if len(linkRecords) > 0 {
+			r.send(c, ifi.Index, c.group, build(linkRecords))
+		}
+	}
+}
+
+// send sends msg to the given address, multicast messages are sent on the
+// interface with the given index (if not zero).
+func (r *MDNSResponder) send(c *mdnsConn, ifIndex int, to net.Addr, msg *dns.Msg) {
+	msg.Compress = true
+
+	buf, err := msg.Pack()
+	if err != nil {
+		r.logger.Debug("Failed to pack response", slog.Any("error", err))
+		return
+	}
+
+	if err := c.writeTo(buf, ifIndex, to); err != nil {
+		r.logger.Debug("Failed to send response",
+			slog.String("remote", to.String()), slog.Any("error", err))
+	}
+}
+
+// knownAnswer returns true if the querier already knows about rr, with at
+// least half of its TTL remaining.
+func knownAnswer(known []dns.RR, rr dns.RR) bool {
+	for _, k := range known {
+		if dns.IsDuplicate(withoutCacheFlush(k), withoutCacheFlush(rr)) && k.Header().Ttl >= rr.Header().Ttl/2 {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressKnownAnswers returns the records the querier doesn't already know
+// about (RFC 6762 section 7.1). Records of a unique record set are only
+// suppressed if the whole set is known, as the cache-flush bit of the records
+// that are sent would cause the querier to flush the others (RFC 6762
+// section 10.2).
+func suppressKnownAnswers(rrs, known []dns.RR) []dns.RR {
+	var answers []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Class&mdnsCacheFlush == 0 {
+			if !knownAnswer(known, rr) {
+				answers = append(answers, rr)
+			}
+			continue
+		}
+
+		setKnown := !slices.ContainsFunc(rrs, func(other dns.RR) bool {
+			return other.Header().Rrtype == rr.Header().Rrtype &&
+				strings.EqualFold(other.Header().Name, rr.Header().Name) &&
+				!knownAnswer(known, other)
+		})
+		if !setKnown {
+			answers = append(answers, rr)
+		}
+	}
+	return answers
+}
+
+// legacyRecords returns copies of records suitable for a legacy unicast
+// response, ie. without the cache-flush bit and with a capped TTL.
+func legacyRecords(rrs []dns.RR) []dns.RR {
+	legacy := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		rr = withoutCacheFlush(rr)
+		rr.Header().Ttl = min(rr.Header().Ttl, mdnsLegacyTTL)
+		legacy = append(legacy, rr)
+	}
+	return legacy
+}
+
+func withoutCacheFlush(rr dns.RR) dns.RR {
+	rr = dns.Copy(rr)
+	rr.Header().Class &^= mdnsCacheFlush
+	return rr
+}
+
+// interfaceAddrs returns the addresses of the machine's network interfaces
+// that are up, excluding loopback interfaces.
+func interfaceAddrs() ([]netip.Addr, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		ifaceAddrs, err := addrsOf(&iface)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, ifaceAddrs...)
+	}
+
+	return addrs, nil
+}
+
+// addrsOf returns the addresses assigned to a network interface.
+func addrsOf(iface *net.Interface) ([]netip.Addr, error) {
+	ifaceAddrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	for _, ifaceAddr := range ifaceAddrs {
+		if prefix, err := netip.ParsePrefix(ifaceAddr.String()); err == nil {
+			addrs = append(addrs, prefix.Addr().Unmap())
+		}
+	}
+
+	return addrs, nil
+}
+
+// multicastInterfaces returns the network interface with the given name, or
+// if name is empty, the (non-loopback) interfaces that are up and support
+// multicast.
+func multicastInterfaces(name string) ([]net.Interface, error) {
+	if name != "" {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get interface %s: %w", name, err)
+		}
+		return []net.Interface{*ifi}, nil
+	}
+
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interfaces: %w", err)
+	}
+
+	var ifaces []net.Interface
+	for _, ifi := range all {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 && ifi.Flags&net.FlagLoopback == 0 {
+			ifaces = append(ifaces, ifi)
+		}
+	}
+
+	if len(ifaces) == 0 {
+		return nil, errors.New("no multicast capable interfaces")
+	}
+
+	return ifaces, nil
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// mdnsConn is a connection that an mDNS responder is serving.
+type mdnsConn struct {
+	pc    net.PacketConn
+	group *net.UDPAddr
+	// ifaces are the interfaces the group was joined on, nil if they are not
+	// known (ie. the connection was passed to Serve).
+	ifaces []net.Interface
+	// Used to learn the interface messages were received on, and to send
+	// multicast messages on a given interface, nil if not supported.
+	ipv4 *ipv4.PacketConn
+	ipv6 *ipv6.PacketConn
+	// ctx is canceled when serving the connection ends.
+	ctx context.Context
+	// probes are the probes in progress on the connection, guarded by the
+	// mutex of the responder.
+	probes []*mdnsProbe
+}
+
+func newMDNSConn(pc net.PacketConn, ifaces []net.Interface) *mdnsConn {
+	c := &mdnsConn{
+		pc:     pc,
+		group:  net.UDPAddrFromAddrPort(mdnsIPv4Group),
+		ifaces: ifaces,
+	}
+
+	udpConn, ok := pc.(*net.UDPConn)
+	if addr, isUDP := pc.LocalAddr().(*net.UDPAddr); isUDP && addr.AddrPort().Addr().Unmap().Is6() {
+		c.group = net.UDPAddrFromAddrPort(mdnsIPv6Group)
+
+		if ok {
+			p := ipv6.NewPacketConn(udpConn)
+			if err := p.SetControlMessage(ipv6.FlagInterface, true); err == nil {
+				c.ipv6 = p
+			}
+		}
+	} else if ok {
+		p := ipv4.NewPacketConn(udpConn)
+		if err := p.SetControlMessage(ipv4.FlagInterface, true); err == nil {
+			c.ipv4 = p
+		}
+	}
+
+	return c
+}
+
+// listenMDNS listens for mDNS messages sent to group, joining it on each of
+// the interfaces. Interfaces the group can't be joined on (eg. as they don't
+// have an address of its family) are skipped.
+func listenMDNS(group netip.AddrPort, ifaces []net.Interface) (*mdnsConn, error) {
+	network := "udp4"
+	if group.Addr().Is6() {
+		network = "udp6"
+	}
+	groupAddr := net.UDPAddrFromAddrPort(group)
+
+	var pc *net.UDPConn
+	var joined []net.Interface
+	var errs []error
+	for _, ifi := range ifaces {
+		var err error
+		switch {
+		case pc == nil:
+			pc, err = net.ListenMulticastUDP(network, &ifi, groupAddr)
+		case group.Addr().Is4():
+			err = ipv4.NewPacketConn(pc).JoinGroup(&ifi, groupAddr)
+		default:
+			err = ipv6.NewPacketConn(pc).JoinGroup(&ifi, groupAddr)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ifi.Name, err))
+			continue
+		}
+
+		joined = append(joined, ifi)
+	}
+
+	if pc == nil {
+		return nil, errors.Join(errs...)
+	}
+
+	return newMDNSConn(pc, joined), nil
+}
+
+// readFrom reads a message, along with the index of the interface it was
+// received on (zero if unknown).
+func (c *mdnsConn) readFrom(buf []byte) (int, int, net.Addr, error) {
+	switch {
+	case c.ipv4 != nil:
+		n, cm, from, err := c.ipv4.ReadFrom(buf)
+		if cm == nil {
+			return n, 0, from, err
+		}
+		return n, cm.IfIndex, from, err
+	case c.ipv6 != nil:
+		n, cm, from, err := c.ipv6.ReadFrom(buf)
+		if cm == nil {
+			return n, 0, from, err
+		}
+		return n, cm.IfIndex, from, err
+	default:
+		n, from, err := c.pc.ReadFrom(buf)
+		return n, 0, from, err
+	}
+}
+
+// writeTo sends a message, multicast messages are sent on the interface with
+// the given index (if not zero).
+func (c *mdnsConn) writeTo(buf []byte, ifIndex int, to net.Addr) error {
+	var multicast bool
+	if addr, ok := to.(*net.UDPAddr); ok {
+		multicast = addr.IP.IsMulticast()
+	}
+
+	var err error
+	switch {
+	case multicast && ifIndex != 0 && c.ipv4 != nil:
+		_, err = c.ipv4.WriteTo(buf, &ipv4.ControlMessage{IfIndex: ifIndex}, to)
+	case multicast && ifIndex != 0 && c.ipv6 != nil:
+		_, err = c.ipv6.WriteTo(buf, &ipv6.ControlMessage{IfIndex: ifIndex}, to)
+	default:
+		_, err = c.pc.WriteTo(buf, to)
+	}
+	return err
+}
+
+// probing returns true if name is being probed for on the connection, the
+// mutex of the responder must be held.
+func (c *mdnsConn) probing(name string) bool {
+	for _, p := range c.probes {
+		for _, probed := range p.names {
+			if strings.EqualFold(probed, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
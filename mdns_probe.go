@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// mdnsProbes is the number of probes sent before claiming a name, and
+	// mdnsProbeInterval the interval between them (RFC 6762 section 8.1).
+	mdnsProbes        = 3
+	mdnsProbeInterval = 250 * time.Millisecond
+)
+
+// mdnsProbe is a probe for the unique names of a set of proposed records.
+type mdnsProbe struct {
+	names   []string
+	records []dns.RR
+	// conflict receives a name that another host is using.
+	conflict chan string
+	// lost is signaled when a simultaneous probe from another host wins the
+	// tie-break (RFC 6762 section 8.2).
+	lost chan struct{}
+}
+
+// startProbe starts probing for the unique names of records on c, until the
+// probe is run (see probe) queries for the names are not answered. It returns
+// nil if there are no unique names, r.mu must be held.
+func (r *MDNSResponder) startProbe(c *mdnsConn, rrs []dns.RR) *mdnsProbe {
+	p := &mdnsProbe{
+		names:    uniqueNames(rrs),
+		records:  slices.Clone(rrs),
+		conflict: make(chan string, 1),
+		lost:     make(chan struct{}, 1),
+	}
+	if len(p.names) == 0 {
+		return nil
+	}
+
+	c.probes = append(c.probes, p)
+
+	return p
+}
+
+// probe makes sure that no other host on the link of c is using the names
+// of a started probe, before they are claimed (RFC 6762 section 8.1).
+// ErrNameConflict is returned if another host is using one of the names.
+func (r *MDNSResponder) probe(ctx context.Context, c *mdnsConn, p *mdnsProbe) error {
+	if p == nil {
+		return nil
+	}
+
+	defer func() {
+		r.mu.Lock()
+		c.probes = slices.DeleteFunc(c.probes, func(other *mdnsProbe) bool {
+			return other == p
+		})
+		r.mu.Unlock()
+	}()
+
+	// A random delay before the first probe, so that hosts starting at the
+	// same time don't probe simultaneously.
+	delay := time.Duration(rand.Int64N(int64(mdnsProbeInterval)))
+
+	var sent int
+	for {
+		t := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case name := <-p.conflict:
+			t.Stop()
+			return fmt.Errorf("%w: %s", ErrNameConflict, name)
+		case <-p.lost:
+			t.Stop()
+			// Probe again later, by which time the other host will have
+			// claimed the names (RFC 6762 section 8.2).
+			sent = 0
+			delay = time.Second
+			continue
+		case <-t.C:
+		}
+
+		// No conflicting responses were received to the last probe.
+		if sent == mdnsProbes {
+			return nil
+		}
+
+		r.multicast(c, p.records, func(rrs []dns.RR) *dns.Msg {
+			return probeQuery(rrs, sent == 0)
+		})
+		sent++
+		delay = mdnsProbeInterval
+	}
+}
+
+// probeQuery returns a probe query for the unique names of records, with the
+// records proposed for them in the authority section (RFC 6762 section 8.2).
+// The first probe asks for responses to be sent via unicast.
+func probeQuery(rrs []dns.RR, first bool) *dns.Msg {
+	class := uint16(dns.ClassINET)
+	if first {
+		class |= mdnsUnicastResponse
+	}
+
+	msg := &dns.Msg{}
+	for _, name := range uniqueNames(rrs) {
+		msg.Question = append(msg.Question, dns.Question{Name: name, Qtype: dns.TypeANY, Qclass: class})
+
+		for _, rr := range rrs {
+			if strings.EqualFold(rr.Header().Name, name) {
+				// The cache-flush bit is only meaningful in responses.
+				msg.Ns = append(msg.Ns, withoutCacheFlush(rr))
+			}
+		}
+	}
+
+	return msg
+}
+
+// observe checks a message received on c for conflicts with the names being
+// probed for on it.
+func (r *MDNSResponder) observe(c *mdnsConn, msg *dns.Msg) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range c.probes {
+		if msg.Response {
+			if name, ok := r.conflicting(p, slices.Concat(msg.Answer, msg.Extra)); ok {
+				select {
+				case p.conflict <- name:
+				default:
+				}
+			}
+		} else if len(msg.Ns) > 0 && p.losesTieBreak(msg.Ns) {
+			select {
+			case p.lost <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// conflicting returns a name being probed for that records from another
+// host's response are using, r.mu must be held.
+func (r *MDNSResponder) conflicting(p *mdnsProbe, rrs []dns.RR) (string, bool) {
+	for _, rr := range rrs {
+		// Negative responses don't claim the name.
+		if rr.Header().Rrtype == dns.TypeNSEC {
+			continue
+		}
+
+		name := dns.CanonicalName(rr.Header().Name)
+		if !slices.Contains(p.names, name) {
+			continue
+		}
+
+		// Our own records (eg. echoed by another responder's cache).
+		ours := slices.ContainsFunc(r.records, func(own dns.RR) bool {
+			return dns.IsDuplicate(withoutCacheFlush(own), withoutCacheFlush(rr))
+		})
+		if !ours {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// losesTieBreak returns true if the records proposed by a simultaneous probe
+// from another host, for any of the names being probed for, are
+// lexicographically later than ours (RFC 6762 section 8.2).
+func (p *mdnsProbe) losesTieBreak(proposed []dns.RR) bool {
+	for _, name := range p.names {
+		theirs := recordsNamed(proposed, name)
+		if len(theirs) > 0 && compareProposed(recordsNamed(p.records, name), theirs) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compareProposed compares two sets of proposed records, by comparing their
+// records in order of class, type, and data (RFC 6762 section 8.2.1).
+func compareProposed(a, b []dns.RR) int {
+	a, b = sortProposed(a), sortProposed(b)
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareProposedRecord(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+func sortProposed(rrs []dns.RR) []dns.RR {
+	rrs = slices.Clone(rrs)
+	slices.SortFunc(rrs, compareProposedRecord)
+	return rrs
+}
+
+func compareProposedRecord(a, b dns.RR) int {
+	return cmp.Or(
+		cmp.Compare(a.Header().Class&^mdnsCacheFlush, b.Header().Class&^mdnsCacheFlush),
+		cmp.Compare(a.Header().Rrtype, b.Header().Rrtype),
+		bytes.Compare(rdata(a), rdata(b)),
+	)
+}
+
+// rdata returns the uncompressed wire format of the data of rr.
+func rdata(rr dns.RR) []byte {
+	buf := make([]byte, dns.Len(rr))
+	off, err := dns.PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+
+	name := make([]byte, 256)
+	nameLen, err := dns.PackDomainName(rr.Header().Name, name, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+
+	// The data follows the name, type, class, TTL, and data length.
+	return buf[nameLen+10 : off]
+}
+
+// recordsNamed returns the records with the given name.
+func recordsNamed(rrs []dns.RR, name string) []dns.RR {
+	var named []dns.RR
+	for _, rr := range rrs {
+		if strings.EqualFold(rr.Header().Name, name) {
+			named = append(named, rr)
+		}
+	}
+	return named
+}
+
+// uniqueNames returns the (canonical) names of the unique records, ie. those
+// with the cache-flush bit set.
+func uniqueNames(rrs []dns.RR) []string {
+	var names []string
+	for _, rr := range rrs {
+		name := dns.CanonicalName(rr.Header().Name)
+		if rr.Header().Class&mdnsCacheFlush != 0 && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// claim runs a probe for the unique names of records added while serving c,
+// and announces them if no other host is using them. Otherwise, the records
+// are withdrawn.
+func (r *MDNSResponder) claim(c *mdnsConn, p *mdnsProbe, rrs []dns.RR) {
+	err := r.probe(c.ctx, c, p)
+	if err == nil {
+		r.advertise(c, rrs, false)
+		return
+	}
+
+	if errors.Is(err, ErrNameConflict) {
+		r.logger.Warn("Withdrawing records with conflicting names", slog.Any("error", err))
+
+		r.mu.Lock()
+		for _, name := range uniqueNames(rrs) {
+			r.remove(name)
+		}
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/address"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+	"golang.org/x/net/ipv4"
+)
+
+var (
+	_ Resolver     = (*mdnsResolver)(nil)
+	_ AddrResolver = (*mdnsResolver)(nil)
+)
+
+// MDNSResolverConfig is the configuration for an mDNS resolver.
+type MDNSResolverConfig struct {
+	// Interface is the optional name of the network interface queries are
+	// sent from. It is required to query the IPv6 mDNS group.
+	Interface string
+	// Groups are the addresses queries are sent to. Defaults to the IPv4
+	// mDNS group (and the IPv6 group, if an interface is configured).
+	Groups []netip.AddrPort
+	// Timeout is how long to wait for an answer, as mDNS has no negative
+	// answers, lookups for names that don't exist always take this long.
+	// Defaults to 1 second.
+	Timeout *time.Duration
+	// Logger is an optional logger, unexpected replies are logged at debug
+	// level.
+	Logger *slog.Logger
+}
+
+// mdnsResolver is a resolver that looks up ".local" names (and the names of
+// link-local addresses) using one-shot multicast DNS queries (RFC 6762
+// section 5.1).
+type mdnsResolver struct {
+	iface   string
+	groups  []netip.AddrPort
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+// MDNS returns a resolver that looks up ".local" names using multicast DNS.
+// Queries are sent from an ephemeral port, so responders answer directly
+// (legacy unicast), and so no mDNS responder or cache is required on the
+// local machine.
+func MDNS(conf *MDNSResolverConfig) *mdnsResolver {
+	var groups []netip.AddrPort
+	if conf != nil {
+		groups = conf.Groups
+	}
+
+	conf, err := defaults.WithDefaults(conf, &MDNSResolverConfig{
+		Timeout: ptr.To(time.Second),
+		Logger:  discardLogger,
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	if len(groups) == 0 {
+		groups = []netip.AddrPort{mdnsIPv4Group}
+		if conf.Interface != "" {
+			groups = append(groups, netip.AddrPortFrom(mdnsIPv6Group.Addr().WithZone(conf.Interface), mdnsPort))
+		}
+	}
+
+	return &mdnsResolver{
+		iface:   conf.Interface,
+		groups:  groups,
+		timeout: *conf.Timeout,
+		logger:  conf.Logger,
+	}
+}
+
+func (r *mdnsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	var qTypes []uint16
+	switch network {
+	case "ip":
+		qTypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	case "ip4":
+		qTypes = []uint16{dns.TypeA}
+	case "ip6":
+		qTypes = []uint16{dns.TypeAAAA}
+	default:
+		return nil, newError(host, "", ErrUnsupportedNetwork)
+	}
+
+	name := dns.CanonicalName(host)
+	if !dns.IsSubDomain("local.", name) {
+		return nil, newError(host, "", ErrNoSuchHost)
+	}
+
+	var addrs []netip.Addr
+	err := r.query(ctx, name, qTypes, func(reply *dns.Msg) bool {
+		// Responders usually include the other address family in the
+		// additional section (RFC 6762 section 6.2).
+		rrs := slices.Concat(reply.Answer, reply.Extra)
+		addrs = address.FilterByNetwork(dedupAddrs(addrsFromAnswers(rrs, name)), network)
+		return len(addrs) > 0
+	})
+	if err != nil {
+		return nil, newError(host, "", err)
+	}
+
+	return addrs, nil
+}
+
+// LookupAddr returns the names of a link-local address, as advertised by the
+// host using it.
+func (r *mdnsResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, newError(addr, "", fmt.Errorf("%w: %w", ErrNoSuchHost, err))
+	}
+
+	var names []string
+	err = r.query(ctx, reverse, []uint16{dns.TypePTR}, func(reply *dns.Msg) bool {
+		names = ptrsFromAnswers(reply.Answer, reverse)
+		return len(names) > 0
+	})
+	if err != nil {
+		return nil, newError(addr, "", err)
+	}
+
+	return names, nil
+}
+
+func (r *mdnsResolver) describe() Description {
+	groups := make([]string, 0, len(r.groups))
+	for _, group := range r.groups {
+		groups = append(groups, group.String())
+	}
+
+	return Description{
+		Type:       "mdns",
+		Attributes: map[string]string{"groups": strings.Join(groups, ",")},
+	}
+}
+
+// query sends a one-shot query for each of qTypes to every group, and waits
+// until accept returns true for a reply (or the timeout elapses).
+func (r *mdnsResolver) query(ctx context.Context, name string, qTypes []uint16, accept func(*dns.Msg) bool) error {
+	queryCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var ifi *net.Interface
+	if r.iface != "" {
+		var err error
+		ifi, err = net.InterfaceByName(r.iface)
+		if err != nil {
+			return fmt.Errorf("failed to get interface %q: %w", r.iface, err)
+		}
+	}
+
+	replies := make(chan *dns.Msg)
+
+	var errs []error
+	var sent int
+	for _, group := range r.groups {
+		network := "udp4"
+		if group.Addr().Is6() {
+			network = "udp6"
+		}
+
+		pc, err := net.ListenUDP(network, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		defer pc.Close()
+
+		if ifi != nil && group.Addr().Is4() {
+			if err := ipv4.NewPacketConn(pc).SetMulticastInterface(ifi); err != nil {
+				errs = append(errs, fmt.Errorf("failed to set multicast interface: %w", err))
+				continue
+			}
+		}
+
+		var failed bool
+		for _, qType := range qTypes {
+			req := &dns.Msg{}
+			req.SetQuestion(name, qType)
+			req.RecursionDesired = false
+
+			msg, err := req.Pack()
+			if err != nil {
+				return fmt.Errorf("failed to pack query: %w", err)
+			}
+
+			if _, err := pc.WriteToUDPAddrPort(msg, group); err != nil {
+				errs = append(errs, fmt.Errorf("failed to send query to %s: %w", group, err))
+				failed = true
+				break
+			}
+		}
+		if failed {
+			continue
+		}
+		sent++
+
+		go r.readReplies(queryCtx, pc, replies)
+	}
+
+	// The host may only have one of IPv4 or IPv6 connectivity.
+	if sent == 0 {
+		return errors.Join(errs...)
+	}
+
+	for {
+		select {
+		case <-queryCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Nobody answered, mDNS has no negative answers.
+			return ErrNoSuchHost
+		case reply := <-replies:
+			if accept(reply) {
+				return nil
+			}
+		}
+	}
+}
+
+// readReplies reads replies from pc until ctx is done (or pc is closed).
+func (r *mdnsResolver) readReplies(ctx context.Context, pc *net.UDPConn, replies chan<- *dns.Msg) {
+	buf := make([]byte, mdnsMaxMsgSize)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var reply dns.Msg
+		if err := reply.Unpack(buf[:n]); err != nil {
+			r.logger.Debug("Failed to unpack reply",
+				slog.String("remote", from.String()), slog.Any("error", err))
+			continue
+		}
+
+		if !reply.Response || reply.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		select {
+		case replies <- &reply:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+// startMDNSResponder starts an mDNS responder for "node1.local" on a
+// loopback port, it returns the address of the responder.
+func startMDNSResponder(t *testing.T) netip.AddrPort {
+	responder, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+		Hostname: "node1",
+		Addrs:    []netip.Addr{netip.MustParseAddr("169.254.0.1"), netip.MustParseAddr("fe80::1")},
+		Announce: ptr.To(false),
+	})
+	require.NoError(t, err)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- responder.Serve(ctx, pc)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	return pc.LocalAddr().(*net.UDPAddr).AddrPort()
+}
+
+func TestMDNSResolver(t *testing.T) {
+	res := resolver.MDNS(&resolver.MDNSResolverConfig{
+		Groups:  []netip.AddrPort{startMDNSResponder(t)},
+		Timeout: ptr.To(200 * time.Millisecond),
+	})
+
+	ctx := context.Background()
+
+	t.Run("LookupNetIP", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(ctx, "ip", "NODE1.local")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []netip.Addr{netip.MustParseAddr("169.254.0.1"), netip.MustParseAddr("fe80::1")}, addrs)
+
+		addrs, err = res.LookupNetIP(ctx, "ip6", "node1.local")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("fe80::1")}, addrs)
+	})
+
+	t.Run("LookupAddr", func(t *testing.T) {
+		names, err := res.LookupAddr(ctx, "169.254.0.1")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"node1.local."}, names)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := res.LookupNetIP(ctx, "ip", "node2.local")
+		require.True(t, resolver.IsNXDomain(err))
+
+		// Only ".local" names are looked up.
+		_, err = res.LookupNetIP(ctx, "ip", "node1.example.com")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+}
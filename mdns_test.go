@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMDNSResponder(t *testing.T) {
+	responder, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+		Hostname: "node1",
+		Addrs:    []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fd00::1")},
+		Announce: ptr.To(false),
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "node1.local.", responder.Hostname())
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- responder.Serve(ctx, pc)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	client := &dns.Client{Timeout: 500 * time.Millisecond}
+	addr := pc.LocalAddr().String()
+
+	t.Run("Address", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("NODE1.local.", dns.TypeA)
+
+		reply, _, err := client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		// Legacy unicast queries get regular DNS responses.
+		require.Equal(t, req.Id, reply.Id)
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "10.0.0.1", reply.Answer[0].(*dns.A).A.String())
+		require.Equal(t, uint16(dns.ClassINET), reply.Answer[0].Header().Class)
+		require.Equal(t, uint32(10), reply.Answer[0].Header().Ttl)
+
+		// The other addresses are included as additional records.
+		require.Len(t, reply.Extra, 1)
+		require.Equal(t, "fd00::1", reply.Extra[0].(*dns.AAAA).AAAA.String())
+	})
+
+	t.Run("Reverse", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("1.0.0.10.in-addr.arpa.", dns.TypePTR)
+
+		reply, _, err := client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "node1.local.", reply.Answer[0].(*dns.PTR).Ptr)
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("node1.local.", dns.TypeTXT)
+
+		reply, _, err := client.Exchange(req, addr)
+		require.NoError(t, err)
+
+		require.Empty(t, reply.Answer)
+		require.Len(t, reply.Extra, 1)
+		require.Equal(t, []uint16{dns.TypeA, dns.TypeAAAA}, reply.Extra[0].(*dns.NSEC).TypeBitMap)
+	})
+
+	t.Run("Known Answer", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("node1.local.", dns.TypeA)
+		req.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: "node1.local.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 120},
+			A:   net.ParseIP("10.0.0.1"),
+		}}
+
+		_, _, err := client.Exchange(req, addr)
+		require.Error(t, err)
+	})
+
+	t.Run("Other Host", func(t *testing.T) {
+		req := new(dns.Msg).SetQuestion("node2.local.", dns.TypeA)
+
+		_, _, err := client.Exchange(req, addr)
+		require.Error(t, err)
+	})
+}
+
+func TestMDNSResponderLinkAddrs(t *testing.T) {
+	responder, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+		Hostname: "node1",
+		Addrs:    []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("10.0.0.1")},
+		Announce: ptr.To(false),
+	})
+	require.NoError(t, err)
+
+	addr := serveMDNSResponder(t, responder)
+
+	// Only the address assigned on the loopback interface is answered (the
+	// interface of queries received before serving begins isn't known).
+	client := &dns.Client{Timeout: 500 * time.Millisecond}
+	require.Eventually(t, func() bool {
+		reply, _, err := client.Exchange(new(dns.Msg).SetQuestion("node1.local.", dns.TypeA), addr)
+		return err == nil && len(reply.Answer) == 1 && reply.Answer[0].(*dns.A).A.String() == "127.0.0.1"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestMDNSResponderProbing(t *testing.T) {
+	newResponder := func(t *testing.T) *resolver.MDNSResponder {
+		responder, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+			Hostname: "node1",
+			Addrs:    []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+		})
+		require.NoError(t, err)
+		return responder
+	}
+
+	query := new(dns.Msg).SetQuestion("node1.local.", dns.TypeA)
+
+	t.Run("Claimed", func(t *testing.T) {
+		addr := serveMDNSResponder(t, newResponder(t))
+
+		// The hostname isn't answered for until probing completes.
+		client := &dns.Client{Timeout: 100 * time.Millisecond}
+		_, _, err := client.Exchange(query, addr)
+		require.Error(t, err)
+
+		require.Eventually(t, func() bool {
+			reply, _, err := client.Exchange(query, addr)
+			return err == nil && len(reply.Answer) == 1
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		responder := newResponder(t)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- responder.Serve(context.Background(), pc)
+		}()
+
+		// Another host responds with a different address for the hostname.
+		resp := new(dns.Msg)
+		resp.Response = true
+		resp.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: "node1.local.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 120},
+			A:   net.ParseIP("10.0.0.2"),
+		}}
+
+		buf, err := resp.Pack()
+		require.NoError(t, err)
+
+		conn, err := net.Dial("udp", pc.LocalAddr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, conn.Close())
+		})
+
+		_, err = conn.Write(buf)
+		require.NoError(t, err)
+
+		select {
+		case err := <-errCh:
+			require.ErrorIs(t, err, resolver.ErrNameConflict)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the conflict to be detected")
+		}
+	})
+}
+
+// serveMDNSResponder serves responder on a loopback port, it returns the
+// address of the responder.
+func serveMDNSResponder(t *testing.T, responder *resolver.MDNSResponder) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- responder.Serve(ctx, pc)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	return pc.LocalAddr().String()
+}
+
+func TestMDNSResponderInvalidHostname(t *testing.T) {
+	_, err := resolver.NewMDNSResponder(&resolver.MDNSResponderConfig{
+		Hostname: "a.b",
+		Addrs:    []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+	})
+	require.Error(t, err)
+}
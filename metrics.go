@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"time"
+)
+
+// MetricsRecorder records metrics about lookups, the metrics/prometheus module
+// provides an implementation that exposes them as Prometheus metrics.
+type MetricsRecorder interface {
+	// RecordQuery is called after each query sent to a DNS server, with the
+	// return code of the reply (if one was received) or the error.
+	RecordQuery(server string, qType uint16, rcode int, duration time.Duration, err error)
+	// RecordCacheLookup is called after each lookup of a caching resolver.
+	RecordCacheLookup(hit bool)
+}
+
+// nopMetricsRecorder is the default MetricsRecorder, it discards all metrics.
+type nopMetricsRecorder struct{}
+
+func (nopMetricsRecorder) RecordQuery(string, uint16, int, time.Duration, error) {}
+func (nopMetricsRecorder) RecordCacheLookup(bool)                                {}
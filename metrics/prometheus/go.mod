@@ -0,0 +1,36 @@
+module github.com/noisysockets/resolver/metrics/prometheus
+
+go 1.22.4
+
+replace github.com/noisysockets/resolver => ../../
+
+require (
+	github.com/miekg/dns v1.1.61
+	github.com/noisysockets/resolver v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.19.1
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	dario.cat/mergo v1.0.0 // indirect
+	github.com/avast/retry-go/v4 v4.6.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fsnotify/fsnotify v1.9.0 // indirect
+	github.com/jinzhu/copier v0.4.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/noisysockets/util v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package prometheus provides a Prometheus collector for resolver metrics. It
+// is a separate module so that the Prometheus client library is only a
+// dependency of programs that use it.
+package prometheus
+
+import (
+	"errors"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_ prometheus.Collector     = (*Collector)(nil)
+	_ resolver.MetricsRecorder = (*Collector)(nil)
+)
+
+// CollectorConfig is the configuration for a Collector.
+type CollectorConfig struct {
+	// Namespace is the optional namespace prefixed to all metric names.
+	Namespace string
+	// LatencyBuckets are the buckets of the per-server latency histogram, in
+	// seconds. Defaults to prometheus.DefBuckets.
+	LatencyBuckets []float64
+}
+
+// Collector is a prometheus.Collector that exposes query counts, error counts
+// by class, per-server latency histograms and cache hits and misses. Pass it as
+// the Metrics field of resolver.DNSResolverConfig and
+// resolver.CacheResolverConfig, and register it with a prometheus.Registerer.
+type Collector struct {
+	queries     *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	cacheLookup *prometheus.CounterVec
+}
+
+// NewCollector returns a new Collector.
+func NewCollector(conf *CollectorConfig) *Collector {
+	if conf == nil {
+		conf = &CollectorConfig{}
+	}
+
+	buckets := conf.LatencyBuckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+
+	return &Collector{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: conf.Namespace,
+			Subsystem: "resolver",
+			Name:      "queries_total",
+			Help:      "Total number of queries sent to DNS servers.",
+		}, []string{"server", "type", "rcode"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: conf.Namespace,
+			Subsystem: "resolver",
+			Name:      "errors_total",
+			Help:      "Total number of failed queries, by error class.",
+		}, []string{"server", "class"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: conf.Namespace,
+			Subsystem: "resolver",
+			Name:      "query_duration_seconds",
+			Help:      "Duration of queries sent to DNS servers.",
+			Buckets:   buckets,
+		}, []string{"server"}),
+		cacheLookup: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: conf.Namespace,
+			Subsystem: "resolver",
+			Name:      "cache_lookups_total",
+			Help:      "Total number of cache lookups, by result.",
+		}, []string{"result"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.queries.Describe(ch)
+	c.errors.Describe(ch)
+	c.latency.Describe(ch)
+	c.cacheLookup.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.queries.Collect(ch)
+	c.errors.Collect(ch)
+	c.latency.Collect(ch)
+	c.cacheLookup.Collect(ch)
+}
+
+// RecordQuery implements resolver.MetricsRecorder.
+func (c *Collector) RecordQuery(server string, qType uint16, rcode int, duration time.Duration, err error) {
+	rcodeLabel := "none"
+	if rcode >= 0 {
+		rcodeLabel = dns.RcodeToString[rcode]
+	}
+
+	c.queries.WithLabelValues(server, dns.TypeToString[qType], rcodeLabel).Inc()
+	c.latency.WithLabelValues(server).Observe(duration.Seconds())
+
+	if err != nil {
+		c.errors.WithLabelValues(server, errorClass(err)).Inc()
+	}
+}
+
+// RecordCacheLookup implements resolver.MetricsRecorder.
+func (c *Collector) RecordCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	c.cacheLookup.WithLabelValues(result).Inc()
+}
+
+// errorClass returns a low cardinality label describing the kind of error.
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, resolver.ErrNoSuchHost):
+		return "nxdomain"
+	case errors.Is(err, resolver.ErrNoData):
+		return "nodata"
+	case errors.Is(err, resolver.ErrServFail):
+		return "servfail"
+	case errors.Is(err, resolver.ErrRefused):
+		return "refused"
+	case errors.Is(err, resolver.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, resolver.ErrTruncated):
+		return "truncated"
+	case errors.Is(err, resolver.ErrSecureFailure),
+		errors.Is(err, resolver.ErrPrivacyRequired),
+		errors.Is(err, resolver.ErrSPKIPinMismatch):
+		return "security"
+	}
+
+	return "other"
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package prometheus_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	resolverprometheus "github.com/noisysockets/resolver/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	c := resolverprometheus.NewCollector(nil)
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+
+	c.RecordQuery("192.0.2.53:53", dns.TypeA, dns.RcodeSuccess, 10*time.Millisecond, nil)
+	c.RecordQuery("192.0.2.53:53", dns.TypeA, dns.RcodeServerFailure, 20*time.Millisecond,
+		fmt.Errorf("%w: %w", resolver.ErrServFail, resolver.ErrServerMisbehaving))
+	c.RecordQuery("192.0.2.53:53", dns.TypeAAAA, -1, time.Second, resolver.ErrTimeout)
+
+	c.RecordCacheLookup(false)
+	c.RecordCacheLookup(true)
+	c.RecordCacheLookup(true)
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP resolver_queries_total Total number of queries sent to DNS servers.
+# TYPE resolver_queries_total counter
+resolver_queries_total{rcode="NOERROR",server="192.0.2.53:53",type="A"} 1
+resolver_queries_total{rcode="SERVFAIL",server="192.0.2.53:53",type="A"} 1
+resolver_queries_total{rcode="none",server="192.0.2.53:53",type="AAAA"} 1
+# HELP resolver_errors_total Total number of failed queries, by error class.
+# TYPE resolver_errors_total counter
+resolver_errors_total{class="servfail",server="192.0.2.53:53"} 1
+resolver_errors_total{class="timeout",server="192.0.2.53:53"} 1
+# HELP resolver_cache_lookups_total Total number of cache lookups, by result.
+# TYPE resolver_cache_lookups_total counter
+resolver_cache_lookups_total{result="hit"} 2
+resolver_cache_lookups_total{result="miss"} 1
+`), "resolver_queries_total", "resolver_errors_total", "resolver_cache_lookups_total"))
+
+	require.Equal(t, 1, testutil.CollectAndCount(c, "resolver_query_duration_seconds"))
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRecorder(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	var recorder testMetricsRecorder
+	res := resolver.Cache(resolver.DNS(resolver.DNSResolverConfig{
+		Server:  server,
+		Metrics: &recorder,
+	}), &resolver.CacheResolverConfig{
+		Metrics: &recorder,
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	require.Len(t, recorder.queries, 1)
+	require.Equal(t, server.String(), recorder.queries[0].server)
+	require.Equal(t, dns.TypeA, recorder.queries[0].qType)
+	require.Equal(t, dns.RcodeSuccess, recorder.queries[0].rcode)
+	require.NoError(t, recorder.queries[0].err)
+
+	require.Equal(t, []bool{false, true}, recorder.cacheLookups)
+}
+
+type recordedQuery struct {
+	server string
+	qType  uint16
+	rcode  int
+	err    error
+}
+
+type testMetricsRecorder struct {
+	mu           sync.Mutex
+	queries      []recordedQuery
+	cacheLookups []bool
+}
+
+func (r *testMetricsRecorder) RecordQuery(server string, qType uint16, rcode int, _ time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.queries = append(r.queries, recordedQuery{server: server, qType: qType, rcode: rcode, err: err})
+}
+
+func (r *testMetricsRecorder) RecordCacheLookup(hit bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cacheLookups = append(r.cacheLookups, hit)
+}
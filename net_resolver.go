@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NetResolver returns a *net.Resolver that resolves addresses using resolver,
+// so that existing code using the net.Resolver API (eg. net.DefaultResolver)
+// can switch without rewriting call sites.
+//
+// Queries from the Go resolver are answered in-process by a custom Dial
+// function. Only address lookups are supported, other record types (eg. MX
+// or PTR) fail. The Go resolver still applies the hosts file and the search
+// list from the system configuration before any queries are made.
+func NetResolver(resolver Resolver) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveNetResolverConn(ctx, resolver, server)
+			return client, nil
+		},
+	}
+}
+
+// serveNetResolverConn answers the queries sent over conn. As conn is not a
+// net.PacketConn, messages are length prefixed (as with DNS over TCP).
+func serveNetResolverConn(ctx context.Context, resolver Resolver, conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+
+		p := make([]byte, length)
+		if _, err := io.ReadFull(conn, p); err != nil {
+			return
+		}
+
+		req := &dns.Msg{}
+		if err := req.Unpack(p); err != nil {
+			return
+		}
+
+		reply, err := answerQuery(ctx, resolver, req, 0).Pack()
+		if err != nil {
+			return
+		}
+
+		buf := binary.BigEndian.AppendUint16(nil, uint16(len(reply)))
+		if _, err := conn.Write(append(buf, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// answerQuery answers an address query using resolver. Answers are given the
+// TTL reported by upstream DNS servers, or localTTL if there is none (eg. for
+// answers from the hosts file). Queries for other record types are answered
+// with NODATA (or NXDOMAIN).
+func answerQuery(ctx context.Context, resolver Resolver, req *dns.Msg, localTTL time.Duration) *dns.Msg {
+	reply := &dns.Msg{}
+	reply.SetReply(req)
+	reply.RecursionAvailable = true
+
+	if req.Opcode != dns.OpcodeQuery {
+		reply.Rcode = dns.RcodeNotImplemented
+		return reply
+	}
+
+	if len(req.Question) != 1 {
+		reply.Rcode = dns.RcodeFormatError
+		return reply
+	}
+
+	q := req.Question[0]
+
+	var network string
+	switch q.Qtype {
+	case dns.TypeA:
+		network = "ip4"
+	case dns.TypeAAAA:
+		network = "ip6"
+	default:
+		// Other record types are never answered, but whether the name exists
+		// determines if the reply is NODATA or NXDOMAIN.
+		network = "ip"
+	}
+
+	recorder := &ttlRecorder{}
+	addrs, err := resolver.LookupNetIP(withTTLRecorder(ctx, recorder), network, q.Name)
+	if err != nil {
+		switch {
+		case IsNoData(err):
+		case IsNXDomain(err):
+			reply.Rcode = dns.RcodeNameError
+		default:
+			reply.Rcode = dns.RcodeServerFailure
+		}
+		return reply
+	}
+
+	ttl, ok := recorder.recordedTTL()
+	if !ok {
+		ttl = localTTL
+	}
+
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: uint32(ttl.Seconds())}
+	for _, addr := range addrs {
+		if q.Qtype == dns.TypeA && addr.Is4() {
+			reply.Answer = append(reply.Answer, &dns.A{Hdr: hdr, A: addr.AsSlice()})
+		} else if q.Qtype == dns.TypeAAAA && addr.Is6() && !addr.Is4In6() {
+			reply.Answer = append(reply.Answer, &dns.AAAA{Hdr: hdr, AAAA: addr.AsSlice()})
+		}
+	}
+
+	return reply
+}
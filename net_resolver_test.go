@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetResolver(t *testing.T) {
+	hostsResolver, err := resolver.Hosts(&resolver.HostsResolverConfig{
+		HostsFileReader: strings.NewReader("192.0.2.1 www.example\n2001:db8::1 www.example\n"),
+	})
+	require.NoError(t, err)
+
+	netResolver := resolver.NetResolver(hostsResolver)
+
+	t.Run("LookupNetIP", func(t *testing.T) {
+		addrs, err := netResolver.LookupNetIP(context.Background(), "ip", "www.example.")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []netip.Addr{
+			netip.MustParseAddr("192.0.2.1"),
+			netip.MustParseAddr("2001:db8::1"),
+		}, addrs)
+	})
+
+	t.Run("LookupHost", func(t *testing.T) {
+		addrs, err := netResolver.LookupHost(context.Background(), "www.example.")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []string{"192.0.2.1", "2001:db8::1"}, addrs)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := netResolver.LookupHost(context.Background(), "missing.example.")
+		require.Error(t, err)
+
+		var dnsErr *net.DNSError
+		require.True(t, errors.As(err, &dnsErr))
+		require.True(t, dnsErr.IsNotFound)
+	})
+
+	t.Run("Unsupported Type", func(t *testing.T) {
+		_, err := netResolver.LookupMX(context.Background(), "www.example.")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+// ContextDialer is implemented by userspace network stacks, eg. a gVisor
+// netstack (as used by userspace WireGuard implementations). UDP must be
+// supported, the returned connections should implement net.PacketConn for
+// off-path spoofing protection.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NetstackResolverConfig is the configuration for a resolver that resolves
+// names over a userspace network stack.
+type NetstackResolverConfig struct {
+	// Servers are the DNS servers reachable over the network stack.
+	Servers []netip.AddrPort
+	// Transport is the optional transport protocol used for DNS resolution.
+	// By default, plain DNS over UDP is used.
+	Transport *DNSTransport
+	// Timeout is the maximum duration to wait for a query to complete.
+	Timeout *time.Duration
+	// Search is an optional list of search domains appended to relative names.
+	Search []string
+}
+
+// Netstack returns a resolver that resolves names using DNS servers reached
+// over a userspace network stack, rather than the host's network. Queries,
+// and the probes used to sort the returned addresses, are sent using the
+// stack's dialer. IP literals are resolved without querying the servers.
+func Netstack(stack ContextDialer, conf *NetstackResolverConfig) (Resolver, error) {
+	var servers []netip.AddrPort
+	var search []string
+	if conf != nil {
+		servers = conf.Servers
+		search = conf.Search
+	}
+
+	if len(servers) == 0 {
+		return nil, errors.New("no servers configured")
+	}
+
+	conf, err := defaults.WithDefaults(conf, &NetstackResolverConfig{
+		Transport: ptr.To(DNSTransportUDP),
+		Timeout:   ptr.To(5 * time.Second),
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	resolvers := make([]Resolver, 0, len(servers))
+	for _, server := range servers {
+		resolvers = append(resolvers, DNS(DNSResolverConfig{
+			Server:      server,
+			Transport:   conf.Transport,
+			Timeout:     conf.Timeout,
+			DialContext: stack.DialContext,
+		}))
+	}
+
+	var resolver Resolver = Sequential(resolvers...)
+	if len(search) > 0 {
+		resolver = Relative(resolver, &RelativeResolverConfig{
+			Search: search,
+		})
+	}
+
+	return Sequential(Literal(), resolver), nil
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetstack(t *testing.T) {
+	upstream := dnstest.NewServer(dnstest.Records{
+		"www.example.internal": {"A 10.0.0.1", "AAAA fd00::1"},
+	})
+	t.Cleanup(upstream.Close)
+
+	stack := &fakeStack{}
+
+	res, err := resolver.Netstack(stack, &resolver.NetstackResolverConfig{
+		Servers: []netip.AddrPort{upstream.Addr},
+		Search:  []string{"example.internal."},
+	})
+	require.NoError(t, err)
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip", "www")
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fd00::1")}, addrs)
+
+	// Queries were sent over the stack.
+	require.Contains(t, stack.dialed(), "udp "+upstream.Addr.String())
+
+	t.Run("Literal", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "10.0.0.2")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.2")}, addrs)
+	})
+
+	t.Run("No Servers", func(t *testing.T) {
+		_, err := resolver.Netstack(stack, nil)
+		require.Error(t, err)
+	})
+}
+
+// fakeStack is a network stack that records the connections dialed.
+type fakeStack struct {
+	mu    sync.Mutex
+	dials []string
+}
+
+func (s *fakeStack) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	s.mu.Lock()
+	s.dials = append(s.dials, network+" "+address)
+	s.mu.Unlock()
+
+	return (&net.Dialer{}).DialContext(ctx, network, address)
+}
+
+func (s *fakeStack) dialed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.dials...)
+}
@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/ptr"
+)
+
+// DNSOption configures a DNS resolver created with NewDNS.
+type DNSOption func(conf *DNSResolverConfig)
+
+// NewDNS creates a new DNS resolver configured by the given options. It is
+// equivalent to calling DNS with a DNSResolverConfig, but allows new options
+// to be added without changing the layout of the configuration struct.
+func NewDNS(opts ...DNSOption) *dnsResolver {
+	var conf DNSResolverConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	return DNS(conf)
+}
+
+// WithServer sets the DNS server to query.
+func WithServer(server netip.AddrPort) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Server = server
+	}
+}
+
+// WithTransport sets the transport protocol used for DNS resolution.
+func WithTransport(transport DNSTransport) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Transport = ptr.To(transport)
+	}
+}
+
+// WithCustomTransport sets the implementation of the transport used to send all queries.
+func WithCustomTransport(transport Transport) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.CustomTransport = transport
+	}
+}
+
+// WithTimeout sets the maximum duration to wait for a query to complete.
+func WithTimeout(timeout time.Duration) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Timeout = ptr.To(timeout)
+	}
+}
+
+// WithAttempts sets the number of times a query sent over UDP is attempted.
+func WithAttempts(n int) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Attempts = ptr.To(n)
+	}
+}
+
+// WithDialContext sets the function used to establish a connection to a DNS server.
+func WithDialContext(dialContext DialContextFunc) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.DialContext = dialContext
+	}
+}
+
+// WithTLSConfig sets the configuration of the TLS client used for DNS over TLS.
+func WithTLSConfig(tlsConfig *tls.Config) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.TLSConfig = tlsConfig
+	}
+}
+
+// WithTLSPolicy restricts the TLS versions and algorithms used for DNS over TLS.
+func WithTLSPolicy(policy TLSPolicy) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.TLSPolicy = ptr.To(policy)
+	}
+}
+
+// WithSPKIPins sets the SPKI pins the server's certificate must match.
+func WithSPKIPins(pins ...string) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.SPKIPins = pins
+	}
+}
+
+// WithSingleRequest sets whether A and AAAA records are queried sequentially.
+func WithSingleRequest(enabled bool) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.SingleRequest = ptr.To(enabled)
+	}
+}
+
+// WithTrustAD sets whether the server is trusted to report if answers were authenticated.
+func WithTrustAD(enabled bool) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.TrustAD = ptr.To(enabled)
+	}
+}
+
+// WithRequireAD sets the domains for which answers must be authenticated by the server.
+func WithRequireAD(domains ...string) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.RequireAD = domains
+	}
+}
+
+// WithCaseRandomization sets whether the case of query names sent over UDP is randomized.
+func WithCaseRandomization(enabled bool) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.CaseRandomization = ptr.To(enabled)
+	}
+}
+
+// WithMaxCNAMEChain sets the maximum number of aliases that will be followed.
+func WithMaxCNAMEChain(n int) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.MaxCNAMEChain = ptr.To(n)
+	}
+}
+
+// WithProxy sets the function returning the HTTP proxy that encrypted connections are tunneled through.
+func WithProxy(proxy func(server netip.AddrPort) (*url.URL, error)) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Proxy = proxy
+	}
+}
+
+// WithLocalAddr sets the local address (and port) that queries are sent from.
+func WithLocalAddr(localAddr netip.AddrPort) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.LocalAddr = ptr.To(localAddr)
+	}
+}
+
+// WithResponseLimits sets the limits applied when reading and parsing responses.
+func WithResponseLimits(limits ResponseLimits) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.ResponseLimits = &limits
+	}
+}
+
+// WithRecursionDesired sets whether the RD bit is set in queries.
+func WithRecursionDesired(enabled bool) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.RecursionDesired = ptr.To(enabled)
+	}
+}
+
+// WithCheckingDisabled sets whether the CD bit is set in queries.
+func WithCheckingDisabled(enabled bool) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.CheckingDisabled = ptr.To(enabled)
+	}
+}
+
+// WithDNSSECOK sets whether the DO bit is set in queries.
+func WithDNSSECOK(enabled bool) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.DNSSECOK = ptr.To(enabled)
+	}
+}
+
+// WithTruncationPolicy sets how truncated replies to UDP queries are handled.
+func WithTruncationPolicy(policy TruncationPolicy) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.TruncationPolicy = ptr.To(policy)
+	}
+}
+
+// WithTSIGKey sets the key used to authenticate queries and replies using TSIG.
+func WithTSIGKey(key *TSIGKey) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.TSIGKey = key
+	}
+}
+
+// WithLogger sets the logger that queries and retries are logged to.
+func WithLogger(logger *slog.Logger) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Logger = logger
+	}
+}
+
+// WithMetrics sets the recorder of query metrics.
+func WithMetrics(metrics MetricsRecorder) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Metrics = metrics
+	}
+}
+
+// WithRand sets the source of randomness for query IDs and case randomization.
+func WithRand(rnd Rand) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Rand = rnd
+	}
+}
+
+// WithClock sets the source of the current time.
+func WithClock(clock Clock) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Clock = clock
+	}
+}
+
+// WithQueryLog sets the log that queries are recorded in.
+func WithQueryLog(queryLog *QueryLog) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.QueryLog = queryLog
+	}
+}
+
+// WithOnQuery sets the hook called before each query is sent.
+func WithOnQuery(hook func(ctx context.Context, req *dns.Msg, info QueryInfo) error) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.OnQuery = hook
+	}
+}
+
+// WithOnResponse sets the hook called with each reply received.
+func WithOnResponse(hook func(ctx context.Context, req, reply *dns.Msg, info QueryInfo)) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.OnResponse = hook
+	}
+}
+
+// WithOnError sets the hook called when a query fails.
+func WithOnError(hook func(ctx context.Context, req *dns.Msg, err error, info QueryInfo)) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.OnError = hook
+	}
+}
+
+// WithSort sets the strategy used to order the returned addresses.
+func WithSort(sort AddrSorter) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.Sort = sort
+	}
+}
+
+// WithPrivacyProfile sets the DNS privacy usage profile (RFC 8310).
+func WithPrivacyProfile(profile PrivacyProfile) DNSOption {
+	return func(conf *DNSResolverConfig) {
+		conf.PrivacyProfile = ptr.To(profile)
+	}
+}
+
+// SystemOption configures a system resolver created with NewSystem.
+type SystemOption func(conf *SystemResolverConfig)
+
+// NewSystem creates a new system resolver configured by the given options, it
+// is equivalent to calling System with a SystemResolverConfig.
+func NewSystem(opts ...SystemOption) (Resolver, error) {
+	var conf SystemResolverConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	return System(&conf)
+}
+
+// WithHostsFilePath sets the path to the hosts file.
+func WithHostsFilePath(path string) SystemOption {
+	return func(conf *SystemResolverConfig) {
+		conf.HostsFilePath = path
+	}
+}
+
+// WithGAIConfPath sets the path to the gai.conf(5) file used to sort addresses.
+func WithGAIConfPath(path string) SystemOption {
+	return func(conf *SystemResolverConfig) {
+		conf.GAIConfPath = path
+	}
+}
+
+// WithZeroconf resolves ".local" names, and the names of link-local
+// addresses, using multicast DNS (see Zeroconf).
+func WithZeroconf() SystemOption {
+	return func(conf *SystemResolverConfig) {
+		conf.Zeroconf = ptr.To(true)
+	}
+}
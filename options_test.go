@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDNS(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	queryLog := resolver.NewQueryLog(10)
+	transport := &countingTransport{Transport: &resolver.TCPTransport{}}
+
+	var transports []resolver.DNSTransport
+	res := resolver.NewDNS(
+		resolver.WithServer(server),
+		resolver.WithTransport(resolver.DNSTransportTCP),
+		resolver.WithTimeout(time.Second),
+		resolver.WithAttempts(2),
+		resolver.WithCustomTransport(transport),
+		resolver.WithQueryLog(queryLog),
+		resolver.WithRand(rand.New(rand.NewPCG(1, 2))),
+		resolver.WithClock(&fakeClock{now: time.Now()}),
+		resolver.WithSort(resolver.SortPreserveOrder()),
+		resolver.WithProxy(resolver.ProxyFromEnvironment),
+		resolver.WithOnQuery(func(_ context.Context, _ *dns.Msg, info resolver.QueryInfo) error {
+			transports = append(transports, info.Transport)
+			return nil
+		}),
+	)
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+
+	require.Equal(t, []resolver.DNSTransport{resolver.DNSTransportTCP}, transports)
+	require.Len(t, queryLog.Entries(), 1)
+	require.Equal(t, int32(1), transport.queries.Load())
+}
+
+type countingTransport struct {
+	resolver.Transport
+	queries atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+	t.queries.Add(1)
+	return t.Transport.RoundTrip(ctx, server, req)
+}
+
+func TestNewSystem(t *testing.T) {
+	res, err := resolver.NewSystem(
+		resolver.WithHostsFilePath("testdata/hosts"),
+		resolver.WithZeroconf(),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, "zeroconf", resolver.Describe(res).Type)
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "dev.mysite.com")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.10")}, addrs)
+}
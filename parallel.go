@@ -76,3 +76,12 @@ func (r *parallelResolver) LookupNetIP(ctx context.Context, network, host string
 		return nil, ctx.Err()
 	}
 }
+
+// Close closes the underlying resolvers.
+func (r *parallelResolver) Close() error {
+	return closeAll(r.resolvers)
+}
+
+func (r *parallelResolver) describe() Description {
+	return Description{Type: "parallel", Children: describeAll(r.resolvers)}
+}
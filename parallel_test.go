@@ -17,19 +17,19 @@ import (
 	"testing"
 
 	"github.com/noisysockets/resolver"
-	"github.com/noisysockets/resolver/internal/testutil"
+	"github.com/noisysockets/resolver/dnstest"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestParallelResolver(t *testing.T) {
-	res1 := new(testutil.MockResolver)
+	res1 := new(dnstest.MockResolver)
 	res1.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).Return([]netip.Addr{}, &net.DNSError{
 		Err:        resolver.ErrNoSuchHost.Error(),
 		IsNotFound: true,
 	})
 
-	res2 := new(testutil.MockResolver)
+	res2 := new(dnstest.MockResolver)
 	res2.On("LookupNetIP", mock.Anything, "ip", "example.com").Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
 	res2.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).Return([]netip.Addr{}, &net.DNSError{
 		Err:        resolver.ErrNoSuchHost.Error(),
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// SPKIPin returns the base64 encoded SHA-256 digest of the certificate's
+// SubjectPublicKeyInfo, in the format used by Stubby and Android Private DNS
+// (and HPKP's "pin-sha256").
+func SPKIPin(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// withSPKIPins returns a copy of the TLS configuration that additionally
+// requires one of the certificates of the verified chain to match one of the
+// SPKI pins. Regular certificate validation is still performed, unless it is
+// disabled (InsecureSkipVerify), in which case only the leaf certificate (whose
+// key the server proved possession of) can match.
+func withSPKIPins(tlsConfig *tls.Config, pins []string) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(cs); err != nil {
+				return err
+			}
+		}
+
+		// Any other certificates presented by the server are unauthenticated.
+		var candidates []*x509.Certificate
+		if len(cs.VerifiedChains) > 0 {
+			for _, chain := range cs.VerifiedChains {
+				candidates = append(candidates, chain...)
+			}
+		} else if len(cs.PeerCertificates) > 0 {
+			candidates = cs.PeerCertificates[:1]
+		}
+
+		for _, cert := range candidates {
+			pin := SPKIPin(cert)
+			for _, expected := range pins {
+				if subtle.ConstantTimeCompare([]byte(pin), []byte(expected)) == 1 {
+					return nil
+				}
+			}
+		}
+
+		return fmt.Errorf("%w: %s", ErrSPKIPinMismatch, cs.ServerName)
+	}
+
+	return tlsConfig
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSPKIPins(t *testing.T) {
+	server, cert := startTLSTestServer(t, "dns.example")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	newResolver := func(pins ...string) resolver.Resolver {
+		return resolver.DNS(resolver.DNSResolverConfig{
+			Server:    server,
+			Transport: ptr.To(resolver.DNSTransportTLS),
+			TLSConfig: &tls.Config{
+				ServerName: "dns.example",
+				RootCAs:    roots,
+			},
+			SPKIPins: pins,
+		})
+	}
+
+	t.Run("Match", func(t *testing.T) {
+		res := newResolver("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", resolver.SPKIPin(cert))
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		res := newResolver("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		require.Error(t, err)
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.Contains(t, dnsErr.Err, resolver.ErrSPKIPinMismatch.Error())
+	})
+
+	t.Run("Unverified Certificate", func(t *testing.T) {
+		// An additional certificate that is not part of the verified chain.
+		_, extraCert := newTestCertificate(t, "extra.example")
+		server, cert := startTLSTestServer(t, "dns.example", func(tlsConfig *tls.Config) {
+			tlsConfig.Certificates[0].Certificate = append(tlsConfig.Certificates[0].Certificate, extraCert.Raw)
+		})
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+
+		for _, tlsConfig := range []*tls.Config{
+			{ServerName: "dns.example", RootCAs: roots},
+			{InsecureSkipVerify: true},
+		} {
+			res := resolver.DNS(resolver.DNSResolverConfig{
+				Server:    server,
+				Transport: ptr.To(resolver.DNSTransportTLS),
+				TLSConfig: tlsConfig,
+				SPKIPins:  []string{resolver.SPKIPin(extraCert)},
+			})
+
+			_, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+			require.ErrorContains(t, err, resolver.ErrSPKIPinMismatch.Error())
+		}
+	})
+}
+
+// startTLSTestServer starts a DNS over TLS server, with a self-signed
+// certificate for hostname, that answers A queries with 192.0.2.1. The server's
+// TLS configuration can be customized using the optional configure functions.
+func startTLSTestServer(t *testing.T, hostname string, configure ...func(*tls.Config)) (netip.AddrPort, *x509.Certificate) {
+	tlsCert, cert := newTestCertificate(t, hostname)
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+	}
+	for _, fn := range configure {
+		fn(tlsConfig)
+	}
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	require.NoError(t, err)
+
+	server := &dns.Server{
+		Listener: lis,
+		Net:      "tcp-tls",
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+			if req.Question[0].Qtype == dns.TypeA {
+				reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+			}
+			_ = w.WriteMsg(reply)
+		}),
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+
+	return netip.MustParseAddrPort(lis.Addr().String()), cert
+}
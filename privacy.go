@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"crypto/tls"
+)
+
+// PrivacyProfile is a DNS privacy usage profile, as defined in RFC 8310.
+type PrivacyProfile string
+
+const (
+	// PrivacyProfileStrict requires queries to be sent over an authenticated
+	// and encrypted transport (eg. DNS over TLS), otherwise lookups fail with
+	// ErrPrivacyRequired.
+	PrivacyProfileStrict PrivacyProfile = "strict"
+	// PrivacyProfileOpportunistic attempts to send queries over an encrypted
+	// transport, but falls back to an unauthenticated encrypted connection,
+	// and then to clear text, if that is not possible.
+	PrivacyProfileOpportunistic PrivacyProfile = "opportunistic"
+)
+
+// isAuthenticated returns true if tlsConfig authenticates the server. Without
+// certificate verification, the server is only authenticated if the public key
+// of its leaf certificate is checked against SPKI pins (see withSPKIPins).
+func isAuthenticated(tlsConfig *tls.Config, leafPinned bool) bool {
+	return !tlsConfig.InsecureSkipVerify || leafPinned
+}
+
+// opportunisticTLSConfig returns a copy of the TLS configuration that accepts
+// any server certificate, as the opportunistic profile allows unauthenticated
+// encryption.
+func opportunisticTLSConfig(tlsConfig *tls.Config) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyConnection = nil
+	return tlsConfig
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivacyProfile(t *testing.T) {
+	server, cert := startTLSTestServer(t, "dns.example")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	expected := []netip.Addr{netip.MustParseAddr("192.0.2.1")}
+
+	t.Run("Strict", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+			TLSConfig: &tls.Config{
+				ServerName: "dns.example",
+				RootCAs:    roots,
+			},
+			PrivacyProfile: ptr.To(resolver.PrivacyProfileStrict),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, expected, addrs)
+	})
+
+	t.Run("Strict Unencrypted", func(t *testing.T) {
+		plainServer := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			t.Error("unexpected clear text query")
+		}))
+
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:         plainServer,
+			Transport:      ptr.To(resolver.DNSTransportUDP),
+			PrivacyProfile: ptr.To(resolver.PrivacyProfileStrict),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		requirePrivacyRequired(t, err)
+	})
+
+	t.Run("Strict Pinned", func(t *testing.T) {
+		// The pinned leaf authenticates the server, without verifying its chain.
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+			TLSConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+			SPKIPins:       []string{resolver.SPKIPin(cert)},
+			PrivacyProfile: ptr.To(resolver.PrivacyProfileStrict),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, expected, addrs)
+	})
+
+	t.Run("Strict Unauthenticated", func(t *testing.T) {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: server,
+			TLSConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+			PrivacyProfile: ptr.To(resolver.PrivacyProfileStrict),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		requirePrivacyRequired(t, err)
+	})
+
+	t.Run("Opportunistic Unauthenticated", func(t *testing.T) {
+		// The certificate is not trusted, but encryption is still used.
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:         server,
+			PrivacyProfile: ptr.To(resolver.PrivacyProfileOpportunistic),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, expected, addrs)
+	})
+
+	t.Run("Per Domain", func(t *testing.T) {
+		res := resolver.Routing(&resolver.RoutingResolverConfig{
+			Routes: []resolver.Route{
+				{
+					Domains: []string{"sensitive.example"},
+					Resolver: resolver.DNS(resolver.DNSResolverConfig{
+						Server:         server,
+						PrivacyProfile: ptr.To(resolver.PrivacyProfileStrict),
+					}),
+				},
+			},
+			Default: resolver.DNS(resolver.DNSResolverConfig{
+				Server:         server,
+				PrivacyProfile: ptr.To(resolver.PrivacyProfileOpportunistic),
+			}),
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.NoError(t, err)
+		require.Equal(t, expected, addrs)
+
+		// The certificate is not trusted, so strict lookups fail.
+		_, err = res.LookupNetIP(context.Background(), "ip4", "www.sensitive.example")
+		require.Error(t, err)
+	})
+}
+
+func requirePrivacyRequired(t *testing.T, err error) {
+	var dnsErr *net.DNSError
+	require.ErrorAs(t, err, &dnsErr)
+	require.Equal(t, resolver.ErrPrivacyRequired.Error(), dnsErr.Err)
+}
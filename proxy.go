@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyFromEnvironment returns the HTTP proxy that connections to server
+// should be tunneled through, as configured by the HTTPS_PROXY and NO_PROXY
+// environment variables (or their lowercase versions). A nil URL is returned
+// if no proxy should be used.
+func ProxyFromEnvironment(server netip.AddrPort) (*url.URL, error) {
+	return httpproxy.FromEnvironment().ProxyFunc()(&url.URL{Scheme: "https", Host: server.String()})
+}
+
+// DialHTTPProxy returns a dialer that tunnels TCP connections through an
+// HTTP proxy using the CONNECT method, eg. to reach DNS over TLS or DNS over
+// HTTPS servers from networks that only allow egress via a proxy. Proxies
+// with an "https" scheme are connected to using TLS, and credentials in the
+// URL are sent using basic authentication. If dial is nil, a net.Dialer is
+// used to connect to the proxy.
+func DialHTTPProxy(proxy *url.URL, dial DialContextFunc) DialContextFunc {
+	dial = dialOrDefault(dial)
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+		default:
+			return nil, fmt.Errorf("network %q cannot be tunneled through an HTTP proxy", network)
+		}
+
+		proxyAddr := proxy.Host
+		if proxy.Port() == "" {
+			port := "80"
+			if proxy.Scheme == "https" {
+				port = "443"
+			}
+			proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+		}
+
+		conn, err := dial(ctx, "tcp", proxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+		}
+
+		if err := connectThroughProxy(ctx, &conn, proxy, address); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+// connectThroughProxy establishes a tunnel to address over the connection to
+// the proxy, the connection is replaced if it is wrapped (eg. by TLS).
+func connectThroughProxy(ctx context.Context, conn *net.Conn, proxy *url.URL, address string) (err error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := (*conn).SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// Unblock any pending reads or writes as soon as the context is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = (*conn).SetDeadline(aLongTimeAgo)
+	})
+	defer func() {
+		stop()
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = (*conn).SetDeadline(time.Time{})
+		}
+	}()
+
+	if proxy.Scheme == "https" {
+		tlsConn := tls.Client(*conn, &tls.Config{ServerName: proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("proxy %w: %w", errTLSHandshake, err)
+		}
+		*conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(*conn); err != nil {
+		return fmt.Errorf("failed to send CONNECT request: %w", err)
+	}
+
+	br := bufio.NewReader(*conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy refused to connect to %s: %s", address, resp.Status)
+	}
+
+	// The server may have sent data following the response.
+	if br.Buffered() > 0 {
+		*conn = &bufferedConn{Conn: *conn, r: br}
+	}
+
+	return nil
+}
+
+// bufferedConn is a connection whose reads are served from a buffered reader.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// dialViaProxy returns a dialer that tunnels connections to each server
+// through the HTTP proxy returned by proxy, if any.
+func dialViaProxy(proxy func(server netip.AddrPort) (*url.URL, error), dial DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		server, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		proxyURL, err := proxy(server)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine proxy: %w", err)
+		}
+
+		if proxyURL == nil {
+			return dial(ctx, network, address)
+		}
+
+		return DialHTTPProxy(proxyURL, dial)(ctx, network, address)
+	}
+}
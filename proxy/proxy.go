@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package proxy implements a local DNS proxy. It listens for queries (UDP and
+// TCP), and answers them using resolver chains built from a declarative
+// configuration, combining the server mode, routing, caching and filtering of
+// the resolver package.
+//
+// Clients are assigned a policy based on their source address, each policy
+// has its own resolver chain (eg. so that containers are sent to an internal
+// DNS server, while everything else uses a filtered public upstream). Clients
+// that don't match any policy use the default chain.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultListenAddr is the address the proxy listens on by default.
+const DefaultListenAddr = "127.0.0.1:53"
+
+// Config is the configuration of a DNS proxy, it can be loaded from a YAML (or
+// JSON) document using UnmarshalConfig.
+type Config struct {
+	// Listen is the address to listen on (for both UDP and TCP). Defaults to
+	// DefaultListenAddr.
+	Listen string `yaml:"listen,omitempty" json:"listen,omitempty"`
+	// Upstream is the resolver chain used for clients that don't match any
+	// policy.
+	Upstream resolver.Config `yaml:"upstream" json:"upstream"`
+	// Policies select another resolver chain based on the source address of
+	// the client, the first matching policy is used.
+	Policies []PolicyConfig `yaml:"policies,omitempty" json:"policies,omitempty"`
+	// LocalTTL is the TTL of answers that did not come from an upstream DNS
+	// server (eg. answers from the hosts file). Defaults to 0.
+	LocalTTL resolver.Duration `yaml:"localTTL,omitempty" json:"localTTL,omitempty"`
+	// Timeout is the maximum amount of time to spend answering a query.
+	// Defaults to 5 seconds.
+	Timeout resolver.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Logger is an optional logger.
+	Logger *slog.Logger `yaml:"-" json:"-"`
+}
+
+// PolicyConfig is the configuration of a client policy.
+type PolicyConfig struct {
+	// Name is an optional human readable name for the policy.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Clients is the list of prefixes matching the source addresses of
+	// clients the policy applies to.
+	Clients []string `yaml:"clients" json:"clients"`
+	// Upstream is the resolver chain used for clients matching the policy.
+	Upstream resolver.Config `yaml:"upstream" json:"upstream"`
+}
+
+// UnmarshalConfig parses a YAML (or JSON) proxy configuration document.
+// Unknown fields are rejected.
+func UnmarshalConfig(data []byte) (*Config, error) {
+	var conf Config
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&conf); err != nil {
+		return nil, fmt.Errorf("failed to parse proxy config: %w", err)
+	}
+
+	return &conf, nil
+}
+
+// Proxy is a local DNS proxy.
+type Proxy struct {
+	resolver resolver.Resolver
+	server   *resolver.DNSServer
+}
+
+// New creates a new DNS proxy from a configuration.
+func New(conf *Config) (*Proxy, error) {
+	if conf == nil {
+		return nil, errors.New("no configuration")
+	}
+
+	defaultResolver, err := resolver.FromConfig(&conf.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upstream resolver: %w", err)
+	}
+
+	views := make([]resolver.View, 0, len(conf.Policies))
+	for i, policyConf := range conf.Policies {
+		view, err := viewFromConfig(&policyConf)
+		if err != nil {
+			_ = closeViews(views, defaultResolver)
+			return nil, fmt.Errorf("policy %d: %w", i, err)
+		}
+
+		views = append(views, *view)
+	}
+
+	var res resolver.Resolver = defaultResolver
+	if len(views) > 0 {
+		res = resolver.Views(&resolver.ViewResolverConfig{
+			Views:   views,
+			Default: defaultResolver,
+		})
+	}
+
+	listen := conf.Listen
+	if listen == "" {
+		listen = DefaultListenAddr
+	}
+
+	serverConf := &resolver.DNSServerConfig{
+		Addr:   &listen,
+		Logger: conf.Logger,
+	}
+	if conf.LocalTTL != 0 {
+		serverConf.LocalTTL = (*time.Duration)(&conf.LocalTTL)
+	}
+	if conf.Timeout != 0 {
+		serverConf.Timeout = (*time.Duration)(&conf.Timeout)
+	}
+
+	return &Proxy{
+		resolver: res,
+		server:   resolver.NewDNSServer(res, serverConf),
+	}, nil
+}
+
+// ListenAndServe listens on the configured address (UDP and TCP) and serves
+// queries until ctx is canceled.
+func (p *Proxy) ListenAndServe(ctx context.Context) error {
+	return p.server.ListenAndServe(ctx)
+}
+
+// Serve serves queries received on pc (UDP) and l (TCP) until ctx is
+// canceled, either may be nil. The listeners are closed when Serve returns.
+func (p *Proxy) Serve(ctx context.Context, pc net.PacketConn, l net.Listener) error {
+	return p.server.Serve(ctx, pc, l)
+}
+
+// Resolver returns the resolver used to answer queries, eg. so that the
+// resolver chains can be inspected with resolver.Describe.
+func (p *Proxy) Resolver() resolver.Resolver {
+	return p.resolver
+}
+
+// Close releases the resources held by the resolver chains.
+func (p *Proxy) Close() error {
+	return resolver.Close(p.resolver)
+}
+
+func viewFromConfig(conf *PolicyConfig) (*resolver.View, error) {
+	if len(conf.Clients) == 0 {
+		return nil, errors.New("no clients")
+	}
+
+	sources := make([]netip.Prefix, 0, len(conf.Clients))
+	for _, client := range conf.Clients {
+		prefix, err := parsePrefix(client)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, prefix)
+	}
+
+	res, err := resolver.FromConfig(&conf.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upstream resolver: %w", err)
+	}
+
+	return &resolver.View{
+		Name:     conf.Name,
+		Sources:  sources,
+		Resolver: res,
+	}, nil
+}
+
+// parsePrefix parses a prefix, a bare address matches only itself.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid client prefix %q: %w", s, err)
+	}
+
+	return prefix.Masked(), nil
+}
+
+func closeViews(views []resolver.View, defaultResolver resolver.Resolver) error {
+	errs := []error{resolver.Close(defaultResolver)}
+	for _, view := range views {
+		errs = append(errs, resolver.Close(view.Resolver))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package proxy_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/resolver/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	public := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.1"},
+	})
+	t.Cleanup(public.Close)
+
+	internal := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 10.0.0.1"},
+	})
+	t.Cleanup(internal.Close)
+
+	exchange := func(t *testing.T, conf *proxy.Config, name string) *dns.Msg {
+		p, err := proxy.New(conf)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, p.Close())
+		})
+
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- p.Serve(ctx, pc, nil)
+		}()
+
+		t.Cleanup(func() {
+			cancel()
+			require.NoError(t, <-errCh)
+		})
+
+		req := &dns.Msg{}
+		req.SetQuestion(name, dns.TypeA)
+
+		var reply *dns.Msg
+		require.Eventually(t, func() bool {
+			reply, _, err = (&dns.Client{}).Exchange(req, pc.LocalAddr().String())
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+
+		return reply
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		conf, err := proxy.UnmarshalConfig([]byte(fmt.Sprintf(`
+upstream:
+  servers:
+    - address: %s
+  cache: {}
+policies:
+  - name: containers
+    clients: ["172.17.0.0/16"]
+    upstream:
+      servers:
+        - address: %s
+`, public.Addr, internal.Addr)))
+		require.NoError(t, err)
+
+		reply := exchange(t, conf, "www.example.com.")
+		require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "192.0.2.1", reply.Answer[0].(*dns.A).A.String())
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		conf, err := proxy.UnmarshalConfig([]byte(fmt.Sprintf(`
+upstream:
+  servers:
+    - address: %s
+policies:
+  - name: local
+    clients: ["127.0.0.1"]
+    upstream:
+      servers:
+        - address: %s
+`, public.Addr, internal.Addr)))
+		require.NoError(t, err)
+
+		reply := exchange(t, conf, "www.example.com.")
+		require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "10.0.0.1", reply.Answer[0].(*dns.A).A.String())
+	})
+
+	t.Run("Invalid Policy", func(t *testing.T) {
+		_, err := proxy.New(&proxy.Config{
+			Upstream: resolver.Config{
+				Servers: []resolver.ServerConfig{{Address: public.Addr.String()}},
+			},
+			Policies: []proxy.PolicyConfig{{Clients: []string{"not-a-prefix"}}},
+		})
+		require.ErrorContains(t, err, "invalid client prefix")
+	})
+}
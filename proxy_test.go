@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPProxy(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupNetIP", mock.Anything, "ip4", "www.example.com.").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	mux := http.NewServeMux()
+	mux.Handle("/dns-query", resolver.NewDNSServer(res, nil))
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	proxy := newConnectProxy(t, "user", "secret")
+
+	tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	tlsConfig.ServerName = "127.0.0.1"
+
+	dohResolver := resolver.DNS(resolver.DNSResolverConfig{
+		Server:    netip.MustParseAddrPort(srv.Listener.Addr().String()),
+		Transport: ptr.To(resolver.DNSTransportHTTPS),
+		TLSConfig: tlsConfig,
+		Proxy: func(server netip.AddrPort) (*url.URL, error) {
+			return &url.URL{Scheme: "http", Host: proxy.addr, User: url.UserPassword("user", "secret")}, nil
+		},
+	})
+	t.Cleanup(func() {
+		require.NoError(t, dohResolver.Close())
+	})
+
+	addrs, err := dohResolver.LookupNetIP(context.Background(), "ip4", "www.example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	require.Equal(t, []string{srv.Listener.Addr().String()}, proxy.connected())
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		dial := resolver.DialHTTPProxy(&url.URL{Scheme: "http", Host: proxy.addr}, nil)
+
+		_, err := dial(context.Background(), "tcp", srv.Listener.Addr().String())
+		require.ErrorContains(t, err, "407")
+	})
+
+	t.Run("UDP", func(t *testing.T) {
+		dial := resolver.DialHTTPProxy(&url.URL{Scheme: "http", Host: proxy.addr}, nil)
+
+		_, err := dial(context.Background(), "udp", srv.Listener.Addr().String())
+		require.Error(t, err)
+	})
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.example:3128")
+	t.Setenv("NO_PROXY", "192.0.2.2")
+
+	proxyURL, err := resolver.ProxyFromEnvironment(netip.MustParseAddrPort("192.0.2.1:853"))
+	require.NoError(t, err)
+	require.Equal(t, "http://proxy.example:3128", proxyURL.String())
+
+	proxyURL, err = resolver.ProxyFromEnvironment(netip.MustParseAddrPort("192.0.2.2:853"))
+	require.NoError(t, err)
+	require.Nil(t, proxyURL)
+}
+
+type connectProxy struct {
+	addr  string
+	mu    sync.Mutex
+	hosts []string
+}
+
+// newConnectProxy starts a HTTP proxy that supports the CONNECT method, and
+// requires basic authentication.
+func newConnectProxy(t *testing.T, username, password string) *connectProxy {
+	p := &connectProxy{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Header.Set("Authorization", r.Header.Get("Proxy-Authorization"))
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		p.mu.Lock()
+		p.hosts = append(p.hosts, r.Host)
+		p.mu.Unlock()
+
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			_ = upstream.Close()
+			return
+		}
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			_ = upstream.Close()
+			_ = conn.Close()
+			return
+		}
+
+		go func() {
+			defer upstream.Close()
+			defer conn.Close()
+
+			go func() {
+				_, _ = io.Copy(upstream, brw)
+			}()
+			_, _ = io.Copy(conn, upstream)
+		}()
+	}))
+	t.Cleanup(srv.Close)
+
+	p.addr = srv.Listener.Addr().String()
+	return p
+}
+
+func (p *connectProxy) connected() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.hosts...)
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// QueryLogEntry is a query recorded in a QueryLog.
+type QueryLogEntry struct {
+	// Time is when the query was sent.
+	Time time.Time
+	// Name is the queried name.
+	Name string
+	// Type is the queried record type (eg. dns.TypeA).
+	Type uint16
+	// Server is the DNS server the query was sent to.
+	Server netip.AddrPort
+	// Rcode is the return code of the reply, or -1 if no reply was received.
+	Rcode int
+	// Duration is how long the query took.
+	Duration time.Duration
+	// Err is the error that occurred, if any.
+	Err error
+}
+
+func (e QueryLogEntry) String() string {
+	rcode := "-"
+	if e.Rcode >= 0 {
+		rcode = dns.RcodeToString[e.Rcode]
+	}
+
+	s := fmt.Sprintf("%s %s %s %s %s %s", e.Time.Format(time.RFC3339Nano),
+		e.Name, dns.TypeToString[e.Type], e.Server, rcode, e.Duration)
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// QueryLog is a bounded, in-memory log of recent queries (eg. for displaying
+// recent DNS activity). Once full, the oldest entries are discarded. It is
+// safe for concurrent use.
+type QueryLog struct {
+	mu      sync.Mutex
+	entries []QueryLogEntry
+	next    int
+	full    bool
+}
+
+// NewQueryLog returns a new QueryLog that retains up to size entries.
+func NewQueryLog(size int) *QueryLog {
+	if size < 1 {
+		size = 1
+	}
+
+	return &QueryLog{
+		entries: make([]QueryLogEntry, size),
+	}
+}
+
+// Entries returns the entries in the log, oldest first.
+func (l *QueryLog) Entries() []QueryLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]QueryLogEntry(nil), l.entries[:l.next]...)
+	}
+
+	entries := make([]QueryLogEntry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	return append(entries, l.entries[:l.next]...)
+}
+
+// Reset removes all the entries from the log.
+func (l *QueryLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clear(l.entries)
+	l.next = 0
+	l.full = false
+}
+
+func (l *QueryLog) add(entry QueryLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = entry
+	l.next++
+	if l.next == len(l.entries) {
+		l.next = 0
+		l.full = true
+	}
+}
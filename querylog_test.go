@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLog(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		if req.Question[0].Name == "missing.example." {
+			reply.Rcode = dns.RcodeNameError
+		} else {
+			reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	queryLog := resolver.NewQueryLog(2)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:   server,
+		QueryLog: queryLog,
+	})
+
+	for _, host := range []string{"first.example", "second.example", "missing.example"} {
+		_, _ = res.LookupNetIP(context.Background(), "ip4", host)
+	}
+
+	entries := queryLog.Entries()
+	require.Len(t, entries, 2)
+
+	require.Equal(t, "second.example.", entries[0].Name)
+	require.Equal(t, dns.TypeA, entries[0].Type)
+	require.Equal(t, server, entries[0].Server)
+	require.Equal(t, dns.RcodeSuccess, entries[0].Rcode)
+	require.NotZero(t, entries[0].Duration)
+
+	require.Equal(t, "missing.example.", entries[1].Name)
+	require.Equal(t, dns.RcodeNameError, entries[1].Rcode)
+	require.Contains(t, entries[1].String(), "missing.example. A "+server.String()+" NXDOMAIN")
+
+	queryLog.Reset()
+	require.Empty(t, queryLog.Entries())
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+var _ Resolver = (*quorumResolver)(nil)
+
+// Divergence describes a resolver whose answer disagreed with the quorum.
+type Divergence struct {
+	// Host is the name that was looked up.
+	Host string
+	// Index is the index of the divergent resolver.
+	Index int
+	// Missing are the addresses agreed upon by the quorum that the resolver
+	// did not return.
+	Missing []netip.Addr
+	// Extra are the addresses returned by the resolver that were not agreed
+	// upon by the quorum.
+	Extra []netip.Addr
+	// Err is the error returned by the resolver, if any.
+	Err error
+}
+
+// QuorumResolverConfig is the configuration for a quorum resolver.
+type QuorumResolverConfig struct {
+	// Quorum is the number of resolvers that must return an address for it to
+	// be included in the answer. Defaults to a majority of the resolvers.
+	Quorum *int
+	// OnDivergence is an optional function that is called for each resolver
+	// whose answer disagreed with the quorum (eg. due to DNS hijacking or a
+	// captive portal).
+	OnDivergence func(Divergence)
+}
+
+// quorumResolver is a resolver that queries multiple resolvers and only
+// returns the addresses that a quorum of them agree upon.
+type quorumResolver struct {
+	resolvers    []Resolver
+	quorum       int
+	onDivergence func(Divergence)
+}
+
+// Quorum returns a resolver that queries all the resolvers in parallel and
+// only returns addresses that are present in the answers of a quorum of them.
+// At least one resolver is required, and the quorum must be between one and
+// the number of resolvers.
+func Quorum(conf *QuorumResolverConfig, resolvers ...Resolver) (*quorumResolver, error) {
+	if conf == nil {
+		conf = &QuorumResolverConfig{}
+	}
+
+	if len(resolvers) == 0 {
+		return nil, errors.New("quorum requires at least one resolver")
+	}
+
+	quorum := len(resolvers)/2 + 1
+	if conf.Quorum != nil {
+		quorum = *conf.Quorum
+	}
+
+	if quorum < 1 || quorum > len(resolvers) {
+		return nil, fmt.Errorf("quorum %d must be between 1 and the number of resolvers (%d)", quorum, len(resolvers))
+	}
+
+	return &quorumResolver{
+		resolvers:    resolvers,
+		quorum:       quorum,
+		onDivergence: conf.OnDivergence,
+	}, nil
+}
+
+func (r *quorumResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	type result struct {
+		addrs []netip.Addr
+		err   error
+	}
+
+	results := make([]result, len(r.resolvers))
+
+	var wg sync.WaitGroup
+	for i, resolver := range r.resolvers {
+		wg.Add(1)
+		go func(i int, resolver Resolver) {
+			defer wg.Done()
+
+			addrs, err := resolver.LookupNetIP(ctx, network, host)
+			results[i] = result{addrs: addrs, err: err}
+		}(i, resolver)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Count the number of resolvers that returned each address (preserving
+	// the order in which addresses were first seen). IPv4-mapped IPv6
+	// addresses are counted as their IPv4 equivalent.
+	var seen []netip.Addr
+	votes := make(map[netip.Addr]int)
+	var errs []error
+	for _, res := range results {
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+
+		for _, addr := range dedupAddrs(res.addrs) {
+			addr = addr.Unmap()
+			if votes[addr] == 0 {
+				seen = append(seen, addr)
+			}
+			votes[addr]++
+		}
+	}
+
+	var addrs []netip.Addr
+	for _, addr := range seen {
+		if votes[addr] >= r.quorum {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	// All the resolvers failed, they are in agreement (that there is no answer).
+	if len(errs) == len(r.resolvers) {
+		return nil, errors.Join(errs...)
+	}
+
+	if r.onDivergence != nil {
+		for i, res := range results {
+			divergence := Divergence{
+				Host:  host,
+				Index: i,
+				Err:   res.err,
+			}
+
+			for _, addr := range addrs {
+				if !slices.ContainsFunc(res.addrs, func(a netip.Addr) bool { return a.Unmap() == addr }) {
+					divergence.Missing = append(divergence.Missing, addr)
+				}
+			}
+
+			for _, addr := range dedupAddrs(res.addrs) {
+				if !slices.Contains(addrs, addr.Unmap()) {
+					divergence.Extra = append(divergence.Extra, addr)
+				}
+			}
+
+			if divergence.Err != nil || len(divergence.Missing) > 0 || len(divergence.Extra) > 0 {
+				r.onDivergence(divergence)
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, newError(host, "", ErrNoQuorum)
+	}
+
+	return addrs, nil
+}
+
+// Close closes the underlying resolvers.
+func (r *quorumResolver) Close() error {
+	return closeAll(r.resolvers)
+}
+
+func (r *quorumResolver) describe() Description {
+	return Description{
+		Type:       "quorum",
+		Attributes: map[string]string{"quorum": strconv.Itoa(r.quorum)},
+		Children:   describeAll(r.resolvers),
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuorumResolver(t *testing.T) {
+	honest := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}
+	notFound := &net.DNSError{
+		Err:        resolver.ErrNoSuchHost.Error(),
+		IsNotFound: true,
+	}
+
+	res1 := new(dnstest.MockResolver)
+	res1.On("LookupNetIP", mock.Anything, "ip", "example.com").Return(honest, nil)
+	res1.On("LookupNetIP", mock.Anything, "ip", "hijacked.example").Return([]netip.Addr{netip.MustParseAddr("198.51.100.1")}, nil)
+	res1.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).Return([]netip.Addr{}, notFound)
+
+	res2 := new(dnstest.MockResolver)
+	res2.On("LookupNetIP", mock.Anything, "ip", "example.com").Return([]netip.Addr{honest[1], honest[0]}, nil)
+	res2.On("LookupNetIP", mock.Anything, "ip", "hijacked.example").Return([]netip.Addr{netip.MustParseAddr("198.51.100.2")}, nil)
+	res2.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).Return([]netip.Addr{}, notFound)
+
+	// A hijacked resolver (eg. a captive portal).
+	res3 := new(dnstest.MockResolver)
+	res3.On("LookupNetIP", mock.Anything, "ip", "example.com").
+		Return([]netip.Addr{honest[0], netip.MustParseAddr("203.0.113.1")}, nil)
+	res3.On("LookupNetIP", mock.Anything, "ip", "hijacked.example").Return([]netip.Addr{netip.MustParseAddr("203.0.113.1")}, nil)
+	res3.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).Return([]netip.Addr{}, notFound)
+
+	var divergencesMu sync.Mutex
+	var divergences []resolver.Divergence
+
+	res, err := resolver.Quorum(&resolver.QuorumResolverConfig{
+		OnDivergence: func(divergence resolver.Divergence) {
+			divergencesMu.Lock()
+			defer divergencesMu.Unlock()
+
+			divergences = append(divergences, divergence)
+		},
+	}, res1, res2, res3)
+	require.NoError(t, err)
+
+	t.Run("Quorum", func(t *testing.T) {
+		divergences = nil
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, honest, addrs)
+
+		require.Equal(t, []resolver.Divergence{{
+			Host:    "example.com",
+			Index:   2,
+			Missing: []netip.Addr{honest[1]},
+			Extra:   []netip.Addr{netip.MustParseAddr("203.0.113.1")},
+		}}, divergences)
+	})
+
+	t.Run("No Quorum", func(t *testing.T) {
+		divergences = nil
+
+		_, err := res.LookupNetIP(context.Background(), "ip", "hijacked.example")
+		require.ErrorIs(t, err, resolver.ErrNoQuorum)
+
+		require.Len(t, divergences, 3)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		divergences = nil
+
+		_, err := res.LookupNetIP(context.Background(), "ip", "notfound.example")
+		require.True(t, resolver.IsNXDomain(err))
+
+		require.Empty(t, divergences)
+	})
+}
+
+func TestQuorumResolverMappedAddrs(t *testing.T) {
+	res1 := new(dnstest.MockResolver)
+	res1.On("LookupNetIP", mock.Anything, "ip", "example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	res2 := new(dnstest.MockResolver)
+	res2.On("LookupNetIP", mock.Anything, "ip", "example.com").
+		Return([]netip.Addr{netip.MustParseAddr("::ffff:192.0.2.1"), netip.MustParseAddr("192.0.2.1")}, nil)
+
+	res, err := resolver.Quorum(&resolver.QuorumResolverConfig{
+		Quorum: ptr.To(2),
+	}, res1, res2)
+	require.NoError(t, err)
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip", "example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+}
+
+func TestQuorumResolverInvalid(t *testing.T) {
+	_, err := resolver.Quorum(nil)
+	require.Error(t, err)
+
+	res := new(dnstest.MockResolver)
+
+	for _, quorum := range []int{-1, 0, 3} {
+		_, err := resolver.Quorum(&resolver.QuorumResolverConfig{
+			Quorum: ptr.To(quorum),
+		}, res, res)
+		require.Error(t, err, "quorum %d", quorum)
+	}
+}
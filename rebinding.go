@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var _ Resolver = (*rebindingResolver)(nil)
+
+// RebindingResolverConfig is the configuration for a rebinding protection
+// resolver.
+type RebindingResolverConfig struct {
+	// InternalZones is a list of domains whose names are permitted to resolve
+	// to internal addresses.
+	InternalZones []string
+}
+
+// rebindingResolver is a resolver that protects against DNS rebinding attacks.
+type rebindingResolver struct {
+	resolver      Resolver
+	internalZones []string
+}
+
+// Rebinding returns a resolver that rejects answers containing private,
+// loopback, link-local or unspecified addresses for names outside of the
+// configured internal zones. This protects services that resolve user
+// supplied hostnames (eg. request proxies) against server side request
+// forgery using DNS rebinding.
+//
+// IP literals are subject to the same checks, so "127.0.0.1" will also be
+// rejected.
+func Rebinding(resolver Resolver, conf *RebindingResolverConfig) *rebindingResolver {
+	if conf == nil {
+		conf = &RebindingResolverConfig{}
+	}
+
+	var internalZones []string
+	for _, zone := range conf.InternalZones {
+		internalZones = append(internalZones, dns.CanonicalName(zone))
+	}
+
+	return &rebindingResolver{
+		resolver:      resolver,
+		internalZones: internalZones,
+	}
+}
+
+func (r *rebindingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	name := dns.CanonicalName(host)
+	for _, zone := range r.internalZones {
+		if dns.IsSubDomain(zone, name) {
+			return addrs, nil
+		}
+	}
+
+	for _, addr := range addrs {
+		if isInternalAddr(addr) {
+			return nil, newError(host, "", ErrRebinding)
+		}
+	}
+
+	return addrs, nil
+}
+
+// isInternalAddr returns true if addr is only reachable from within the local
+// host, link or site, including unspecified and "this network" addresses.
+func isInternalAddr(addr netip.Addr) bool {
+	switch ScopeOf(addr) {
+	case AddrScopeLoopback, AddrScopeLinkLocal, AddrScopeUniqueLocal:
+		return true
+	}
+
+	addr = canonicalAddr(addr)
+	return addr.IsUnspecified() || thisNetworkPrefix.Contains(addr)
+}
+
+// Close closes the underlying resolver.
+func (r *rebindingResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *rebindingResolver) describe() Description {
+	return Description{
+		Type:       "rebinding",
+		Attributes: map[string]string{"internal_zones": strings.Join(r.internalZones, ",")},
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
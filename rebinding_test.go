@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebindingResolver(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "www.example.com").Return([]netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", "evil.example.com").Return([]netip.Addr{netip.MustParseAddr("93.184.216.35"), netip.MustParseAddr("127.0.0.1")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", "db.corp.internal").Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", "metadata.example.com").Return([]netip.Addr{netip.MustParseAddr("::ffff:169.254.169.254")}, nil)
+
+	res := resolver.Rebinding(inner, &resolver.RebindingResolverConfig{
+		InternalZones: []string{"corp.internal"},
+	})
+
+	t.Run("Public", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("93.184.216.34")}, addrs)
+	})
+
+	t.Run("Internal Zone", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "db.corp.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+	})
+
+	for _, host := range []string{"evil.example.com", "metadata.example.com"} {
+		t.Run("Rebinding "+host, func(t *testing.T) {
+			_, err := res.LookupNetIP(context.Background(), "ip", host)
+
+			var dnsErr *net.DNSError
+			require.ErrorAs(t, err, &dnsErr)
+			require.Equal(t, resolver.ErrRebinding.Error(), dnsErr.Err)
+		})
+	}
+}
+
+func TestRebindingResolverInternalAddrs(t *testing.T) {
+	tests := []struct {
+		addr     string
+		internal bool
+	}{
+		{addr: "93.184.216.34"},
+		{addr: "10.1.2.3", internal: true},
+		{addr: "127.0.0.1", internal: true},
+		{addr: "0.0.0.0", internal: true},
+		{addr: "0.1.2.3", internal: true},
+		{addr: "100.64.0.1", internal: true},
+		{addr: "100.127.255.254", internal: true},
+		{addr: "100.128.0.1"},
+		{addr: "::ffff:192.168.1.1", internal: true},
+		{addr: "::1", internal: true},
+		{addr: "64:ff9b::5db8:d822"},
+		{addr: "64:ff9b::10.0.0.1", internal: true},
+		{addr: "64:ff9b::127.0.0.1", internal: true},
+		{addr: "2002:5db8:d822::1"},
+		{addr: "2002:c0a8:0101::1", internal: true},
+		{addr: "2002:7f00:0001::1", internal: true},
+		{addr: "::93.184.216.34"},
+		{addr: "::10.0.0.1", internal: true},
+		{addr: "::169.254.169.254", internal: true},
+		{addr: "2001:db8::1"},
+		{addr: "fd00::1", internal: true},
+		{addr: "fe80::1", internal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			inner := new(dnstest.MockResolver)
+			inner.On("LookupNetIP", mock.Anything, "ip", "www.example.com").Return([]netip.Addr{netip.MustParseAddr(tt.addr)}, nil)
+
+			res := resolver.Rebinding(inner, &resolver.RebindingResolverConfig{})
+
+			_, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+			if tt.internal {
+				require.ErrorIs(t, err, resolver.ErrRebinding)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,517 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RecordedExchange is a query sent to a DNS server, and the response that was
+// received for it.
+type RecordedExchange struct {
+	// Server is the address of the server.
+	Server string `json:"server"`
+	// Name is the queried name, for readability.
+	Name string `json:"name"`
+	// Type is the queried record type, for readability.
+	Type string `json:"type"`
+	// Query is the query in wire format.
+	Query []byte `json:"query"`
+	// Response is the response in wire format.
+	Response []byte `json:"response"`
+}
+
+// Recorder records the queries and responses exchanged with DNS servers, so
+// that they can later be replayed offline using a Replayer. It is used as the
+// dialer of a DNS resolver (see DNSResolverConfig.DialContext). Only
+// unencrypted transports (UDP and TCP) can be recorded.
+type Recorder struct {
+	dialContext DialContextFunc
+	mu          sync.Mutex
+	exchanges   []RecordedExchange
+}
+
+// NewRecorder returns a recorder that dials connections using dialContext, or
+// the system's dialer if nil.
+func NewRecorder(dialContext DialContextFunc) *Recorder {
+	if dialContext == nil {
+		dialContext = (&net.Dialer{}).DialContext
+	}
+
+	return &Recorder{
+		dialContext: dialContext,
+	}
+}
+
+// DialContext dials a connection, recording the exchanges made over it.
+func (r *Recorder) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := r.dialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &recordingConn{
+		Conn:    conn,
+		rec:     r,
+		server:  address,
+		queries: make(map[uint16][]byte),
+	}
+
+	if pc, ok := conn.(net.PacketConn); ok {
+		return &recordingPacketConn{recordingConn: rc, pc: pc}, nil
+	}
+
+	return rc, nil
+}
+
+// Exchanges returns the recorded exchanges, in the order that the responses
+// were received.
+func (r *Recorder) Exchanges() []RecordedExchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]RecordedExchange(nil), r.exchanges...)
+}
+
+// Save writes the recorded exchanges to w, as JSON lines.
+func (r *Recorder) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, exchange := range r.Exchanges() {
+		if err := enc.Encode(exchange); err != nil {
+			return fmt.Errorf("failed to encode exchange: %w", err)
+		}
+	}
+	return nil
+}
+
+// SaveFile writes the recorded exchanges to the file at path.
+func (r *Recorder) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	if err := r.Save(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (r *Recorder) record(server string, query, response []byte) {
+	// Skip anything that isn't a valid exchange (eg. encrypted traffic).
+	req := &dns.Msg{}
+	if err := req.Unpack(query); err != nil || len(req.Question) != 1 {
+		return
+	}
+	if err := (&dns.Msg{}).Unpack(response); err != nil {
+		return
+	}
+
+	exchange := RecordedExchange{
+		Server:   server,
+		Name:     req.Question[0].Name,
+		Type:     dns.TypeToString[req.Question[0].Qtype],
+		Query:    query,
+		Response: response,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.exchanges = append(r.exchanges, exchange)
+}
+
+// recordingConn is a connection that records the exchanges made over it.
+type recordingConn struct {
+	net.Conn
+	rec    *Recorder
+	server string
+	// mu protects the fields below.
+	mu       sync.Mutex
+	queries  map[uint16][]byte
+	written  []byte
+	read     []byte
+	disabled bool
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.onWrite(b[:n], false)
+	return n, err
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.onRead(b[:n], false)
+	return n, err
+}
+
+// onWrite records the queries in b, packet is whether b is a single datagram
+// (otherwise messages are length prefixed).
+func (c *recordingConn) onWrite(b []byte, packet bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, msg := range c.messages(&c.written, b, packet) {
+		c.queries[binary.BigEndian.Uint16(msg)] = msg
+	}
+}
+
+// onRead records the responses in b, along with their matching queries.
+func (c *recordingConn) onRead(b []byte, packet bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, msg := range c.messages(&c.read, b, packet) {
+		id := binary.BigEndian.Uint16(msg)
+		if query, ok := c.queries[id]; ok {
+			delete(c.queries, id)
+			c.rec.record(c.server, query, msg)
+		}
+	}
+}
+
+// messages returns the complete messages in b, buffering any partial
+// messages in buf. Connections carrying anything other than DNS messages (eg.
+// TLS) are not recorded.
+func (c *recordingConn) messages(buf *[]byte, b []byte, packet bool) [][]byte {
+	if c.disabled || len(b) == 0 {
+		return nil
+	}
+
+	if packet {
+		if len(b) < dnsHeaderLen {
+			return nil
+		}
+		return [][]byte{append([]byte(nil), b...)}
+	}
+
+	*buf = append(*buf, b...)
+
+	var msgs [][]byte
+	for len(*buf) >= 2 {
+		length := int(binary.BigEndian.Uint16(*buf))
+		if length < dnsHeaderLen {
+			c.disabled = true
+			return nil
+		}
+		if len(*buf) < 2+length {
+			break
+		}
+
+		msgs = append(msgs, append([]byte(nil), (*buf)[2:2+length]...))
+		*buf = (*buf)[2+length:]
+	}
+
+	return msgs
+}
+
+// recordingPacketConn is a packet oriented recording connection.
+type recordingPacketConn struct {
+	*recordingConn
+	pc net.PacketConn
+}
+
+func (c *recordingPacketConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.onWrite(b[:n], true)
+	return n, err
+}
+
+func (c *recordingPacketConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.onRead(b[:n], true)
+	return n, err
+}
+
+func (c *recordingPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.pc.ReadFrom(b)
+	c.onRead(b[:n], true)
+	return n, addr, err
+}
+
+func (c *recordingPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.pc.WriteTo(b, addr)
+	c.onWrite(b[:n], true)
+	return n, err
+}
+
+// dnsHeaderLen is the length of a DNS message header.
+const dnsHeaderLen = 12
+
+// Replayer answers queries using previously recorded exchanges (see
+// Recorder), without any network access. It is used as the dialer of a DNS
+// resolver (see DNSResolverConfig.DialContext).
+//
+// Queries are matched to recorded exchanges by name, type and class
+// (regardless of the server). If a query was recorded more than once, the
+// responses are replayed in turn. Queries without a recorded exchange fail.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges map[replayKey][][]byte
+	next      map[replayKey]int
+}
+
+type replayKey struct {
+	name   string
+	qType  uint16
+	qClass uint16
+}
+
+// NewReplayer returns a replayer for the given exchanges.
+func NewReplayer(exchanges []RecordedExchange) (*Replayer, error) {
+	r := &Replayer{
+		exchanges: make(map[replayKey][][]byte),
+		next:      make(map[replayKey]int),
+	}
+
+	for _, exchange := range exchanges {
+		req := &dns.Msg{}
+		if err := req.Unpack(exchange.Query); err != nil {
+			return nil, fmt.Errorf("failed to unpack recorded query: %w", err)
+		}
+		if len(req.Question) != 1 {
+			return nil, errors.New("recorded query does not have exactly one question")
+		}
+
+		if err := (&dns.Msg{}).Unpack(exchange.Response); err != nil {
+			return nil, fmt.Errorf("failed to unpack recorded response for %s: %w", req.Question[0].Name, err)
+		}
+
+		key := keyOf(req.Question[0])
+		r.exchanges[key] = append(r.exchanges[key], exchange.Response)
+	}
+
+	return r, nil
+}
+
+// LoadReplayer returns a replayer for the exchanges read from rd, as written
+// by Recorder.Save.
+func LoadReplayer(rd io.Reader) (*Replayer, error) {
+	var exchanges []RecordedExchange
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var exchange RecordedExchange
+		if err := json.Unmarshal([]byte(line), &exchange); err != nil {
+			return nil, fmt.Errorf("failed to decode exchange: %w", err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return NewReplayer(exchanges)
+}
+
+// LoadReplayerFile returns a replayer for the exchanges read from the file at
+// path, as written by Recorder.SaveFile.
+func LoadReplayerFile(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	return LoadReplayer(f)
+}
+
+// DialContext returns a connection over which queries are answered using the
+// recorded exchanges.
+func (r *Replayer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+		addr, err := net.ResolveUDPAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &replayPacketConn{replayConn: &replayConn{replayer: r, packet: true, remote: addr}}, nil
+	case "tcp", "tcp4", "tcp6":
+		addr, err := net.ResolveTCPAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &replayConn{replayer: r, remote: addr}, nil
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+}
+
+// replay returns the recorded response to query (in wire format).
+func (r *Replayer) replay(query []byte) ([]byte, error) {
+	req := &dns.Msg{}
+	if err := req.Unpack(query); err != nil {
+		return nil, fmt.Errorf("failed to unpack query: %w", err)
+	}
+	if len(req.Question) != 1 {
+		return nil, errors.New("query does not have exactly one question")
+	}
+
+	q := req.Question[0]
+	key := keyOf(q)
+
+	r.mu.Lock()
+	responses := r.exchanges[key]
+	if len(responses) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s %s", q.Name, dns.TypeToString[q.Qtype])
+	}
+	p := responses[r.next[key]%len(responses)]
+	r.next[key]++
+	r.mu.Unlock()
+
+	reply := &dns.Msg{}
+	if err := reply.Unpack(p); err != nil {
+		return nil, err
+	}
+
+	// Echo the query, as it was sent (eg. with a randomized case).
+	reply.Id = req.Id
+	reply.Question = req.Question
+
+	return reply.Pack()
+}
+
+func keyOf(q dns.Question) replayKey {
+	return replayKey{name: dns.CanonicalName(q.Name), qType: q.Qtype, qClass: q.Qclass}
+}
+
+// replayConn is a fake connection to a DNS server, over which queries are
+// answered by a replayer.
+type replayConn struct {
+	replayer *Replayer
+	packet   bool
+	remote   net.Addr
+	mu       sync.Mutex
+	written  []byte
+	pending  [][]byte
+	closed   bool
+}
+
+func (c *replayConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	var queries [][]byte
+	if c.packet {
+		queries = [][]byte{b}
+	} else {
+		c.written = append(c.written, b...)
+		for len(c.written) >= 2 {
+			length := int(binary.BigEndian.Uint16(c.written))
+			if len(c.written) < 2+length {
+				break
+			}
+			queries = append(queries, c.written[2:2+length])
+			c.written = c.written[2+length:]
+		}
+	}
+
+	for _, query := range queries {
+		response, err := c.replayer.replay(query)
+		if err != nil {
+			return 0, err
+		}
+
+		if !c.packet {
+			response = append(binary.BigEndian.AppendUint16(nil, uint16(len(response))), response...)
+		}
+		c.pending = append(c.pending, response)
+	}
+
+	return len(b), nil
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	if len(c.pending) == 0 {
+		// Responses are always available as soon as the query is written,
+		// so there is nothing more to read.
+		if c.packet {
+			return 0, os.ErrDeadlineExceeded
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(b, c.pending[0])
+	if c.packet || n == len(c.pending[0]) {
+		c.pending = c.pending[1:]
+	} else {
+		c.pending[0] = c.pending[0][n:]
+	}
+
+	return n, nil
+}
+
+func (c *replayConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	return nil
+}
+
+func (c *replayConn) LocalAddr() net.Addr {
+	if c.packet {
+		return &net.UDPAddr{IP: net.IPv4zero}
+	}
+	return &net.TCPAddr{IP: net.IPv4zero}
+}
+
+func (c *replayConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *replayConn) SetDeadline(t time.Time) error      { return nil }
+func (c *replayConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *replayConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// replayPacketConn is a packet oriented replay connection.
+type replayPacketConn struct {
+	*replayConn
+}
+
+func (c *replayPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.remote, err
+}
+
+func (c *replayPacketConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return c.Write(b)
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	expected := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("2001:db8::1"),
+	}
+
+	for _, transport := range []resolver.DNSTransport{resolver.DNSTransportUDP, resolver.DNSTransportTCP} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := dnstest.NewServer(dnstest.Records{
+				"www.example.com":   {"A 192.0.2.1", "AAAA 2001:db8::1"},
+				"alias.example.com": {"CNAME www.example.com."},
+			})
+
+			rec := resolver.NewRecorder(nil)
+
+			res := resolver.DNS(resolver.DNSResolverConfig{
+				Server:      srv.Addr,
+				Transport:   ptr.To(transport),
+				DialContext: rec.DialContext,
+			})
+
+			addrs, err := res.LookupNetIP(context.Background(), "ip", "alias.example.com")
+			require.NoError(t, err)
+			require.ElementsMatch(t, expected, addrs)
+
+			_, err = res.LookupNetIP(context.Background(), "ip4", "missing.example.com")
+			require.True(t, resolver.IsNXDomain(err))
+
+			require.Len(t, rec.Exchanges(), 3)
+
+			path := filepath.Join(t.TempDir(), "recording.jsonl")
+			require.NoError(t, rec.SaveFile(path))
+
+			// Replay without the server.
+			srv.Close()
+
+			replayer, err := resolver.LoadReplayerFile(path)
+			require.NoError(t, err)
+
+			res = resolver.DNS(resolver.DNSResolverConfig{
+				Server:            srv.Addr,
+				Transport:         ptr.To(transport),
+				DialContext:       replayer.DialContext,
+				CaseRandomization: ptr.To(true),
+			})
+
+			addrs, err = res.LookupNetIP(context.Background(), "ip", "alias.example.com")
+			require.NoError(t, err)
+			require.ElementsMatch(t, expected, addrs)
+
+			_, err = res.LookupNetIP(context.Background(), "ip4", "missing.example.com")
+			require.True(t, resolver.IsNXDomain(err))
+
+			_, err = res.LookupNetIP(context.Background(), "ip", "unrecorded.example.com")
+			require.Error(t, err)
+			require.False(t, resolver.IsNXDomain(err))
+		})
+	}
+
+	t.Run("Invalid Recording", func(t *testing.T) {
+		_, err := resolver.LoadReplayer(bytes.NewBufferString("{\"query\": \"AAAA\"}\n"))
+		require.Error(t, err)
+	})
+}
@@ -13,6 +13,8 @@ import (
 	"context"
 	"errors"
 	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/miekg/dns"
@@ -29,19 +31,33 @@ type RelativeResolverConfig struct {
 	Search []string
 	// NDots is the number of dots in a name to trigger an absolute lookup.
 	NDots *int
+	// NoSearchQualified disables appending search domains to names that
+	// contain a dot (regardless of NDots), as they are likely to already be
+	// fully qualified.
+	NoSearchQualified *bool
+	// NoSingleLabel prevents single-label names (eg. "intranet") from being
+	// looked up as is (ie. as a top-level domain), so that internal hostnames
+	// are never leaked to public resolvers. They are only looked up with the
+	// search domains appended.
+	NoSingleLabel *bool
 }
 
 type relativeResolver struct {
-	resolver Resolver
-	search   []string
-	nDots    int
+	conf              *RelativeResolverConfig
+	resolver          Resolver
+	search            []string
+	nDots             int
+	noSearchQualified bool
+	noSingleLabel     bool
 }
 
 // Relative returns a resolver that resolves relative hostnames.
 func Relative(resolver Resolver, conf *RelativeResolverConfig) *relativeResolver {
 	conf, err := defaults.WithDefaults(conf, &RelativeResolverConfig{
-		Search: []string{"."},
-		NDots:  ptr.To(1),
+		Search:            []string{"."},
+		NDots:             ptr.To(1),
+		NoSearchQualified: ptr.To(false),
+		NoSingleLabel:     ptr.To(false),
 	})
 	if err != nil {
 		// Should never happen.
@@ -49,16 +65,39 @@ func Relative(resolver Resolver, conf *RelativeResolverConfig) *relativeResolver
 	}
 
 	return &relativeResolver{
-		resolver: resolver,
-		search:   conf.Search,
-		nDots:    *conf.NDots,
+		conf:              conf,
+		resolver:          resolver,
+		search:            conf.Search,
+		nDots:             *conf.NDots,
+		noSearchQualified: *conf.NoSearchQualified,
+		noSingleLabel:     *conf.NoSingleLabel,
 	}
 }
 
+// With returns a new relative resolver derived from this one, with the fields
+// set in conf overriding the original configuration (eg. to use different
+// search domains). The underlying resolver (and any caches) is shared.
+func (r *relativeResolver) With(conf *RelativeResolverConfig) *relativeResolver {
+	conf, err := defaults.WithDefaults(conf, r.conf)
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	return Relative(r.resolver, conf)
+}
+
+// Clone returns a copy of the resolver, sharing the underlying resolver.
+func (r *relativeResolver) Clone() *relativeResolver {
+	return r.With(nil)
+}
+
 func (r *relativeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
 	names := []string{dns.Fqdn(host)}
 
-	if nDots := strings.Count(host, "."); !strings.HasSuffix(host, ".") && nDots < r.nDots {
+	nDots := strings.Count(host, ".")
+	rooted := strings.HasSuffix(host, ".")
+	if !rooted && nDots < r.nDots && !(r.noSearchQualified && nDots > 0) {
 		// If the name has fewer dots than the threshold, append the search
 		// domains to the name.
 		names = nil
@@ -70,8 +109,20 @@ func (r *relativeResolver) LookupNetIP(ctx context.Context, network, host string
 		}
 	}
 
+	if r.noSingleLabel && !rooted {
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return dns.CountLabel(name) < 2
+		})
+
+		if len(names) == 0 {
+			return nil, newError(host, "", ErrNoSuchHost)
+		}
+	}
+
 	var errs []error
 	for _, name := range names {
+		traceEvent(ctx, TraceEvent{Type: TraceEventSearch, Name: name})
+
 		addrs, err := r.resolver.LookupNetIP(ctx, network, name)
 		if err == nil {
 			return addrs, nil
@@ -81,3 +132,19 @@ func (r *relativeResolver) LookupNetIP(ctx context.Context, network, host string
 
 	return nil, errors.Join(errs...)
 }
+
+// Close closes the underlying resolver.
+func (r *relativeResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *relativeResolver) describe() Description {
+	return Description{
+		Type: "relative",
+		Attributes: map[string]string{
+			"search": strings.Join(r.search, ","),
+			"ndots":  strconv.Itoa(r.nDots),
+		},
+		Children: []Description{Describe(r.resolver)},
+	}
+}
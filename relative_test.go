@@ -16,13 +16,14 @@ import (
 	"testing"
 
 	"github.com/noisysockets/resolver"
-	"github.com/noisysockets/resolver/internal/testutil"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRelativeResolver(t *testing.T) {
-	inner := new(testutil.MockResolver)
+	inner := new(dnstest.MockResolver)
 	inner.On("LookupNetIP", mock.Anything, "ip", "www.example.com.").Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
 	inner.On("LookupNetIP", mock.Anything, "ip", "www.foobar.com.").Return([]netip.Addr{netip.MustParseAddr("10.0.0.2")}, nil)
 	inner.On("LookupNetIP", mock.Anything, "ip", mock.Anything).Return([]netip.Addr{}, &net.DNSError{
@@ -55,4 +56,64 @@ func TestRelativeResolver(t *testing.T) {
 		require.ErrorAs(t, err, &dnsErr)
 		require.Equal(t, resolver.ErrNoSuchHost.Error(), dnsErr.Err)
 	})
+
+	t.Run("With", func(t *testing.T) {
+		derived := res.With(&resolver.RelativeResolverConfig{
+			Search: []string{"foobar.com."},
+		})
+
+		addrs, err := derived.LookupNetIP(context.Background(), "ip", "www")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.2")}, addrs)
+
+		// The original resolver is unchanged.
+		addrs, err = res.Clone().LookupNetIP(context.Background(), "ip", "www")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+	})
+}
+
+func TestRelativeResolverLeakPrevention(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", "intranet.corp.example.").Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", "www.foobar.com.").Return([]netip.Addr{netip.MustParseAddr("10.0.0.2")}, nil)
+	inner.On("LookupNetIP", mock.Anything, "ip", mock.Anything).Return([]netip.Addr{}, &net.DNSError{
+		Err:        resolver.ErrNoSuchHost.Error(),
+		IsNotFound: true,
+	})
+
+	res := resolver.Relative(inner, &resolver.RelativeResolverConfig{
+		Search:            []string{"corp.example.", "."},
+		NDots:             ptr.To(3),
+		NoSearchQualified: ptr.To(true),
+		NoSingleLabel:     ptr.To(true),
+	})
+
+	t.Run("Single Label", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "intranet")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+
+		inner.Calls = nil
+
+		_, err = res.LookupNetIP(context.Background(), "ip", "printer")
+		require.True(t, resolver.IsNXDomain(err))
+
+		// The bare single-label name is never looked up.
+		inner.AssertCalled(t, "LookupNetIP", mock.Anything, "ip", "printer.corp.example.")
+		inner.AssertNotCalled(t, "LookupNetIP", mock.Anything, "ip", "printer.")
+	})
+
+	t.Run("Qualified", func(t *testing.T) {
+		inner.Calls = nil
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "www.foobar.com")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.2")}, addrs)
+
+		// Search domains are not appended, despite NDots.
+		inner.AssertNumberOfCalls(t, "LookupNetIP", 1)
+	})
 }
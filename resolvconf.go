@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Limits on resolv.conf options imposed by glibc (see resolv.conf(5)).
+	maxResolvConfNDots    = 15
+	maxResolvConfAttempts = 5
+	maxResolvConfTimeout  = 30
+)
+
+// MarshalResolvConf renders a configuration in the resolv.conf(5) format,
+// as nameserver, search and options lines. Only the servers, search domains
+// and lookup options are rendered, the hosts file, cache and filters are
+// not the concern of resolv.conf.
+//
+// An error is returned if the configuration can't be expressed in
+// resolv.conf, eg. if it has routes, uses encrypted transports, or servers
+// listening on ports other than 53.
+func MarshalResolvConf(conf *Config) ([]byte, error) {
+	if len(conf.Servers) == 0 {
+		return nil, errors.New("no servers configured")
+	}
+
+	if len(conf.Routes) > 0 {
+		return nil, errors.New("routes cannot be expressed in resolv.conf")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by github.com/noisysockets/resolver.\n")
+
+	var options []string
+	var transport string
+	var timeout time.Duration
+	for i, serverConf := range conf.Servers {
+		addr, err := resolvConfServer(serverConf.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		switch serverConf.Transport {
+		case "", "udp", "tcp":
+		default:
+			return nil, fmt.Errorf("server %q: transport %q cannot be expressed in resolv.conf", serverConf.Address, serverConf.Transport)
+		}
+
+		if i > 0 && serverConf.Transport != transport {
+			return nil, errors.New("servers with different transports cannot be expressed in resolv.conf")
+		}
+		transport = serverConf.Transport
+
+		if i > 0 && time.Duration(serverConf.Timeout) != timeout {
+			return nil, errors.New("servers with different timeouts cannot be expressed in resolv.conf")
+		}
+		timeout = time.Duration(serverConf.Timeout)
+
+		fmt.Fprintf(&buf, "nameserver %s\n", addr)
+	}
+
+	if len(conf.Search) > 0 {
+		search := make([]string, 0, len(conf.Search))
+		for _, domain := range conf.Search {
+			search = append(search, strings.TrimSuffix(domain, "."))
+		}
+		fmt.Fprintf(&buf, "search %s\n", strings.Join(search, " "))
+	}
+
+	if conf.NDots != nil {
+		options = append(options, fmt.Sprintf("ndots:%d", min(max(*conf.NDots, 0), maxResolvConfNDots)))
+	}
+
+	if timeout > 0 {
+		seconds := int(math.Ceil(timeout.Seconds()))
+		options = append(options, fmt.Sprintf("timeout:%d", min(seconds, maxResolvConfTimeout)))
+	}
+
+	if conf.Attempts != nil {
+		options = append(options, fmt.Sprintf("attempts:%d", min(max(*conf.Attempts, 1), maxResolvConfAttempts)))
+	}
+
+	switch conf.Strategy {
+	case "", "sequential":
+	case "round-robin":
+		options = append(options, "rotate")
+	default:
+		return nil, fmt.Errorf("strategy %q cannot be expressed in resolv.conf", conf.Strategy)
+	}
+
+	if transport == "tcp" {
+		options = append(options, "use-vc")
+	}
+
+	if len(options) > 0 {
+		fmt.Fprintf(&buf, "options %s\n", strings.Join(options, " "))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SaveResolvConf atomically replaces the resolv.conf file at path (eg.
+// "/etc/resolv.conf") with the rendered configuration, by writing to a
+// temporary file in the same directory and renaming it. The permissions of
+// the original file are retained.
+//
+// Inside containers resolv.conf is often a bind mount, which can't be
+// replaced by renaming, in which case it is overwritten in place.
+func SaveResolvConf(path string, conf *Config) error {
+	data, err := MarshalResolvConf(conf)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat resolv.conf: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write resolv.conf: %w", err)
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync resolv.conf: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close resolv.conf: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		if !errors.Is(err, syscall.EBUSY) {
+			return fmt.Errorf("failed to replace resolv.conf: %w", err)
+		}
+
+		if err := os.WriteFile(path, data, mode); err != nil {
+			return fmt.Errorf("failed to overwrite resolv.conf: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// resolvConfServer returns the address of a server, as written in a
+// resolv.conf nameserver line.
+func resolvConfServer(address string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("invalid server address %q", address)
+		}
+
+		if addrPort.Port() != 53 {
+			return netip.Addr{}, fmt.Errorf("server %q: ports other than 53 cannot be expressed in resolv.conf", address)
+		}
+
+		addr = addrPort.Addr()
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalResolvConf(t *testing.T) {
+	conf := &resolver.Config{
+		Servers: []resolver.ServerConfig{
+			{Address: "192.0.2.53", Timeout: resolver.Duration(1500 * time.Millisecond)},
+			{Address: "[2001:db8::53]:53", Timeout: resolver.Duration(1500 * time.Millisecond)},
+		},
+		Strategy: "round-robin",
+		Search:   []string{"corp.example.", "example.com"},
+		NDots:    ptr.To(2),
+		Attempts: ptr.To(3),
+	}
+
+	data, err := resolver.MarshalResolvConf(conf)
+	require.NoError(t, err)
+
+	require.Equal(t, `# Generated by github.com/noisysockets/resolver.
+nameserver 192.0.2.53
+nameserver 2001:db8::53
+search corp.example example.com
+options ndots:2 timeout:2 attempts:3 rotate
+`, string(data))
+
+	t.Run("Save", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resolv.conf")
+		require.NoError(t, os.WriteFile(path, []byte("nameserver 127.0.0.1\n"), 0o600))
+
+		require.NoError(t, resolver.SaveResolvConf(path, conf))
+
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+		saved, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, data, saved)
+	})
+
+	t.Run("Inexpressible", func(t *testing.T) {
+		for name, conf := range map[string]*resolver.Config{
+			"No Servers": {},
+			"Port":       {Servers: []resolver.ServerConfig{{Address: "192.0.2.53:5353"}}},
+			"Transport":  {Servers: []resolver.ServerConfig{{Address: "192.0.2.53", Transport: "tls"}}},
+			"Strategy":   {Servers: []resolver.ServerConfig{{Address: "192.0.2.53"}}, Strategy: "parallel"},
+			"Routes": {
+				Servers: []resolver.ServerConfig{{Address: "192.0.2.53"}},
+				Routes:  []resolver.RouteConfig{{Domains: []string{"corp.example"}}},
+			},
+		} {
+			_, err := resolver.MarshalResolvConf(conf)
+			require.Error(t, err, name)
+		}
+	})
+}
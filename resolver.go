@@ -45,6 +45,12 @@ import (
 	"net/netip"
 )
 
+var (
+	_ AddrResolver = (*net.Resolver)(nil)
+	_ AddrResolver = (*dnsResolver)(nil)
+	_ AddrResolver = (*ZoneResolver)(nil)
+)
+
 // DialContextFunc is a network dialer that can be used to dial a network.
 type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
 
@@ -56,3 +62,10 @@ type Resolver interface {
 	// one of "ip", "ip4" or "ip6".
 	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
 }
+
+// AddrResolver looks up the names of an address (PTR records), it is
+// implemented by *net.Resolver, *ZoneResolver and DNS resolvers.
+type AddrResolver interface {
+	// LookupAddr returns the names mapping to the given address.
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
@@ -11,7 +11,10 @@ package resolver
 
 import (
 	"context"
+	"log/slog"
+	"net"
 	"net/netip"
+	"strconv"
 
 	"github.com/avast/retry-go/v4"
 	"github.com/noisysockets/util/defaults"
@@ -25,18 +28,22 @@ type RetryResolverConfig struct {
 	// Attempts is the number of attempts to make before giving up.
 	// Setting this to 0 will cause the resolver to retry indefinitely.
 	Attempts *int
+	// Logger is an optional logger, retries are logged at debug level.
+	Logger *slog.Logger
 }
 
 // retryResolver is a resolver that retries a resolver a number of times.
 type retryResolver struct {
 	resolver Resolver
 	attempts int
+	logger   *slog.Logger
 }
 
 // Retry returns a resolver that retries a resolver a number of times.
 func Retry(resolver Resolver, conf *RetryResolverConfig) *retryResolver {
 	conf, err := defaults.WithDefaults(conf, &RetryResolverConfig{
 		Attempts: ptr.To(2), // glibc defaults to 2 attempts.
+		Logger:   discardLogger,
 	})
 	if err != nil {
 		// Should never happen.
@@ -46,16 +53,58 @@ func Retry(resolver Resolver, conf *RetryResolverConfig) *retryResolver {
 	return &retryResolver{
 		resolver: resolver,
 		attempts: *conf.Attempts,
+		logger:   conf.Logger,
 	}
 }
 
 func (r *retryResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
 	return retry.DoWithData(func() ([]netip.Addr, error) {
 		return r.resolver.LookupNetIP(ctx, network, host)
-	},
+	}, r.options(ctx, host)...)
+}
+
+// LookupMX retries looking up the MX records for name, the underlying
+// resolver must implement MailResolver.
+func (r *retryResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	mailResolver, ok := r.resolver.(MailResolver)
+	if !ok {
+		return nil, newError(name, "", ErrNoSuchHost)
+	}
+
+	return retry.DoWithData(func() ([]*net.MX, error) {
+		return mailResolver.LookupMX(ctx, name)
+	}, r.options(ctx, name)...)
+}
+
+func (r *retryResolver) options(ctx context.Context, host string) []retry.Option {
+	attempts := r.attempts
+	if override, ok := LookupAttempts(ctx); ok {
+		attempts = override
+	}
+
+	return []retry.Option{
 		retry.Context(ctx),
-		retry.Attempts(uint(r.attempts)),
+		retry.Attempts(uint(attempts)),
 		retry.RetryIf(isTemporary),
 		retry.LastErrorOnly(true),
-	)
+		retry.OnRetry(func(attempt uint, err error) {
+			r.logger.LogAttrs(ctx, slog.LevelDebug, "Lookup attempt failed",
+				slog.String("host", host),
+				slog.Uint64("attempt", uint64(attempt)+1),
+				slog.String("error", err.Error()))
+		}),
+	}
+}
+
+// Close closes the underlying resolver.
+func (r *retryResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *retryResolver) describe() Description {
+	return Description{
+		Type:       "retry",
+		Attributes: map[string]string{"attempts": strconv.Itoa(r.attempts)},
+		Children:   []Description{Describe(r.resolver)},
+	}
 }
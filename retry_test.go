@@ -16,13 +16,13 @@ import (
 	"testing"
 
 	"github.com/noisysockets/resolver"
-	"github.com/noisysockets/resolver/internal/testutil"
+	"github.com/noisysockets/resolver/dnstest"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRetryResolver(t *testing.T) {
-	inner := new(testutil.MockResolver)
+	inner := new(dnstest.MockResolver)
 	inner.On("LookupNetIP", mock.Anything, mock.Anything, "notfound.com").Return([]netip.Addr{}, &net.DNSError{
 		Err:        resolver.ErrNoSuchHost.Error(),
 		IsNotFound: true,
@@ -0,0 +1,131 @@
+//go:build go1.23
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/netip"
+	"sync"
+
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+// ReverseRangeConfig is the configuration for a reverse range lookup.
+type ReverseRangeConfig struct {
+	// Concurrency is the maximum number of lookups in flight at once.
+	// Defaults to 16.
+	Concurrency *int
+	// SkipNotFound omits addresses without any names (NXDOMAIN or NODATA)
+	// from the results, as is the case for most addresses in a typical
+	// network. Defaults to true.
+	SkipNotFound *bool
+}
+
+// ReverseResult is the result of looking up the names of a single address.
+type ReverseResult struct {
+	// Addr is the address that was looked up.
+	Addr netip.Addr
+	// Names are the names mapping to the address, if the lookup succeeded.
+	Names []string
+	// Err is the error, if the lookup failed.
+	Err error
+}
+
+// ReverseRange returns an iterator over the names of every address in prefix,
+// eg. for documenting the hosts of a network. Results are yielded as lookups
+// complete, and so are not ordered by address. Breaking out of the loop
+// cancels any outstanding lookups.
+//
+// Addresses are enumerated lazily, but the prefix should still be small
+// enough that every address can be looked up (eg. an IPv4 /16 at most).
+func ReverseRange(ctx context.Context, resolver AddrResolver, prefix netip.Prefix, conf *ReverseRangeConfig) iter.Seq[ReverseResult] {
+	conf, err := defaults.WithDefaults(conf, &ReverseRangeConfig{
+		Concurrency:  ptr.To(16),
+		SkipNotFound: ptr.To(true),
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	return func(yield func(ReverseResult) bool) {
+		if !prefix.IsValid() {
+			yield(ReverseResult{Err: fmt.Errorf("invalid prefix: %s", prefix)})
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		addrs := make(chan netip.Addr)
+		go func() {
+			defer close(addrs)
+
+			prefix = prefix.Masked()
+			for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+				select {
+				case addrs <- addr:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		results := make(chan ReverseResult)
+
+		var wg sync.WaitGroup
+		for i := 0; i < max(*conf.Concurrency, 1); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for addr := range addrs {
+					names, err := resolver.LookupAddr(ctx, addr.String())
+					if err != nil {
+						if ctx.Err() != nil {
+							return
+						}
+
+						if *conf.SkipNotFound && (IsNXDomain(err) || IsNoData(err)) {
+							continue
+						}
+					}
+
+					select {
+					case results <- ReverseResult{Addr: addr, Names: names, Err: err}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		go func() {
+			wg.Wait()
+
+			close(results)
+		}()
+
+		for result := range results {
+			if !yield(result) {
+				return
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			yield(ReverseResult{Err: err})
+		}
+	}
+}
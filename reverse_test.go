@@ -0,0 +1,82 @@
+//go:build go1.23
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseRange(t *testing.T) {
+	res, err := resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFilePath: "testdata/example.zone",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	prefix := netip.MustParsePrefix("10.0.0.0/27")
+
+	t.Run("Skip Not Found", func(t *testing.T) {
+		found := map[netip.Addr][]string{}
+		for result := range resolver.ReverseRange(ctx, res, prefix, nil) {
+			require.NoError(t, result.Err)
+			found[result.Addr] = result.Names
+		}
+
+		require.Equal(t, map[netip.Addr][]string{
+			netip.MustParseAddr("10.0.0.10"): {"www.example.internal."},
+			netip.MustParseAddr("10.0.0.20"): {"v4only.example.internal."},
+		}, found)
+	})
+
+	t.Run("Report Not Found", func(t *testing.T) {
+		var results, failed int
+		for result := range resolver.ReverseRange(ctx, res, prefix, &resolver.ReverseRangeConfig{
+			Concurrency:  ptr.To(4),
+			SkipNotFound: ptr.To(false),
+		}) {
+			results++
+			if result.Err != nil {
+				require.True(t, resolver.IsNXDomain(result.Err))
+				failed++
+			}
+		}
+
+		require.Equal(t, 32, results)
+		require.Equal(t, 30, failed)
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		var results int
+		for range resolver.ReverseRange(ctx, res, netip.MustParsePrefix("10.0.0.0/16"), &resolver.ReverseRangeConfig{
+			SkipNotFound: ptr.To(false),
+		}) {
+			results++
+			if results == 5 {
+				break
+			}
+		}
+
+		require.Equal(t, 5, results)
+	})
+
+	t.Run("Invalid Prefix", func(t *testing.T) {
+		for result := range resolver.ReverseRange(ctx, res, netip.Prefix{}, nil) {
+			require.Error(t, result.Err)
+		}
+	})
+}
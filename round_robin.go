@@ -22,6 +22,7 @@ var _ Resolver = (*roundRobinResolver)(nil)
 // using a round-robin strategy.
 type roundRobinResolver struct {
 	resolvers []Resolver
+	rand      Rand
 }
 
 // RoundRobin returns a Resolver that load balances between multiple resolvers
@@ -32,10 +33,32 @@ func RoundRobin(resolvers ...Resolver) *roundRobinResolver {
 	}
 }
 
+// WithRand returns a copy of the resolver that shuffles the resolvers using
+// rnd, for deterministic tests (see Rand).
+func (r *roundRobinResolver) WithRand(rnd Rand) *roundRobinResolver {
+	return &roundRobinResolver{
+		resolvers: r.resolvers,
+		rand:      rnd,
+	}
+}
+
 func (r *roundRobinResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
 	rotatedResolvers := make([]Resolver, len(r.resolvers))
 	copy(rotatedResolvers, r.resolvers)
-	rotatedResolvers = util.Shuffle(rotatedResolvers)
+	if r.rand != nil {
+		rotatedResolvers = shuffle(r.rand, rotatedResolvers)
+	} else {
+		rotatedResolvers = util.Shuffle(rotatedResolvers)
+	}
 
 	return Sequential(rotatedResolvers...).LookupNetIP(ctx, network, host)
 }
+
+// Close closes the underlying resolvers.
+func (r *roundRobinResolver) Close() error {
+	return closeAll(r.resolvers)
+}
+
+func (r *roundRobinResolver) describe() Description {
+	return Description{Type: "round-robin", Children: describeAll(r.resolvers)}
+}
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver/util"
+)
+
+var _ Resolver = (*routingResolver)(nil)
+
+// Route sends lookups for names within a set of domains to a resolver.
+type Route struct {
+	// Domains is the list of domains (and their subdomains) matched by this
+	// route.
+	Domains []string
+	// Resolver is used to answer lookups matching this route.
+	Resolver Resolver
+	// Search is an optional list of search domains, appended to relative
+	// names before they are routed (eg. so that "wiki" is looked up as
+	// "wiki.corp.example" using this route's resolver).
+	Search []string
+	// NDots is the number of dots a relative name must have to not have the
+	// route's search domains appended. Defaults to 1.
+	NDots *int
+}
+
+// RoutingResolverConfig is the configuration for a routing resolver.
+type RoutingResolverConfig struct {
+	// Routes is the list of routes, the route with the most specific matching
+	// domain is used.
+	Routes []Route
+	// Default is an optional resolver used when no route matches the name.
+	// By default, lookups for unmatched names fail with ErrNoSuchHost.
+	Default Resolver
+	// DefaultSearch is an optional list of search domains, appended to
+	// relative names after those of every route.
+	DefaultSearch []string
+	// DefaultNDots is the number of dots a relative name must have to not
+	// have the default search domains appended. Defaults to 1.
+	DefaultNDots *int
+}
+
+// routingResolver is a resolver that selects a child resolver based on the
+// name being looked up (aka. split DNS).
+type routingResolver struct {
+	routes          []Route
+	defaultResolver Resolver
+	// search are the search lists of each route, followed by the default
+	// search list, in the order they are tried.
+	search []routeSearch
+}
+
+// routeSearch is a search list, and the number of dots a name must have to
+// not have it applied.
+type routeSearch struct {
+	domains []string
+	nDots   int
+}
+
+// Routing returns a resolver that selects a child resolver based on the
+// domain of the name being looked up.
+func Routing(conf *RoutingResolverConfig) *routingResolver {
+	if conf == nil {
+		conf = &RoutingResolverConfig{}
+	}
+
+	var search []routeSearch
+	routes := make([]Route, len(conf.Routes))
+	for i, route := range conf.Routes {
+		routes[i] = Route{Resolver: route.Resolver, Search: route.Search, NDots: route.NDots}
+		for _, domain := range route.Domains {
+			routes[i].Domains = append(routes[i].Domains, dns.CanonicalName(domain))
+		}
+
+		if len(route.Search) > 0 {
+			search = append(search, newRouteSearch(route.Search, route.NDots))
+		}
+	}
+
+	if len(conf.DefaultSearch) > 0 {
+		search = append(search, newRouteSearch(conf.DefaultSearch, conf.DefaultNDots))
+	}
+
+	return &routingResolver{
+		routes:          routes,
+		defaultResolver: conf.Default,
+		search:          search,
+	}
+}
+
+func newRouteSearch(domains []string, nDots *int) routeSearch {
+	s := routeSearch{nDots: 1}
+	if nDots != nil {
+		s.nDots = *nDots
+	}
+
+	for _, domain := range domains {
+		s.domains = append(s.domains, dns.CanonicalName(domain))
+	}
+
+	return s
+}
+
+func (r *routingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	names := r.searchNames(host)
+	if len(names) == 0 {
+		return r.lookupRouted(ctx, network, host)
+	}
+
+	// The name as given is tried last, as the default route would.
+	names = append(names, host)
+
+	var errs []error
+	for _, name := range names {
+		traceEvent(ctx, TraceEvent{Type: TraceEventSearch, Name: name})
+
+		addrs, err := r.lookupRouted(ctx, network, name)
+		if err == nil {
+			return addrs, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// searchNames returns the names formed by appending the search domains of
+// each route to a relative host, in the order they should be tried.
+func (r *routingResolver) searchNames(host string) []string {
+	if strings.HasSuffix(host, ".") {
+		return nil
+	}
+
+	var names []string
+	dots := strings.Count(host, ".")
+	for _, search := range r.search {
+		if dots >= search.nDots {
+			continue
+		}
+
+		for _, domain := range search.domains {
+			name := util.Join(host, domain)
+			if _, ok := dns.IsDomainName(name); ok && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// lookupRouted looks up host using the resolver of the matching route.
+func (r *routingResolver) lookupRouted(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	resolver := r.defaultResolver
+	if route, ok := r.match(host); ok {
+		resolver = route.Resolver
+	}
+
+	if resolver == nil {
+		return nil, newError(host, "", ErrNoSuchHost)
+	}
+
+	return resolver.LookupNetIP(ctx, network, host)
+}
+
+// match returns the route with the most specific domain containing host.
+func (r *routingResolver) match(host string) (*Route, bool) {
+	name := dns.CanonicalName(host)
+
+	var match *Route
+	labels := -1
+	for i := range r.routes {
+		route := &r.routes[i]
+		for _, domain := range route.Domains {
+			if dns.IsSubDomain(domain, name) && dns.CountLabel(domain) > labels {
+				match = route
+				labels = dns.CountLabel(domain)
+			}
+		}
+	}
+
+	return match, match != nil
+}
+
+// Close closes the resolvers of every route and the default resolver.
+func (r *routingResolver) Close() error {
+	resolvers := make([]Resolver, 0, len(r.routes)+1)
+	for _, route := range r.routes {
+		resolvers = append(resolvers, route.Resolver)
+	}
+	return closeAll(append(resolvers, r.defaultResolver))
+}
+
+func (r *routingResolver) describe() Description {
+	d := Description{Type: "routing"}
+	for _, route := range r.routes {
+		attrs := map[string]string{"domains": strings.Join(route.Domains, ",")}
+		if len(route.Search) > 0 {
+			attrs["search"] = strings.Join(route.Search, ",")
+		}
+
+		d.Children = append(d.Children, Description{
+			Type:       "route",
+			Attributes: attrs,
+			Children:   []Description{Describe(route.Resolver)},
+		})
+	}
+
+	if r.defaultResolver != nil {
+		d.Children = append(d.Children, Description{
+			Type:     "default",
+			Children: []Description{Describe(r.defaultResolver)},
+		})
+	}
+
+	return d
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutingResolver(t *testing.T) {
+	corp := new(dnstest.MockResolver)
+	corp.On("LookupNetIP", mock.Anything, "ip", mock.Anything).
+		Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+
+	lab := new(dnstest.MockResolver)
+	lab.On("LookupNetIP", mock.Anything, "ip", mock.Anything).
+		Return([]netip.Addr{netip.MustParseAddr("10.1.0.1")}, nil)
+
+	public := new(dnstest.MockResolver)
+	public.On("LookupNetIP", mock.Anything, "ip", mock.Anything).
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	routes := []resolver.Route{
+		{Domains: []string{"corp.example", "corp.internal"}, Resolver: corp},
+		{Domains: []string{"lab.corp.example"}, Resolver: lab},
+	}
+
+	t.Run("Most Specific", func(t *testing.T) {
+		res := resolver.Routing(&resolver.RoutingResolverConfig{
+			Routes:  routes,
+			Default: public,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "host.corp.internal")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+
+		addrs, err = res.LookupNetIP(context.Background(), "ip", "host.LAB.corp.example.")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.1.0.1")}, addrs)
+
+		addrs, err = res.LookupNetIP(context.Background(), "ip", "example.com")
+		require.NoError(t, err)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("No Default", func(t *testing.T) {
+		res := resolver.Routing(&resolver.RoutingResolverConfig{
+			Routes: routes,
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip", "example.com")
+		require.Error(t, err)
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.True(t, dnsErr.IsNotFound)
+	})
+}
+
+func TestRoutingResolverSearch(t *testing.T) {
+	corp := new(dnstest.MockResolver)
+	corp.On("LookupNetIP", mock.Anything, "ip", "wiki.corp.example.").
+		Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+	corp.On("LookupNetIP", mock.Anything, "ip", mock.Anything).
+		Return([]netip.Addr(nil), resolver.ErrNoSuchHost)
+
+	lab := new(dnstest.MockResolver)
+	lab.On("LookupNetIP", mock.Anything, "ip", "printer.lab.example.").
+		Return([]netip.Addr{netip.MustParseAddr("10.1.0.1")}, nil)
+	lab.On("LookupNetIP", mock.Anything, "ip", mock.Anything).
+		Return([]netip.Addr(nil), resolver.ErrNoSuchHost)
+
+	public := new(dnstest.MockResolver)
+	public.On("LookupNetIP", mock.Anything, "ip", "www.example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+	public.On("LookupNetIP", mock.Anything, "ip", mock.Anything).
+		Return([]netip.Addr(nil), resolver.ErrNoSuchHost)
+
+	res := resolver.Routing(&resolver.RoutingResolverConfig{
+		Routes: []resolver.Route{
+			{Domains: []string{"corp.example"}, Resolver: corp, Search: []string{"corp.example"}},
+			{Domains: []string{"lab.example"}, Resolver: lab, Search: []string{"lab.example"}},
+		},
+		Default: public,
+	})
+
+	ctx := context.Background()
+
+	for host, expected := range map[string]string{
+		"wiki":            "10.0.0.1",
+		"printer":         "10.1.0.1",
+		"www.example.com": "192.0.2.1",
+	} {
+		addrs, err := res.LookupNetIP(ctx, "ip", host)
+		require.NoError(t, err, host)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr(expected)}, addrs, host)
+	}
+
+	// Qualified names aren't searched.
+	_, err := res.LookupNetIP(ctx, "ip", "wiki.internal")
+	require.True(t, resolver.IsNXDomain(err))
+
+	corp.AssertNotCalled(t, "LookupNetIP", mock.Anything, "ip", "wiki.internal.corp.example.")
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var _ Resolver = (*scopeFilterResolver)(nil)
+
+// AddrScope is a class of addresses, by how far they are routable.
+type AddrScope string
+
+const (
+	// AddrScopeLoopback is a loopback address (127.0.0.0/8 or ::1).
+	AddrScopeLoopback AddrScope = "loopback"
+	// AddrScopeLinkLocal is a link-local unicast or multicast address (eg.
+	// 169.254.0.0/16 or fe80::/10).
+	AddrScopeLinkLocal AddrScope = "link-local"
+	// AddrScopeUniqueLocal is a unique local IPv6 address (fc00::/7), or its
+	// IPv4 counterparts, a private (RFC 1918) or shared (RFC 6598) address.
+	AddrScopeUniqueLocal AddrScope = "unique-local"
+	// AddrScopeUnroutable is any other address that is not globally routable
+	// (eg. unspecified, multicast, reserved, or documentation addresses).
+	AddrScopeUnroutable AddrScope = "unroutable"
+	// AddrScopeGlobal is a globally routable address.
+	AddrScopeGlobal AddrScope = "global"
+)
+
+var (
+	// "This network" (RFC 1122 section 3.2.1.3).
+	thisNetworkPrefix = netip.MustParsePrefix("0.0.0.0/8")
+	// Shared address space, used for carrier-grade NAT (RFC 6598).
+	sharedAddressPrefix = netip.MustParsePrefix("100.64.0.0/10")
+	// The NAT64 well-known prefix (RFC 6052).
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	// 6to4 addresses (RFC 3056).
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+	// Deprecated IPv4-compatible IPv6 addresses (RFC 4291 section 2.5.5.1).
+	ipv4CompatiblePrefix = netip.MustParsePrefix("::/96")
+)
+
+// unroutablePrefixes are special-purpose prefixes that are not globally
+// routable, beyond those identified by the methods of netip.Addr.
+var unroutablePrefixes = []netip.Prefix{
+	thisNetworkPrefix,
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// ScopeOf returns the scope of addr. IPv6 addresses embedding an IPv4 address
+// (eg. NAT64 or 6to4 addresses) have the scope of the embedded address.
+func ScopeOf(addr netip.Addr) AddrScope {
+	addr = canonicalAddr(addr)
+	switch {
+	case addr.IsLoopback():
+		return AddrScopeLoopback
+	case addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast():
+		return AddrScopeLinkLocal
+	case addr.IsPrivate() || sharedAddressPrefix.Contains(addr):
+		return AddrScopeUniqueLocal
+	case !addr.IsGlobalUnicast():
+		return AddrScopeUnroutable
+	}
+
+	for _, prefix := range unroutablePrefixes {
+		if prefix.Contains(addr) {
+			return AddrScopeUnroutable
+		}
+	}
+
+	return AddrScopeGlobal
+}
+
+// canonicalAddr returns the IPv4 address embedded in, or mapped to, addr if
+// there is one, and addr otherwise.
+func canonicalAddr(addr netip.Addr) netip.Addr {
+	addr = addr.Unmap()
+	if embedded, ok := embeddedIPv4(addr); ok {
+		return embedded
+	}
+	return addr
+}
+
+// embeddedIPv4 returns the IPv4 address embedded in a NAT64, 6to4 or
+// IPv4-compatible IPv6 address.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !addr.Is6() {
+		return netip.Addr{}, false
+	}
+
+	b := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[12:])), true
+	case sixToFourPrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[2:6])), true
+	case ipv4CompatiblePrefix.Contains(addr) && !addr.IsUnspecified() && !addr.IsLoopback():
+		return netip.AddrFrom4([4]byte(b[12:])), true
+	default:
+		return netip.Addr{}, false
+	}
+}
+
+// ScopeFilterResolverConfig is the configuration for a scope filtering
+// resolver.
+type ScopeFilterResolverConfig struct {
+	// Exclude are the scopes of addresses removed from answers.
+	Exclude []AddrScope
+	// InternalZones is a list of domains whose answers are not filtered.
+	InternalZones []string
+}
+
+// scopeFilterResolver is a resolver that removes addresses of excluded
+// scopes from answers.
+type scopeFilterResolver struct {
+	resolver      Resolver
+	exclude       []AddrScope
+	internalZones []string
+}
+
+// ScopeFilter returns a resolver that removes addresses of the excluded
+// scopes from the answers of resolver (eg. to drop 127.0.0.1 answers from
+// public DNS), for names outside of the configured internal zones. Unlike
+// Rebinding, the remaining addresses are still returned, if no addresses
+// remain the lookup fails with ErrNoData.
+func ScopeFilter(resolver Resolver, conf *ScopeFilterResolverConfig) *scopeFilterResolver {
+	if conf == nil {
+		conf = &ScopeFilterResolverConfig{}
+	}
+
+	var internalZones []string
+	for _, zone := range conf.InternalZones {
+		internalZones = append(internalZones, dns.CanonicalName(zone))
+	}
+
+	return &scopeFilterResolver{
+		resolver:      resolver,
+		exclude:       slices.Clone(conf.Exclude),
+		internalZones: internalZones,
+	}
+}
+
+func (r *scopeFilterResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	name := dns.CanonicalName(host)
+	for _, zone := range r.internalZones {
+		if dns.IsSubDomain(zone, name) {
+			return addrs, nil
+		}
+	}
+
+	var filtered []netip.Addr
+	for _, addr := range addrs {
+		if !slices.Contains(r.exclude, ScopeOf(addr)) {
+			filtered = append(filtered, addr)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, newError(host, "", ErrNoData)
+	}
+
+	return filtered, nil
+}
+
+// Close closes the underlying resolver.
+func (r *scopeFilterResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *scopeFilterResolver) describe() Description {
+	exclude := make([]string, 0, len(r.exclude))
+	for _, scope := range r.exclude {
+		exclude = append(exclude, string(scope))
+	}
+
+	return Description{
+		Type: "scope-filter",
+		Attributes: map[string]string{
+			"exclude":        strings.Join(exclude, ","),
+			"internal_zones": strings.Join(r.internalZones, ","),
+		},
+		Children: []Description{Describe(r.resolver)},
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeOf(t *testing.T) {
+	tests := map[string]resolver.AddrScope{
+		"127.0.0.1":        resolver.AddrScopeLoopback,
+		"::1":              resolver.AddrScopeLoopback,
+		"169.254.1.1":      resolver.AddrScopeLinkLocal,
+		"fe80::1":          resolver.AddrScopeLinkLocal,
+		"10.1.2.3":         resolver.AddrScopeUniqueLocal,
+		"fd00::1":          resolver.AddrScopeUniqueLocal,
+		"0.0.0.0":          resolver.AddrScopeUnroutable,
+		"224.0.0.251":      resolver.AddrScopeLinkLocal,
+		"239.1.1.1":        resolver.AddrScopeUnroutable,
+		"192.0.2.1":        resolver.AddrScopeUnroutable,
+		"2001:db8::1":      resolver.AddrScopeUnroutable,
+		"8.8.8.8":          resolver.AddrScopeGlobal,
+		"::ffff:127.0.0.1": resolver.AddrScopeLoopback,
+		"2606:4700::1111":  resolver.AddrScopeGlobal,
+		"100.64.0.1":       resolver.AddrScopeUniqueLocal,
+		"64:ff9b::7f00:1":  resolver.AddrScopeLoopback,
+		"64:ff9b::a00:1":   resolver.AddrScopeUniqueLocal,
+		"64:ff9b::808:808": resolver.AddrScopeGlobal,
+		"2002:a9fe:101::1": resolver.AddrScopeLinkLocal,
+		"::127.0.0.1":      resolver.AddrScopeLoopback,
+	}
+
+	for addr, expected := range tests {
+		require.Equal(t, expected, resolver.ScopeOf(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestScopeFilter(t *testing.T) {
+	answer := []netip.Addr{
+		netip.MustParseAddr("127.0.0.1"),
+		netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("fe80::1"),
+		netip.MustParseAddr("8.8.8.8"),
+	}
+
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", mock.Anything).Return(answer, nil)
+
+	res := resolver.ScopeFilter(inner, &resolver.ScopeFilterResolverConfig{
+		Exclude:       []resolver.AddrScope{resolver.AddrScopeLoopback, resolver.AddrScopeLinkLocal},
+		InternalZones: []string{"corp.example"},
+	})
+
+	ctx := context.Background()
+
+	addrs, err := res.LookupNetIP(ctx, "ip", "www.example")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("8.8.8.8")}, addrs)
+
+	t.Run("Internal Zone", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(ctx, "ip", "intranet.corp.example")
+		require.NoError(t, err)
+		require.Equal(t, answer, addrs)
+	})
+
+	t.Run("All Excluded", func(t *testing.T) {
+		res := resolver.ScopeFilter(inner, &resolver.ScopeFilterResolverConfig{
+			Exclude: []resolver.AddrScope{
+				resolver.AddrScopeLoopback,
+				resolver.AddrScopeLinkLocal,
+				resolver.AddrScopeUniqueLocal,
+				resolver.AddrScopeGlobal,
+			},
+		})
+
+		_, err := res.LookupNetIP(ctx, "ip", "www.example")
+		require.True(t, resolver.IsNoData(err))
+	})
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// ErrSecretZeroized is returned when using a secret that has been zeroized.
+var ErrSecretZeroized = errors.New("secret has been zeroized")
+
+const redacted = "[REDACTED]"
+
+// Secret is a credential, such as a TSIG key. Implementations must never
+// reveal the value of the secret when formatted or logged.
+type Secret interface {
+	// Use calls fn with the current value of the secret, the value must not be
+	// retained after fn returns.
+	Use(fn func(value []byte) error) error
+}
+
+var _ Secret = (*RotatingSecret)(nil)
+
+// RotatingSecret is an in-memory Secret that can be rotated and zeroized.
+type RotatingSecret struct {
+	mu    sync.RWMutex
+	value []byte
+}
+
+// NewRotatingSecret returns a new secret with a copy of the given value.
+func NewRotatingSecret(value []byte) *RotatingSecret {
+	s := &RotatingSecret{}
+	s.Rotate(value)
+	return s
+}
+
+func (s *RotatingSecret) Use(fn func(value []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.value == nil {
+		return ErrSecretZeroized
+	}
+
+	return fn(s.value)
+}
+
+// Rotate replaces the value of the secret with a copy of value, the previous
+// value is zeroized. In-flight uses of the secret are not affected.
+func (s *RotatingSecret) Rotate(value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.value)
+	s.value = append(make([]byte, 0, len(value)), value...)
+}
+
+// Zeroize overwrites the value of the secret with zeros, after which the
+// secret can no longer be used (until it is rotated).
+func (s *RotatingSecret) Zeroize() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.value)
+	s.value = nil
+}
+
+func (s *RotatingSecret) String() string {
+	return redacted
+}
+
+func (s *RotatingSecret) GoString() string {
+	return redacted
+}
+
+func (s *RotatingSecret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
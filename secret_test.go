@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingSecret(t *testing.T) {
+	value := []byte("hunter2")
+	secret := resolver.NewRotatingSecret(value)
+
+	// The secret holds its own copy of the value.
+	value[0] = 'H'
+	require.NoError(t, secret.Use(func(value []byte) error {
+		require.Equal(t, "hunter2", string(value))
+		return nil
+	}))
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		require.NotContains(t, fmt.Sprintf(format, secret), "hunter2")
+	}
+
+	var retained []byte
+	require.NoError(t, secret.Use(func(value []byte) error {
+		retained = value
+		return nil
+	}))
+
+	secret.Rotate([]byte("correct horse"))
+	require.Equal(t, make([]byte, len("hunter2")), retained)
+
+	require.NoError(t, secret.Use(func(value []byte) error {
+		require.Equal(t, "correct horse", string(value))
+		return nil
+	}))
+
+	secret.Zeroize()
+	require.ErrorIs(t, secret.Use(func(value []byte) error {
+		return nil
+	}), resolver.ErrSecretZeroized)
+}
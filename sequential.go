@@ -12,6 +12,7 @@ package resolver
 import (
 	"context"
 	"errors"
+	"net"
 	"net/netip"
 )
 
@@ -41,3 +42,36 @@ func (r *sequentialResolver) LookupNetIP(ctx context.Context, network, host stri
 
 	return nil, errors.Join(errs...)
 }
+
+// LookupMX tries each resolver that implements MailResolver in order until
+// one succeeds.
+func (r *sequentialResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	var errs []error
+	for _, resolver := range r.resolvers {
+		mailResolver, ok := resolver.(MailResolver)
+		if !ok {
+			continue
+		}
+
+		mxs, err := mailResolver.LookupMX(ctx, name)
+		if err == nil {
+			return mxs, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return nil, newError(name, "", ErrNoSuchHost)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// Close closes the underlying resolvers.
+func (r *sequentialResolver) Close() error {
+	return closeAll(r.resolvers)
+}
+
+func (r *sequentialResolver) describe() Description {
+	return Description{Type: "sequential", Children: describeAll(r.resolvers)}
+}
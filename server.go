@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ dns.Handler = (*DNSServer)(nil)
+
+// DNSServerConfig is the configuration for a DNS server.
+type DNSServerConfig struct {
+	// Addr is the address to listen on (for both UDP and TCP) when using
+	// ListenAndServe. Defaults to "127.0.0.1:53", so that the server is not
+	// an open resolver unless explicitly configured to be one.
+	Addr *string
+	// TLSAddr is the address to listen on for DNS over TLS when using
+	// ListenAndServeTLS. Defaults to "127.0.0.1:853".
+	TLSAddr *string
+	// TLSConfig is the TLS configuration used for DNS over TLS, it must
+	// contain at least one certificate.
+	TLSConfig *tls.Config
+	// ClientCAs is an optional pool of certificate authorities, if set DNS
+	// over TLS clients are required to present a certificate signed by one
+	// of them.
+	ClientCAs *x509.CertPool
+	// LocalTTL is the TTL of answers that did not come from an upstream DNS
+	// server (eg. answers from the hosts file). Defaults to 0, so that
+	// clients do not cache them.
+	LocalTTL *time.Duration
+	// Timeout is the maximum amount of time to spend answering a query.
+	// Defaults to 5 seconds.
+	Timeout *time.Duration
+	// Logger is an optional logger, failures to answer queries are logged at
+	// debug level.
+	Logger *slog.Logger
+}
+
+// DNSServer is a stub DNS server that answers address queries (A and AAAA)
+// using a resolver, so that the resolution logic of an application (eg. hosts
+// file, split DNS, and caching) can be published to other processes or
+// containers. Queries for other record types are answered without any records
+// (or with NXDOMAIN if the name does not exist).
+type DNSServer struct {
+	resolver  Resolver
+	addr      string
+	tlsAddr   string
+	tlsConfig *tls.Config
+	localTTL  time.Duration
+	timeout   time.Duration
+	logger    *slog.Logger
+}
+
+// NewDNSServer creates a new DNS server that answers queries using resolver.
+func NewDNSServer(resolver Resolver, conf *DNSServerConfig) *DNSServer {
+	var tlsConfig *tls.Config
+	if conf != nil && conf.TLSConfig != nil {
+		tlsConfig = conf.TLSConfig.Clone()
+		if conf.ClientCAs != nil {
+			tlsConfig.ClientCAs = conf.ClientCAs
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	conf, err := defaults.WithDefaults(conf, &DNSServerConfig{
+		Addr:     ptr.To("127.0.0.1:53"),
+		TLSAddr:  ptr.To("127.0.0.1:853"),
+		LocalTTL: ptr.To(time.Duration(0)),
+		Timeout:  ptr.To(5 * time.Second),
+		Logger:   discardLogger,
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	return &DNSServer{
+		resolver:  resolver,
+		addr:      *conf.Addr,
+		tlsAddr:   *conf.TLSAddr,
+		tlsConfig: tlsConfig,
+		localTTL:  *conf.LocalTTL,
+		timeout:   *conf.Timeout,
+		logger:    conf.Logger,
+	}
+}
+
+// Handler returns a dns.Handler that answers address queries using resolver,
+// so that it can be used as the backend of an existing miekg/dns server, eg.
+//
+//	dns.Handle(".", resolver.Handler(res))
+//
+// It is equivalent to a DNS server with the default configuration.
+func Handler(resolver Resolver) dns.Handler {
+	return NewDNSServer(resolver, nil)
+}
+
+// ListenAndServe listens on the configured address (UDP and TCP) and serves
+// queries until ctx is canceled.
+func (s *DNSServer) ListenAndServe(ctx context.Context) error {
+	pc, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on udp %s: %w", s.addr, err)
+	}
+
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		_ = pc.Close()
+		return fmt.Errorf("failed to listen on tcp %s: %w", s.addr, err)
+	}
+
+	return s.Serve(ctx, pc, l)
+}
+
+// Serve serves queries received on pc (UDP) and l (TCP) until ctx is
+// canceled, either may be nil. The listeners are closed when Serve returns.
+func (s *DNSServer) Serve(ctx context.Context, pc net.PacketConn, l net.Listener) error {
+	var servers []*dns.Server
+	if pc != nil {
+		servers = append(servers, &dns.Server{PacketConn: pc, Handler: s})
+	}
+	if l != nil {
+		servers = append(servers, &dns.Server{Listener: l, Handler: s})
+	}
+
+	return serveDNS(ctx, servers...)
+}
+
+// ListenAndServeTLS listens on the configured TLS address and serves DNS over
+// TLS queries until ctx is canceled.
+func (s *DNSServer) ListenAndServeTLS(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.tlsAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on tcp %s: %w", s.tlsAddr, err)
+	}
+
+	return s.ServeTLS(ctx, l)
+}
+
+// ServeTLS serves DNS over TLS queries received on l until ctx is canceled,
+// TLS is terminated using the configured TLS configuration. The listener is
+// closed when ServeTLS returns.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc7858
+func (s *DNSServer) ServeTLS(ctx context.Context, l net.Listener) error {
+	if s.tlsConfig == nil {
+		_ = l.Close()
+		return errors.New("no TLS configuration")
+	}
+
+	return serveDNS(ctx, &dns.Server{
+		Net:      "tcp-tls",
+		Listener: tls.NewListener(l, s.tlsConfig),
+		Handler:  s,
+	})
+}
+
+// ServeDNS answers a single query, it implements dns.Handler. The source
+// address of the client is stored in the lookup context (see ClientAddr), so
+// that views can be used to answer differently depending on the client.
+func (s *DNSServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	ctx := context.Background()
+	if addr, ok := clientAddr(w.RemoteAddr()); ok {
+		ctx = WithClientAddr(ctx, addr)
+	}
+
+	reply := s.answer(ctx, req)
+
+	// Make sure the reply fits in the client's buffer, if it doesn't the
+	// client is expected to retry over TCP.
+	if _, ok := w.LocalAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = max(int(opt.UDPSize()), dns.MinMsgSize)
+		}
+		reply.Truncate(size)
+	}
+
+	if err := w.WriteMsg(reply); err != nil {
+		s.logger.Debug("Failed to write reply",
+			slog.String("remote", w.RemoteAddr().String()), slog.Any("error", err))
+	}
+}
+
+// answer answers a single query, within the configured timeout.
+func (s *DNSServer) answer(ctx context.Context, req *dns.Msg) *dns.Msg {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return answerQuery(ctx, s.resolver, req, s.localTTL)
+}
+
+// clientAddr returns the IP address of the client a query was received from.
+func clientAddr(addr net.Addr) (netip.Addr, bool) {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.AddrPort().Addr().Unmap(), true
+	case *net.TCPAddr:
+		return addr.AddrPort().Addr().Unmap(), true
+	default:
+		return netip.Addr{}, false
+	}
+}
+
+// serveDNS runs servers until ctx is canceled, or any of them fails.
+func serveDNS(ctx context.Context, servers ...*dns.Server) error {
+	g, ctx := errgroup.WithContext(ctx)
+
+	for _, server := range servers {
+		started := make(chan struct{})
+		stopped := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+
+		g.Go(func() error {
+			defer close(stopped)
+
+			return server.ActivateAndServe()
+		})
+
+		g.Go(func() error {
+			select {
+			case <-ctx.Done():
+			case <-stopped:
+				return nil
+			}
+
+			// A server can only be shut down once it has started.
+			select {
+			case <-started:
+				return server.Shutdown()
+			case <-stopped:
+				return nil
+			}
+		})
+	}
+
+	return g.Wait()
+}
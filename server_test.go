@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSServer(t *testing.T) {
+	upstream := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.1"},
+	})
+	t.Cleanup(upstream.Close)
+
+	hostsResolver, err := resolver.Hosts(&resolver.HostsResolverConfig{
+		HostsFileReader: strings.NewReader("192.0.2.2 local.example\n"),
+	})
+	require.NoError(t, err)
+
+	res := resolver.Sequential(hostsResolver, resolver.DNS(resolver.DNSResolverConfig{
+		Server: upstream.Addr,
+	}))
+
+	pc, l, err := dnstest.ListenLoopback()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- resolver.NewDNSServer(res, &resolver.DNSServerConfig{
+			LocalTTL: ptr.To(10 * time.Second),
+		}).Serve(ctx, pc, l)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	for _, transport := range []string{"udp", "tcp"} {
+		t.Run(transport, func(t *testing.T) {
+			client := &dns.Client{Net: transport}
+
+			exchange := func(name string, qType uint16) *dns.Msg {
+				req := &dns.Msg{}
+				req.SetQuestion(name, qType)
+
+				var reply *dns.Msg
+				require.Eventually(t, func() bool {
+					reply, _, err = client.Exchange(req, pc.LocalAddr().String())
+					return err == nil
+				}, time.Second, 10*time.Millisecond)
+
+				return reply
+			}
+
+			t.Run("Upstream", func(t *testing.T) {
+				reply := exchange("www.example.com.", dns.TypeA)
+				require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+				require.Len(t, reply.Answer, 1)
+				require.Equal(t, "192.0.2.1", reply.Answer[0].(*dns.A).A.String())
+				require.Equal(t, uint32(300), reply.Answer[0].Header().Ttl)
+			})
+
+			t.Run("Hosts", func(t *testing.T) {
+				reply := exchange("local.example.", dns.TypeA)
+				require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+				require.Len(t, reply.Answer, 1)
+				require.Equal(t, "192.0.2.2", reply.Answer[0].(*dns.A).A.String())
+				require.Equal(t, uint32(10), reply.Answer[0].Header().Ttl)
+			})
+
+			t.Run("No Data", func(t *testing.T) {
+				reply := exchange("www.example.com.", dns.TypeAAAA)
+				require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+				require.Empty(t, reply.Answer)
+			})
+
+			t.Run("NXDOMAIN", func(t *testing.T) {
+				reply := exchange("missing.example.com.", dns.TypeA)
+				require.Equal(t, dns.RcodeNameError, reply.Rcode)
+			})
+
+			t.Run("Other Types", func(t *testing.T) {
+				reply := exchange("www.example.com.", dns.TypeMX)
+				require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+				require.Empty(t, reply.Answer)
+
+				reply = exchange("missing.example.com.", dns.TypeMX)
+				require.Equal(t, dns.RcodeNameError, reply.Rcode)
+			})
+		})
+	}
+}
+
+func TestDNSServerCache(t *testing.T) {
+	upstream := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.1"},
+	})
+	t.Cleanup(upstream.Close)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	res := resolver.Cache(resolver.DNS(resolver.DNSResolverConfig{
+		Server: upstream.Addr,
+	}), &resolver.CacheResolverConfig{
+		Clock: clock,
+	})
+
+	pc, l, err := dnstest.ListenLoopback()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- resolver.NewDNSServer(res, nil).Serve(ctx, pc, l)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	req := &dns.Msg{}
+	req.SetQuestion("www.example.com.", dns.TypeA)
+
+	var reply *dns.Msg
+	require.Eventually(t, func() bool {
+		reply, _, err = new(dns.Client).Exchange(req, pc.LocalAddr().String())
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Len(t, reply.Answer, 1)
+	require.Equal(t, uint32(300), reply.Answer[0].Header().Ttl)
+
+	clock.Advance(time.Minute)
+
+	reply, _, err = new(dns.Client).Exchange(req, pc.LocalAddr().String())
+	require.NoError(t, err)
+
+	require.Len(t, reply.Answer, 1)
+	require.Equal(t, uint32(240), reply.Answer[0].Header().Ttl)
+}
+
+func TestHandler(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupNetIP", mock.Anything, "ip4", "www.example.com.").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	mux := dns.NewServeMux()
+	mux.Handle("example.com.", resolver.Handler(res))
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, Handler: mux, NotifyStartedFunc: func() { close(started) }}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		require.NoError(t, server.Shutdown())
+	})
+
+	req := &dns.Msg{}
+	req.SetQuestion("www.example.com.", dns.TypeA)
+
+	reply, _, err := new(dns.Client).Exchange(req, pc.LocalAddr().String())
+	require.NoError(t, err)
+
+	require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+	require.Len(t, reply.Answer, 1)
+	require.Equal(t, "192.0.2.1", reply.Answer[0].(*dns.A).A.String())
+}
+
+func TestDNSServerTLS(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupNetIP", mock.Anything, "ip4", "www.example.com.").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	serverCert, serverX509 := newTestCertificate(t, "dns.example")
+	clientCert, clientX509 := newTestCertificate(t, "client.example")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientX509)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- resolver.NewDNSServer(res, &resolver.DNSServerConfig{
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{serverCert}},
+			ClientCAs: clientCAs,
+		}).ServeTLS(ctx, l)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverX509)
+
+	req := &dns.Msg{}
+	req.SetQuestion("www.example.com.", dns.TypeA)
+
+	t.Run("Client Certificate", func(t *testing.T) {
+		client := &dns.Client{
+			Net: "tcp-tls",
+			TLSConfig: &tls.Config{
+				ServerName:   "dns.example",
+				RootCAs:      rootCAs,
+				Certificates: []tls.Certificate{clientCert},
+			},
+		}
+
+		reply, _, err := client.Exchange(req, l.Addr().String())
+		require.NoError(t, err)
+
+		require.Equal(t, dns.RcodeSuccess, reply.Rcode)
+		require.Len(t, reply.Answer, 1)
+		require.Equal(t, "192.0.2.1", reply.Answer[0].(*dns.A).A.String())
+	})
+
+	t.Run("No Client Certificate", func(t *testing.T) {
+		client := &dns.Client{
+			Net: "tcp-tls",
+			TLSConfig: &tls.Config{
+				ServerName: "dns.example",
+				RootCAs:    rootCAs,
+			},
+		}
+
+		_, _, err := client.Exchange(req, l.Addr().String())
+		require.Error(t, err)
+	})
+
+	t.Run("No TLS Configuration", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		err = resolver.NewDNSServer(res, nil).ServeTLS(context.Background(), l)
+		require.Error(t, err)
+	})
+}
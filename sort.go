@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+
+	"github.com/noisysockets/resolver/addrselect"
+)
+
+// AddrSorter orders the addresses returned by a lookup, in place. The dial
+// function can be used to determine the source address that would be used to
+// reach each address (no packets are sent).
+type AddrSorter func(ctx context.Context, dial DialContextFunc, addrs []netip.Addr)
+
+// SortRFC6724 returns a sorter that orders addresses using the destination
+// address selection rules of RFC 6724, with the default policy table.
+func SortRFC6724() AddrSorter {
+	return SortWithPolicy(addrselect.DefaultPolicyTable())
+}
+
+// SortWithPolicy returns a sorter that orders addresses using the destination
+// address selection rules of RFC 6724, with a custom policy table (eg. to
+// prefer IPv4).
+func SortWithPolicy(table addrselect.PolicyTable) AddrSorter {
+	return func(ctx context.Context, dial DialContextFunc, addrs []netip.Addr) {
+		table.Sort(func(network, address string) (net.Conn, error) {
+			return dial(ctx, network, address)
+		}, addrs)
+	}
+}
+
+// SortWithGAIConf returns a sorter that orders addresses using the
+// destination address selection rules of RFC 6724, with the policy described
+// by the gai.conf(5) file at path (eg. "/etc/gai.conf"), matching the
+// behavior of glibc's getaddrinfo.
+func SortWithGAIConf(path string) (AddrSorter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gai.conf %q: %w", path, err)
+	}
+	defer f.Close()
+
+	policy, err := addrselect.ParseGAIConf(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gai.conf %q: %w", path, err)
+	}
+
+	return func(ctx context.Context, dial DialContextFunc, addrs []netip.Addr) {
+		policy.Sort(func(network, address string) (net.Conn, error) {
+			return dial(ctx, network, address)
+		}, addrs)
+	}, nil
+}
+
+// SortPreserveOrder returns a sorter that leaves addresses in the order they
+// were returned by the server, eg. for load balancers that depend on it.
+func SortPreserveOrder() AddrSorter {
+	return func(ctx context.Context, dial DialContextFunc, addrs []netip.Addr) {}
+}
+
+// SortRandom returns a sorter that shuffles addresses, spreading load across
+// them. If rnd is nil, the global source of math/rand/v2 is used.
+func SortRandom(rnd Rand) AddrSorter {
+	if rnd == nil {
+		rnd = systemRand{}
+	}
+
+	return func(ctx context.Context, dial DialContextFunc, addrs []netip.Addr) {
+		shuffle(rnd, addrs)
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/addrselect"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrSorter(t *testing.T) {
+	upstream := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.3", "A 192.0.2.1", "A 192.0.2.2", "AAAA 2001:db8::1"},
+	})
+	t.Cleanup(upstream.Close)
+
+	lookup := func(t *testing.T, sort resolver.AddrSorter) []netip.Addr {
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server: upstream.Addr,
+			Sort:   sort,
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "www.example.com")
+		require.NoError(t, err)
+
+		return addrs
+	}
+
+	serverOrder := []netip.Addr{
+		netip.MustParseAddr("192.0.2.3"),
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("2001:db8::1"),
+	}
+
+	t.Run("Preserve Order", func(t *testing.T) {
+		require.Equal(t, serverOrder, lookup(t, resolver.SortPreserveOrder()))
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		addrs := lookup(t, resolver.SortRandom(rand.New(rand.NewPCG(1, 2))))
+		require.ElementsMatch(t, serverOrder, addrs)
+		require.NotEqual(t, serverOrder, addrs)
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		// The addresses are unreachable (no source address), so the
+		// relative order is unchanged.
+		addrs := lookup(t, resolver.SortWithPolicy(addrselect.DefaultPolicyTable()))
+		require.ElementsMatch(t, serverOrder, addrs)
+	})
+
+	t.Run("gai.conf", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gai.conf")
+		require.NoError(t, os.WriteFile(path, []byte("precedence ::ffff:0:0/96 100\n"), 0o644))
+
+		sort, err := resolver.SortWithGAIConf(path)
+		require.NoError(t, err)
+
+		addrs := lookup(t, sort)
+		require.ElementsMatch(t, serverOrder, addrs)
+
+		_, err = resolver.SortWithGAIConf(filepath.Join(t.TempDir(), "missing"))
+		require.Error(t, err)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		reverse := func(ctx context.Context, dial resolver.DialContextFunc, addrs []netip.Addr) {
+			for i, j := 0, len(addrs)-1; i < j; i, j = i+1, j-1 {
+				addrs[i], addrs[j] = addrs[j], addrs[i]
+			}
+		}
+
+		addrs := lookup(t, reverse)
+		require.Equal(t, netip.MustParseAddr("2001:db8::1"), addrs[0])
+	})
+}
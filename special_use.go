@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"maps"
+	"net/netip"
+	"strconv"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/address"
+)
+
+var _ Resolver = (*specialUseResolver)(nil)
+
+// SpecialUseAction is how lookups for names in a special-use domain are
+// handled.
+type SpecialUseAction string
+
+const (
+	// SpecialUseActionLoopback answers lookups locally with loopback addresses.
+	SpecialUseActionLoopback SpecialUseAction = "loopback"
+	// SpecialUseActionNXDomain answers lookups locally with ErrNoSuchHost.
+	SpecialUseActionNXDomain SpecialUseAction = "nxdomain"
+	// SpecialUseActionForward passes lookups through to the next resolver.
+	SpecialUseActionForward SpecialUseAction = "forward"
+)
+
+// DefaultSpecialUseZones is the default handling of special-use domains, as
+// described in RFC 6761 and RFC 7686. Names under "test." can be passed through
+// to local DNS servers (RFC 6761 section 6.2) using SpecialUseResolverConfig.
+var DefaultSpecialUseZones = map[string]SpecialUseAction{
+	"localhost.": SpecialUseActionLoopback,
+	"invalid.":   SpecialUseActionNXDomain,
+	"test.":      SpecialUseActionNXDomain,
+	"onion.":     SpecialUseActionNXDomain,
+}
+
+// SpecialUseResolverConfig is the configuration for a special-use domain
+// resolver.
+type SpecialUseResolverConfig struct {
+	// Zones overrides the handling of specific special-use domains, entries
+	// are merged with DefaultSpecialUseZones.
+	Zones map[string]SpecialUseAction
+}
+
+// specialUseResolver is a resolver that prevents special-use domain names
+// from leaking to upstream DNS servers.
+type specialUseResolver struct {
+	resolver Resolver
+	zones    map[string]SpecialUseAction
+}
+
+// SpecialUse returns a resolver that handles special-use domain names (eg.
+// "localhost" and ".onion") locally, instead of forwarding them to the next
+// resolver.
+func SpecialUse(resolver Resolver, conf *SpecialUseResolverConfig) *specialUseResolver {
+	zones := maps.Clone(DefaultSpecialUseZones)
+	if conf != nil {
+		for zone, action := range conf.Zones {
+			zones[dns.CanonicalName(zone)] = action
+		}
+	}
+
+	return &specialUseResolver{
+		resolver: resolver,
+		zones:    zones,
+	}
+}
+
+func (r *specialUseResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if network != "ip" && network != "ip4" && network != "ip6" {
+		return nil, newError(host, "", ErrUnsupportedNetwork)
+	}
+
+	switch r.action(host) {
+	case SpecialUseActionLoopback:
+		addrs := address.FilterByNetwork([]netip.Addr{
+			netip.IPv6Loopback(),
+			netip.MustParseAddr("127.0.0.1"),
+		}, network)
+		return addrs, nil
+	case SpecialUseActionNXDomain:
+		return nil, newError(host, "", ErrNoSuchHost)
+	default:
+		return r.resolver.LookupNetIP(ctx, network, host)
+	}
+}
+
+// action returns the action of the most specific zone containing host.
+func (r *specialUseResolver) action(host string) SpecialUseAction {
+	name := dns.CanonicalName(host)
+
+	action := SpecialUseActionForward
+	var labels int
+	for zone, zoneAction := range r.zones {
+		if dns.IsSubDomain(zone, name) && dns.CountLabel(zone) >= labels {
+			action = zoneAction
+			labels = dns.CountLabel(zone)
+		}
+	}
+
+	return action
+}
+
+// Close closes the underlying resolver.
+func (r *specialUseResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *specialUseResolver) describe() Description {
+	return Description{
+		Type:       "special-use",
+		Attributes: map[string]string{"zones": strconv.Itoa(len(r.zones))},
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
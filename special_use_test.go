@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecialUseResolver(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", mock.Anything).Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+
+	res := resolver.SpecialUse(inner, &resolver.SpecialUseResolverConfig{
+		Zones: map[string]resolver.SpecialUseAction{
+			"dev.test": resolver.SpecialUseActionForward,
+		},
+	})
+
+	t.Run("Localhost", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "app.localhost")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("127.0.0.1")}, addrs)
+	})
+
+	for _, host := range []string{"foo.invalid", "example.test", "duckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczad.onion"} {
+		t.Run("Not Forwarded "+host, func(t *testing.T) {
+			_, err := res.LookupNetIP(context.Background(), "ip", host)
+
+			var dnsErr *net.DNSError
+			require.ErrorAs(t, err, &dnsErr)
+			require.True(t, dnsErr.IsNotFound)
+		})
+	}
+
+	t.Run("Override", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "app.dev.test")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+	})
+
+	t.Run("Forwarded Test Zone", func(t *testing.T) {
+		res := resolver.SpecialUse(inner, &resolver.SpecialUseResolverConfig{
+			Zones: map[string]resolver.SpecialUseAction{
+				"test": resolver.SpecialUseActionForward,
+			},
+		})
+
+		addrs, err := res.LookupNetIP(context.Background(), "ip", "example.test")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+	})
+
+	inner.AssertNumberOfCalls(t, "LookupNetIP", 2)
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// SplitDNSRoute is a set of DNS servers, and the search domains that are
+// used along with them.
+type SplitDNSRoute struct {
+	// Servers are the DNS servers used for lookups matching the route.
+	Servers []ServerConfig
+	// Strategy is how the servers are queried, one of "sequential" (the
+	// default), "round-robin", or "parallel".
+	Strategy string
+	// Search is an optional list of search domains, appended to relative
+	// names (eg. "wiki" might become "wiki.corp.example").
+	Search []string
+}
+
+// SplitDNSConfig is the configuration for a split DNS resolver.
+type SplitDNSConfig struct {
+	// Routes maps domain suffixes (eg. "corp.example" or "ts.net") to the
+	// route used for names within them. The route with the most specific
+	// matching suffix is used.
+	Routes map[string]SplitDNSRoute
+	// Default is the route used for all other names. If it has no servers,
+	// lookups for other names fail with ErrNoSuchHost (eg. for VPNs that only
+	// resolve internal names, alongside the system resolver).
+	Default SplitDNSRoute
+	// NDots is the number of dots a name must have to be looked up as an
+	// absolute name, rather than with the search domains appended. Defaults
+	// to 1.
+	NDots *int
+}
+
+// SplitDNS returns a resolver that sends lookups to different sets of DNS
+// servers depending on the domain of the name being looked up, the shape of
+// configuration needed by mesh VPNs (eg. Tailscale's MagicDNS) and most
+// other VPN clients.
+//
+// Relative names are expanded using the search domains of every route (in
+// order of the route's suffix, followed by those of the default route), each
+// expanded name is then sent to the route matching it. See Route.Search.
+func SplitDNS(conf *SplitDNSConfig) (Resolver, error) {
+	if conf == nil || (len(conf.Routes) == 0 && len(conf.Default.Servers) == 0) {
+		return nil, fmt.Errorf("no routes configured")
+	}
+
+	suffixes := make([]string, 0, len(conf.Routes))
+	for suffix := range conf.Routes {
+		suffixes = append(suffixes, suffix)
+	}
+	slices.Sort(suffixes)
+
+	routes := make([]Route, 0, len(conf.Routes))
+	for _, suffix := range suffixes {
+		if _, ok := dns.IsDomainName(suffix); !ok {
+			return nil, fmt.Errorf("invalid domain suffix %q", suffix)
+		}
+
+		routeConf := conf.Routes[suffix]
+
+		resolver, err := serversFromConfig(routeConf.Servers, routeConf.Strategy)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", suffix, err)
+		}
+
+		routes = append(routes, Route{
+			Domains:  []string{suffix},
+			Resolver: resolver,
+			Search:   routeConf.Search,
+			NDots:    conf.NDots,
+		})
+	}
+
+	var defaultResolver Resolver
+	if len(conf.Default.Servers) > 0 {
+		var err error
+		defaultResolver, err = serversFromConfig(conf.Default.Servers, conf.Default.Strategy)
+		if err != nil {
+			return nil, fmt.Errorf("default route: %w", err)
+		}
+	}
+
+	return Routing(&RoutingResolverConfig{
+		Routes:        routes,
+		Default:       defaultResolver,
+		DefaultSearch: conf.Default.Search,
+		DefaultNDots:  conf.NDots,
+	}), nil
+}
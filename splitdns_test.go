@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDNS(t *testing.T) {
+	corp := dnstest.NewServer(dnstest.Records{
+		"wiki.corp.example": {"A 10.0.0.1"},
+	})
+	t.Cleanup(corp.Close)
+
+	mesh := dnstest.NewServer(dnstest.Records{
+		"laptop.tail1234.ts.net": {"A 100.64.0.2"},
+	})
+	t.Cleanup(mesh.Close)
+
+	public := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.1"},
+		// Must never be reached, internal names are routed.
+		"wiki.corp.example": {"A 192.0.2.66"},
+	})
+	t.Cleanup(public.Close)
+
+	res, err := resolver.SplitDNS(&resolver.SplitDNSConfig{
+		Routes: map[string]resolver.SplitDNSRoute{
+			"corp.example": {
+				Servers: []resolver.ServerConfig{{Address: corp.Addr.String()}},
+				Search:  []string{"corp.example"},
+			},
+			"ts.net": {
+				Servers: []resolver.ServerConfig{{Address: mesh.Addr.String()}},
+				Search:  []string{"tail1234.ts.net"},
+			},
+		},
+		Default: resolver.SplitDNSRoute{
+			Servers: []resolver.ServerConfig{{Address: public.Addr.String()}},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resolver.Close(res) })
+
+	ctx := context.Background()
+
+	for host, expected := range map[string]string{
+		"wiki.corp.example":      "10.0.0.1",
+		"wiki":                   "10.0.0.1",
+		"laptop":                 "100.64.0.2",
+		"laptop.tail1234.ts.net": "100.64.0.2",
+		"www.example.com":        "192.0.2.1",
+	} {
+		addrs, err := res.LookupNetIP(ctx, "ip4", host)
+		require.NoError(t, err, host)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr(expected)}, addrs, host)
+	}
+
+	t.Run("No Default", func(t *testing.T) {
+		res, err := resolver.SplitDNS(&resolver.SplitDNSConfig{
+			Routes: map[string]resolver.SplitDNSRoute{
+				"corp.example": {Servers: []resolver.ServerConfig{{Address: corp.Addr.String()}}},
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = res.LookupNetIP(ctx, "ip4", "www.example.com")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := resolver.SplitDNS(nil)
+		require.Error(t, err)
+
+		_, err = resolver.SplitDNS(&resolver.SplitDNSConfig{
+			Routes: map[string]resolver.SplitDNSRoute{"corp.example": {}},
+		})
+		require.Error(t, err)
+	})
+}
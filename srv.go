@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+	"github.com/noisysockets/util/ptr"
+)
+
+var (
+	_ SRVResolver = (*net.Resolver)(nil)
+	_ SRVResolver = (*dnsResolver)(nil)
+	_ SRVResolver = (*ZoneResolver)(nil)
+)
+
+// SRVResolver looks up SRV records, it is implemented by *net.Resolver,
+// *ZoneResolver and DNS resolvers.
+type SRVResolver interface {
+	// LookupSRV returns the SRV records for the given service, protocol and
+	// domain.
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVSelectorConfig is the configuration for an SRV target selector.
+type SRVSelectorConfig struct {
+	// FailureTimeout is how long a target is avoided after a failure is
+	// reported. Defaults to 30 seconds.
+	FailureTimeout *time.Duration
+	// Rand is an optional source of randomness, used for weighted selection.
+	Rand Rand
+	// Clock is an optional source of the current time, used to expire
+	// failures. Defaults to the system's time.
+	Clock Clock
+}
+
+type srvKey struct {
+	target string
+	port   uint16
+}
+
+// SRVSelector selects the target to connect to from a set of SRV records,
+// using the priority and weighted random selection described in RFC 2782.
+// Targets that fail are avoided for a while, so that clients move on to the
+// next target (or priority) without hammering a dead server.
+type SRVSelector struct {
+	failureTimeout time.Duration
+	rnd            Rand
+	clock          Clock
+	mu             sync.Mutex
+	srvs           []*net.SRV
+	failed         map[srvKey]time.Time
+}
+
+// LookupSRVSelector looks up the SRV records for the given service, protocol
+// and domain using resolver, returning a selector for the targets.
+func LookupSRVSelector(ctx context.Context, resolver SRVResolver, service, proto, name string, conf *SRVSelectorConfig) (*SRVSelector, error) {
+	_, srvs, err := resolver.LookupSRV(ctx, service, proto, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSRVSelector(srvs, conf)
+}
+
+// NewSRVSelector creates a selector for the targets of srvs. If the records
+// indicate the service is decidedly not available (a single record with a
+// target of "."), an error wrapping ErrServiceUnavailable is returned.
+func NewSRVSelector(srvs []*net.SRV, conf *SRVSelectorConfig) (*SRVSelector, error) {
+	conf, err := defaults.WithDefaults(conf, &SRVSelectorConfig{
+		FailureTimeout: ptr.To(30 * time.Second),
+		Rand:           systemRand{},
+		Clock:          systemClock{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to SRV selector config: %w", err)
+	}
+
+	if len(srvs) == 0 {
+		return nil, errors.New("no SRV records")
+	}
+
+	if len(srvs) == 1 && srvs[0].Target == "." {
+		return nil, ErrServiceUnavailable
+	}
+
+	return &SRVSelector{
+		failureTimeout: *conf.FailureTimeout,
+		rnd:            conf.Rand,
+		clock:          conf.Clock,
+		srvs:           slices.Clone(srvs),
+		failed:         make(map[srvKey]time.Time),
+	}, nil
+}
+
+// Next returns the target to try next.
+func (s *SRVSelector) Next() *net.SRV {
+	return s.Targets()[0]
+}
+
+// Targets returns every target, in the order they should be tried. Targets
+// are ordered by priority, and randomly by weight within a priority. Targets
+// that failed recently are moved to the end, ordered by how long ago they
+// failed.
+func (s *SRVSelector) Targets() []*net.SRV {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+
+	var healthy, failed []*net.SRV
+	for _, srv := range s.srvs {
+		if failedAt, ok := s.failed[srvKeyOf(srv)]; ok && now.Sub(failedAt) < s.failureTimeout {
+			failed = append(failed, srv)
+		} else {
+			healthy = append(healthy, srv)
+		}
+	}
+
+	slices.SortStableFunc(failed, func(a, b *net.SRV) int {
+		return s.failed[srvKeyOf(a)].Compare(s.failed[srvKeyOf(b)])
+	})
+
+	return append(orderSRV(s.rnd, healthy), failed...)
+}
+
+// Failed reports that connecting to target failed, it is avoided until the
+// failure timeout elapses (or a success is reported).
+func (s *SRVSelector) Failed(target *net.SRV) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed[srvKeyOf(target)] = s.clock.Now()
+}
+
+// Succeeded reports that connecting to target succeeded, clearing any
+// earlier failure.
+func (s *SRVSelector) Succeeded(target *net.SRV) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failed, srvKeyOf(target))
+}
+
+func srvKeyOf(srv *net.SRV) srvKey {
+	return srvKey{target: dns.CanonicalName(srv.Target), port: srv.Port}
+}
+
+// orderSRV orders srvs as described in RFC 2782: by priority, and within a
+// priority by repeatedly choosing a target at random with a probability
+// proportional to its weight.
+func orderSRV(rnd Rand, srvs []*net.SRV) []*net.SRV {
+	srvs = slices.Clone(srvs)
+	slices.SortStableFunc(srvs, func(a, b *net.SRV) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+
+	ordered := make([]*net.SRV, 0, len(srvs))
+	for len(srvs) > 0 {
+		end := 1
+		for end < len(srvs) && srvs[end].Priority == srvs[0].Priority {
+			end++
+		}
+
+		ordered = append(ordered, orderByWeight(rnd, srvs[:end])...)
+		srvs = srvs[end:]
+	}
+
+	return ordered
+}
+
+// orderByWeight orders srvs (of equal priority) by weighted random selection.
+// Targets with a weight of zero are placed first, so they have a very small
+// chance of being selected (unless every target has a weight of zero).
+func orderByWeight(rnd Rand, srvs []*net.SRV) []*net.SRV {
+	remaining := slices.Clone(srvs)
+	slices.SortStableFunc(remaining, func(a, b *net.SRV) int {
+		return cmp.Compare(min(a.Weight, 1), min(b.Weight, 1))
+	})
+
+	ordered := make([]*net.SRV, 0, len(remaining))
+	for len(remaining) > 0 {
+		var total int
+		for _, srv := range remaining {
+			total += int(srv.Weight)
+		}
+
+		n := rnd.IntN(total + 1)
+
+		var i, sum int
+		for i = range remaining {
+			sum += int(remaining[i].Weight)
+			if sum >= n {
+				break
+			}
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+
+	return ordered
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRVSelector(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "backup.example.", Port: 5060, Priority: 20, Weight: 0},
+		{Target: "a.example.", Port: 5060, Priority: 10, Weight: 90},
+		{Target: "b.example.", Port: 5060, Priority: 10, Weight: 10},
+	}
+
+	t.Run("Priority", func(t *testing.T) {
+		sel, err := resolver.NewSRVSelector(srvs, nil)
+		require.NoError(t, err)
+
+		targets := sel.Targets()
+		require.Len(t, targets, 3)
+
+		require.Equal(t, uint16(10), targets[0].Priority)
+		require.Equal(t, uint16(10), targets[1].Priority)
+		require.Equal(t, "backup.example.", targets[2].Target)
+	})
+
+	t.Run("Weight", func(t *testing.T) {
+		sel, err := resolver.NewSRVSelector(srvs, &resolver.SRVSelectorConfig{
+			Rand: rand.New(rand.NewPCG(1, 2)),
+		})
+		require.NoError(t, err)
+
+		counts := make(map[string]int)
+		for i := 0; i < 1000; i++ {
+			counts[sel.Next().Target]++
+		}
+
+		require.Zero(t, counts["backup.example."])
+		require.InDelta(t, 900, counts["a.example."], 50)
+		require.InDelta(t, 100, counts["b.example."], 50)
+	})
+
+	t.Run("Failure Feedback", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+		sel, err := resolver.NewSRVSelector(srvs, &resolver.SRVSelectorConfig{
+			FailureTimeout: ptr.To(time.Minute),
+			Clock:          clock,
+		})
+		require.NoError(t, err)
+
+		sel.Failed(srvs[1])
+		clock.Advance(time.Second)
+		sel.Failed(srvs[2])
+
+		// Both preferred targets failed, so the backup is tried first, then the
+		// target that failed longest ago.
+		targets := sel.Targets()
+		require.Equal(t, []string{"backup.example.", "a.example.", "b.example."}, targetNames(targets))
+
+		sel.Succeeded(srvs[2])
+		require.Equal(t, "b.example.", sel.Next().Target)
+
+		clock.Advance(time.Minute)
+		require.Equal(t, uint16(10), sel.Next().Priority)
+	})
+
+	t.Run("Service Unavailable", func(t *testing.T) {
+		_, err := resolver.NewSRVSelector([]*net.SRV{{Target: "."}}, nil)
+		require.ErrorIs(t, err, resolver.ErrServiceUnavailable)
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		zone, err := resolver.Zone(&resolver.ZoneResolverConfig{
+			ZoneFileReader: strings.NewReader(`$ORIGIN example.
+_sip._udp 300 IN SRV 10 100 5060 sip.example.
+sip 300 IN A 192.0.2.1
+`),
+		})
+		require.NoError(t, err)
+
+		sel, err := resolver.LookupSRVSelector(context.Background(), zone, "sip", "udp", "example.", nil)
+		require.NoError(t, err)
+
+		require.Equal(t, &net.SRV{Target: "sip.example.", Port: 5060, Priority: 10, Weight: 100}, sel.Next())
+	})
+}
+
+func targetNames(srvs []*net.SRV) []string {
+	var names []string
+	for _, srv := range srvs {
+		names = append(names, srv.Target)
+	}
+	return names
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// rttSamples is the number of recent round trip times retained per server for
+// calculating percentiles.
+const rttSamples = 1024
+
+// ServerStats are statistics about the queries sent to a DNS server.
+type ServerStats struct {
+	// Server is the DNS server.
+	Server netip.AddrPort
+	// Queries is the number of queries sent to the server.
+	Queries uint64
+	// Failures is the number of queries that did not receive a valid reply
+	// (including timeouts).
+	Failures uint64
+	// Timeouts is the number of queries that timed out.
+	Timeouts uint64
+	// MeanRTT is the mean round trip time of successful queries.
+	MeanRTT time.Duration
+	// P50RTT, P95RTT and P99RTT are percentiles of the round trip time of
+	// recent successful queries.
+	P50RTT, P95RTT, P99RTT time.Duration
+	// LastError is the most recent error, if any.
+	LastError error
+	// LastErrorTime is when the most recent error occurred.
+	LastErrorTime time.Time
+}
+
+// serverStatsTracker tracks statistics for each server queried.
+type serverStatsTracker struct {
+	clock   Clock
+	mu      sync.Mutex
+	servers map[netip.AddrPort]*serverStats
+}
+
+type serverStats struct {
+	queries       uint64
+	failures      uint64
+	timeouts      uint64
+	successes     uint64
+	totalRTT      time.Duration
+	rtts          []time.Duration
+	nextRTT       int
+	lastError     error
+	lastErrorTime time.Time
+}
+
+func newServerStatsTracker(clock Clock) *serverStatsTracker {
+	return &serverStatsTracker{
+		clock:   clock,
+		servers: make(map[netip.AddrPort]*serverStats),
+	}
+}
+
+func (t *serverStatsTracker) record(server netip.AddrPort, rtt time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.servers[server]
+	if !ok {
+		s = &serverStats{}
+		t.servers[server] = s
+	}
+
+	s.queries++
+
+	if err != nil {
+		s.failures++
+		if errors.Is(err, ErrTimeout) {
+			s.timeouts++
+		}
+		s.lastError = err
+		s.lastErrorTime = t.clock.Now()
+		return
+	}
+
+	s.successes++
+	s.totalRTT += rtt
+
+	if len(s.rtts) < rttSamples {
+		s.rtts = append(s.rtts, rtt)
+	} else {
+		s.rtts[s.nextRTT] = rtt
+		s.nextRTT = (s.nextRTT + 1) % rttSamples
+	}
+}
+
+func (t *serverStatsTracker) snapshot() []ServerStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]ServerStats, 0, len(t.servers))
+	for server, s := range t.servers {
+		serverStats := ServerStats{
+			Server:        server,
+			Queries:       s.queries,
+			Failures:      s.failures,
+			Timeouts:      s.timeouts,
+			LastError:     s.lastError,
+			LastErrorTime: s.lastErrorTime,
+		}
+
+		if s.successes > 0 {
+			serverStats.MeanRTT = s.totalRTT / time.Duration(s.successes)
+
+			rtts := slices.Clone(s.rtts)
+			slices.Sort(rtts)
+
+			serverStats.P50RTT = percentile(rtts, 50)
+			serverStats.P95RTT = percentile(rtts, 95)
+			serverStats.P99RTT = percentile(rtts, 99)
+		}
+
+		stats = append(stats, serverStats)
+	}
+
+	slices.SortFunc(stats, func(a, b ServerStats) int {
+		return a.Server.Compare(b.Server)
+	})
+
+	return stats
+}
+
+// percentile returns the p-th percentile of the sorted durations, using the
+// nearest rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSResolverStats(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		// Never reply to queries for slow names.
+		if req.Question[0].Name == "slow.example." {
+			return
+		}
+
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+		reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:  server,
+		Timeout: ptr.To(50 * time.Millisecond),
+	})
+
+	require.Empty(t, res.Stats())
+
+	for i := 0; i < 3; i++ {
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+	}
+
+	_, err := res.LookupNetIP(context.Background(), "ip4", "slow.example")
+	require.ErrorIs(t, err, resolver.ErrTimeout)
+
+	stats := res.Stats()
+	require.Len(t, stats, 1)
+
+	require.Equal(t, server, stats[0].Server)
+	require.Equal(t, uint64(4), stats[0].Queries)
+	require.Equal(t, uint64(1), stats[0].Failures)
+	require.Equal(t, uint64(1), stats[0].Timeouts)
+	require.NotZero(t, stats[0].MeanRTT)
+	require.NotZero(t, stats[0].P50RTT)
+	require.LessOrEqual(t, stats[0].P50RTT, stats[0].P99RTT)
+	require.ErrorIs(t, stats[0].LastError, resolver.ErrTimeout)
+	require.False(t, stats[0].LastErrorTime.IsZero())
+}
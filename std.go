@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net"
+)
+
+// StdResolver adds the address lookup methods of net.Resolver to a Resolver,
+// so that it satisfies interfaces in code written against the standard
+// library's method set.
+type StdResolver struct {
+	Resolver
+}
+
+// Std returns resolver wrapped with the address lookup methods of
+// net.Resolver.
+func Std(resolver Resolver) *StdResolver {
+	return &StdResolver{Resolver: resolver}
+}
+
+// LookupIPAddr looks up host, returning its IPv4 and IPv6 addresses. The
+// zone of IPv6 addresses (eg. from a scoped literal) is preserved.
+func (r *StdResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, err := r.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	ipAddrs := make([]net.IPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		ipAddrs = append(ipAddrs, net.IPAddr{
+			IP:   addr.AsSlice(),
+			Zone: addr.Zone(),
+		})
+	}
+
+	return ipAddrs, nil
+}
+
+// LookupIP looks up host for the given network, which must be one of "ip",
+// "ip4" or "ip6".
+func (r *StdResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	addrs, err := r.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.AsSlice())
+	}
+
+	return ips, nil
+}
+
+// LookupHost looks up host, returning the string form of its addresses.
+func (r *StdResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		hosts = append(hosts, addr.String())
+	}
+
+	return hosts, nil
+}
+
+// Close closes the underlying resolver.
+func (r *StdResolver) Close() error {
+	return Close(r.Resolver)
+}
+
+func (r *StdResolver) describe() Description {
+	return Describe(r.Resolver)
+}
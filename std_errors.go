@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+)
+
+var _ Resolver = (*stdErrorsResolver)(nil)
+
+// stdErrorsResolver is a resolver that reports errors the same way as the Go
+// standard library's resolver.
+type stdErrorsResolver struct {
+	resolver Resolver
+}
+
+// StdErrors returns a resolver whose error strings, and net.DNSError fields,
+// match those of the Go standard library's resolver (eg. "no such host" for
+// both NXDOMAIN and NODATA, and "server misbehaving"). This is only intended
+// for compatibility with code that matches on error strings, the underlying
+// errors can still be inspected using errors.Is and errors.As.
+func StdErrors(resolver Resolver) *stdErrorsResolver {
+	return &stdErrorsResolver{resolver: resolver}
+}
+
+func (r *stdErrorsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, err := r.resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, stdError(host, err)
+	}
+
+	return addrs, nil
+}
+
+// stdError converts err into the error the Go standard library's resolver
+// would have returned when looking up host. Errors without a standard library
+// equivalent are returned unchanged.
+func stdError(host string, err error) error {
+	dnsErr := net.DNSError{Name: host}
+
+	var cause *net.DNSError
+	if errors.As(err, &cause) {
+		dnsErr.Server = cause.Server
+	}
+
+	// Temporary errors take precedence, as the standard library stops
+	// searching when one occurs.
+	switch {
+	case errors.Is(err, context.Canceled):
+		dnsErr.Err = "operation was canceled"
+	case errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		dnsErr.Err = "i/o timeout"
+		dnsErr.IsTimeout = true
+		dnsErr.IsTemporary = true
+	case errors.Is(err, ErrServFail):
+		dnsErr.Err = "server misbehaving"
+		dnsErr.IsTemporary = true
+	case errors.Is(err, ErrServerMisbehaving):
+		dnsErr.Err = "server misbehaving"
+	case errors.Is(err, ErrNoSuchHost) || errors.Is(err, ErrNoData):
+		dnsErr.Err = "no such host"
+		dnsErr.IsNotFound = true
+	default:
+		return err
+	}
+
+	e := &Error{
+		DNSError: dnsErr,
+		causes:   []error{err},
+	}
+
+	var resolverErr *Error
+	if errors.As(err, &resolverErr) {
+		e.ExtendedErrors = resolverErr.ExtendedErrors
+	}
+
+	return e
+}
+
+// Close closes the underlying resolver.
+func (r *stdErrorsResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *stdErrorsResolver) describe() Description {
+	return Description{Type: "std-errors", Children: []Description{Describe(r.resolver)}}
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdErrors(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		switch req.Question[0].Name {
+		case "missing.example.":
+			reply.Rcode = dns.RcodeNameError
+		case "failing.example.":
+			reply.Rcode = dns.RcodeServerFailure
+		case "refused.example.":
+			reply.Rcode = dns.RcodeRefused
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	res := resolver.StdErrors(resolver.DNS(resolver.DNSResolverConfig{
+		Server: server,
+	}))
+
+	tests := []struct {
+		host     string
+		expected net.DNSError
+		cause    error
+	}{
+		{
+			host: "missing.example",
+			expected: net.DNSError{
+				Err:        "no such host",
+				Name:       "missing.example",
+				Server:     server.String(),
+				IsNotFound: true,
+			},
+			cause: resolver.ErrNoSuchHost,
+		},
+		{
+			host: "nodata.example",
+			expected: net.DNSError{
+				Err:        "no such host",
+				Name:       "nodata.example",
+				Server:     server.String(),
+				IsNotFound: true,
+			},
+			cause: resolver.ErrNoData,
+		},
+		{
+			host: "failing.example",
+			expected: net.DNSError{
+				Err:         "server misbehaving",
+				Name:        "failing.example",
+				Server:      server.String(),
+				IsTemporary: true,
+			},
+			cause: resolver.ErrServFail,
+		},
+		{
+			host: "refused.example",
+			expected: net.DNSError{
+				Err:    "server misbehaving",
+				Name:   "refused.example",
+				Server: server.String(),
+			},
+			cause: resolver.ErrRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			_, err := res.LookupNetIP(context.Background(), "ip4", tt.host)
+			require.Error(t, err)
+
+			require.Equal(t, tt.expected.Error(), err.Error())
+
+			var dnsErr *net.DNSError
+			require.ErrorAs(t, err, &dnsErr)
+			require.Equal(t, tt.expected, *dnsErr)
+
+			require.ErrorIs(t, err, tt.cause)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+// ipAddrResolver is the method set downstream code might program against.
+type ipAddrResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var (
+	_ ipAddrResolver = (*net.Resolver)(nil)
+	_ ipAddrResolver = (*resolver.StdResolver)(nil)
+)
+
+func TestStdResolver(t *testing.T) {
+	hostsResolver, err := resolver.Hosts(&resolver.HostsResolverConfig{
+		HostsFileReader: strings.NewReader("192.0.2.1 www.example\n2001:db8::1 www.example\n"),
+	})
+	require.NoError(t, err)
+
+	res := resolver.Std(resolver.Sequential(resolver.Literal(), hostsResolver))
+
+	t.Run("LookupIPAddr", func(t *testing.T) {
+		addrs, err := res.LookupIPAddr(context.Background(), "www.example")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []net.IPAddr{
+			{IP: net.ParseIP("192.0.2.1").To4()},
+			{IP: net.ParseIP("2001:db8::1")},
+		}, addrs)
+	})
+
+	t.Run("Zone", func(t *testing.T) {
+		addrs, err := res.LookupIPAddr(context.Background(), "fe80::1%eth0")
+		require.NoError(t, err)
+
+		require.Equal(t, []net.IPAddr{{IP: net.ParseIP("fe80::1"), Zone: "eth0"}}, addrs)
+	})
+
+	t.Run("LookupIP", func(t *testing.T) {
+		ips, err := res.LookupIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []net.IP{net.ParseIP("192.0.2.1").To4()}, ips)
+	})
+
+	t.Run("LookupHost", func(t *testing.T) {
+		hosts, err := res.LookupHost(context.Background(), "www.example")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []string{"192.0.2.1", "2001:db8::1"}, hosts)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := res.LookupIPAddr(context.Background(), "missing.example")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.True(t, dnsErr.IsNotFound)
+	})
+}
@@ -0,0 +1,116 @@
+//go:build go1.23
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/netip"
+	"sync"
+)
+
+// streamer is implemented by resolvers that can stream addresses as they
+// are resolved.
+type streamer interface {
+	Addresses(ctx context.Context, network, host string) iter.Seq2[netip.Addr, error]
+}
+
+// Addresses returns an iterator over the addresses of host, of the type
+// specified by network. Resolvers that race multiple backends (eg. Parallel)
+// yield addresses as soon as each backend answers, so that dialers can start
+// connecting to the first address before slower backends finish. Other
+// resolvers yield the result of LookupNetIP.
+//
+// If the lookup fails, a single zero address is yielded along with the error.
+// Breaking out of the loop cancels any outstanding queries.
+func Addresses(ctx context.Context, resolver Resolver, network, host string) iter.Seq2[netip.Addr, error] {
+	if s, ok := resolver.(streamer); ok {
+		return s.Addresses(ctx, network, host)
+	}
+
+	return func(yield func(netip.Addr, error) bool) {
+		addrs, err := resolver.LookupNetIP(ctx, network, host)
+		if err != nil {
+			yield(netip.Addr{}, err)
+			return
+		}
+
+		for _, addr := range addrs {
+			if !yield(addr, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Addresses returns an iterator over the addresses of host, addresses are
+// yielded as soon as each resolver answers (duplicates are omitted). An
+// error is only yielded if every resolver fails.
+func (r *parallelResolver) Addresses(ctx context.Context, network, host string) iter.Seq2[netip.Addr, error] {
+	return func(yield func(netip.Addr, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			addrs []netip.Addr
+			err   error
+		}
+
+		results := make(chan result)
+
+		var wg sync.WaitGroup
+		wg.Add(len(r.resolvers))
+
+		go func() {
+			wg.Wait()
+
+			close(results)
+		}()
+
+		for _, resolver := range r.resolvers {
+			go func(resolver Resolver) {
+				defer wg.Done()
+
+				addrs, err := resolver.LookupNetIP(ctx, network, host)
+				select {
+				case results <- result{addrs: addrs, err: err}:
+				case <-ctx.Done():
+				}
+			}(resolver)
+		}
+
+		var errs []error
+		seen := make(map[netip.Addr]struct{})
+		for res := range results {
+			if res.err != nil {
+				errs = append(errs, res.err)
+				continue
+			}
+
+			for _, addr := range res.addrs {
+				if _, ok := seen[addr]; ok {
+					continue
+				}
+				seen[addr] = struct{}{}
+
+				if !yield(addr, nil) {
+					return
+				}
+			}
+		}
+
+		if len(seen) == 0 && len(errs) > 0 {
+			yield(netip.Addr{}, errors.Join(errs...))
+		}
+	}
+}
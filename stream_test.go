@@ -0,0 +1,87 @@
+//go:build go1.23
+
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddresses(t *testing.T) {
+	fast := new(dnstest.MockResolver)
+	fast.On("LookupNetIP", mock.Anything, "ip", "example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	slow := new(dnstest.MockResolver)
+	slow.On("LookupNetIP", mock.Anything, "ip", "example.com").
+		After(50*time.Millisecond).
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}, nil)
+
+	failing := new(dnstest.MockResolver)
+	failing.On("LookupNetIP", mock.Anything, mock.Anything, mock.Anything).
+		Return([]netip.Addr(nil), resolver.ErrNoSuchHost)
+
+	t.Run("Streams", func(t *testing.T) {
+		res := resolver.Parallel(slow, failing, fast)
+
+		var addrs []netip.Addr
+		for addr, err := range resolver.Addresses(context.Background(), res, "ip", "example.com") {
+			require.NoError(t, err)
+			addrs = append(addrs, addr)
+		}
+
+		require.Equal(t, []netip.Addr{
+			netip.MustParseAddr("192.0.2.1"),
+			netip.MustParseAddr("192.0.2.2"),
+		}, addrs)
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		res := resolver.Parallel(slow, fast)
+
+		start := time.Now()
+		for addr, err := range resolver.Addresses(context.Background(), res, "ip", "example.com") {
+			require.NoError(t, err)
+			require.Equal(t, netip.MustParseAddr("192.0.2.1"), addr)
+			break
+		}
+		require.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		res := resolver.Parallel(failing, failing)
+
+		var errs int
+		for _, err := range resolver.Addresses(context.Background(), res, "ip", "example.com") {
+			require.ErrorIs(t, err, resolver.ErrNoSuchHost)
+			errs++
+		}
+		require.Equal(t, 1, errs)
+	})
+
+	t.Run("Not Streaming", func(t *testing.T) {
+		var addrs []netip.Addr
+		for addr, err := range resolver.Addresses(context.Background(), fast, "ip", "example.com") {
+			require.NoError(t, err)
+			addrs = append(addrs, addr)
+		}
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+}
@@ -10,8 +10,11 @@
 package resolver
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net"
 	"net/netip"
 	"os"
@@ -27,14 +30,31 @@ type SystemResolverConfig struct {
 	// HostsFilePath is the optional path to the hosts file.
 	// By default, the system's hosts file is used.
 	HostsFilePath string
-	// DialContext is used to establish a connection to a DNS server.
+	// GAIConfPath is the optional path to a gai.conf(5) file, used to sort
+	// addresses. By default, /etc/gai.conf is used if it exists.
+	GAIConfPath string
+	// DialContext is used to establish a connection to a DNS server, see
+	// DialByServer to reach individual servers using different dialers.
 	DialContext DialContextFunc
+	// Logger is an optional logger, queries and retries are logged at debug
+	// level.
+	Logger *slog.Logger
+	// Zeroconf resolves ".local" names, and the names of link-local
+	// addresses, using multicast DNS like desktop operating systems do (see
+	// Zeroconf). Defaults to false.
+	Zeroconf *bool
 }
 
+// defaultGAIConfPath is the location of glibc's address sorting
+// configuration.
+const defaultGAIConfPath = "/etc/gai.conf"
+
 // System returns a Resolver that uses the system's default DNS configuration.
 func System(conf *SystemResolverConfig) (Resolver, error) {
 	conf, err := defaults.WithDefaults(conf, &SystemResolverConfig{
 		DialContext: (&net.Dialer{}).DialContext,
+		Logger:      discardLogger,
+		Zeroconf:    ptr.To(false),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to apply defaults to system resolver config: %w", err)
@@ -45,6 +65,19 @@ func System(conf *SystemResolverConfig) (Resolver, error) {
 		return nil, fmt.Errorf("failed to read system DNS configuration: %w", err)
 	}
 
+	var sort AddrSorter
+	if conf.GAIConfPath != "" {
+		sort, err = SortWithGAIConf(conf.GAIConfPath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sort, err = SortWithGAIConf(defaultGAIConfPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			conf.Logger.Warn("Ignoring invalid gai.conf", slog.Any("error", err))
+		}
+	}
+
 	transport := DNSTransportUDP
 	if systemDNSConf.UseTCP {
 		transport = DNSTransportTCP
@@ -68,6 +101,9 @@ func System(conf *SystemResolverConfig) (Resolver, error) {
 			Timeout:       timeout,
 			DialContext:   conf.DialContext,
 			SingleRequest: &systemDNSConf.SingleRequest,
+			TrustAD:       &systemDNSConf.TrustAD,
+			Sort:          sort,
+			Logger:        conf.Logger,
 		}))
 	}
 
@@ -87,6 +123,7 @@ func System(conf *SystemResolverConfig) (Resolver, error) {
 
 	resolver = Retry(resolver, &RetryResolverConfig{
 		Attempts: attempts,
+		Logger:   conf.Logger,
 	})
 
 	if len(systemDNSConf.Search) > 0 {
@@ -119,5 +156,22 @@ func System(conf *SystemResolverConfig) (Resolver, error) {
 		return nil, fmt.Errorf("failed to create hosts file resolver: %w", err)
 	}
 
-	return Sequential(Literal(), hostsResolver, resolver), nil
+	// Special-use names may still be defined in the hosts file.
+	resolver = SpecialUse(resolver, nil)
+
+	resolver = Sequential(Literal(), hostsResolver, resolver)
+
+	if *conf.Zeroconf {
+		// The hosts file takes precedence over multicast DNS.
+		res, err := Zeroconf(&ZeroconfResolverConfig{
+			Unicast: resolver,
+			Local:   hostsResolver,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	return resolver, nil
 }
@@ -12,9 +12,12 @@ package resolver_test
 import (
 	"context"
 	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
 	"github.com/stretchr/testify/require"
 )
 
@@ -64,3 +67,20 @@ func TestSystemResolver(t *testing.T) {
 		require.ElementsMatch(t, expected, addrs)
 	})
 }
+
+func TestSystemResolverZeroconf(t *testing.T) {
+	hostsFilePath := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(hostsFilePath, []byte("192.0.2.5 printer.local\n"), 0o644))
+
+	res, err := resolver.System(&resolver.SystemResolverConfig{
+		HostsFilePath: hostsFilePath,
+		Zeroconf:      ptr.To(true),
+	})
+	require.NoError(t, err)
+
+	// Hosts file entries for ".local" names take precedence over mDNS.
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "printer.local")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.5")}, addrs)
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"crypto/tls"
+)
+
+// TLSPolicy restricts the TLS versions, cipher suites and key exchange
+// mechanisms used for encrypted transports (eg. DNS over TLS).
+type TLSPolicy string
+
+const (
+	// TLSPolicyDefault uses the Go defaults, or the configured TLSConfig.
+	TLSPolicyDefault TLSPolicy = "default"
+	// TLSPolicyFIPS restricts TLS to FIPS 140 approved algorithms: TLS 1.2 or
+	// later, AES-GCM cipher suites, and NIST P-256/P-384 key exchange.
+	// Go does not allow TLS 1.3 cipher suites to be configured, for full
+	// compliance also build with a FIPS 140 validated crypto module (eg.
+	// GOFIPS140), which enforces the same restrictions for TLS 1.3.
+	TLSPolicyFIPS TLSPolicy = "fips"
+)
+
+// valid returns whether the policy is a known policy.
+func (p TLSPolicy) valid() bool {
+	switch p {
+	case TLSPolicyDefault, TLSPolicyFIPS:
+		return true
+	default:
+		return false
+	}
+}
+
+// fipsCipherSuites are the FIPS 140 approved TLS 1.2 cipher suites.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the FIPS 140 approved key exchange mechanisms.
+var fipsCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+}
+
+// withTLSPolicy returns a copy of the TLS configuration restricted by the
+// policy.
+func withTLSPolicy(tlsConfig *tls.Config, policy TLSPolicy) *tls.Config {
+	if policy != TLSPolicyFIPS {
+		return tlsConfig
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.MinVersion < tls.VersionTLS12 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	tlsConfig.CipherSuites = fipsCipherSuites
+	tlsConfig.CurvePreferences = fipsCurves
+
+	return tlsConfig
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSPolicyFIPS(t *testing.T) {
+	newResolver := func(server netip.AddrPort, cert *x509.Certificate) resolver.Resolver {
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+
+		return resolver.DNS(resolver.DNSResolverConfig{
+			Server:    server,
+			Transport: ptr.To(resolver.DNSTransportTLS),
+			TLSConfig: &tls.Config{
+				ServerName: "dns.example",
+				RootCAs:    roots,
+			},
+			TLSPolicy: ptr.To(resolver.TLSPolicyFIPS),
+		})
+	}
+
+	t.Run("Approved", func(t *testing.T) {
+		server, cert := startTLSTestServer(t, "dns.example")
+
+		_, err := newResolver(server, cert).LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+	})
+
+	t.Run("Not Approved", func(t *testing.T) {
+		server, cert := startTLSTestServer(t, "dns.example", func(tlsConfig *tls.Config) {
+			tlsConfig.MaxVersion = tls.VersionTLS12
+			tlsConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}
+			tlsConfig.CurvePreferences = []tls.CurveID{tls.X25519}
+		})
+
+		_, err := newResolver(server, cert).LookupNetIP(context.Background(), "ip4", "www.example")
+		require.Error(t, err)
+	})
+}
+
+func TestTLSPolicyUnknown(t *testing.T) {
+	require.Panics(t, func() {
+		resolver.DNS(resolver.DNSResolverConfig{
+			Server:    netip.MustParseAddrPort("192.0.2.53:853"),
+			Transport: ptr.To(resolver.DNSTransportTLS),
+			TLSPolicy: ptr.To(resolver.TLSPolicy("FIPS")),
+		})
+	})
+
+	require.Panics(t, func() {
+		resolver.NewDNS(resolver.WithTLSPolicy("strict"))
+	})
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/util/defaults"
+)
+
+// TraceEventType is the type of a step in the resolution of a name.
+type TraceEventType string
+
+const (
+	// TraceEventSearch is a candidate name (eg. from the search list) being
+	// looked up.
+	TraceEventSearch TraceEventType = "search"
+	// TraceEventQuery is a query sent to a DNS server.
+	TraceEventQuery TraceEventType = "query"
+	// TraceEventCNAME is an alias being followed.
+	TraceEventCNAME TraceEventType = "cname"
+	// TraceEventSort is the addresses being sorted (RFC 6724).
+	TraceEventSort TraceEventType = "sort"
+)
+
+// TraceEvent is a step in the resolution of a name.
+type TraceEvent struct {
+	// Type is the type of the event.
+	Type TraceEventType
+	// Time is when the event occurred (or started, for queries).
+	Time time.Time
+	// Name is the name being looked up or queried, or the owner of an alias.
+	Name string
+	// Target is the target of an alias.
+	Target string
+	// QType is the queried record type.
+	QType uint16
+	// Server is the DNS server a query was sent to.
+	Server netip.AddrPort
+	// Transport is the transport protocol used for a query.
+	Transport DNSTransport
+	// Rcode is the return code of the reply to a query, or -1 if no reply was
+	// received.
+	Rcode int
+	// RTT is the round trip time of a query.
+	RTT time.Duration
+	// Err is the error a query failed with.
+	Err error
+	// Addrs are the addresses after sorting.
+	Addrs []netip.Addr
+}
+
+func (e TraceEvent) String() string {
+	switch e.Type {
+	case TraceEventSearch:
+		return fmt.Sprintf("search %s", e.Name)
+	case TraceEventQuery:
+		rcode := "-"
+		if e.Rcode >= 0 {
+			rcode = dns.RcodeToString[e.Rcode]
+		}
+
+		s := fmt.Sprintf("query %s %s @%s (%s) %s in %s", e.Name,
+			dns.TypeToString[e.QType], e.Server, e.Transport, rcode, e.RTT)
+		if e.Err != nil {
+			s += ": " + e.Err.Error()
+		}
+		return s
+	case TraceEventCNAME:
+		return fmt.Sprintf("cname %s -> %s", e.Name, e.Target)
+	case TraceEventSort:
+		return fmt.Sprintf("sort %v", e.Addrs)
+	default:
+		return string(e.Type)
+	}
+}
+
+// ResolutionTrace is the full resolution path of a lookup.
+type ResolutionTrace struct {
+	// Network is the network the lookup was for.
+	Network string
+	// Host is the name that was looked up.
+	Host string
+	// Events are the steps taken to resolve the name, in order.
+	Events []TraceEvent
+	// Addrs are the addresses the lookup returned.
+	Addrs []netip.Addr
+	// Err is the error the lookup failed with.
+	Err error
+	// Duration is how long the lookup took.
+	Duration time.Duration
+}
+
+func (t *ResolutionTrace) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "lookup %s %s\n", t.Network, t.Host)
+	for _, e := range t.Events {
+		fmt.Fprintf(&sb, "  %s\n", e)
+	}
+
+	if t.Err != nil {
+		fmt.Fprintf(&sb, "failed in %s: %v\n", t.Duration, t.Err)
+	} else {
+		fmt.Fprintf(&sb, "resolved in %s: %v\n", t.Duration, t.Addrs)
+	}
+
+	return sb.String()
+}
+
+// TraceConfig is the configuration of a trace.
+type TraceConfig struct {
+	// Clock is an optional source of the current time, used to time the
+	// lookup and its steps. Defaults to the system's time.
+	Clock Clock
+}
+
+// Trace looks up host using resolver, recording each step taken (search list
+// candidates, queries sent and their replies, aliases followed, and address
+// sorting).
+func Trace(ctx context.Context, resolver Resolver, network, host string, conf *TraceConfig) *ResolutionTrace {
+	conf, err := defaults.WithDefaults(conf, &TraceConfig{
+		Clock: systemClock{},
+	})
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+
+	tracer := &tracer{clock: conf.Clock}
+
+	start := tracer.clock.Now()
+	addrs, err := resolver.LookupNetIP(context.WithValue(ctx, tracerKey{}, tracer), network, host)
+
+	return &ResolutionTrace{
+		Network:  network,
+		Host:     host,
+		Events:   tracer.events(),
+		Addrs:    addrs,
+		Err:      err,
+		Duration: tracer.clock.Now().Sub(start),
+	}
+}
+
+type tracerKey struct{}
+
+type tracer struct {
+	clock Clock
+	mu    sync.Mutex
+	evs   []TraceEvent
+}
+
+func (t *tracer) events() []TraceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return slices.Clone(t.evs)
+}
+
+// traceEvent records an event, if the lookup is being traced.
+func traceEvent(ctx context.Context, event TraceEvent) {
+	t, ok := ctx.Value(tracerKey{}).(*tracer)
+	if !ok {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = t.clock.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.evs = append(t.evs, event)
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	server := startTestServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := &dns.Msg{}
+		reply.SetReply(req)
+
+		q := req.Question[0]
+		switch q.Name {
+		case "www.example.":
+			// Don't resolve the alias, so that the resolver has to follow it.
+			reply.Answer = []dns.RR{mustRR(t, "www.example. 300 IN CNAME target.example.")}
+		case "target.example.":
+			if q.Qtype == dns.TypeA {
+				reply.Answer = []dns.RR{mustRR(t, "target.example. 300 IN A 192.0.2.1")}
+			}
+		default:
+			reply.Rcode = dns.RcodeNameError
+		}
+
+		_ = w.WriteMsg(reply)
+	}))
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	res := resolver.Relative(resolver.DNS(resolver.DNSResolverConfig{
+		Server:        server,
+		SingleRequest: ptr.To(true),
+	}), &resolver.RelativeResolverConfig{
+		Search: []string{"missing.", "example."},
+	})
+
+	trace := resolver.Trace(context.Background(), res, "ip", "www", &resolver.TraceConfig{
+		Clock: clock,
+	})
+	require.NoError(t, trace.Err)
+	require.Zero(t, trace.Duration)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, trace.Addrs)
+
+	var types []resolver.TraceEventType
+	for _, e := range trace.Events {
+		types = append(types, e.Type)
+
+		// Queries are timed using the clock of the DNS resolver.
+		if e.Type != resolver.TraceEventQuery {
+			require.Equal(t, clock.now, e.Time)
+		}
+	}
+
+	require.Equal(t, []resolver.TraceEventType{
+		resolver.TraceEventSearch,
+		resolver.TraceEventQuery, // www.missing. A (NXDOMAIN)
+		resolver.TraceEventSearch,
+		resolver.TraceEventQuery, // www.example. A
+		resolver.TraceEventCNAME,
+		resolver.TraceEventQuery, // target.example. A
+		resolver.TraceEventQuery, // www.example. AAAA
+		resolver.TraceEventCNAME,
+		resolver.TraceEventQuery, // target.example. AAAA
+		resolver.TraceEventSort,
+	}, types)
+
+	require.Equal(t, "www.missing.", trace.Events[0].Name)
+	require.Equal(t, dns.RcodeNameError, trace.Events[1].Rcode)
+	require.Equal(t, server, trace.Events[1].Server)
+
+	require.Equal(t, "www.example.", trace.Events[4].Name)
+	require.Equal(t, "target.example.", trace.Events[4].Target)
+
+	require.Equal(t, "target.example.", trace.Events[5].Name)
+	require.Equal(t, dns.TypeA, trace.Events[5].QType)
+	require.Equal(t, dns.RcodeSuccess, trace.Events[5].Rcode)
+	require.NotZero(t, trace.Events[5].RTT)
+
+	require.Contains(t, trace.String(), "cname www.example. -> target.example.")
+	require.Contains(t, trace.String(), "resolved in")
+}
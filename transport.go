@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// errTLSHandshake is wrapped by errors from failed TLS handshakes, which are
+// not likely to be temporary.
+var errTLSHandshake = errors.New("TLS handshake failed")
+
+// Transport sends DNS queries to servers. Implementing it allows queries to
+// be sent using exotic transports (eg. a userspace network stack, a QUIC
+// tunnel, or a test fake), without changing the resolver.
+type Transport interface {
+	// RoundTrip sends req to server and returns the reply. Implementations
+	// should enforce any response limits, but are not required to check
+	// that the reply answers the query (the resolver does this).
+	RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error)
+}
+
+// TransportFunc is an adapter to allow the use of an ordinary function as a
+// transport (eg. as a test fake).
+type TransportFunc func(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error)
+
+func (f TransportFunc) RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+	return f(ctx, server, req)
+}
+
+var (
+	_ Transport = TransportFunc(nil)
+	_ Transport = (*UDPTransport)(nil)
+	_ Transport = (*TCPTransport)(nil)
+	_ Transport = (*TLSTransport)(nil)
+	_ Transport = (*HTTPSTransport)(nil)
+)
+
+// UDPTransport sends DNS queries over UDP (RFC 1035).
+type UDPTransport struct {
+	// DialContext is used to establish connections, defaults to a net.Dialer.
+	DialContext DialContextFunc
+	// ResponseLimits are the limits applied when reading and parsing responses.
+	ResponseLimits ResponseLimits
+	// TSIGKey is an optional key used to sign queries and verify replies.
+	TSIGKey *TSIGKey
+	// Clock is an optional source of the current time, used when signing
+	// queries. Defaults to the system's time.
+	Clock Clock
+}
+
+func (t *UDPTransport) RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+	conn, err := dialOrDefault(t.DialContext)(ctx, "udp", server.String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return exchangeWithConn(ctx, newPeerConn(conn, server), req, t.ResponseLimits, t.TSIGKey, t.Clock)
+}
+
+// TCPTransport sends DNS queries over TCP (RFC 1035 and RFC 7766).
+type TCPTransport struct {
+	// DialContext is used to establish connections, defaults to a net.Dialer.
+	DialContext DialContextFunc
+	// ResponseLimits are the limits applied when reading and parsing responses.
+	ResponseLimits ResponseLimits
+	// TSIGKey is an optional key used to sign queries and verify replies.
+	TSIGKey *TSIGKey
+	// Clock is an optional source of the current time, used when signing
+	// queries. Defaults to the system's time.
+	Clock Clock
+}
+
+func (t *TCPTransport) RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+	conn, err := dialOrDefault(t.DialContext)(ctx, "tcp", server.String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return exchangeWithConn(ctx, conn, req, t.ResponseLimits, t.TSIGKey, t.Clock)
+}
+
+// TLSTransport sends DNS queries over TLS (RFC 7858).
+type TLSTransport struct {
+	// DialContext is used to establish connections, defaults to a net.Dialer.
+	DialContext DialContextFunc
+	// TLSConfig is the configuration of the TLS client.
+	TLSConfig *tls.Config
+	// ResponseLimits are the limits applied when reading and parsing responses.
+	ResponseLimits ResponseLimits
+	// TSIGKey is an optional key used to sign queries and verify replies.
+	TSIGKey *TSIGKey
+	// Clock is an optional source of the current time, used when signing
+	// queries. Defaults to the system's time.
+	Clock Clock
+}
+
+func (t *TLSTransport) RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+	conn, err := dialOrDefault(t.DialContext)(ctx, "tcp", server.String())
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, t.TLSConfig)
+	defer tlsConn.Close()
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %w", errTLSHandshake, err)
+	}
+
+	return exchangeWithConn(ctx, tlsConn, req, t.ResponseLimits, t.TSIGKey, t.Clock)
+}
+
+// HTTPSTransport sends DNS queries over HTTPS (RFC 8484). Connections are
+// reused between queries, CloseIdleConnections should be called when the
+// transport is no longer needed.
+type HTTPSTransport struct {
+	// Path is the path of the DNS over HTTPS endpoint, defaults to
+	// "/dns-query".
+	Path string
+	// DialContext is used to establish connections, defaults to a net.Dialer.
+	DialContext DialContextFunc
+	// TLSConfig is the configuration of the TLS client.
+	TLSConfig *tls.Config
+	// ResponseLimits are the limits applied when reading and parsing responses.
+	ResponseLimits ResponseLimits
+	// TSIGKey is an optional key used to sign queries and verify replies.
+	TSIGKey *TSIGKey
+	// Clock is an optional source of the current time, used when signing
+	// queries. Defaults to the system's time.
+	Clock Clock
+
+	once      sync.Once
+	transport *http.Transport
+}
+
+func (t *HTTPSTransport) RoundTrip(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+	var buf []byte
+	var requestMAC string
+	var err error
+	if t.TSIGKey != nil {
+		buf, requestMAC, err = t.TSIGKey.signRequest(req, clockOrDefault(t.Clock).Now())
+	} else {
+		buf, err = req.Pack()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path := t.Path
+	if path == "" {
+		path = "/dns-query"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+server.String()+path, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", dohContentType)
+	httpReq.Header.Set("Content-Type", dohContentType)
+
+	resp, err := t.httpTransport().RoundTrip(httpReq)
+	if err != nil {
+		var recordErr tls.RecordHeaderError
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &recordErr) || errors.As(err, &certErr) {
+			return nil, fmt.Errorf("%w: %w", errTLSHandshake, err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q: %w", resp.Status, ErrServerMisbehaving)
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != dohContentType {
+		return nil, fmt.Errorf("unexpected content type %q: %w", mediaType, ErrServerMisbehaving)
+	}
+
+	maxSize := t.ResponseLimits.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = dns.MaxMsgSize
+	}
+
+	p, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p) > maxSize {
+		return nil, fmt.Errorf("message size exceeds %d: %w", maxSize, errLimitExceeded)
+	}
+
+	return unpackReply(p, t.ResponseLimits, t.TSIGKey, requestMAC)
+}
+
+// CloseIdleConnections closes any connections that are not in use.
+func (t *HTTPSTransport) CloseIdleConnections() {
+	t.httpTransport().CloseIdleConnections()
+}
+
+func (t *HTTPSTransport) httpTransport() *http.Transport {
+	t.once.Do(func() {
+		t.transport = &http.Transport{
+			DialContext:       dialOrDefault(t.DialContext),
+			TLSClientConfig:   t.TLSConfig,
+			ForceAttemptHTTP2: true,
+		}
+	})
+	return t.transport
+}
+
+func dialOrDefault(dial DialContextFunc) DialContextFunc {
+	if dial != nil {
+		return dial
+	}
+	return (&net.Dialer{}).DialContext
+}
+
+func clockOrDefault(clock Clock) Clock {
+	if clock != nil {
+		return clock
+	}
+	return systemClock{}
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransports(t *testing.T) {
+	upstream := dnstest.NewServer(dnstest.Records{
+		"www.example.com": {"A 192.0.2.1"},
+	})
+	t.Cleanup(upstream.Close)
+
+	for _, transport := range []resolver.Transport{
+		&resolver.UDPTransport{},
+		&resolver.TCPTransport{},
+	} {
+		req := new(dns.Msg).SetQuestion("www.example.com.", dns.TypeA)
+
+		reply, err := transport.RoundTrip(context.Background(), upstream.Addr, req)
+		require.NoError(t, err)
+
+		require.Equal(t, req.Id, reply.Id)
+		require.Len(t, reply.Answer, 1)
+	}
+}
+
+func TestCustomTransport(t *testing.T) {
+	var servers []netip.AddrPort
+	transport := resolver.TransportFunc(func(ctx context.Context, server netip.AddrPort, req *dns.Msg) (*dns.Msg, error) {
+		servers = append(servers, server)
+
+		return dnstest.Answer().A(req.Question[0].Name, "192.0.2.1", 300).Reply(req), nil
+	})
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server:          netip.MustParseAddrPort("192.0.2.53:53"),
+		CustomTransport: transport,
+	})
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	require.Equal(t, []netip.AddrPort{netip.MustParseAddrPort("192.0.2.53:53")}, servers)
+
+	t.Run("Strict Privacy", func(t *testing.T) {
+		// Custom transports are only considered encrypted if the transport
+		// says so.
+		res := resolver.DNS(resolver.DNSResolverConfig{
+			Server:          netip.MustParseAddrPort("192.0.2.53:53"),
+			Transport:       ptr.To(resolver.DNSTransportUDP),
+			CustomTransport: transport,
+			PrivacyProfile:  ptr.To(resolver.PrivacyProfileStrict),
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.ErrorIs(t, err, resolver.ErrPrivacyRequired)
+	})
+}
+
+func TestHTTPSTransport(t *testing.T) {
+	res := new(dnstest.MockResolver)
+	res.On("LookupNetIP", mock.Anything, "ip4", "www.example.com.").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+
+	mux := http.NewServeMux()
+	mux.Handle("/dns-query", resolver.NewDNSServer(res, nil))
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	tlsConfig.ServerName = "127.0.0.1"
+
+	dohResolver := resolver.DNS(resolver.DNSResolverConfig{
+		Server:    netip.MustParseAddrPort(srv.Listener.Addr().String()),
+		Transport: ptr.To(resolver.DNSTransportHTTPS),
+		TLSConfig: tlsConfig,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, dohResolver.Close())
+	})
+
+	addrs, err := dohResolver.LookupNetIP(context.Background(), "ip4", "www.example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+
+	t.Run("Derived", func(t *testing.T) {
+		var dials atomic.Int32
+		parent := resolver.DNS(resolver.DNSResolverConfig{
+			Server:    netip.MustParseAddrPort(srv.Listener.Addr().String()),
+			Transport: ptr.To(resolver.DNSTransportHTTPS),
+			TLSConfig: tlsConfig,
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dials.Add(1)
+				return (&net.Dialer{}).DialContext(ctx, network, address)
+			},
+		})
+		t.Cleanup(func() {
+			require.NoError(t, parent.Close())
+		})
+
+		// The connection pool is shared with derived resolvers.
+		for _, r := range []resolver.Resolver{parent, parent.Clone(), parent.With(resolver.DNSResolverConfig{
+			Timeout: ptr.To(time.Second),
+		})} {
+			_, err := r.LookupNetIP(context.Background(), "ip4", "www.example.com")
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, int32(1), dials.Load())
+	})
+
+	t.Run("Untrusted", func(t *testing.T) {
+		untrusted := resolver.DNS(resolver.DNSResolverConfig{
+			Server:    netip.MustParseAddrPort(srv.Listener.Addr().String()),
+			Transport: ptr.To(resolver.DNSTransportHTTPS),
+			TLSConfig: &tls.Config{ServerName: "127.0.0.1"},
+		})
+
+		_, err := untrusted.LookupNetIP(context.Background(), "ip4", "www.example.com")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGKey is a shared key used to authenticate queries and replies using
+// TSIG (RFC 8945).
+type TSIGKey struct {
+	// Name is the name of the key.
+	Name string
+	// Algorithm is the HMAC algorithm (eg. dns.HmacSHA256). Defaults to
+	// hmac-sha256.
+	Algorithm string
+	// Secret is the raw (not base64 encoded) value of the key.
+	Secret Secret
+}
+
+func (k *TSIGKey) algorithm() string {
+	if k.Algorithm == "" {
+		return dns.HmacSHA256
+	}
+	return dns.CanonicalName(k.Algorithm)
+}
+
+// signRequest returns the wire format of req signed with the key at the given
+// time, along with the request MAC (which is required to verify the reply).
+func (k *TSIGKey) signRequest(req *dns.Msg, now time.Time) ([]byte, string, error) {
+	// Signing removes the TSIG record from the message, so sign a copy.
+	signed := req.Copy()
+	signed.SetTsig(dns.CanonicalName(k.Name), k.algorithm(), 300, now.Unix())
+
+	buf, mac, err := dns.TsigGenerateWithProvider(signed, &tsigProvider{key: k}, "", false)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	return buf, mac, nil
+}
+
+// verifyReply verifies that the wire format reply was signed with the key.
+func (k *TSIGKey) verifyReply(p []byte, requestMAC string) error {
+	if err := dns.TsigVerifyWithProvider(p, &tsigProvider{key: k}, requestMAC, false); err != nil {
+		return fmt.Errorf("failed to verify reply signature: %w", err)
+	}
+
+	return nil
+}
+
+// tsigProvider computes TSIG MACs without exposing the key (eg. as a base64
+// encoded string).
+type tsigProvider struct {
+	key *TSIGKey
+}
+
+func (p *tsigProvider) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	var newHash func() hash.Hash
+	switch dns.CanonicalName(t.Algorithm) {
+	case dns.HmacSHA1:
+		newHash = sha1.New
+	case dns.HmacSHA224:
+		newHash = sha256.New224
+	case dns.HmacSHA256:
+		newHash = sha256.New
+	case dns.HmacSHA384:
+		newHash = sha512.New384
+	case dns.HmacSHA512:
+		newHash = sha512.New
+	default:
+		return nil, dns.ErrKeyAlg
+	}
+
+	var mac []byte
+	err := p.key.Secret.Use(func(value []byte) error {
+		h := hmac.New(newHash, value)
+		h.Write(msg)
+		mac = h.Sum(nil)
+		return nil
+	})
+	return mac, err
+}
+
+func (p *tsigProvider) Verify(msg []byte, t *dns.TSIG) error {
+	expected, err := p.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(expected, mac) {
+		return dns.ErrSig
+	}
+
+	return nil
+}
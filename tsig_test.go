@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSIG(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{
+		PacketConn: pc,
+		TsigSecret: map[string]string{"test-key.": base64.StdEncoding.EncodeToString(key)},
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			reply := &dns.Msg{}
+			reply.SetReply(req)
+
+			if req.IsTsig() == nil || w.TsigStatus() != nil {
+				reply.Rcode = dns.RcodeNotAuth
+			} else if req.Question[0].Qtype == dns.TypeA {
+				reply.Answer = []dns.RR{mustRR(t, req.Question[0].Name+" 300 IN A 192.0.2.1")}
+			}
+
+			if req.IsTsig() != nil {
+				reply.SetTsig("test-key.", dns.HmacSHA256, 300, time.Now().Unix())
+			}
+
+			_ = w.WriteMsg(reply)
+		}),
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+
+	secret := resolver.NewRotatingSecret(key)
+
+	res := resolver.DNS(resolver.DNSResolverConfig{
+		Server: netip.MustParseAddrPort(pc.LocalAddr().String()),
+		TSIGKey: &resolver.TSIGKey{
+			Name:   "test-key",
+			Secret: secret,
+		},
+	})
+
+	t.Run("Signed", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+	})
+
+	t.Run("Wrong Key", func(t *testing.T) {
+		secret.Rotate([]byte("not the right key"))
+		t.Cleanup(func() {
+			secret.Rotate(key)
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.Error(t, err)
+
+		require.NotContains(t, err.Error(), "not the right key")
+		require.NotContains(t, err.Error(), base64.StdEncoding.EncodeToString([]byte("not the right key")))
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		// Queries are signed at the time given by the resolver's clock, an
+		// hour is outside of the allowed clock skew.
+		skewed := res.With(resolver.DNSResolverConfig{
+			Clock: &fakeClock{now: time.Now().Add(-time.Hour)},
+		})
+
+		_, err := skewed.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.Error(t, err)
+	})
+
+	t.Run("Zeroized", func(t *testing.T) {
+		secret.Zeroize()
+		t.Cleanup(func() {
+			secret.Rotate(key)
+		})
+
+		_, err := res.LookupNetIP(context.Background(), "ip4", "www.example")
+		require.ErrorContains(t, err, resolver.ErrSecretZeroized.Error())
+	})
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ParseResponse parses a response to req from its wire format, and checks
+// that it is a well formed answer to the request. The response limits are
+// enforced before the response is parsed, so that malicious messages (eg.
+// with compression pointer loops) are rejected cheaply. It performs no I/O,
+// so can be used to validate responses received by other means.
+func ParseResponse(req *dns.Msg, msg []byte, limits ResponseLimits) (*dns.Msg, error) {
+	if len(msg) < 2 {
+		return nil, dns.ErrShortRead
+	}
+
+	if binary.BigEndian.Uint16(msg) != req.Id {
+		return nil, fmt.Errorf("id mismatch: %w", ErrServerMisbehaving)
+	}
+
+	if err := checkLimits(msg, limits); err != nil {
+		return nil, fmt.Errorf("%w: %w", err, ErrServerMisbehaving)
+	}
+
+	reply := &dns.Msg{}
+	if err := reply.Unpack(msg); err != nil {
+		return nil, fmt.Errorf("failed to unpack response: %w: %w", err, ErrServerMisbehaving)
+	}
+
+	if err := validateReply(req, reply); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// AnswerAddrs returns the addresses in the answer section of a response,
+// for name or the targets of any aliases of it. Records for any other names
+// are ignored.
+func AnswerAddrs(reply *dns.Msg, name string) []netip.Addr {
+	return addrsFromAnswers(reply.Answer, name)
+}
+
+// validateReply checks that a reply is a well formed answer to the request,
+// replies that fail validation should be discarded.
+func validateReply(req, reply *dns.Msg) error {
+	if !reply.Response {
+		return fmt.Errorf("message is not a response: %w", ErrServerMisbehaving)
+	}
+
+	// Truncated replies, and errors from servers that could not parse (or do
+	// not implement) the query, are allowed to omit the question.
+	if len(reply.Question) == 0 && (reply.Truncated ||
+		reply.Rcode == dns.RcodeFormatError || reply.Rcode == dns.RcodeNotImplemented) {
+		return nil
+	}
+
+	if len(reply.Question) != len(req.Question) {
+		return fmt.Errorf("unexpected number of questions in response: %w", ErrServerMisbehaving)
+	}
+
+	if len(req.Question) == 0 {
+		return nil
+	}
+
+	q, rq := req.Question[0], reply.Question[0]
+	if !strings.EqualFold(q.Name, rq.Name) || q.Qtype != rq.Qtype || q.Qclass != rq.Qclass {
+		return fmt.Errorf("question mismatch %q: %w", rq.String(), ErrServerMisbehaving)
+	}
+
+	// All answer records must either be for the query name, or for names
+	// encountered while following aliases.
+	names := aliasChain(reply.Answer, q.Name)
+	for _, rr := range reply.Answer {
+		owner := dns.CanonicalName(rr.Header().Name)
+		if names[owner] {
+			continue
+		}
+
+		// A DNAME record is owned by an ancestor of the name it applies to.
+		if _, ok := rr.(*dns.DNAME); ok {
+			for name := range names {
+				if dns.IsSubDomain(owner, name) {
+					names[owner] = true
+					break
+				}
+			}
+		}
+
+		if !names[owner] {
+			return fmt.Errorf("unsolicited answer record for %q: %w", rr.Header().Name, ErrServerMisbehaving)
+		}
+	}
+
+	return nil
+}
+
+// aliasChain returns the set of names reachable from name by following the
+// CNAME and DNAME records in rrs (regardless of their order).
+func aliasChain(rrs []dns.RR, name string) map[string]bool {
+	names := map[string]bool{dns.CanonicalName(name): true}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, rr := range rrs {
+			owner := dns.CanonicalName(rr.Header().Name)
+
+			var targets []string
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				if names[owner] {
+					targets = append(targets, dns.CanonicalName(rr.Target))
+				}
+			case *dns.DNAME:
+				for name := range names {
+					if dns.IsSubDomain(owner, name) && owner != name {
+						targets = append(targets, dns.CanonicalName(strings.TrimSuffix(name, owner)+rr.Target))
+					}
+				}
+			}
+
+			for _, target := range targets {
+				if !names[target] {
+					names[target] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// addrsFromAnswers extracts the addresses from the A and AAAA records in rrs
+// whose owner is either name, or an alias target of name. Records for any
+// other names are ignored.
+func addrsFromAnswers(rrs []dns.RR, name string) []netip.Addr {
+	names := aliasChain(rrs, name)
+
+	var addrs []netip.Addr
+	for _, rr := range rrs {
+		if !names[dns.CanonicalName(rr.Header().Name)] {
+			continue
+		}
+
+		switch rr := rr.(type) {
+		case *dns.A:
+			addrs = append(addrs, netip.AddrFrom4([4]byte(rr.A.To4())))
+		case *dns.AAAA:
+			addrs = append(addrs, netip.AddrFrom16([16]byte(rr.AAAA.To16())))
+		}
+	}
+
+	return addrs
+}
+
+// ptrsFromAnswers extracts the names from the PTR records in rrs whose owner
+// is either name, or an alias target of name (eg. an RFC 2317 classless
+// delegation). Records for any other names are ignored.
+func ptrsFromAnswers(rrs []dns.RR, name string) []string {
+	names := aliasChain(rrs, name)
+
+	var ptrs []string
+	for _, rr := range rrs {
+		if ptr, ok := rr.(*dns.PTR); ok && names[dns.CanonicalName(ptr.Hdr.Name)] {
+			ptrs = append(ptrs, ptr.Ptr)
+		}
+	}
+
+	return ptrs
+}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResponse(t *testing.T) {
+	req := new(dns.Msg).SetQuestion("www.example.com.", dns.TypeA)
+
+	reply := dnstest.Answer().
+		CNAME("www.example.com.", "web.example.com.", 300).
+		A("web.example.com.", "192.0.2.1", 300).
+		A("other.example.com.", "192.0.2.2", 300).
+		Reply(req)
+
+	packed, err := reply.Pack()
+	require.NoError(t, err)
+
+	t.Run("Unsolicited Record", func(t *testing.T) {
+		_, err := resolver.ParseResponse(req, packed, resolver.ResponseLimits{})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+	})
+
+	reply.Answer = reply.Answer[:2]
+	packed, err = reply.Pack()
+	require.NoError(t, err)
+
+	t.Run("Valid", func(t *testing.T) {
+		parsed, err := resolver.ParseResponse(req, packed, resolver.ResponseLimits{})
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, resolver.AnswerAddrs(parsed, "www.example.com."))
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		_, err := resolver.ParseResponse(req, packed, resolver.ResponseLimits{MaxRecords: 1})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+
+		_, err = resolver.ParseResponse(req, packed, resolver.ResponseLimits{MaxMessageSize: 16})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+
+		_, err = resolver.ParseResponse(req, packed, resolver.ResponseLimits{MaxNameLength: 8})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+	})
+
+	t.Run("ID Mismatch", func(t *testing.T) {
+		other := req.Copy()
+		other.Id++
+
+		_, err := resolver.ParseResponse(other, packed, resolver.ResponseLimits{})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+	})
+
+	t.Run("Question Mismatch", func(t *testing.T) {
+		other := new(dns.Msg).SetQuestion("www.example.org.", dns.TypeA)
+		other.Id = req.Id
+
+		_, err := resolver.ParseResponse(other, packed, resolver.ResponseLimits{})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+	})
+
+	t.Run("Missing Question", func(t *testing.T) {
+		noQuestion := reply.Copy()
+		noQuestion.Question = nil
+
+		packed, err := noQuestion.Pack()
+		require.NoError(t, err)
+
+		_, err = resolver.ParseResponse(req, packed, resolver.ResponseLimits{})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+	})
+
+	t.Run("Truncated Without Question", func(t *testing.T) {
+		truncated := new(dns.Msg).SetReply(req)
+		truncated.Question = nil
+		truncated.Truncated = true
+
+		packed, err := truncated.Pack()
+		require.NoError(t, err)
+
+		parsed, err := resolver.ParseResponse(req, packed, resolver.ResponseLimits{})
+		require.NoError(t, err)
+		require.True(t, parsed.Truncated)
+	})
+
+	t.Run("Error Without Question", func(t *testing.T) {
+		formErr := new(dns.Msg).SetRcode(req, dns.RcodeFormatError)
+		formErr.Question = nil
+
+		packed, err := formErr.Pack()
+		require.NoError(t, err)
+
+		parsed, err := resolver.ParseResponse(req, packed, resolver.ResponseLimits{})
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeFormatError, parsed.Rcode)
+	})
+
+	t.Run("NXDOMAIN Without Question", func(t *testing.T) {
+		nxDomain := new(dns.Msg).SetRcode(req, dns.RcodeNameError)
+		nxDomain.Question = nil
+
+		packed, err := nxDomain.Pack()
+		require.NoError(t, err)
+
+		_, err = resolver.ParseResponse(req, packed, resolver.ResponseLimits{})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := resolver.ParseResponse(req, packed[:len(packed)-3], resolver.ResponseLimits{})
+		require.ErrorIs(t, err, resolver.ErrServerMisbehaving)
+
+		_, err = resolver.ParseResponse(req, packed[:1], resolver.ResponseLimits{})
+		require.Error(t, err)
+	})
+}
+
+func FuzzParseResponse(f *testing.F) {
+	req := new(dns.Msg).SetQuestion("www.example.com.", dns.TypeA)
+	req.Id = 1
+
+	reply := dnstest.Answer().
+		CNAME("www.example.com.", "web.example.com.", 300).
+		A("web.example.com.", "192.0.2.1", 300).
+		AAAA("web.example.com.", "2001:db8::1", 300).
+		Reply(req)
+	reply.Compress = true
+
+	packed, err := reply.Pack()
+	require.NoError(f, err)
+
+	f.Add(packed)
+	// A question whose name is a compression pointer loop.
+	f.Add([]byte{
+		0, 1, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0,
+		1, 'a', 0xC0, 12,
+		0, 1, 0, 1,
+	})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		parsed, err := resolver.ParseResponse(req, msg, resolver.ResponseLimits{
+			MaxRecords:             64,
+			MaxExpandedSize:        4096,
+			MaxCompressionPointers: 256,
+			MaxNameLength:          128,
+		})
+		if err != nil {
+			return
+		}
+
+		for _, addr := range resolver.AnswerAddrs(parsed, "www.example.com.") {
+			require.True(t, addr.IsValid())
+		}
+	})
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+)
+
+var _ Resolver = (*validatingResolver)(nil)
+
+// HostValidatorFunc validates a hostname prior to resolution, returning an
+// error if the hostname should not be resolved.
+type HostValidatorFunc func(host string) error
+
+// ValidatingResolverConfig is the configuration for a validating resolver.
+type ValidatingResolverConfig struct {
+	// Validators is the list of validators to apply to each hostname.
+	Validators []HostValidatorFunc
+}
+
+// validatingResolver is a resolver that validates hostnames before resolving
+// them.
+type validatingResolver struct {
+	resolver   Resolver
+	validators []HostValidatorFunc
+}
+
+// Validating returns a resolver that validates hostnames before resolving
+// them. Errors returned by validators are passed through unchanged, so that
+// callers can inspect them with errors.Is/As.
+func Validating(resolver Resolver, conf *ValidatingResolverConfig) *validatingResolver {
+	if conf == nil {
+		conf = &ValidatingResolverConfig{}
+	}
+
+	return &validatingResolver{
+		resolver:   resolver,
+		validators: conf.Validators,
+	}
+}
+
+func (r *validatingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	for _, validate := range r.validators {
+		if err := validate(host); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.resolver.LookupNetIP(ctx, network, host)
+}
+
+// Close closes the underlying resolver.
+func (r *validatingResolver) Close() error {
+	return Close(r.resolver)
+}
+
+func (r *validatingResolver) describe() Description {
+	return Description{
+		Type:       "validating",
+		Attributes: map[string]string{"validators": strconv.Itoa(len(r.validators))},
+		Children:   []Description{Describe(r.resolver)},
+	}
+}
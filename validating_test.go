@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatingResolver(t *testing.T) {
+	inner := new(dnstest.MockResolver)
+	inner.On("LookupNetIP", mock.Anything, "ip", mock.Anything).Return([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil)
+
+	res := resolver.Validating(inner, &resolver.ValidatingResolverConfig{
+		Validators: []resolver.HostValidatorFunc{resolver.DetectConfusables},
+	})
+
+	for _, host := range []string{"www.example.com", "münchen.de", "日本語とにほんご.jp", "xn--mnchen-3ya.de"} {
+		t.Run("Valid "+host, func(t *testing.T) {
+			addrs, err := res.LookupNetIP(context.Background(), "ip", host)
+			require.NoError(t, err)
+
+			require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+		})
+	}
+
+	// The "а" and "р" are Cyrillic.
+	for _, host := range []string{"аpple.com", "xn--pple-43d.com", "pаyрal.com"} {
+		t.Run("Confusable "+host, func(t *testing.T) {
+			_, err := res.LookupNetIP(context.Background(), "ip", host)
+			require.ErrorIs(t, err, resolver.ErrConfusable)
+
+			var confusableErr *resolver.ConfusableError
+			require.ErrorAs(t, err, &confusableErr)
+			require.Equal(t, []string{"Cyrillic", "Latin"}, confusableErr.Scripts)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+)
+
+var _ Resolver = (*viewResolver)(nil)
+
+type clientIdentityKey struct{}
+
+type clientAddrKey struct{}
+
+// WithClientIdentity returns a copy of ctx carrying the identity of the client
+// on whose behalf a lookup is being performed (eg. a tenant ID).
+func WithClientIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, identity)
+}
+
+// ClientIdentity returns the client identity stored in ctx, if any.
+func ClientIdentity(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(clientIdentityKey{}).(string)
+	return identity, ok
+}
+
+// WithClientAddr returns a copy of ctx carrying the source address of the
+// client on whose behalf a lookup is being performed.
+func WithClientAddr(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, clientAddrKey{}, addr)
+}
+
+// ClientAddr returns the client source address stored in ctx, if any.
+func ClientAddr(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientAddrKey{}).(netip.Addr)
+	return addr, ok
+}
+
+// View is a set of clients that share a common DNS policy.
+type View struct {
+	// Name is an optional human readable name for the view.
+	Name string
+	// Identities is a list of client identities (see WithClientIdentity) that
+	// belong to this view.
+	Identities []string
+	// Sources is a list of prefixes matching the source addresses of clients
+	// (see WithClientAddr) that belong to this view.
+	Sources []netip.Prefix
+	// Resolver is used to answer lookups for clients in this view. Static
+	// answers can be provided by using a HostsResolver.
+	Resolver Resolver
+}
+
+// ViewResolverConfig is the configuration for a view resolver.
+type ViewResolverConfig struct {
+	// Views is the list of views, the first matching view is used.
+	Views []View
+	// Default is an optional resolver used when no view matches the client.
+	// By default, lookups from unmatched clients fail with ErrNoSuchHost.
+	Default Resolver
+}
+
+// viewResolver is a resolver that answers lookups differently depending on the
+// identity of the client.
+type viewResolver struct {
+	views           []View
+	defaultResolver Resolver
+}
+
+// Views returns a resolver that selects a child resolver based on the identity
+// of the client (as stored in the lookup context).
+func Views(conf *ViewResolverConfig) *viewResolver {
+	if conf == nil {
+		conf = &ViewResolverConfig{}
+	}
+
+	return &viewResolver{
+		views:           conf.Views,
+		defaultResolver: conf.Default,
+	}
+}
+
+func (r *viewResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	resolver := r.defaultResolver
+	if view, ok := r.match(ctx); ok {
+		resolver = view.Resolver
+	}
+
+	if resolver == nil {
+		return nil, newError(host, "", ErrNoSuchHost)
+	}
+
+	return resolver.LookupNetIP(ctx, network, host)
+}
+
+func (r *viewResolver) match(ctx context.Context) (*View, bool) {
+	identity, hasIdentity := ClientIdentity(ctx)
+	addr, hasAddr := ClientAddr(ctx)
+
+	for i := range r.views {
+		view := &r.views[i]
+
+		if hasIdentity && slices.Contains(view.Identities, identity) {
+			return view, true
+		}
+
+		if hasAddr {
+			for _, prefix := range view.Sources {
+				if prefix.Contains(addr.Unmap()) {
+					return view, true
+				}
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Close closes the resolvers of every view and the default resolver.
+func (r *viewResolver) Close() error {
+	resolvers := make([]Resolver, 0, len(r.views)+1)
+	for _, view := range r.views {
+		resolvers = append(resolvers, view.Resolver)
+	}
+	return closeAll(append(resolvers, r.defaultResolver))
+}
+
+func (r *viewResolver) describe() Description {
+	d := Description{Type: "views"}
+	for _, view := range r.views {
+		d.Children = append(d.Children, Description{
+			Type:       "view",
+			Attributes: map[string]string{"name": view.Name},
+			Children:   []Description{Describe(view.Resolver)},
+		})
+	}
+
+	if r.defaultResolver != nil {
+		d.Children = append(d.Children, Description{
+			Type:     "default",
+			Children: []Description{Describe(r.defaultResolver)},
+		})
+	}
+
+	return d
+}
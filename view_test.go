@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewResolver(t *testing.T) {
+	tenantA, err := resolver.Hosts(&resolver.HostsResolverConfig{NoHostsFile: ptr.To(true)})
+	require.NoError(t, err)
+	tenantA.AddHost("db.internal", netip.MustParseAddr("10.0.0.1"))
+
+	tenantB, err := resolver.Hosts(&resolver.HostsResolverConfig{NoHostsFile: ptr.To(true)})
+	require.NoError(t, err)
+	tenantB.AddHost("db.internal", netip.MustParseAddr("10.0.1.1"))
+
+	res := resolver.Views(&resolver.ViewResolverConfig{
+		Views: []resolver.View{
+			{
+				Name:       "tenant-a",
+				Identities: []string{"a"},
+				Resolver:   tenantA,
+			},
+			{
+				Name:     "tenant-b",
+				Sources:  []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+				Resolver: tenantB,
+			},
+		},
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		ctx := resolver.WithClientIdentity(context.Background(), "a")
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "db.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, addrs)
+	})
+
+	t.Run("Source Address", func(t *testing.T) {
+		ctx := resolver.WithClientAddr(context.Background(), netip.MustParseAddr("192.168.1.20"))
+
+		addrs, err := res.LookupNetIP(ctx, "ip", "db.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.1.1")}, addrs)
+	})
+
+	t.Run("No Matching View", func(t *testing.T) {
+		_, err := res.LookupNetIP(context.Background(), "ip", "db.internal")
+
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		require.Equal(t, resolver.ErrNoSuchHost.Error(), dnsErr.Err)
+	})
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+)
+
+var (
+	_ Resolver     = (*zeroconfResolver)(nil)
+	_ AddrResolver = (*zeroconfResolver)(nil)
+)
+
+// ZeroconfResolverConfig is the configuration for a zeroconf resolver.
+type ZeroconfResolverConfig struct {
+	// Unicast is the resolver used for all other names. Defaults to the
+	// system resolver.
+	Unicast Resolver
+	// Local is an optional resolver consulted for ".local" names before
+	// multicast DNS, eg. a hosts file resolver (like the nsswitch "files
+	// mdns4_minimal" order).
+	Local Resolver
+	// MDNS is the optional configuration of the mDNS resolver.
+	MDNS *MDNSResolverConfig
+}
+
+// zeroconfResolver is a resolver that resolves ".local" names (and the names
+// of link-local addresses) using multicast DNS, and all other names using
+// unicast DNS.
+type zeroconfResolver struct {
+	*routingResolver
+	mdns    *mdnsResolver
+	unicast Resolver
+}
+
+// Zeroconf returns a resolver that interoperates with Avahi and Bonjour,
+// matching the behavior of desktop operating systems: ".local" names are
+// resolved using multicast DNS (RFC 6762 section 3) and never sent to unicast
+// DNS, as are reverse lookups of link-local addresses, and all other names are
+// resolved using unicast DNS.
+func Zeroconf(conf *ZeroconfResolverConfig) (*zeroconfResolver, error) {
+	if conf == nil {
+		conf = &ZeroconfResolverConfig{}
+	}
+
+	unicast := conf.Unicast
+	if unicast == nil {
+		var err error
+		unicast, err = System(nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mdns := MDNS(conf.MDNS)
+
+	var local Resolver = mdns
+	if conf.Local != nil {
+		local = Sequential(conf.Local, mdns)
+	}
+
+	return &zeroconfResolver{
+		routingResolver: Routing(&RoutingResolverConfig{
+			Routes: []Route{{
+				Domains:  []string{"local."},
+				Resolver: local,
+			}},
+			Default: unicast,
+		}),
+		mdns:    mdns,
+		unicast: unicast,
+	}, nil
+}
+
+// LookupAddr returns the names mapping to the given address. Link-local
+// addresses are looked up using multicast DNS, other addresses using the
+// unicast resolver (if it supports reverse lookups).
+func (r *zeroconfResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return nil, newError(addr, "", fmt.Errorf("%w: %w", ErrNoSuchHost, err))
+	}
+
+	if ip.Unmap().IsLinkLocalUnicast() {
+		return r.mdns.LookupAddr(ctx, addr)
+	}
+
+	unicast, ok := r.unicast.(AddrResolver)
+	if !ok {
+		return nil, newError(addr, "", fmt.Errorf("reverse lookups are not supported: %w", ErrNoSuchHost))
+	}
+
+	return unicast.LookupAddr(ctx, addr)
+}
+
+func (r *zeroconfResolver) describe() Description {
+	d := r.routingResolver.describe()
+	d.Type = "zeroconf"
+	return d
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/noisysockets/resolver/dnstest"
+	"github.com/noisysockets/util/ptr"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZeroconf(t *testing.T) {
+	unicast := new(dnstest.MockResolver)
+	unicast.On("LookupNetIP", mock.Anything, "ip4", "www.example.com").
+		Return([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil)
+	unicast.On("LookupAddr", mock.Anything, "192.0.2.1").
+		Return([]string{"www.example.com."}, nil)
+
+	res, err := resolver.Zeroconf(&resolver.ZeroconfResolverConfig{
+		Unicast: unicast,
+		MDNS: &resolver.MDNSResolverConfig{
+			Groups:  []netip.AddrPort{startMDNSResponder(t)},
+			Timeout: ptr.To(200 * time.Millisecond),
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	addrs, err := res.LookupNetIP(ctx, "ip4", "node1.local")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("169.254.0.1")}, addrs)
+
+	addrs, err = res.LookupNetIP(ctx, "ip4", "www.example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+
+	names, err := res.LookupAddr(ctx, "fe80::1")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"node1.local."}, names)
+
+	// Global addresses are looked up using the unicast resolver.
+	names, err = res.LookupAddr(ctx, "192.0.2.1")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"www.example.com."}, names)
+
+	unicast.AssertExpectations(t)
+}
@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/noisysockets/resolver/addrselect"
+	"github.com/noisysockets/util/address"
+	"github.com/noisysockets/util/defaults"
+)
+
+// maxZoneCNAMEChain is the maximum number of CNAMEs followed when answering
+// from a zone file.
+const maxZoneCNAMEChain = 8
+
+var _ Resolver = (*ZoneResolver)(nil)
+
+type ZoneResolverConfig struct {
+	// ZoneFileReader is an optional reader that will be used as the source of
+	// the zone file. Either ZoneFileReader or ZoneFilePath must be provided.
+	ZoneFileReader io.Reader
+	// ZoneFilePath is the optional path to the zone file. $INCLUDE directives
+	// are only permitted when the zone is loaded from a path.
+	ZoneFilePath string
+	// Origin is the origin relative names in the zone file are relative to,
+	// unless overridden by an $ORIGIN directive. Defaults to the root.
+	Origin string
+	// DialContext is an optional dialer used for ordering the returned addresses.
+	DialContext DialContextFunc
+}
+
+// ZoneResolver is a resolver that answers queries locally from the records
+// of a standard (RFC 1035) zone file, eg. for air-gapped environments.
+type ZoneResolver struct {
+	records     map[string][]dns.RR
+	dialContext DialContextFunc
+}
+
+// Zone returns a resolver that answers queries from the records of a zone file.
+func Zone(conf *ZoneResolverConfig) (*ZoneResolver, error) {
+	conf, err := defaults.WithDefaults(conf, &ZoneResolverConfig{
+		Origin:      ".",
+		DialContext: (&net.Dialer{}).DialContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply defaults to zone resolver config: %w", err)
+	}
+
+	r := conf.ZoneFileReader
+	if r == nil {
+		if conf.ZoneFilePath == "" {
+			return nil, fmt.Errorf("either a zone file reader or path must be provided")
+		}
+
+		f, err := os.Open(conf.ZoneFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zone file: %w", err)
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	zp := dns.NewZoneParser(r, dns.Fqdn(conf.Origin), conf.ZoneFilePath)
+	zp.SetIncludeAllowed(conf.ZoneFileReader == nil)
+
+	records := make(map[string][]dns.RR)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name := dns.CanonicalName(rr.Header().Name)
+		records[name] = append(records[name], rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse zone file: %w", err)
+	}
+
+	return &ZoneResolver{
+		records:     records,
+		dialContext: conf.DialContext,
+	}, nil
+}
+
+func (r *ZoneResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if network != "ip" && network != "ip4" && network != "ip6" {
+		return nil, newError(host, "", ErrUnsupportedNetwork)
+	}
+
+	var qtypes []uint16
+	if network != "ip6" {
+		qtypes = append(qtypes, dns.TypeA)
+	}
+	if network != "ip4" {
+		qtypes = append(qtypes, dns.TypeAAAA)
+	}
+
+	var addrs []netip.Addr
+	for _, qtype := range qtypes {
+		rrs, err := r.lookup(host, qtype)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rr := range rrs {
+			var addr netip.Addr
+			switch rr := rr.(type) {
+			case *dns.A:
+				addr = netip.AddrFrom4([4]byte(rr.A.To4()))
+			case *dns.AAAA:
+				addr = netip.AddrFrom16([16]byte(rr.AAAA.To16()))
+			default:
+				continue
+			}
+
+			ttl := time.Duration(rr.Header().Ttl) * time.Second
+			recordTTL(ctx, ttl)
+			recordProvenance(ctx, AddrInfo{Source: AddrSourceZone, TTL: ttl}, addr)
+
+			addrs = append(addrs, addr)
+		}
+	}
+
+	addrs = address.FilterByNetwork(addrs, network)
+	if len(addrs) == 0 {
+		return nil, newError(host, "", ErrNoData)
+	}
+
+	if network != "ip4" {
+		dial := func(network, address string) (net.Conn, error) {
+			return r.dialContext(ctx, network, address)
+		}
+
+		addrselect.SortByRFC6724(dial, addrs)
+		traceEvent(ctx, TraceEvent{Type: TraceEventSort, Addrs: slices.Clone(addrs)})
+	}
+
+	return addrs, nil
+}
+
+// LookupCNAME returns the canonical name for the given host, after following
+// any CNAME records in the zone.
+func (r *ZoneResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	name := dns.CanonicalName(host)
+	if _, ok := r.records[name]; !ok {
+		return "", newError(host, "", ErrNoSuchHost)
+	}
+
+	for i := 0; i < maxZoneCNAMEChain; i++ {
+		cname, ok := r.cname(name)
+		if !ok {
+			return name, nil
+		}
+		name = cname
+	}
+
+	return "", newError(host, "", fmt.Errorf("too many CNAMEs: %w", ErrServFail))
+}
+
+// LookupTXT returns the TXT records for the given host, the strings of each
+// record are concatenated.
+func (r *ZoneResolver) LookupTXT(ctx context.Context, host string) ([]string, error) {
+	rrs, err := r.lookup(host, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	var txts []string
+	for _, rr := range rrs {
+		if txt, ok := rr.(*dns.TXT); ok {
+			txts = append(txts, strings.Join(txt.Txt, ""))
+		}
+	}
+
+	if len(txts) == 0 {
+		return nil, newError(host, "", ErrNoData)
+	}
+
+	return txts, nil
+}
+
+// LookupMX returns the MX records for the given domain, sorted by
+// preference.
+func (r *ZoneResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	rrs, err := r.lookup(name, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	var mxs []*net.MX
+	for _, rr := range rrs {
+		if mx, ok := rr.(*dns.MX); ok {
+			mxs = append(mxs, &net.MX{Host: mx.Mx, Pref: mx.Preference})
+		}
+	}
+
+	if len(mxs) == 0 {
+		return nil, newError(name, "", ErrNoData)
+	}
+
+	slices.SortStableFunc(mxs, func(a, b *net.MX) int {
+		return cmp.Compare(a.Pref, b.Pref)
+	})
+
+	return mxs, nil
+}
+
+// LookupSRV returns the SRV records for the given service, protocol and
+// domain, sorted by priority and weight. As with net.Resolver, if service
+// and proto are both empty, name is looked up directly.
+func (r *ZoneResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+
+	rrs, err := r.lookup(target, dns.TypeSRV)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cname := dns.CanonicalName(target)
+	var srvs []*net.SRV
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.CNAME:
+			cname = dns.CanonicalName(rr.Target)
+		case *dns.SRV:
+			srvs = append(srvs, &net.SRV{
+				Target:   rr.Target,
+				Port:     rr.Port,
+				Priority: rr.Priority,
+				Weight:   rr.Weight,
+			})
+		}
+	}
+
+	if len(srvs) == 0 {
+		return "", nil, newError(target, "", ErrNoData)
+	}
+
+	slices.SortStableFunc(srvs, func(a, b *net.SRV) int {
+		if c := cmp.Compare(a.Priority, b.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Weight, a.Weight)
+	})
+
+	return cname, srvs, nil
+}
+
+// LookupAddr returns the names mapping to the given address, from the PTR
+// records of the zone.
+func (r *ZoneResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, newError(addr, "", fmt.Errorf("%w: %w", ErrNoSuchHost, err))
+	}
+
+	rrs, err := r.lookup(reverse, dns.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, rr := range rrs {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			names = append(names, ptr.Ptr)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, newError(addr, "", ErrNoData)
+	}
+
+	return names, nil
+}
+
+// lookup returns the records of the given type for a name, along with any
+// CNAMEs followed to get there.
+func (r *ZoneResolver) lookup(host string, qtype uint16) ([]dns.RR, error) {
+	name := dns.CanonicalName(host)
+	if _, ok := r.records[name]; !ok && !r.isEmptyNonTerminal(name) {
+		return nil, newError(host, "", ErrNoSuchHost)
+	}
+
+	var answer []dns.RR
+	for i := 0; i <= maxZoneCNAMEChain; i++ {
+		var cname *dns.CNAME
+		for _, rr := range r.records[name] {
+			switch {
+			case rr.Header().Rrtype == qtype:
+				answer = append(answer, rr)
+			case rr.Header().Rrtype == dns.TypeCNAME:
+				cname = rr.(*dns.CNAME)
+			}
+		}
+
+		if cname == nil || qtype == dns.TypeCNAME {
+			return answer, nil
+		}
+
+		answer = append(answer, cname)
+		name = dns.CanonicalName(cname.Target)
+	}
+
+	return nil, newError(host, "", fmt.Errorf("too many CNAMEs: %w", ErrServFail))
+}
+
+func (r *ZoneResolver) cname(name string) (string, bool) {
+	for _, rr := range r.records[name] {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			return dns.CanonicalName(cname.Target), true
+		}
+	}
+	return "", false
+}
+
+// isEmptyNonTerminal returns true if the name has no records of its own, but
+// there are records for names below it (RFC 8020).
+func (r *ZoneResolver) isEmptyNonTerminal(name string) bool {
+	for owner := range r.records {
+		if owner != name && dns.IsSubDomain(name, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ZoneResolver) describe() Description {
+	return Description{
+		Type:       "zone",
+		Attributes: map[string]string{"names": strconv.Itoa(len(r.records))},
+	}
+}
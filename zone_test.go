@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 The Noisy Sockets Authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noisysockets/resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneResolver(t *testing.T) {
+	res, err := resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFilePath: "testdata/example.zone",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("LookupNetIP", func(t *testing.T) {
+		addrs, err := res.LookupNetIP(ctx, "ip", "www.example.internal")
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []netip.Addr{netip.MustParseAddr("10.0.0.10"), netip.MustParseAddr("fd00::10")}, addrs)
+
+		addrs, err = res.LookupNetIP(ctx, "ip4", "WEB.example.internal.")
+		require.NoError(t, err)
+
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.10")}, addrs)
+	})
+
+	t.Run("Detailed", func(t *testing.T) {
+		result, err := resolver.LookupHostDetailed(ctx, res, "ip4", "v4only.example.internal")
+		require.NoError(t, err)
+
+		require.Len(t, result.Addrs, 1)
+		require.Equal(t, resolver.AddrSourceZone, result.Addrs[0].Source)
+		require.Equal(t, time.Minute, result.Addrs[0].TTL)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := res.LookupNetIP(ctx, "ip", "missing.example.internal")
+		require.True(t, resolver.IsNXDomain(err))
+
+		_, err = res.LookupNetIP(ctx, "ip6", "v4only.example.internal")
+		require.True(t, resolver.IsNoData(err))
+
+		// Empty non-terminal.
+		_, err = res.LookupNetIP(ctx, "ip", "b.example.internal")
+		require.True(t, resolver.IsNoData(err))
+
+		_, err = res.LookupNetIP(ctx, "ip", "loop1.example.internal")
+		require.ErrorIs(t, err, resolver.ErrServFail)
+	})
+
+	t.Run("LookupCNAME", func(t *testing.T) {
+		cname, err := res.LookupCNAME(ctx, "web.example.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, "www.example.internal.", cname)
+	})
+
+	t.Run("LookupTXT", func(t *testing.T) {
+		txts, err := res.LookupTXT(ctx, "example.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"v=spf1 -all"}, txts)
+	})
+
+	t.Run("LookupMX", func(t *testing.T) {
+		mxs, err := res.LookupMX(ctx, "example.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, []*net.MX{
+			{Host: "www.example.internal.", Pref: 10},
+			{Host: "mx2.example.internal.", Pref: 20},
+		}, mxs)
+	})
+
+	t.Run("LookupSRV", func(t *testing.T) {
+		cname, srvs, err := res.LookupSRV(ctx, "http", "tcp", "example.internal")
+		require.NoError(t, err)
+
+		require.Equal(t, "_http._tcp.example.internal.", cname)
+		require.Equal(t, []*net.SRV{
+			{Target: "www.example.internal.", Port: 8081, Priority: 10, Weight: 10},
+			{Target: "www.example.internal.", Port: 8080, Priority: 10, Weight: 5},
+			{Target: "web.example.internal.", Port: 80, Priority: 20, Weight: 0},
+		}, srvs)
+	})
+
+	t.Run("LookupAddr", func(t *testing.T) {
+		names, err := res.LookupAddr(ctx, "10.0.0.10")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"www.example.internal."}, names)
+
+		_, err = res.LookupAddr(ctx, "10.0.0.11")
+		require.True(t, resolver.IsNXDomain(err))
+	})
+}
+
+func TestZoneResolverReader(t *testing.T) {
+	res, err := resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFileReader: strings.NewReader("host 300 IN A 192.0.2.1\n"),
+		Origin:         "example.com",
+	})
+	require.NoError(t, err)
+
+	addrs, err := res.LookupNetIP(context.Background(), "ip", "host.example.com")
+	require.NoError(t, err)
+
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+
+	_, err = resolver.Zone(&resolver.ZoneResolverConfig{
+		ZoneFileReader: strings.NewReader("$INCLUDE /etc/passwd\n"),
+	})
+	require.Error(t, err)
+}